@@ -21,4 +21,8 @@ const (
 	AvailableModels   = "available_models"
 	KeyRequestBody    = "key_request_body"
 	SystemPrompt      = "system_prompt"
+	RelayMessageId    = "relay_message_id"
+	ToolCallDepth     = "tool_call_depth"
+	ResponseCacheKey  = "response_cache_key"
+	RelayCitations    = "relay_citations"
 )
@@ -39,4 +39,5 @@ func Init() {
 	// Initialize the logger
 	InitRedisClient()
 	InitLocker()
+	InitRateLimiter()
 }
@@ -0,0 +1,225 @@
+package common
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+var RATE_LIMITER RateLimiter
+
+func InitRateLimiter() {
+	if RedisEnabled {
+		RATE_LIMITER = NewRedisRateLimiter(RDB)
+	} else {
+		RATE_LIMITER = NewLocalRateLimiter()
+	}
+}
+
+type RateLimiter interface {
+	// Allow 滑动窗口限流
+	// key: 限流对象
+	// limit: 窗口内允许的最大请求数
+	// window: 窗口大小
+	// 返回是否放行，以及被拒绝时需要等待的时间
+	Allow(key string, limit int, window time.Duration) (bool, time.Duration)
+
+	// Acquire 占用一个并发名额，ttl 为名额的最长占用时间，防止异常退出后名额无法释放
+	Acquire(key string, limit int, ttl time.Duration) bool
+
+	// Release 释放并发名额
+	Release(key string)
+
+	// Refund 撤销 Allow 最近放行的一次请求，用于后续检查拒绝请求时归还名额
+	Refund(key string)
+}
+
+// 本地限流清理空闲 key 的间隔
+const localRateLimitSweepInterval = 5 * time.Minute
+
+func NewLocalRateLimiter() *LocalRateLimiter {
+	return &LocalRateLimiter{
+		windows:    make(map[string]*localWindow),
+		concurrent: make(map[string]int),
+		lastSweep:  time.Now(),
+	}
+}
+
+// LocalRateLimiter 进程内限流，仅在未启用 Redis 的单实例部署下生效
+type LocalRateLimiter struct {
+	mu         sync.Mutex
+	windows    map[string]*localWindow
+	concurrent map[string]int
+	lastSweep  time.Time
+}
+
+type localWindow struct {
+	records []time.Time
+	window  time.Duration
+}
+
+func (l *LocalRateLimiter) Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w := l.windows[key]
+	if w == nil {
+		w = &localWindow{}
+		l.windows[key] = w
+	}
+	w.window = window
+	// 移除窗口外的记录
+	i := 0
+	for i < len(w.records) && now.Sub(w.records[i]) >= window {
+		i++
+	}
+	w.records = w.records[i:]
+
+	if len(w.records) >= limit {
+		return false, w.records[0].Add(window).Sub(now)
+	}
+
+	w.records = append(w.records, now)
+	return true, 0
+}
+
+func (l *LocalRateLimiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w := l.windows[key]
+	if w == nil || len(w.records) == 0 {
+		return
+	}
+	w.records = w.records[:len(w.records)-1]
+	if len(w.records) == 0 {
+		delete(l.windows, key)
+	}
+}
+
+// sweep 定期删除窗口内已没有记录的 key，避免按用户、IP 等维度的 key 无限增长。调用方需持有锁
+func (l *LocalRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < localRateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, w := range l.windows {
+		if len(w.records) == 0 || now.Sub(w.records[len(w.records)-1]) >= w.window {
+			delete(l.windows, key)
+		}
+	}
+}
+
+func (l *LocalRateLimiter) Acquire(key string, limit int, ttl time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.concurrent[key] >= limit {
+		return false
+	}
+	l.concurrent[key]++
+	return true
+}
+
+func (l *LocalRateLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.concurrent[key] <= 1 {
+		delete(l.concurrent, key)
+		return
+	}
+	l.concurrent[key]--
+}
+
+// 基于有序集合的滑动窗口，返回 0 表示放行，否则返回需要等待的毫秒数
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return 0
+end
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local wait = tonumber(oldest[2]) + window - now
+if wait < 1 then
+	wait = 1
+end
+return wait
+`)
+
+var acquireScript = redis.NewScript(`
+local current = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if current > tonumber(ARGV[1]) then
+	redis.call('DECR', KEYS[1])
+	return 0
+end
+return 1
+`)
+
+var releaseScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[1]) or '0') > 0 then
+	redis.call('DECR', KEYS[1])
+end
+return 1
+`)
+
+type RedisRateLimiter struct {
+	client redis.Cmdable
+}
+
+func NewRedisRateLimiter(client redis.Cmdable) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client}
+}
+
+func (rl *RedisRateLimiter) Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	member := strconv.FormatInt(time.Now().UnixNano(), 10)
+	wait, err := slidingWindowScript.Run(ctx, rl.client, []string{"ratelimit:" + key}, now, window.Milliseconds(), limit, member).Int64()
+	if err != nil {
+		// Redis 异常时放行，避免限流组件故障导致服务不可用
+		logger.SysErrorf("rate limit check failed: %s", err.Error())
+		return true, 0
+	}
+	if wait > 0 {
+		return false, time.Duration(wait) * time.Millisecond
+	}
+	return true, 0
+}
+
+func (rl *RedisRateLimiter) Refund(key string) {
+	ctx := context.Background()
+	if err := rl.client.ZPopMax(ctx, "ratelimit:"+key).Err(); err != nil {
+		logger.SysErrorf("rate limit refund failed: %s", err.Error())
+	}
+}
+
+func (rl *RedisRateLimiter) Acquire(key string, limit int, ttl time.Duration) bool {
+	ctx := context.Background()
+	ok, err := acquireScript.Run(ctx, rl.client, []string{"ratelimit:" + key}, limit, ttl.Milliseconds()).Int64()
+	if err != nil {
+		logger.SysErrorf("rate limit acquire failed: %s", err.Error())
+		return true
+	}
+	return ok == 1
+}
+
+func (rl *RedisRateLimiter) Release(key string) {
+	ctx := context.Background()
+	if err := releaseScript.Run(ctx, rl.client, []string{"ratelimit:" + key}).Err(); err != nil {
+		logger.SysErrorf("rate limit release failed: %s", err.Error())
+	}
+}
@@ -15,4 +15,5 @@ const (
 	SESSION_REQUEST_PROTOCOL = "SESSION_REQUEST_PROTOCOL"
 	SESSION_REQUEST_DOMAIN   = "SESSION_REQUEST_DOMAIN"
 	SESSION_ENV_VERSION      = "SESSION_ENV_VERSION"
+	SESSION_API_KEY          = "SESSION_API_KEY"
 )
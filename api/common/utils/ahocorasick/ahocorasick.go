@@ -0,0 +1,116 @@
+// Package ahocorasick 基于 rune 的 Aho-Corasick 多模式匹配，匹配时忽略大小写
+package ahocorasick
+
+import (
+	"unicode"
+)
+
+// Match 一次命中，Start/End 为文本中的 rune 下标，区间为 [Start, End)
+type Match struct {
+	Pattern int
+	Start   int
+	End     int
+}
+
+type node struct {
+	children map[rune]int
+	fail     int
+	outputs  []int
+}
+
+// Matcher 由一组模式构建的自动机，构建后可并发使用
+type Matcher struct {
+	nodes    []node
+	patterns [][]rune
+	maxLen   int
+}
+
+// New 构建自动机，空模式会被忽略
+func New(patterns []string) *Matcher {
+	m := &Matcher{nodes: []node{{children: map[rune]int{}}}}
+	for _, pattern := range patterns {
+		runes := []rune(pattern)
+		for i, r := range runes {
+			runes[i] = unicode.ToLower(r)
+		}
+		m.patterns = append(m.patterns, runes)
+		if len(runes) == 0 {
+			continue
+		}
+		if len(runes) > m.maxLen {
+			m.maxLen = len(runes)
+		}
+
+		current := 0
+		for _, r := range runes {
+			next, ok := m.nodes[current].children[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, node{children: map[rune]int{}})
+				m.nodes[current].children[r] = next
+			}
+			current = next
+		}
+		m.nodes[current].outputs = append(m.nodes[current].outputs, len(m.patterns)-1)
+	}
+	m.buildFailLinks()
+	return m
+}
+
+// buildFailLinks 按层序计算失败指针，并把失败指针所指节点的输出合并到当前节点
+func (m *Matcher) buildFailLinks() {
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].children {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[current].children {
+			fail := m.nodes[current].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].children[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].children[r]; ok && next != child {
+				m.nodes[child].fail = next
+			}
+			m.nodes[child].outputs = append(m.nodes[child].outputs, m.nodes[m.nodes[child].fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// FindAll 返回文本中的全部命中（包括相互重叠的命中），按结束位置排序
+func (m *Matcher) FindAll(text []rune) []Match {
+	var matches []Match
+	current := 0
+	for i, r := range text {
+		r = unicode.ToLower(r)
+		for current != 0 {
+			if _, ok := m.nodes[current].children[r]; ok {
+				break
+			}
+			current = m.nodes[current].fail
+		}
+		if next, ok := m.nodes[current].children[r]; ok {
+			current = next
+		}
+		for _, p := range m.nodes[current].outputs {
+			matches = append(matches, Match{Pattern: p, Start: i + 1 - len(m.patterns[p]), End: i + 1})
+		}
+	}
+	return matches
+}
+
+// Pattern 返回下标对应的模式（已转为小写）
+func (m *Matcher) Pattern(i int) string {
+	return string(m.patterns[i])
+}
+
+// MaxLen 返回最长模式的 rune 长度
+func (m *Matcher) MaxLen() int {
+	return m.maxLen
+}
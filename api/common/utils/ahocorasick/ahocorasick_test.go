@@ -0,0 +1,94 @@
+package ahocorasick
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     []Match
+	}{
+		{
+			name:     "重叠命中",
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want: []Match{
+				{Pattern: 1, Start: 1, End: 4},
+				{Pattern: 0, Start: 2, End: 4},
+				{Pattern: 3, Start: 2, End: 6},
+			},
+		},
+		{
+			name:     "忽略大小写",
+			patterns: []string{"Secret"},
+			text:     "a SECRET and a secret",
+			want: []Match{
+				{Pattern: 0, Start: 2, End: 8},
+				{Pattern: 0, Start: 15, End: 21},
+			},
+		},
+		{
+			name:     "中文按 rune 计算位置",
+			patterns: []string{"敏感词", "感词"},
+			text:     "这是敏感词",
+			want: []Match{
+				{Pattern: 0, Start: 2, End: 5},
+				{Pattern: 1, Start: 3, End: 5},
+			},
+		},
+		{
+			name:     "失败指针跳转后继续匹配",
+			patterns: []string{"abcd", "bce"},
+			text:     "abce",
+			want: []Match{
+				{Pattern: 1, Start: 1, End: 4},
+			},
+		},
+		{
+			name:     "空模式被忽略",
+			patterns: []string{"", "ab"},
+			text:     "abab",
+			want: []Match{
+				{Pattern: 1, Start: 0, End: 2},
+				{Pattern: 1, Start: 2, End: 4},
+			},
+		},
+		{
+			name:     "没有命中",
+			patterns: []string{"foo"},
+			text:     "bar",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.patterns).FindAll([]rune(tt.text))
+			// 同一结束位置的命中顺序不作要求
+			sort.Slice(got, func(i, j int) bool {
+				if got[i].End != got[j].End {
+					return got[i].End < got[j].End
+				}
+				return got[i].Start < got[j].Start
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternAndMaxLen(t *testing.T) {
+	m := New([]string{"ABC", "敏感词汇", ""})
+	if got := m.Pattern(0); got != "abc" {
+		t.Errorf("Pattern(0) = %q, want %q", got, "abc")
+	}
+	if got := m.MaxLen(); got != 4 {
+		t.Errorf("MaxLen() = %d, want 4", got)
+	}
+}
@@ -0,0 +1,180 @@
+// Package docparse 从常见办公文档中提取纯文本，表格以 Markdown 表格的形式输出
+package docparse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupported 不支持的文件类型
+var ErrUnsupported = errors.New("unsupported document type")
+
+// ErrTooLarge 文档解压后的内容超出上限
+var ErrTooLarge = errors.New("document is too large")
+
+// 单个文档解压后的总字节数上限。单个 zip 文件或 PDF 流另有上限，
+// 总上限防止由大量文件或流组成的压缩炸弹耗尽内存
+const maxDocumentDecompressedSize = 128 << 20
+
+// decompressBudget 记录单个文档剩余可解压的字节数
+type decompressBudget struct {
+	remaining int64
+}
+
+func newDecompressBudget() *decompressBudget {
+	return &decompressBudget{remaining: maxDocumentDecompressedSize}
+}
+
+// read 读取不超过 limit 字节并计入总预算，超出 limit 或总预算时返回 ErrTooLarge
+func (b *decompressBudget) read(r io.Reader, limit int64) ([]byte, error) {
+	limit = min(limit, b.remaining)
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	b.remaining -= int64(len(data))
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, err
+}
+
+var (
+	htmlTagRegexp    = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>|<[^>]+>`)
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+)
+
+// Supported 是否支持该扩展名，扩展名可带或不带前导点
+func Supported(ext string) bool {
+	switch normalizeExt(ext) {
+	case "txt", "md", "markdown", "csv", "tsv", "json", "xml", "yaml", "yml", "log",
+		"html", "htm", "docx", "xlsx", "pptx", "pdf":
+		return true
+	}
+	return false
+}
+
+// Extract 按扩展名提取文档文本
+func Extract(ext string, data []byte) (string, error) {
+	var text string
+	var err error
+	switch normalizeExt(ext) {
+	case "txt", "md", "markdown", "json", "xml", "yaml", "yml", "log":
+		text = decodeText(data)
+	case "csv":
+		text = csvToTable(decodeText(data), ',')
+	case "tsv":
+		text = csvToTable(decodeText(data), '\t')
+	case "html", "htm":
+		text = html.UnescapeString(htmlTagRegexp.ReplaceAllString(decodeText(data), "\n"))
+	case "docx":
+		text, err = extractDocx(data)
+	case "xlsx":
+		text, err = extractXlsx(data)
+	case "pptx":
+		text, err = extractPptx(data)
+	case "pdf":
+		text, err = extractPDF(data)
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, ext)
+	}
+	if err != nil {
+		return "", err
+	}
+	return cleanText(text), nil
+}
+
+func normalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+// decodeText 去掉 BOM，非 UTF-8 内容按 Latin-1 处理以免产生非法字符
+func decodeText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func cleanText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = strings.Join(lines, "\n")
+	text = blankLinesRegexp.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// tableWriter 将行数据输出为 Markdown 表格，第一行作为表头
+type tableWriter struct {
+	b    *strings.Builder
+	rows int
+	cols int
+}
+
+func (t *tableWriter) writeRow(cells []string) {
+	if t.rows == 0 {
+		t.cols = len(cells)
+	}
+	for len(cells) < t.cols {
+		cells = append(cells, "")
+	}
+	t.b.WriteString("|")
+	for _, cell := range cells {
+		cell = strings.ReplaceAll(strings.TrimSpace(cell), "\n", " ")
+		cell = strings.ReplaceAll(cell, "|", "\\|")
+		t.b.WriteString(" " + cell + " |")
+	}
+	t.b.WriteString("\n")
+	if t.rows == 0 {
+		t.b.WriteString("|" + strings.Repeat(" --- |", len(cells)) + "\n")
+	}
+	t.rows++
+}
+
+func csvToTable(text string, sep rune) string {
+	var b strings.Builder
+	table := &tableWriter{b: &b}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		table.writeRow(splitCSVLine(line, sep))
+	}
+	return b.String()
+}
+
+// splitCSVLine 按分隔符拆分一行，支持双引号转义；字段内的换行不做处理
+func splitCSVLine(line string, sep rune) []string {
+	var cells []string
+	var cell strings.Builder
+	quoted := false
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quoted && r == '"' && i+1 < len(runes) && runes[i+1] == '"':
+			cell.WriteRune('"')
+			i++
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteRune(r)
+		}
+	}
+	return append(cells, cell.String())
+}
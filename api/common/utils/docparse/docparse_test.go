@@ -0,0 +1,296 @@
+package docparse
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildZip 按文件名和内容生成 zip 包
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildPDF 生成只包含一个 FlateDecode 内容流的 PDF
+func buildPDF(t *testing.T, content string) []byte {
+	t.Helper()
+	stream := deflate(t, []byte(content))
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n1 0 obj\n")
+	b.WriteString(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n", len(stream)))
+	b.Write(stream)
+	b.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	const wordNS = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	docx := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + wordNS + `><w:body>` +
+			`<w:p><w:r><w:t>标题</w:t></w:r></w:p>` +
+			`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>名称</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>数量</w:t></w:r></w:p></w:tc></w:tr>` +
+			`<w:tr><w:tc><w:p><w:r><w:t>苹果</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>3</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+			`<w:p><w:r><w:t>结尾</w:t></w:r></w:p></w:body></w:document>`,
+	})
+	pptx := buildZip(t, map[string]string{
+		"ppt/slides/slide10.xml": `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:r><a:t>第十页</a:t></a:r></a:p></p:sld>`,
+		"ppt/slides/slide2.xml":  `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:r><a:t>第二页</a:t></a:r></a:p></p:sld>`,
+	})
+	xlsx := buildZip(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="销量" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>产品</t></si><si><r><t>销</t></r><r><t>量</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+			`<row><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>在售</t></is></c></row>` +
+			`<row><c r="A2" t="inlineStr"><is><t>苹果</t></is></c><c r="C2" t="b"><v>1</v></c></row>` +
+			`<row><c r="A3"><v> </v></c></row>` +
+			`</sheetData></worksheet>`,
+	})
+
+	tests := []struct {
+		name    string
+		ext     string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{
+			name: "文本去掉 BOM 并清理空行",
+			ext:  ".TXT",
+			data: []byte("\xef\xbb\xbf第一行  \r\n\r\n\r\n\r\n第二行"),
+			want: "第一行\n\n第二行",
+		},
+		{
+			name: "CSV 转为 Markdown 表格",
+			ext:  "csv",
+			data: []byte("name,note\n\"a,b\",\"say \"\"hi\"\"\"\n\nc|d\n"),
+			want: "| name | note |\n| --- | --- |\n| a,b | say \"hi\" |\n| c\\|d |  |",
+		},
+		{
+			name: "TSV 转为 Markdown 表格",
+			ext:  "tsv",
+			data: []byte("a\tb\n1\t2"),
+			want: "| a | b |\n| --- | --- |\n| 1 | 2 |",
+		},
+		{
+			name: "HTML 去掉标签和脚本",
+			ext:  "html",
+			data: []byte("<html><script>alert(1)</script><p>A &amp; B</p></html>"),
+			want: "A & B",
+		},
+		{
+			name: "非 UTF-8 文本按 Latin-1 处理",
+			ext:  "txt",
+			data: []byte{'c', 'a', 'f', 0xe9},
+			want: "café",
+		},
+		{
+			name: "docx 段落和表格",
+			ext:  "docx",
+			data: docx,
+			want: "标题\n\n| 名称 | 数量 |\n| --- | --- |\n| 苹果 | 3 |\n\n结尾",
+		},
+		{
+			name: "pptx 按页码排序",
+			ext:  "pptx",
+			data: pptx,
+			want: "## 第 2 页\n第二页\n\n## 第 10 页\n第十页",
+		},
+		{
+			name: "xlsx 共享字符串、内联字符串和布尔值",
+			ext:  "xlsx",
+			data: xlsx,
+			want: "## 销量\n| 产品 | 销量 | 在售 |\n| --- | --- | --- |\n| 苹果 |  | TRUE |",
+		},
+		{
+			name: "pdf 内容流",
+			ext:  "pdf",
+			data: buildPDF(t, "BT /F1 12 Tf 72 712 Td (Hello World) Tj ET"),
+			want: "Hello World",
+		},
+		{
+			name:    "不支持的类型",
+			ext:     "exe",
+			data:    []byte("MZ"),
+			wantErr: ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.ext, tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Extract() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Extract() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupported(t *testing.T) {
+	tests := []struct {
+		ext  string
+		want bool
+	}{
+		{"pdf", true},
+		{".DOCX", true},
+		{" md ", true},
+		{"doc", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Supported(tt.ext); got != tt.want {
+			t.Errorf("Supported(%q) = %v, want %v", tt.ext, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCSVLine(t *testing.T) {
+	tests := []struct {
+		line string
+		sep  rune
+		want []string
+	}{
+		{"a,b,c", ',', []string{"a", "b", "c"}},
+		{`"a,b",c`, ',', []string{"a,b", "c"}},
+		{`"say ""hi""",`, ',', []string{`say "hi"`, ""}},
+		{"a\tb", '\t', []string{"a", "b"}},
+		{"", ',', []string{""}},
+	}
+	for _, tt := range tests {
+		if got := splitCSVLine(tt.line, tt.sep); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCSVLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"A1", 0},
+		{"Z9", 25},
+		{"AA10", 26},
+		{"AB12", 27},
+	}
+	for _, tt := range tests {
+		if got := columnIndex(tt.ref); got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, want %d", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestDecompressBudget(t *testing.T) {
+	tests := []struct {
+		name          string
+		remaining     int64
+		data          string
+		limit         int64
+		want          string
+		wantErr       error
+		wantRemaining int64
+	}{
+		{"未超出", 10, "abc", 5, "abc", nil, 7},
+		{"恰好等于单个文件上限", 10, "abcde", 5, "abcde", nil, 5},
+		{"超出单个文件上限", 10, "abcdef", 5, "", ErrTooLarge, 4},
+		{"超出总预算", 3, "abcd", 5, "", ErrTooLarge, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &decompressBudget{remaining: tt.remaining}
+			got, err := budget.read(strings.NewReader(tt.data), tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("read() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read() = %q, want %q", got, tt.want)
+			}
+			if budget.remaining != tt.wantRemaining {
+				t.Errorf("remaining = %d, want %d", budget.remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestDecompressBudgetShared(t *testing.T) {
+	// 多个文件各自未超出单个文件上限，合计超出总预算
+	data := buildZip(t, map[string]string{
+		"ppt/slides/slide1.xml": strings.Repeat("a", 60),
+		"ppt/slides/slide2.xml": strings.Repeat("b", 60),
+	})
+	doc, err := openZip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.budget.remaining = 100
+	if _, err := doc.readFile("ppt/slides/slide1.xml"); err != nil {
+		t.Fatalf("readFile(slide1) error = %v", err)
+	}
+	if _, err := doc.readFile("ppt/slides/slide2.xml"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("readFile(slide2) error = %v, want %v", err, ErrTooLarge)
+	}
+
+	tests := []struct {
+		name      string
+		remaining int64
+		wantErr   error
+	}{
+		{"单个流在总预算内", 100, nil},
+		{"超出总预算", 10, ErrTooLarge},
+	}
+	stream := deflate(t, bytes.Repeat([]byte("x"), 50))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inflate(stream, &decompressBudget{remaining: tt.remaining})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("inflate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && len(got) != 50 {
+				t.Errorf("inflate() returned %d bytes, want 50", len(got))
+			}
+		})
+	}
+}
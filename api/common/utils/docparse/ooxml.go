@@ -0,0 +1,360 @@
+package docparse
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// 单个 zip 内文件解压后的最大字节数，防止压缩炸弹
+	maxZipEntrySize = 64 << 20
+	// 每个工作表最多读取的行数
+	maxSheetRows = 5000
+)
+
+var slideNameRegexp = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// zipDocument 办公文档的 zip 包，读取的文件共享同一个解压预算
+type zipDocument struct {
+	r      *zip.Reader
+	budget *decompressBudget
+}
+
+func openZip(data []byte) (*zipDocument, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid office document: %w", err)
+	}
+	return &zipDocument{r: r, budget: newDecompressBudget()}, nil
+}
+
+func (d *zipDocument) readFile(name string) ([]byte, error) {
+	for _, f := range d.r.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := d.budget.read(rc, maxZipEntrySize)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+// ooxmlText 提取 WordprocessingML / DrawingML 中的文本：p 为段落，t 为文本，tbl/tr/tc 为表格
+func ooxmlText(data []byte) (string, error) {
+	var b strings.Builder
+	type tableState struct {
+		table *tableWriter
+		row   []string
+		cell  *strings.Builder
+	}
+	var tables []*tableState
+	// out 返回当前文本应写入的位置：表格单元格内写入单元格，否则写入正文
+	out := func() *strings.Builder {
+		for i := len(tables) - 1; i >= 0; i-- {
+			if tables[i].cell != nil {
+				return tables[i].cell
+			}
+		}
+		return &b
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				out().WriteString("\t")
+			case "br", "cr":
+				out().WriteString("\n")
+			case "tbl":
+				// 嵌套表格的内容并入外层单元格
+				if out() != &b {
+					tables = append(tables, &tableState{})
+				} else {
+					out().WriteString("\n")
+					tables = append(tables, &tableState{table: &tableWriter{b: &b}})
+				}
+			case "tr":
+				if len(tables) > 0 && tables[len(tables)-1].table != nil {
+					tables[len(tables)-1].row = nil
+				}
+			case "tc":
+				if len(tables) > 0 && tables[len(tables)-1].table != nil {
+					tables[len(tables)-1].cell = &strings.Builder{}
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if w := out(); w != &b {
+					w.WriteString(" ")
+				} else {
+					w.WriteString("\n")
+				}
+			case "tc":
+				if len(tables) > 0 && tables[len(tables)-1].table != nil {
+					state := tables[len(tables)-1]
+					if state.cell != nil {
+						state.row = append(state.row, state.cell.String())
+					}
+					state.cell = nil
+				}
+			case "tr":
+				if len(tables) > 0 && tables[len(tables)-1].table != nil {
+					state := tables[len(tables)-1]
+					state.table.writeRow(state.row)
+					state.row = nil
+				}
+			case "tbl":
+				if len(tables) > 0 {
+					tables = tables[:len(tables)-1]
+				}
+				out().WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				out().Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func extractDocx(data []byte) (string, error) {
+	r, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+	document, err := r.readFile("word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	return ooxmlText(document)
+}
+
+func extractPptx(data []byte) (string, error) {
+	r, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+
+	type slide struct {
+		index int
+		name  string
+	}
+	var slides []slide
+	for _, f := range r.r.File {
+		if m := slideNameRegexp.FindStringSubmatch(f.Name); m != nil {
+			index, _ := strconv.Atoi(m[1])
+			slides = append(slides, slide{index: index, name: f.Name})
+		}
+	}
+	sort.Slice(slides, func(i, j int) bool { return slides[i].index < slides[j].index })
+
+	var b strings.Builder
+	for _, s := range slides {
+		content, err := r.readFile(s.name)
+		if err != nil {
+			return "", err
+		}
+		text, err := ooxmlText(content)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(fmt.Sprintf("## 第 %d 页\n%s\n\n", s.index, strings.TrimSpace(text)))
+	}
+	return b.String(), nil
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxStringItem 共享字符串，可能是纯文本或多段富文本
+type xlsxStringItem struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s xlsxStringItem) text() string {
+	if len(s.Runs) == 0 {
+		return s.T
+	}
+	var b strings.Builder
+	for _, run := range s.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string         `xml:"r,attr"`
+			Type   string         `xml:"t,attr"`
+			Value  string         `xml:"v"`
+			Inline xlsxStringItem `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func extractXlsx(data []byte) (string, error) {
+	r, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+
+	var sharedStrings []string
+	if content, err := r.readFile("xl/sharedStrings.xml"); errors.Is(err, ErrTooLarge) {
+		return "", err
+	} else if err == nil {
+		var sst struct {
+			Items []xlsxStringItem `xml:"si"`
+		}
+		if err := xml.Unmarshal(content, &sst); err != nil {
+			return "", err
+		}
+		for _, item := range sst.Items {
+			sharedStrings = append(sharedStrings, item.text())
+		}
+	}
+
+	content, err := r.readFile("xl/workbook.xml")
+	if err != nil {
+		return "", err
+	}
+	var workbook xlsxWorkbook
+	if err := xml.Unmarshal(content, &workbook); err != nil {
+		return "", err
+	}
+	targets := map[string]string{}
+	if content, err := r.readFile("xl/_rels/workbook.xml.rels"); err == nil {
+		var rels xlsxRelationships
+		if err := xml.Unmarshal(content, &rels); err == nil {
+			for _, rel := range rels.Relationships {
+				targets[rel.ID] = rel.Target
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, sheet := range workbook.Sheets {
+		target := targets[sheet.RID]
+		if target == "" {
+			target = fmt.Sprintf("worksheets/sheet%d.xml", i+1)
+		}
+		name := path.Join("xl", target)
+		if strings.HasPrefix(target, "/") {
+			name = strings.TrimPrefix(target, "/")
+		}
+		content, err := r.readFile(name)
+		if errors.Is(err, ErrTooLarge) {
+			return "", err
+		}
+		if err != nil {
+			continue
+		}
+		var ws xlsxSheet
+		if err := xml.Unmarshal(content, &ws); err != nil {
+			return "", err
+		}
+
+		b.WriteString("## " + sheet.Name + "\n")
+		table := &tableWriter{b: &b}
+		for rowIndex, row := range ws.Rows {
+			if rowIndex >= maxSheetRows {
+				b.WriteString(fmt.Sprintf("（仅保留前 %d 行）\n", maxSheetRows))
+				break
+			}
+			var cells []string
+			empty := true
+			for _, cell := range row.Cells {
+				col := len(cells)
+				if cell.Ref != "" {
+					col = columnIndex(cell.Ref)
+				}
+				for len(cells) < col {
+					cells = append(cells, "")
+				}
+				value := cell.Value
+				switch cell.Type {
+				case "s":
+					if idx, err := strconv.Atoi(value); err == nil && idx >= 0 && idx < len(sharedStrings) {
+						value = sharedStrings[idx]
+					}
+				case "inlineStr":
+					value = cell.Inline.text()
+				case "b":
+					if value == "1" {
+						value = "TRUE"
+					} else {
+						value = "FALSE"
+					}
+				}
+				if strings.TrimSpace(value) != "" {
+					empty = false
+				}
+				cells = append(cells, value)
+			}
+			if !empty {
+				table.writeRow(cells)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// columnIndex 将单元格引用（如 AB12）的列转换为从 0 开始的序号
+func columnIndex(ref string) int {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+	}
+	return index - 1
+}
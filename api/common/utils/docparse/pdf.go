@@ -0,0 +1,515 @@
+package docparse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// ErrNoText PDF 中没有可提取的文本，通常是扫描件
+var ErrNoText = errors.New("no extractable text in pdf")
+
+// 单个流解压后的最大字节数
+const maxPDFStreamSize = 32 << 20
+
+var (
+	pdfStreamRegexp    = regexp.MustCompile(`stream\r?\n`)
+	pdfBFCharRegexp    = regexp.MustCompile(`(?s)beginbfchar(.*?)endbfchar`)
+	pdfBFRangeRegexp   = regexp.MustCompile(`(?s)beginbfrange(.*?)endbfrange`)
+	pdfHexTokenRegexp  = regexp.MustCompile(`<([0-9A-Fa-f\s]*)>|\[([^\]]*)\]`)
+	pdfSkipDictRegexp  = regexp.MustCompile(`/(Image|XRef|ObjStm|Metadata|FontFile[23]?|EmbeddedFile)\b`)
+	pdfOtherCodecRegex = regexp.MustCompile(`/(DCTDecode|JPXDecode|CCITTFaxDecode|JBIG2Decode|LZWDecode|ASCII85Decode|ASCIIHexDecode|RunLengthDecode)\b`)
+)
+
+// pdfCMap ToUnicode 映射，按编码字节数分别保存
+type pdfCMap struct {
+	one map[uint32]string
+	two map[uint32]string
+}
+
+// extractPDF 解压内容流并解析文本绘制操作符（Tj、TJ、'、"），通过 ToUnicode 映射还原 CID 字体的文字。
+// 所有字体的映射合并使用，不解析页面资源和对象引用，能覆盖大多数由办公软件导出的 PDF；加密和扫描件无法提取
+func extractPDF(data []byte) (string, error) {
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", errors.New("encrypted pdf is not supported")
+	}
+
+	budget := newDecompressBudget()
+	cmap := &pdfCMap{one: map[uint32]string{}, two: map[uint32]string{}}
+	var contents [][]byte
+	for _, loc := range pdfStreamRegexp.FindAllIndex(data, -1) {
+		start := loc[1]
+		// 跳过 endstream 中的 stream
+		if loc[0] >= 3 && string(data[loc[0]-3:loc[0]]) == "end" {
+			continue
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[start : start+end]
+
+		dictStart := bytes.LastIndex(data[:loc[0]], []byte("obj"))
+		if dictStart < 0 {
+			continue
+		}
+		dict := data[dictStart:loc[0]]
+		if pdfSkipDictRegexp.Match(dict) || pdfOtherCodecRegex.Match(dict) {
+			continue
+		}
+
+		stream := raw
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			decoded, err := inflate(raw, budget)
+			if errors.Is(err, ErrTooLarge) {
+				return "", err
+			}
+			if err != nil {
+				continue
+			}
+			stream = decoded
+		}
+
+		if bytes.Contains(stream, []byte("begincmap")) {
+			cmap.parse(stream)
+		} else if bytes.Contains(stream, []byte("BT")) {
+			contents = append(contents, stream)
+		}
+	}
+
+	var b strings.Builder
+	for _, content := range contents {
+		b.WriteString(pdfContentText(content, cmap))
+		b.WriteString("\n")
+	}
+	text := b.String()
+	if !readableText(text) {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// inflate 解压流，超出单个流上限的部分截断，超出文档总预算时返回 ErrTooLarge
+func inflate(raw []byte, budget *decompressBudget) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	limit := min(int64(maxPDFStreamSize), budget.remaining)
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	budget.remaining -= int64(len(data))
+	if int64(len(data)) > limit {
+		if limit < maxPDFStreamSize {
+			return nil, ErrTooLarge
+		}
+		data = data[:limit]
+	}
+	// 部分 PDF 的流末尾校验不完整，已读出的内容仍然可用
+	if len(data) > 0 {
+		return data, nil
+	}
+	return nil, err
+}
+
+// readableText 可打印字符占多数时认为提取成功
+func readableText(text string) bool {
+	total, readable := 0, 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if r != unicode.ReplacementChar && unicode.IsPrint(r) && !unicode.Is(unicode.Co, r) {
+			readable++
+		}
+	}
+	return total > 0 && readable*10 >= total*8
+}
+
+func (m *pdfCMap) parse(stream []byte) {
+	for _, block := range pdfBFCharRegexp.FindAllSubmatch(stream, -1) {
+		tokens := pdfHexTokenRegexp.FindAllSubmatch(block[1], -1)
+		for i := 0; i+1 < len(tokens); i += 2 {
+			src := hexBytes(tokens[i][1])
+			dst := hexBytes(tokens[i+1][1])
+			m.set(src, utf16BE(dst))
+		}
+	}
+	for _, block := range pdfBFRangeRegexp.FindAllSubmatch(stream, -1) {
+		tokens := pdfHexTokenRegexp.FindAllSubmatch(block[1], -1)
+		for i := 0; i+2 < len(tokens); i += 3 {
+			lo := hexBytes(tokens[i][1])
+			hi := hexBytes(tokens[i+1][1])
+			if len(lo) == 0 || len(lo) != len(hi) {
+				continue
+			}
+			loCode, hiCode := bytesToCode(lo), bytesToCode(hi)
+			if hiCode < loCode || hiCode-loCode > 0xFFFF {
+				continue
+			}
+			if tokens[i+2][2] != nil {
+				// 数组形式：每个编码对应一个目标
+				items := pdfHexTokenRegexp.FindAllSubmatch(tokens[i+2][2], -1)
+				for j, item := range items {
+					if loCode+uint32(j) > hiCode {
+						break
+					}
+					m.setCode(len(lo), loCode+uint32(j), utf16BE(hexBytes(item[1])))
+				}
+				continue
+			}
+			dst := []rune(utf16BE(hexBytes(tokens[i+2][1])))
+			if len(dst) == 0 {
+				continue
+			}
+			for code := loCode; code <= hiCode; code++ {
+				mapped := append([]rune{}, dst...)
+				mapped[len(mapped)-1] += rune(code - loCode)
+				m.setCode(len(lo), code, string(mapped))
+			}
+		}
+	}
+}
+
+func (m *pdfCMap) set(src []byte, dst string) {
+	if len(src) == 0 {
+		return
+	}
+	m.setCode(len(src), bytesToCode(src), dst)
+}
+
+func (m *pdfCMap) setCode(width int, code uint32, dst string) {
+	switch width {
+	case 1:
+		m.one[code] = dst
+	case 2:
+		m.two[code] = dst
+	}
+}
+
+// decode 优先按双字节映射解码，全部编码都能映射时采用；否则尝试单字节映射，最后按 PDFDocEncoding 近似处理
+func (m *pdfCMap) decode(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		return utf16BE(s[2:])
+	}
+	if len(m.two) > 0 && len(s)%2 == 0 {
+		var b strings.Builder
+		ok := true
+		for i := 0; i < len(s); i += 2 {
+			mapped, found := m.two[uint32(s[i])<<8|uint32(s[i+1])]
+			if !found {
+				ok = false
+				break
+			}
+			b.WriteString(mapped)
+		}
+		if ok {
+			return b.String()
+		}
+	}
+	var b strings.Builder
+	for _, c := range s {
+		if mapped, found := m.one[uint32(c)]; found {
+			b.WriteString(mapped)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+func hexBytes(src []byte) []byte {
+	var clean []byte
+	for _, c := range src {
+		if !unicode.IsSpace(rune(c)) {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	out := make([]byte, len(clean)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(clean[i*2:i*2+2]), 16, 8)
+		if err != nil {
+			return nil
+		}
+		out[i] = byte(v)
+	}
+	return out
+}
+
+func bytesToCode(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pdfToken 内容流中的词法单元
+type pdfToken struct {
+	kind  byte // s 字符串，n 数字，a 数组，o 操作符，x 其他
+	str   []byte
+	num   float64
+	items []pdfToken
+	op    string
+}
+
+// pdfLexer 内容流词法分析，只识别文本提取需要的语法
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return pdfToken{}, false
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return pdfToken{kind: 's', str: l.literalString()}, true
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return pdfToken{kind: 'x'}, true
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfToken{kind: 'x'}, true
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			l.pos = len(l.data)
+			return pdfToken{kind: 'x'}, true
+		}
+		token := pdfToken{kind: 's', str: hexBytes(l.data[l.pos+1 : l.pos+end])}
+		l.pos += end + 1
+		return token, true
+	case c == '[':
+		l.pos++
+		var items []pdfToken
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				break
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				break
+			}
+			item, ok := l.next()
+			if !ok {
+				break
+			}
+			items = append(items, item)
+		}
+		return pdfToken{kind: 'a', items: items}, true
+	case c == '/':
+		l.pos++
+		for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfToken{kind: 'x'}, true
+	case c == ']' || c == ')' || c == '{' || c == '}' || c == '>':
+		l.pos++
+		return pdfToken{kind: 'x'}, true
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if num, err := strconv.ParseFloat(word, 64); err == nil {
+		return pdfToken{kind: 'n', num: num}, true
+	}
+	return pdfToken{kind: 'o', op: word}, true
+}
+
+func (l *pdfLexer) literalString() []byte {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// pdfContentText 执行内容流中的文本操作符，按文本位置变化插入换行和空格
+func pdfContentText(content []byte, cmap *pdfCMap) string {
+	var b strings.Builder
+	lexer := &pdfLexer{data: content}
+	var operands []pdfToken
+	lastY, hasY := 0.0, false
+	var last byte
+
+	write := func(s string) {
+		if s != "" {
+			b.WriteString(s)
+			last = s[len(s)-1]
+		}
+	}
+	newline := func() {
+		if b.Len() > 0 && last != '\n' {
+			write("\n")
+		}
+	}
+	moveTo := func(y float64) {
+		if hasY && y != lastY {
+			newline()
+		}
+		lastY, hasY = y, true
+	}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if token.kind != 'o' {
+			operands = append(operands, token)
+			continue
+		}
+		switch token.op {
+		case "Tj":
+			if s := lastString(operands); s != nil {
+				write(cmap.decode(s))
+			}
+		case "'", "\"":
+			newline()
+			if s := lastString(operands); s != nil {
+				write(cmap.decode(s))
+			}
+		case "TJ":
+			if len(operands) > 0 && operands[len(operands)-1].kind == 'a' {
+				for _, item := range operands[len(operands)-1].items {
+					switch item.kind {
+					case 's':
+						write(cmap.decode(item.str))
+					case 'n':
+						// 较大的负偏移通常表示单词间距
+						if item.num < -200 {
+							write(" ")
+						}
+					}
+				}
+			}
+		case "T*":
+			newline()
+		case "Td", "TD":
+			if len(operands) >= 2 && operands[len(operands)-1].kind == 'n' && operands[len(operands)-1].num != 0 {
+				newline()
+			}
+		case "Tm":
+			if len(operands) >= 6 && operands[len(operands)-1].kind == 'n' {
+				moveTo(operands[len(operands)-1].num)
+			}
+		case "ET":
+			write(" ")
+		case "BI":
+			// 跳过内联图片数据
+			if end := bytes.Index(content[lexer.pos:], []byte("EI")); end >= 0 {
+				lexer.pos += end + 2
+			} else {
+				lexer.pos = len(content)
+			}
+		}
+		operands = operands[:0]
+	}
+	return b.String()
+}
+
+func lastString(operands []pdfToken) []byte {
+	if len(operands) > 0 && operands[len(operands)-1].kind == 's' {
+		return operands[len(operands)-1].str
+	}
+	return nil
+}
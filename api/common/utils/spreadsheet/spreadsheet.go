@@ -0,0 +1,107 @@
+// Package spreadsheet 以流式方式逐行写出 CSV 或 XLSX 表格，适用于大批量数据导出
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 支持的文件格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ErrUnsupportedFormat 不支持的文件格式
+var ErrUnsupportedFormat = errors.New("unsupported spreadsheet format")
+
+// Writer 逐行写出表格，单元格支持字符串、整数、浮点数和布尔值
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	// Close 写出剩余内容，不会关闭底层的 io.Writer
+	Close() error
+}
+
+// Supported 是否支持该格式
+func Supported(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+// ContentType 文件格式对应的 MIME 类型
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter 按格式创建 Writer
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w)
+	case FormatXLSX:
+		return NewXLSXWriter(w)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter 创建 CSV Writer，文件开头写入 UTF-8 BOM，避免 Excel 打开中文乱码
+func NewCSVWriter(w io.Writer) (Writer, error) {
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		if text, ok := cell.(string); ok {
+			record[i] = escapeCSVFormula(text)
+		} else {
+			record[i] = formatCell(cell)
+		}
+	}
+	return c.w.Write(record)
+}
+
+// escapeCSVFormula 以公式起始字符开头的文本前加单引号，避免 Excel 打开时作为公式执行（CSV 注入）。
+// XLSX 中字符串以内联字符串写出，不会被当作公式，无需处理
+func escapeCSVFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(cell)
+}
@@ -0,0 +1,233 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/utils/docparse"
+)
+
+func TestCSVWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]interface{}
+		want string
+	}{
+		{
+			name: "各类型单元格",
+			rows: [][]interface{}{
+				{"名称", "数量", "金额", "启用", nil},
+				{"苹果", 3, int64(1) << 40, 1.5, true},
+			},
+			want: "名称,数量,金额,启用,\n苹果,3,1099511627776,1.5,true\n",
+		},
+		{
+			name: "含逗号和引号的文本加引号",
+			rows: [][]interface{}{{`a,b`, `say "hi"`}},
+			want: "\"a,b\",\"say \"\"hi\"\"\"\n",
+		},
+		{
+			name: "公式起始字符加单引号",
+			rows: [][]interface{}{{"=1+1", "+cmd", "-2", "@SUM(A1)", "\tx", "a=b"}},
+			want: "'=1+1,'+cmd,'-2,'@SUM(A1),'\tx,a=b\n",
+		},
+		{
+			name: "数字不会被转义",
+			rows: [][]interface{}{{-2, -1.5}},
+			want: "-2,-1.5\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(FormatCSV, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tt.rows {
+				if err := w.WriteRow(row...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != "\xEF\xBB\xBF"+tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeCSVFormula(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"hello", "hello"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@A1", "'@A1"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{" =1", " =1"},
+	}
+	for _, tt := range tests {
+		if got := escapeCSVFormula(tt.text); got != tt.want {
+			t.Errorf("escapeCSVFormula(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// readSheet 读取 XLSX 中工作表的 XML
+func readSheet(t *testing.T, data []byte) string {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestXLSXWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]interface{}
+		want []string // 工作表 XML 中应包含的片段
+	}{
+		{
+			name: "数字和布尔值",
+			rows: [][]interface{}{{1, int64(2), 1.5, true, false}},
+			want: []string{
+				`<c r="A1"><v>1</v></c>`,
+				`<c r="B1"><v>2</v></c>`,
+				`<c r="C1"><v>1.5</v></c>`,
+				`<c r="D1" t="b"><v>1</v></c>`,
+				`<c r="E1" t="b"><v>0</v></c>`,
+			},
+		},
+		{
+			name: "字符串转义且公式按文本写入",
+			rows: [][]interface{}{{"<a&b>", "=1+1"}},
+			want: []string{
+				`<c r="A1" t="inlineStr"><is><t xml:space="preserve">&lt;a&amp;b&gt;</t></is></c>`,
+				`<c r="B1" t="inlineStr"><is><t xml:space="preserve">=1+1</t></is></c>`,
+			},
+		},
+		{
+			name: "空值跳过，NaN 写为文本",
+			rows: [][]interface{}{{nil, "", math.NaN()}},
+			want: []string{`<row r="1"><c r="C1" t="inlineStr"><is><t xml:space="preserve">NaN</t></is></c></row>`},
+		},
+		{
+			name: "行号递增",
+			rows: [][]interface{}{{"a"}, {"b"}},
+			want: []string{`<row r="2"><c r="A2"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(FormatXLSX, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tt.rows {
+				if err := w.WriteRow(row...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			sheet := readSheet(t, buf.Bytes())
+			for _, want := range tt.want {
+				if !strings.Contains(sheet, want) {
+					t.Errorf("sheet %q does not contain %q", sheet, want)
+				}
+			}
+		})
+	}
+}
+
+func TestXLSXWriterReadable(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.WriteRow("名称", "数量")
+	_ = w.WriteRow("苹果", 3)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := docparse.Extract("xlsx", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## Sheet1\n| 名称 | 数量 |\n| --- | --- |\n| 苹果 | 3 |"
+	if text != want {
+		t.Errorf("Extract() = %q, want %q", text, want)
+	}
+}
+
+func TestNewWriterUnsupported(t *testing.T) {
+	if _, err := NewWriter("xls", io.Discard); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("NewWriter() error = %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.index); got != tt.want {
+			t.Errorf("columnName(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateCell(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int // 截断后的字符数
+	}{
+		{"未超出", "abc", 3},
+		{"多字节字符按字符数计算", strings.Repeat("中", XLSXMaxCellChars), XLSXMaxCellChars},
+		{"超出上限", strings.Repeat("中", XLSXMaxCellChars+10), XLSXMaxCellChars},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := []rune(truncateCell(tt.value)); len(got) != tt.want {
+				t.Errorf("truncateCell() has %d chars, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -31,8 +31,21 @@ var MAX_UPLOAD_FILE_SIZE_STRING = env.String("MAX_UPLOAD_FILE_SIZE", "30MB")
 var MAX_UPLOAD_FILE_SIZE, _ = helper.ParseSize(MAX_UPLOAD_FILE_SIZE_STRING)
 
 var CHANNEL_RETRY_TIMES = env.Int64("CHANNEL_RETRY_TIMES", 3)
+var CHANNEL_PROBE_INTERVAL = env.Int64("CHANNEL_PROBE_INTERVAL", 600) // seconds, 0 to disable
 var EnforceIncludeUsage = env.Bool("ENFORCE_INCLUDE_USAGE", false)
 
+var WORKFLOW_RUN_WORKERS = env.Int64("WORKFLOW_RUN_WORKERS", 8)          // 异步工作流并发执行数
+var WORKFLOW_RUN_QUEUE_SIZE = env.Int64("WORKFLOW_RUN_QUEUE_SIZE", 1000) // 异步工作流排队上限
+var WORKFLOW_RUN_TIMEOUT = env.Int64("WORKFLOW_RUN_TIMEOUT", 1800)       // seconds
+
+var EXPORT_JOB_WORKERS = env.Int64("EXPORT_JOB_WORKERS", 2)         // 导出任务并发执行数
+var EXPORT_JOB_QUEUE_SIZE = env.Int64("EXPORT_JOB_QUEUE_SIZE", 100) // 导出任务排队上限
+var EXPORT_JOB_TIMEOUT = env.Int64("EXPORT_JOB_TIMEOUT", 7200)      // seconds
+var EXPORT_FILE_TTL = env.Int64("EXPORT_FILE_TTL", 604800)          // seconds，导出文件保留时长
+var EXPORT_LINK_TTL = env.Int64("EXPORT_LINK_TTL", 900)             // seconds，下载链接有效期
+
+var QUOTA_PER_POINT = env.Int64("QUOTA_PER_POINT", 1000) // 积分订阅下每 1 积分对应的配额
+
 var PreConsumedQuota int64 = 500
 var WECOM_SUITE_ID = env.String("WECOM_SUITE_ID", "")
 var IS_TEST_WECOM_SUITE = env.Bool("IS_TEST_WECOM_SUITE", false)
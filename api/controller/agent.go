@@ -41,7 +41,7 @@ type AgentRequest struct {
 	Model                string  `json:"model" example:"gpt-3.5-turbo"`
 	GroupId              int64   `json:"group_id" example:"0"`
 	UseCases             string  `json:"use_cases" example:"[]"`
-	Tools                string  `json:"tools"  example:"[]"`
+	Tools                string  `json:"tools"  example:"[]"` // 工具定义 JSON 数组，见 model.AgentTool
 	CustomConfig         string  `json:"custom_config" example:"{}"`
 	UserGroupIds         []int64 `json:"user_group_ids"`
 	Enable               bool    `json:"enable" example:"true"`
@@ -81,6 +81,11 @@ func CreateAgent(c *gin.Context) {
 		return
 	}
 
+	if err := service.ValidateAgentTools(agentReq.Tools); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
 	params := map[string]interface{}{
 		"from": "agent",
 	}
@@ -260,6 +265,11 @@ func UpdateAgent(c *gin.Context) {
 		return
 	}
 
+	if err := service.ValidateAgentTools(agentReq.Tools); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
 	// Start transaction
 	tx := model.DB.Begin()
 	if tx.Error != nil {
@@ -0,0 +1,521 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/controller"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relay_model "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+const (
+	// maxToolRounds 单次请求最多执行的工具调用轮数，之后的一轮不再提供工具，要求模型直接回答
+	maxToolRounds = 5
+	// maxToolCallDepth 智能体工具互相调用的最大深度
+	maxToolCallDepth = 2
+)
+
+// getRelayTools 获取当前请求需要在服务端执行的工具
+// 仅支持函数调用的渠道生效；客户端自带 tools 时由客户端自行处理，不注入智能体工具
+func getRelayTools(agent *model.Agent, meta *meta.Meta, textRequest *relay_model.GeneralOpenAIRequest) []model.AgentTool {
+	if meta.Mode != relaymode.ChatCompletions || len(textRequest.Tools) > 0 {
+		return nil
+	}
+	switch meta.APIType {
+	case apitype.OpenAI, apitype.Anthropic, apitype.Gemini:
+	default:
+		return nil
+	}
+	return agent.GetTools()
+}
+
+// buildToolDefinitions 将智能体工具转换为 OpenAI 函数定义
+func buildToolDefinitions(tools []model.AgentTool) []relay_model.Tool {
+	definitions := make([]relay_model.Tool, 0, len(tools))
+	for _, tool := range tools {
+		parameters := tool.Parameters
+		if parameters == nil {
+			switch tool.Type {
+			case model.AgentToolTypeBuiltin:
+				parameters = service.BuiltinToolParameters(tool.Builtin)
+			case model.AgentToolTypeAgent:
+				parameters = map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"input": map[string]interface{}{"type": "string", "description": "The question or task for the agent"},
+					},
+					"required": []string{"input"},
+				}
+			default:
+				parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			}
+		}
+		definitions = append(definitions, relay_model.Tool{
+			Type: "function",
+			Function: relay_model.Function{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// relayWithTools 带工具的转发：模型返回工具调用时在服务端执行并回填结果，直到模型给出最终回答
+// 流式请求中工具调用相关的数据块不会发送给客户端，最终回答照常流式返回
+func relayWithTools(c *gin.Context, agent *model.Agent, meta *meta.Meta, adaptor adaptor.Adaptor,
+	textRequest *relay_model.GeneralOpenAIRequest, tools []model.AgentTool, messageID int64, requestId string,
+) (*relay_model.Usage, string, string, *relay_model.ErrorWithStatusCode) {
+	ctx := c.Request.Context()
+	usage := &relay_model.Usage{}
+	textRequest.Tools = buildToolDefinitions(tools)
+	firstFrameSent := false
+
+	for round := 1; ; round++ {
+		if round > maxToolRounds {
+			// 部分渠道会忽略 tool_choice，直接去掉工具定义
+			textRequest.Tools = nil
+			textRequest.ToolChoice = nil
+		}
+
+		convertedRequest, err := adaptor.ConvertRequest(c, meta.Mode, textRequest)
+		if err != nil {
+			return usage, "", "", openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
+		}
+		jsonData, err := json.Marshal(convertedRequest)
+		if err != nil {
+			return usage, "", "", openai.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
+		}
+
+		resp, err := adaptor.DoRequest(c, meta, bytes.NewBuffer(jsonData))
+		if err != nil {
+			logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+			return usage, "", "", openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+		}
+		if isErrorHappened(meta, resp) {
+			return usage, "", "", controller.RelayErrorHandler(resp)
+		}
+
+		if meta.IsStream && !firstFrameSent {
+			if err := sendSaveMessageEvent(c, requestId, textRequest.Model, messageID); err != nil {
+				logger.Warnf(ctx, "sendSaveMessageEvent failed: %s", err.Error())
+			}
+			firstFrameSent = true
+		}
+
+		var roundUsage *relay_model.Usage
+		var respErr *relay_model.ErrorWithStatusCode
+		var assistant relay_model.Message
+		originalWriter := c.Writer
+		if meta.IsStream {
+			writer := newToolStreamWriter(originalWriter)
+			c.Writer = writer
+			roundUsage, respErr = adaptor.DoResponse(c, resp, meta)
+			c.Writer = originalWriter
+			assistant = writer.assistantMessage()
+			if respErr == nil && len(assistant.ToolCalls) == 0 {
+				writer.flushHeld()
+			}
+		} else {
+			writer := newBufferedResponseWriter(originalWriter)
+			c.Writer = writer
+			roundUsage, respErr = adaptor.DoResponse(c, resp, meta)
+			c.Writer = originalWriter
+			if respErr == nil {
+				var textResponse openai.TextResponse
+				if err := json.Unmarshal(writer.body.Bytes(), &textResponse); err == nil && len(textResponse.Choices) > 0 {
+					assistant = textResponse.Choices[0].Message
+					assistant.ToolCalls = normalizeToolCalls(assistant.ToolCalls)
+				}
+				if len(assistant.ToolCalls) == 0 {
+					// 最终回答，写回客户端
+					writer.flushTo(originalWriter)
+					responseContent, reasoningContent := GetResponseContent(c, false, &http.Response{Body: io.NopCloser(bytes.NewReader(writer.body.Bytes()))})
+					addUsage(usage, roundUsage)
+					return usage, responseContent, reasoningContent, nil
+				}
+			}
+		}
+		addUsage(usage, roundUsage)
+		if respErr != nil {
+			return usage, "", "", respErr
+		}
+
+		if len(assistant.ToolCalls) == 0 {
+			responseContent, reasoningContent := GetResponseContent(c, true, resp)
+			return usage, responseContent, reasoningContent, nil
+		}
+
+		if round > maxToolRounds {
+			return usage, "", "", openai.ErrorWrapper(fmt.Errorf("model still returns tool calls after %d rounds", maxToolRounds),
+				"tool_rounds_exceeded", http.StatusInternalServerError)
+		}
+
+		// 执行工具调用，将结果作为 tool 消息回填
+		assistant.Role = "assistant"
+		textRequest.Messages = append(textRequest.Messages, assistant)
+		for _, call := range assistant.ToolCalls {
+			result := executeToolCall(c, agent, tools, call, round, messageID)
+			textRequest.Messages = append(textRequest.Messages, relay_model.Message{
+				Role:       "tool",
+				Content:    result,
+				ToolCallId: call.Id,
+			})
+		}
+		logger.Infof(ctx, "tool round %d finished, %d tool calls executed", round, len(assistant.ToolCalls))
+	}
+}
+
+// executeToolCall 执行单个工具调用并记录，失败时将错误信息返回给模型
+func executeToolCall(c *gin.Context, agent *model.Agent, tools []model.AgentTool, call relay_model.Tool, round int, messageID int64) string {
+	ctx := c.Request.Context()
+	startTime := time.Now()
+	arguments, _ := call.Function.Arguments.(string)
+
+	var tool *model.AgentTool
+	for i := range tools {
+		if tools[i].Name == call.Function.Name {
+			tool = &tools[i]
+			break
+		}
+	}
+
+	var result string
+	var err error
+	if tool == nil {
+		err = fmt.Errorf("unknown tool: %s", call.Function.Name)
+	} else {
+		switch tool.Type {
+		case model.AgentToolTypeHTTP:
+			result, err = service.ExecuteHTTPTool(ctx, tool, arguments)
+		case model.AgentToolTypeBuiltin:
+			result, err = service.ExecuteBuiltinTool(tool.Builtin, arguments)
+		case model.AgentToolTypeAgent:
+			result, err = callAgentTool(c, agent.Eid, tool.AgentID, arguments)
+		}
+	}
+
+	record := &model.MessageToolCall{
+		Eid:         agent.Eid,
+		MessageID:   messageID,
+		AgentID:     agent.AgentID,
+		Round:       round,
+		ToolCallID:  call.Id,
+		ToolName:    call.Function.Name,
+		Arguments:   arguments,
+		Result:      result,
+		Success:     err == nil,
+		ElapsedTime: helper.CalcElapsedTime(startTime),
+	}
+	if tool != nil {
+		record.ToolType = tool.Type
+	}
+	if err != nil {
+		logger.Warnf(ctx, "tool %s failed: %s", call.Function.Name, err.Error())
+		record.ErrorMessage = err.Error()
+		result = "Error: " + err.Error()
+	}
+	go func() {
+		if err := model.CreateMessageToolCall(record); err != nil {
+			logger.SysErrorf("record tool call failed: %v", err)
+		}
+	}()
+	return result
+}
+
+// callAgentTool 以当前用户身份调用本企业的其他智能体，返回其回答
+func callAgentTool(c *gin.Context, eid int64, agentID int64, arguments string) (string, error) {
+	depth := c.GetInt(ctxkey.ToolCallDepth)
+	if depth >= maxToolCallDepth {
+		return "", errors.New("agent tool call depth exceeded")
+	}
+
+	var args struct {
+		Input string `json:"input"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil || args.Input == "" {
+		return "", errors.New("input is required")
+	}
+
+	agent, err := model.GetAgentByID(eid, agentID)
+	if err != nil || !agent.Enable {
+		return "", fmt.Errorf("agent %d not found", agentID)
+	}
+	if agent.AgentType == model.AgentTypeWorkflow {
+		return "", fmt.Errorf("agent %d is a workflow agent", agentID)
+	}
+	// 与直接调用智能体一致，校验 API 密钥范围、用户分组权限和预算暂停状态
+	user, err := model.GetUserByID(config.GetUserId(c))
+	if err != nil {
+		return "", errors.New("user not found")
+	}
+	if err := service.CheckAgentAccess(c, user, agent); err != nil {
+		return "", fmt.Errorf("agent %d: %w", agentID, err)
+	}
+
+	w := httptest.NewRecorder()
+	subContext, _ := gin.CreateTestContext(w)
+	subContext.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(c.Request.Context())
+	for _, key := range []string{session.SESSION_USER_ID, session.SESSION_USER_NICKNAME, session.SESSION_USER_ROLE, session.SESSION_USER_GROUP_ID, session.ENV_EID, session.SESSION_API_KEY} {
+		if value, exists := c.Get(key); exists {
+			subContext.Set(key, value)
+		}
+	}
+	subContext.Set(ctxkey.Group, c.GetString(ctxkey.Group))
+	subContext.Set(ctxkey.ToolCallDepth, depth+1)
+	subContext.Set(session.SESSION_AGENT_ID, agent.AgentID)
+	subContext.Set(session.SESSION_AGENT, agent)
+	// 被调用的智能体不关联会话
+	subContext.Set(session.SESSION_CONVERSATION, &model.Conversation{})
+
+	processChatRequest(subContext, &ChatRequest{
+		Messages: []Message{{Role: "user", Content: args.Input}},
+	}, agent, relaymode.ChatCompletions)
+
+	if w.Code != http.StatusOK {
+		return "", fmt.Errorf("agent %d failed: %s", agentID, service.TruncateToolResult(w.Body.String()))
+	}
+	var textResponse openai.TextResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &textResponse); err != nil || len(textResponse.Choices) == 0 {
+		return "", fmt.Errorf("agent %d returned an invalid response", agentID)
+	}
+	return service.TruncateToolResult(textResponse.Choices[0].Message.StringContent()), nil
+}
+
+// normalizeToolCalls 统一工具调用参数为 JSON 字符串
+func normalizeToolCalls(calls []relay_model.Tool) []relay_model.Tool {
+	for i := range calls {
+		switch args := calls[i].Function.Arguments.(type) {
+		case string:
+		case nil:
+			calls[i].Function.Arguments = "{}"
+		default:
+			b, _ := json.Marshal(args)
+			calls[i].Function.Arguments = string(b)
+		}
+		if calls[i].Type == "" {
+			calls[i].Type = "function"
+		}
+	}
+	return calls
+}
+
+func addUsage(total *relay_model.Usage, usage *relay_model.Usage) {
+	if usage == nil {
+		return
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
+
+// bufferedResponseWriter 缓存非流式响应，确认不是工具调用后再写回客户端
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter(w gin.ResponseWriter) *bufferedResponseWriter {
+	return &bufferedResponseWriter{ResponseWriter: w, header: make(http.Header), status: http.StatusOK}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return false
+}
+
+func (w *bufferedResponseWriter) Flush() {}
+
+func (w *bufferedResponseWriter) flushTo(dst gin.ResponseWriter) {
+	for k, v := range w.header {
+		dst.Header()[k] = v
+	}
+	dst.WriteHeader(w.status)
+	_, _ = dst.Write(w.body.Bytes())
+}
+
+// toolStreamDelta 流式响应中的增量数据，用于识别工具调用
+type toolStreamDelta struct {
+	Choices []struct {
+		Delta struct {
+			Content   any `json:"content"`
+			ToolCalls []struct {
+				Index    *int   `json:"index"`
+				Id       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string          `json:"name"`
+					Arguments json.RawMessage `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// toolStreamWriter 过滤流式响应：普通内容直接转发给客户端，工具调用的数据块被拦截并拼接
+// 只有用量信息和 [DONE] 的数据块会暂存，确认本轮为最终回答后再发送
+type toolStreamWriter struct {
+	gin.ResponseWriter
+	pending   bytes.Buffer
+	held      [][]byte
+	content   strings.Builder
+	toolCalls []relay_model.Tool
+	argsBuf   []*strings.Builder
+}
+
+func newToolStreamWriter(w gin.ResponseWriter) *toolStreamWriter {
+	return &toolStreamWriter{ResponseWriter: w}
+}
+
+func (w *toolStreamWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *toolStreamWriter) Write(b []byte) (int, error) {
+	w.pending.Write(b)
+	for {
+		line, err := w.pending.ReadBytes('\n')
+		if err != nil {
+			// 不完整的行留到下次处理
+			rest := append([]byte{}, line...)
+			w.pending.Reset()
+			w.pending.Write(rest)
+			break
+		}
+		w.processLine(line)
+	}
+	return len(b), nil
+}
+
+func (w *toolStreamWriter) processLine(line []byte) {
+	trimmed := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(trimmed, "data:") {
+		// 空行等事件分隔符直接转发
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+
+	data := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if data == "[DONE]" {
+		w.held = append(w.held, append([]byte{}, line...), []byte("\n"))
+		return
+	}
+
+	var delta toolStreamDelta
+	if err := json.Unmarshal([]byte(data), &delta); err != nil {
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+	if len(delta.Choices) == 0 {
+		w.held = append(w.held, append([]byte{}, line...), []byte("\n"))
+		return
+	}
+
+	choice := delta.Choices[0]
+	if len(choice.Delta.ToolCalls) > 0 {
+		w.collectToolCalls(delta)
+		return
+	}
+	if choice.FinishReason != nil && *choice.FinishReason == "tool_calls" {
+		return
+	}
+	if content, ok := choice.Delta.Content.(string); ok {
+		w.content.WriteString(content)
+	}
+	_, _ = w.ResponseWriter.Write(line)
+}
+
+func (w *toolStreamWriter) collectToolCalls(delta toolStreamDelta) {
+	for _, call := range delta.Choices[0].Delta.ToolCalls {
+		index := len(w.toolCalls) - 1
+		if call.Index != nil {
+			index = *call.Index
+		} else if call.Id != "" {
+			index = len(w.toolCalls)
+		}
+		if index < 0 {
+			index = 0
+		}
+		for len(w.toolCalls) <= index {
+			w.toolCalls = append(w.toolCalls, relay_model.Tool{Type: "function"})
+			w.argsBuf = append(w.argsBuf, &strings.Builder{})
+		}
+		if call.Id != "" {
+			w.toolCalls[index].Id = call.Id
+		}
+		if call.Function.Name != "" {
+			w.toolCalls[index].Function.Name = call.Function.Name
+		}
+		if len(call.Function.Arguments) > 0 {
+			var s string
+			if err := json.Unmarshal(call.Function.Arguments, &s); err == nil {
+				w.argsBuf[index].WriteString(s)
+			} else {
+				w.argsBuf[index].Write(call.Function.Arguments)
+			}
+		}
+	}
+}
+
+// assistantMessage 返回本轮模型输出，包含拼接完成的工具调用
+func (w *toolStreamWriter) assistantMessage() relay_model.Message {
+	message := relay_model.Message{Role: "assistant"}
+	if w.content.Len() > 0 {
+		message.Content = w.content.String()
+	}
+	for i := range w.toolCalls {
+		w.toolCalls[i].Function.Arguments = w.argsBuf[i].String()
+	}
+	message.ToolCalls = normalizeToolCalls(w.toolCalls)
+	return message
+}
+
+// flushHeld 发送暂存的用量信息和 [DONE]
+func (w *toolStreamWriter) flushHeld() {
+	for _, line := range w.held {
+		_, _ = w.ResponseWriter.Write(line)
+	}
+	w.held = nil
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,531 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// AnthropicContentBlock Anthropic 格式的内容块，目前仅支持 text 类型
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// AnthropicMessage Anthropic 格式的消息，content 可以是字符串或内容块数组
+type AnthropicMessage struct {
+	Role    string          `json:"role" example:"user"`
+	Content json.RawMessage `json:"content" swaggertype:"string" example:"who are you"`
+}
+
+// AnthropicMessagesRequest Anthropic Messages API 请求
+type AnthropicMessagesRequest struct {
+	Model          string             `json:"model" example:"agent-6"`
+	Messages       []AnthropicMessage `json:"messages"`
+	System         json.RawMessage    `json:"system,omitempty" swaggertype:"string"`
+	MaxTokens      int                `json:"max_tokens"`
+	Stream         bool               `json:"stream"`
+	Temperature    float64            `json:"temperature,omitempty"`
+	TopP           float64            `json:"top_p,omitempty"`
+	ConversationID int64              `json:"conversation_id"` // 扩展字段，与 /v1/chat/completions 一致
+}
+
+// AnthropicUsage Anthropic 格式的用量
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicMessagesResponse Anthropic Messages API 非流式响应
+type AnthropicMessagesResponse struct {
+	ID           string                   `json:"id"`
+	Type         string                   `json:"type"`
+	Role         string                   `json:"role"`
+	Model        string                   `json:"model"`
+	Content      []AnthropicResponseBlock `json:"content"`
+	StopReason   *string                  `json:"stop_reason"`
+	StopSequence *string                  `json:"stop_sequence"`
+	Usage        AnthropicUsage           `json:"usage"`
+	MessageID    int64                    `json:"message_id,omitempty"` // 扩展字段，53AIHub 消息ID，与流式 message_start 中的一致，用于反馈和复制会话
+}
+
+// AnthropicResponseBlock 响应中的内容块
+type AnthropicResponseBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+}
+
+// AnthropicErrorResponse Anthropic 格式的错误响应
+type AnthropicErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// @Summary Anthropic Messages
+// @Description 兼容 Anthropic Messages API 的对话接口，model 使用 agent-{id}。请求转换为 /v1/chat/completions 处理，配额、消息记录和权限校验与其一致；stream=true 时按 Anthropic 事件格式（message_start、content_block_delta、message_stop 等）推送。响应的 message_id 扩展字段为 53AIHub 消息ID，流式响应在 message_start 中返回，可用于反馈和复制会话。认证支持 Authorization: Bearer 或 x-api-key
+// @Tags Relay
+// @Accept json
+// @Produce json,text/event-stream
+// @Param request body AnthropicMessagesRequest true "AnthropicMessagesRequest"
+// @Success 200 {object} AnthropicMessagesResponse
+// @Failure 400 {object} AnthropicErrorResponse
+// @Router /v1/messages [post]
+// @Security BearerAuth
+func AnthropicMessages(c *gin.Context) {
+	c.Set(ctxkey.Group, "vip")
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondAnthropicError(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	var request AnthropicMessagesRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		respondAnthropicError(c, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	agent, err := GetSessionAgent(c)
+	if err != nil {
+		respondAnthropicError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if agent.AgentType == model.AgentTypeWorkflow {
+		respondAnthropicError(c, http.StatusBadRequest, "工作流类型的 Agent 请使用 /v1/workflow/run 接口")
+		return
+	}
+
+	chatRequest, err := request.toChatRequest()
+	if err != nil {
+		respondAnthropicError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 按 /v1/chat/completions 处理，渠道适配器据此构造上游请求地址
+	c.Request.URL.Path = "/v1/chat/completions"
+	c.Request.URL.RawPath = ""
+
+	originalWriter := c.Writer
+	writer := newAnthropicResponseWriter(originalWriter, request.Stream, request.Model)
+	c.Writer = writer
+	processChatRequest(c, chatRequest, agent, relaymode.ChatCompletions)
+	writer.messageID = c.GetInt64(ctxkey.RelayMessageId)
+	writer.finish()
+	c.Writer = originalWriter
+}
+
+// toChatRequest 转换为内部的聊天请求：system 作为首条系统消息，内容块拼接为文本
+func (r *AnthropicMessagesRequest) toChatRequest() (*ChatRequest, error) {
+	if len(r.Messages) == 0 {
+		return nil, errors.New("messages: at least one message is required")
+	}
+
+	chatRequest := &ChatRequest{
+		Model:          r.Model,
+		Stream:         r.Stream,
+		Temperature:    r.Temperature,
+		TopP:           r.TopP,
+		MaxTokens:      r.MaxTokens,
+		ConversationID: r.ConversationID,
+	}
+
+	system, err := anthropicContentText(r.System)
+	if err != nil {
+		return nil, fmt.Errorf("system: %w", err)
+	}
+	if system != "" {
+		chatRequest.Messages = append(chatRequest.Messages, Message{Role: "system", Content: system})
+	}
+
+	for i, m := range r.Messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			return nil, fmt.Errorf("messages.%d.role: unexpected role %q", i, m.Role)
+		}
+		content, err := anthropicContentText(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("messages.%d.content: %w", i, err)
+		}
+		chatRequest.Messages = append(chatRequest.Messages, Message{Role: m.Role, Content: content})
+	}
+	return chatRequest, nil
+}
+
+// anthropicContentText 解析字符串或内容块数组，拼接其中的 text 块
+func anthropicContentText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return "", errors.New("content must be a string or an array of content blocks")
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type != "text" {
+			return "", fmt.Errorf("unsupported content block type %q", block.Type)
+		}
+		parts = append(parts, block.Text)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+func anthropicErrorType(statusCode int) string {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return "authentication_error"
+	case statusCode == http.StatusForbidden:
+		return "permission_error"
+	case statusCode == http.StatusNotFound:
+		return "not_found_error"
+	case statusCode == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case statusCode >= http.StatusInternalServerError:
+		return "api_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+func newAnthropicError(statusCode int, message string) AnthropicErrorResponse {
+	var resp AnthropicErrorResponse
+	resp.Type = "error"
+	resp.Error.Type = anthropicErrorType(statusCode)
+	resp.Error.Message = message
+	return resp
+}
+
+func respondAnthropicError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, newAnthropicError(statusCode, message))
+}
+
+// anthropicStopReason 将 OpenAI 的 finish_reason 转换为 Anthropic 的 stop_reason
+func anthropicStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+// openAIChunk OpenAI 格式的流式数据块
+type openAIChunk struct {
+	ID        string `json:"id"`
+	Model     string `json:"model"`
+	MessageID int64  `json:"message_id"`
+	Choices   []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// anthropicResponseWriter 将转发流程写出的 OpenAI 格式响应转换为 Anthropic 格式：
+// 流式响应逐行转换为 Anthropic 事件，非流式响应和错误响应缓存后在 finish 中整体转换
+type anthropicResponseWriter struct {
+	gin.ResponseWriter
+	stream    bool
+	model     string
+	status    int
+	body      bytes.Buffer
+	messageID int64 // 非流式响应返回的消息ID，流式响应从首帧读取
+
+	// 流式转换状态
+	pending      bytes.Buffer
+	started      bool
+	stopped      bool
+	blockIndex   int
+	blockType    string
+	stopReason   string
+	inputTokens  int
+	outputTokens int
+}
+
+func newAnthropicResponseWriter(w gin.ResponseWriter, stream bool, modelName string) *anthropicResponseWriter {
+	return &anthropicResponseWriter{ResponseWriter: w, stream: stream, model: modelName, status: http.StatusOK, blockIndex: -1}
+}
+
+func (w *anthropicResponseWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+}
+
+func (w *anthropicResponseWriter) WriteHeaderNow() {}
+
+func (w *anthropicResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *anthropicResponseWriter) Written() bool {
+	return w.started || w.body.Len() > 0
+}
+
+func (w *anthropicResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *anthropicResponseWriter) Write(b []byte) (int, error) {
+	if !w.stream || w.status >= http.StatusBadRequest {
+		return w.body.Write(b)
+	}
+
+	w.pending.Write(b)
+	for {
+		line, err := w.pending.ReadBytes('\n')
+		if err != nil {
+			// 不完整的行留到下次处理
+			rest := append([]byte{}, line...)
+			w.pending.Reset()
+			w.pending.Write(rest)
+			break
+		}
+		w.processLine(line)
+	}
+	return len(b), nil
+}
+
+func (w *anthropicResponseWriter) Flush() {
+	if w.started {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *anthropicResponseWriter) processLine(line []byte) {
+	trimmed := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(trimmed, "data:") {
+		if !w.started {
+			// 未进入流式输出前的非 SSE 内容（如 JSON 响应）交由 finish 整体转换
+			w.body.Write(line)
+		}
+		return
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if data == "[DONE]" {
+		w.stop()
+		return
+	}
+
+	var chunk openAIChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+	w.start(chunk.ID, chunk.MessageID)
+	if chunk.Usage != nil {
+		w.inputTokens = chunk.Usage.PromptTokens
+		w.outputTokens = chunk.Usage.CompletionTokens
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+	choice := chunk.Choices[0]
+	if choice.Delta.ReasoningContent != "" {
+		w.delta("thinking", map[string]string{"type": "thinking_delta", "thinking": choice.Delta.ReasoningContent})
+	}
+	if choice.Delta.Content != "" {
+		w.delta("text", map[string]string{"type": "text_delta", "text": choice.Delta.Content})
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		w.stopReason = anthropicStopReason(*choice.FinishReason)
+	}
+}
+
+// start 发送 message_start 事件，首帧携带 message_id 时一并返回
+func (w *anthropicResponseWriter) start(id string, messageID int64) {
+	if w.started {
+		return
+	}
+	w.started = true
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+
+	w.event("message_start", map[string]interface{}{
+		"type": "message_start",
+		"message": AnthropicMessagesResponse{
+			ID:        id,
+			Type:      "message",
+			Role:      "assistant",
+			Model:     w.model,
+			Content:   []AnthropicResponseBlock{},
+			MessageID: messageID,
+		},
+	})
+}
+
+// delta 发送内容增量，内容类型变化时关闭上一个内容块并开启新的内容块
+func (w *anthropicResponseWriter) delta(blockType string, delta map[string]string) {
+	if w.blockType != blockType {
+		w.closeBlock()
+		w.blockIndex++
+		w.blockType = blockType
+		block := map[string]string{"type": blockType}
+		if blockType == "thinking" {
+			block["thinking"] = ""
+		} else {
+			block["text"] = ""
+		}
+		w.event("content_block_start", map[string]interface{}{
+			"type":          "content_block_start",
+			"index":         w.blockIndex,
+			"content_block": block,
+		})
+	}
+	w.event("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": w.blockIndex,
+		"delta": delta,
+	})
+}
+
+func (w *anthropicResponseWriter) closeBlock() {
+	if w.blockType == "" {
+		return
+	}
+	w.event("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": w.blockIndex,
+	})
+	w.blockType = ""
+}
+
+// stop 发送 message_delta 和 message_stop 事件
+func (w *anthropicResponseWriter) stop() {
+	if !w.started || w.stopped {
+		return
+	}
+	w.stopped = true
+	w.closeBlock()
+	if w.stopReason == "" {
+		w.stopReason = "end_turn"
+	}
+	w.event("message_delta", map[string]interface{}{
+		"type": "message_delta",
+		"delta": map[string]interface{}{
+			"stop_reason":   w.stopReason,
+			"stop_sequence": nil,
+		},
+		"usage": AnthropicUsage{InputTokens: w.inputTokens, OutputTokens: w.outputTokens},
+	})
+	w.event("message_stop", map[string]string{"type": "message_stop"})
+}
+
+func (w *anthropicResponseWriter) event(name string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(w.ResponseWriter, "event: %s\ndata: %s\n\n", name, data)
+	w.ResponseWriter.Flush()
+}
+
+// finish 转发结束后调用：补齐未结束的流，或将缓存的非流式响应、错误响应转换后写出
+func (w *anthropicResponseWriter) finish() {
+	if w.started {
+		w.stop()
+		return
+	}
+	w.body.Write(w.pending.Bytes())
+	if w.body.Len() == 0 {
+		return
+	}
+
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if w.status >= http.StatusBadRequest {
+		w.writeJSON(w.status, newAnthropicError(w.status, openAIErrorMessage(w.body.Bytes())))
+		return
+	}
+
+	var textResponse struct {
+		ID      string `json:"id"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content          string `json:"content"`
+				ReasoningContent string `json:"reasoning_content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &textResponse); err != nil || len(textResponse.Choices) == 0 {
+		w.writeJSON(http.StatusInternalServerError, newAnthropicError(http.StatusInternalServerError, openAIErrorMessage(w.body.Bytes())))
+		return
+	}
+
+	choice := textResponse.Choices[0]
+	content := make([]AnthropicResponseBlock, 0, 2)
+	if choice.Message.ReasoningContent != "" {
+		content = append(content, AnthropicResponseBlock{Type: "thinking", Thinking: choice.Message.ReasoningContent})
+	}
+	content = append(content, AnthropicResponseBlock{Type: "text", Text: choice.Message.Content})
+	stopReason := anthropicStopReason(choice.FinishReason)
+	w.writeJSON(http.StatusOK, AnthropicMessagesResponse{
+		ID:         textResponse.ID,
+		Type:       "message",
+		Role:       "assistant",
+		Model:      w.model,
+		Content:    content,
+		StopReason: &stopReason,
+		Usage: AnthropicUsage{
+			InputTokens:  textResponse.Usage.PromptTokens,
+			OutputTokens: textResponse.Usage.CompletionTokens,
+		},
+		MessageID: w.messageID,
+	})
+}
+
+func (w *anthropicResponseWriter) writeJSON(statusCode int, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	w.ResponseWriter.WriteHeader(statusCode)
+	_, _ = w.ResponseWriter.Write(data)
+}
+
+// openAIErrorMessage 从 OpenAI 或 53AIHub 格式的错误响应中提取错误信息
+func openAIErrorMessage(body []byte) string {
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if resp.Error.Message != "" {
+			return resp.Error.Message
+		}
+		if resp.Message != "" {
+			return resp.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
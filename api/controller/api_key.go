@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ApiKeyRequest 创建或更新 API 密钥的请求参数
+type ApiKeyRequest struct {
+	Name        string   `json:"name" binding:"required" example:"my script"` // 密钥名称
+	UserID      int64    `json:"user_id" example:"0"`                         // 服务密钥所属用户，仅管理员创建服务密钥时有效，默认为当前用户
+	AgentIDs    []int64  `json:"agent_ids"`                                   // 允许访问的智能体，为空表示不限制
+	AllowedIPs  []string `json:"allowed_ips"`                                 // IP白名单，支持CIDR，为空表示不限制
+	ExpiredTime int64    `json:"expired_time" example:"0"`                    // 过期时间（毫秒时间戳），0表示永不过期
+}
+
+// ApiKeyListRequest 获取 API 密钥列表的请求参数
+type ApiKeyListRequest struct {
+	Keyword string `form:"keyword" json:"keyword"`
+	Offset  int    `form:"offset" json:"offset"`
+	Limit   int    `form:"limit" json:"limit"`
+}
+
+// ApiKeysResponse API 密钥列表响应
+type ApiKeysResponse struct {
+	Count   int64           `json:"count"`
+	ApiKeys []*model.ApiKey `json:"api_keys"`
+}
+
+// CreateApiKeyResponse 创建密钥的响应，明文密钥只返回这一次
+type CreateApiKeyResponse struct {
+	Key    string        `json:"key"`
+	ApiKey *model.ApiKey `json:"api_key"`
+}
+
+// GetApiKeys 获取当前用户的 API 密钥列表
+// @Summary 获取我的 API 密钥列表
+// @Description 获取当前用户的 API 密钥列表，不返回明文密钥
+// @Tags ApiKey
+// @Produce json
+// @Security BearerAuth
+// @Param keyword query string false "名称关键词"
+// @Param offset query int false "分页偏移量"
+// @Param limit query int false "分页大小" default(10)
+// @Success 200 {object} model.CommonResponse{data=ApiKeysResponse} "成功"
+// @Router /api/api_keys [get]
+func GetApiKeys(c *gin.Context) {
+	getApiKeyList(c, config.GetUserId(c))
+}
+
+// GetAllApiKeys 获取企业下全部 API 密钥
+// @Summary 获取企业 API 密钥列表
+// @Description 管理员获取企业下全部 API 密钥，不返回明文密钥
+// @Tags ApiKey
+// @Produce json
+// @Security BearerAuth
+// @Param keyword query string false "名称关键词"
+// @Param offset query int false "分页偏移量"
+// @Param limit query int false "分页大小" default(10)
+// @Success 200 {object} model.CommonResponse{data=ApiKeysResponse} "成功"
+// @Router /api/api_keys/admin [get]
+func GetAllApiKeys(c *gin.Context) {
+	getApiKeyList(c, 0)
+}
+
+func getApiKeyList(c *gin.Context, userID int64) {
+	var req ApiKeyListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	count, keys, err := model.GetApiKeyList(config.GetEID(c), userID, req.Keyword, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(ApiKeysResponse{
+		Count:   count,
+		ApiKeys: keys,
+	}))
+}
+
+// CreateApiKey 创建 API 密钥
+// @Summary 创建 API 密钥
+// @Description 为当前用户创建个人密钥，或由管理员为指定用户创建服务密钥，明文密钥只在创建时返回一次
+// @Tags ApiKey
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ApiKeyRequest true "密钥信息"
+// @Success 200 {object} model.CommonResponse{data=CreateApiKeyResponse} "成功"
+// @Router /api/api_keys [post]
+// @Router /api/api_keys/service [post]
+func CreateApiKey(c *gin.Context) {
+	var req ApiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	currentUserID := config.GetUserId(c)
+
+	keyType := model.ApiKeyTypePersonal
+	ownerID := currentUserID
+	if strings.HasSuffix(c.Request.URL.Path, "/service") {
+		keyType = model.ApiKeyTypeService
+		if req.UserID > 0 {
+			owner, err := model.GetUserByID(req.UserID)
+			if err != nil || owner.Eid != eid {
+				c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("user not found")))
+				return
+			}
+			ownerID = owner.UserID
+		}
+	}
+
+	if err := validateApiKeyRequest(eid, &req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	plainKey, err := model.GenerateApiKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+
+	apiKey := &model.ApiKey{
+		Eid:         eid,
+		UserID:      ownerID,
+		CreatedBy:   currentUserID,
+		Name:        req.Name,
+		Type:        keyType,
+		KeyHash:     model.HashApiKey(plainKey),
+		KeyPrefix:   plainKey[:len(model.ApiKeyPrefix)+4],
+		ExpiredTime: req.ExpiredTime,
+		Status:      model.ApiKeyStatusNormal,
+	}
+	apiKey.SetAgentIDs(req.AgentIDs)
+	apiKey.SetAllowedIPs(req.AllowedIPs)
+
+	if err := model.CreateApiKey(apiKey); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(CreateApiKeyResponse{
+		Key:    plainKey,
+		ApiKey: apiKey,
+	}))
+}
+
+// UpdateApiKey 更新 API 密钥
+// @Summary 更新 API 密钥
+// @Description 更新密钥名称、智能体范围、IP白名单和过期时间，普通用户只能更新自己的密钥
+// @Tags ApiKey
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "密钥ID"
+// @Param request body ApiKeyRequest true "密钥信息"
+// @Success 200 {object} model.CommonResponse{data=model.ApiKey} "成功"
+// @Router /api/api_keys/{id} [put]
+func UpdateApiKey(c *gin.Context) {
+	apiKey, ok := getOwnedApiKey(c)
+	if !ok {
+		return
+	}
+
+	var req ApiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if err := validateApiKeyRequest(apiKey.Eid, &req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	apiKey.Name = req.Name
+	apiKey.ExpiredTime = req.ExpiredTime
+	apiKey.SetAgentIDs(req.AgentIDs)
+	apiKey.SetAllowedIPs(req.AllowedIPs)
+	if err := apiKey.Update(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(apiKey))
+}
+
+// RevokeApiKey 吊销 API 密钥
+// @Summary 吊销 API 密钥
+// @Description 吊销后密钥立即失效，普通用户只能吊销自己的密钥
+// @Tags ApiKey
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "密钥ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/api_keys/{id} [delete]
+func RevokeApiKey(c *gin.Context) {
+	apiKey, ok := getOwnedApiKey(c)
+	if !ok {
+		return
+	}
+
+	if err := apiKey.Revoke(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// getOwnedApiKey 获取路径中的密钥，并校验当前用户是否有权操作
+func getOwnedApiKey(c *gin.Context) (*model.ApiKey, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, false
+	}
+
+	apiKey, err := model.GetApiKeyByID(config.GetEID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+
+	if apiKey.UserID != config.GetUserId(c) && !common.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+		return nil, false
+	}
+
+	apiKey.LoadScopes()
+	return apiKey, true
+}
+
+// validateApiKeyRequest 校验密钥范围配置
+func validateApiKeyRequest(eid int64, req *ApiKeyRequest) error {
+	if req.ExpiredTime < 0 {
+		return errors.New("expired_time must be greater than or equal to 0")
+	}
+	if req.ExpiredTime > 0 && req.ExpiredTime < time.Now().UTC().UnixMilli() {
+		return errors.New("expired_time must be in the future")
+	}
+	for _, agentID := range req.AgentIDs {
+		if _, err := model.GetAgentByID(eid, agentID); err != nil {
+			return errors.New("agent not found: " + strconv.FormatInt(agentID, 10))
+		}
+	}
+	for _, ip := range req.AllowedIPs {
+		ip = strings.TrimSpace(ip)
+		if ip != "" && !model.IsValidIPRule(ip) {
+			return errors.New("invalid ip rule: " + ip)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+type BudgetRequest struct {
+	Name          string   `json:"name" binding:"required,max=100" example:"客服智能体月度预算"`
+	ScopeType     string   `json:"scope_type" binding:"required" example:"agent"` // enterprise、channel、agent 或 user_group
+	ScopeID       int64    `json:"scope_id" example:"1"`                          // 渠道、智能体或用户分组ID，企业预算不需要
+	Metric        string   `json:"metric" binding:"required" example:"quota"`     // quota 或 tokens
+	Period        string   `json:"period" binding:"required" example:"month"`     // day 或 month
+	Threshold     int64    `json:"threshold" binding:"required" example:"5000000"`
+	AlertLevels   []int    `json:"alert_levels" example:"50,80,100"` // 告警比例（百分比）
+	NotifyEmails  []string `json:"notify_emails" example:"admin@example.com"`
+	WebhookURL    string   `json:"webhook_url" binding:"max=512" example:"https://example.com/hooks/budget"`
+	PauseOnExceed bool     `json:"pause_on_exceed" example:"false"` // 用量达到 100% 时暂停智能体或渠道，新周期自动恢复
+	Enabled       bool     `json:"enabled" example:"true"`
+}
+
+// BudgetResponse 预算，usage 为最近一次检查时当前周期的用量
+type BudgetResponse struct {
+	*model.Budget
+	AlertLevels  []int    `json:"alert_levels"`
+	NotifyEmails []string `json:"notify_emails"`
+}
+
+type BudgetAlertListRequest struct {
+	BudgetID int64 `form:"budget_id"`
+	Offset   int   `form:"offset" default:"0"`
+	Limit    int   `form:"limit" default:"10"`
+}
+
+type BudgetAlertListResponse struct {
+	Count  int64                `json:"count"`
+	Alerts []*model.BudgetAlert `json:"alerts"`
+}
+
+// @Summary List budgets
+// @Description List the consumption budgets of the enterprise
+// @Tags Budget
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=[]BudgetResponse} "Success"
+// @Router /api/budgets [get]
+func GetBudgets(c *gin.Context) {
+	budgets, err := model.GetBudgets(config.GetEID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	items := make([]*BudgetResponse, len(budgets))
+	for i, budget := range budgets {
+		items[i] = newBudgetResponse(budget)
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(items))
+}
+
+// @Summary Create a budget
+// @Description Create a daily or monthly quota or token budget for the enterprise, a channel, an agent or a user group. Usage is checked every few minutes; alerts are sent by email and webhook when it reaches each alert level
+// @Tags Budget
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BudgetRequest true "Budget"
+// @Success 200 {object} model.CommonResponse{data=BudgetResponse} "Success"
+// @Router /api/budgets [post]
+func CreateBudget(c *gin.Context) {
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	budget := &model.Budget{Eid: config.GetEID(c)}
+	if !applyBudgetRequest(c, budget, &req) {
+		return
+	}
+	if err := model.CreateBudget(budget); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(newBudgetResponse(budget)))
+}
+
+// @Summary Get a budget
+// @Tags Budget
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Budget ID"
+// @Success 200 {object} model.CommonResponse{data=BudgetResponse} "Success"
+// @Router /api/budgets/{id} [get]
+func GetBudget(c *gin.Context) {
+	budget, ok := getBudget(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(newBudgetResponse(budget)))
+}
+
+// @Summary Update a budget
+// @Description Update a budget. A paused agent or channel is resumed at the next check once usage is below the new threshold or the budget is disabled
+// @Tags Budget
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Budget ID"
+// @Param request body BudgetRequest true "Budget"
+// @Success 200 {object} model.CommonResponse{data=BudgetResponse} "Success"
+// @Router /api/budgets/{id} [put]
+func UpdateBudget(c *gin.Context) {
+	budget, ok := getBudget(c)
+	if !ok {
+		return
+	}
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	threshold := budget.Threshold
+	if !applyBudgetRequest(c, budget, &req) {
+		return
+	}
+	if err := model.UpdateBudget(budget); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	// 调整预算后重新按新的预算告警
+	if budget.Threshold != threshold && budget.AlertedLevel > 0 {
+		budget.AlertedLevel = 0
+		if err := model.UpdateBudgetState(budget); err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(newBudgetResponse(budget)))
+}
+
+// @Summary Delete a budget
+// @Description Delete a budget and its alert history. An agent or channel paused by the budget is resumed
+// @Tags Budget
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Budget ID"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Router /api/budgets/{id} [delete]
+func DeleteBudget(c *gin.Context) {
+	budget, ok := getBudget(c)
+	if !ok {
+		return
+	}
+	if err := service.DeleteBudget(budget); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary List budget alerts
+// @Description List the alerts sent for the budgets of the enterprise, newest first
+// @Tags Budget
+// @Produce json
+// @Security BearerAuth
+// @Param budget_id query int false "Budget ID"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=BudgetAlertListResponse} "Success"
+// @Router /api/budgets/alerts [get]
+func GetBudgetAlerts(c *gin.Context) {
+	var req BudgetAlertListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+
+	alerts, count, err := model.GetBudgetAlerts(config.GetEID(c), req.BudgetID, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(BudgetAlertListResponse{
+		Count:  count,
+		Alerts: alerts,
+	}))
+}
+
+// getBudget 获取当前企业的预算，失败时已返回错误
+func getBudget(c *gin.Context) (*model.Budget, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	budget, err := model.GetBudget(config.GetEID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return nil, false
+	}
+	return budget, true
+}
+
+// applyBudgetRequest 校验请求并写入预算配置，失败时已返回错误
+func applyBudgetRequest(c *gin.Context, budget *model.Budget, req *BudgetRequest) bool {
+	if req.AlertLevels == nil {
+		req.AlertLevels = []int{}
+	}
+	if req.NotifyEmails == nil {
+		req.NotifyEmails = []string{}
+	}
+	alertLevels, _ := json.Marshal(req.AlertLevels)
+	notifyEmails, _ := json.Marshal(req.NotifyEmails)
+
+	budget.Name = req.Name
+	budget.ScopeType = req.ScopeType
+	budget.ScopeID = req.ScopeID
+	budget.Metric = req.Metric
+	budget.Period = req.Period
+	budget.Threshold = req.Threshold
+	budget.AlertLevels = string(alertLevels)
+	budget.NotifyEmails = string(notifyEmails)
+	budget.WebhookURL = req.WebhookURL
+	budget.PauseOnExceed = req.PauseOnExceed
+	budget.Enabled = req.Enabled
+
+	if err := service.ValidateBudget(budget); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return false
+	}
+	if err := service.ValidateBudgetScope(budget.Eid, budget.ScopeType, budget.ScopeID); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return false
+	}
+	for _, address := range req.NotifyEmails {
+		if !helper.IsValidEmail(address) {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("invalid email: "+address)))
+			return false
+		}
+	}
+	return true
+}
+
+func newBudgetResponse(budget *model.Budget) *BudgetResponse {
+	return &BudgetResponse{
+		Budget:       budget,
+		AlertLevels:  budget.GetAlertLevels(),
+		NotifyEmails: budget.GetNotifyEmails(),
+	}
+}
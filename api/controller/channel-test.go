@@ -20,6 +20,7 @@ import (
 	"github.com/53AI/53AIHub/model"
 	"github.com/53AI/53AIHub/service"
 	"github.com/53AI/53AIHub/service/hub_adaptor/custom"
+	"github.com/53AI/53AIHub/tasks"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/meta"
@@ -27,6 +28,10 @@ import (
 	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
+func init() {
+	tasks.ChannelProber = probeChannel
+}
+
 type ChannelTestResponse struct {
 	Success bool    `json:"success"`
 	Message string  `json:"message"`
@@ -78,7 +83,20 @@ func TestChannel(c *gin.Context) {
 	}))
 }
 
+// probeChannel 使用测试请求探测渠道，供定时健康检查任务使用
+func probeChannel(ctx context.Context, channel *model.Channel) (time.Duration, error) {
+	tik := time.Now()
+	_, err, _ := testChannel(ctx, channel, buildTestRequest(""))
+	return time.Since(tik), err
+}
+
 func testChannel(ctx context.Context, channel *model.Channel, request *relaymodel.GeneralOpenAIRequest) (responseMessage string, err error, openaiErr *relaymodel.Error) {
+	responseMessage, _, err, openaiErr = completeWithChannel(ctx, channel, request)
+	return responseMessage, err, openaiErr
+}
+
+// completeWithChannel 直接向渠道发送一次非流式对话请求，返回回答和上游用量
+func completeWithChannel(ctx context.Context, channel *model.Channel, request *relaymodel.GeneralOpenAIRequest) (responseMessage string, usage *relaymodel.Usage, err error, openaiErr *relaymodel.Error) {
 	//startTime := time.Now()
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -105,11 +123,11 @@ func testChannel(ctx context.Context, channel *model.Channel, request *relaymode
 		UserId:         "53AIHub",
 	})
 	if err != nil {
-		return "", err, nil
+		return "", nil, err, nil
 	}
 	// adaptor := relay.GetAdaptor(apiType)
 	if adaptor == nil {
-		return "", fmt.Errorf("invalid api type: %d, adaptor is nil", apiType), nil
+		return "", nil, fmt.Errorf("invalid api type: %d, adaptor is nil", apiType), nil
 	}
 	adaptor.Init(meta)
 	modelName := request.Model
@@ -127,11 +145,11 @@ func testChannel(ctx context.Context, channel *model.Channel, request *relaymode
 	request.Model = modelName
 	convertedRequest, err := adaptor.ConvertRequest(c, relaymode.ChatCompletions, request)
 	if err != nil {
-		return "", err, nil
+		return "", nil, err, nil
 	}
 	jsonData, err := json.Marshal(convertedRequest)
 	if err != nil {
-		return "", err, nil
+		return "", nil, err, nil
 	}
 	defer func() {
 		//logContent := fmt.Sprintf("渠道 %s 测试成功，响应：%s", channel.Name, responseMessage)
@@ -156,7 +174,7 @@ func testChannel(ctx context.Context, channel *model.Channel, request *relaymode
 	c.Request.Body = io.NopCloser(requestBody)
 	resp, err := adaptor.DoRequest(c, meta, requestBody)
 	if err != nil {
-		return "", err, nil
+		return "", nil, err, nil
 	}
 	if resp != nil && resp.StatusCode != http.StatusOK {
 		// err := controller.RelayErrorHandler(resp)
@@ -165,29 +183,29 @@ func testChannel(ctx context.Context, channel *model.Channel, request *relaymode
 		// if errorMessage != "" {
 		// 	errorMessage = ", error message: " + errorMessage
 		// }
-		return "", fmt.Errorf("http status code: %d%s", resp.StatusCode, ""), nil
+		return "", nil, fmt.Errorf("http status code: %d%s", resp.StatusCode, ""), nil
 	}
 	usage, respErr := adaptor.DoResponse(c, resp, meta)
 	if respErr != nil {
-		return "", fmt.Errorf("%s", respErr.Error.Message), &respErr.Error
+		return "", nil, fmt.Errorf("%s", respErr.Error.Message), &respErr.Error
 	}
 	if usage == nil {
-		return "", errors.New("usage is nil"), nil
+		return "", nil, errors.New("usage is nil"), nil
 	}
 	rawResponse := w.Body.String()
 	_, responseMessage, err = parseTestResponse(rawResponse)
 	if err != nil {
 		logger.SysError(fmt.Sprintf("failed to parse error: %s, \nresponse: %s", err.Error(), rawResponse))
-		return "", err, nil
+		return "", nil, err, nil
 	}
 	result := w.Result()
 	// print result.Body
 	respBody, err := io.ReadAll(result.Body)
 	if err != nil {
-		return "", err, nil
+		return "", nil, err, nil
 	}
 	logger.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.ChannelID, string(respBody)))
-	return responseMessage, nil, nil
+	return responseMessage, usage, nil, nil
 }
 
 func parseTestResponse(resp string) (*openai.TextResponse, string, error) {
@@ -274,3 +274,24 @@ func GetChannels(c *gin.Context) {
 
 	c.JSON(http.StatusOK, model.Success.ToResponse(channels))
 }
+
+// @Summary Get channel health
+// @Description Get rolling error rate, latency percentiles and circuit breaker state of every channel
+// @Tags Channel
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=[]model.ChannelHealthStats}
+// @Router /api/channels/health [get]
+func GetChannelsHealth(c *gin.Context) {
+	channels, err := model.GetChannelsByEid(config.GetEID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	stats := make([]model.ChannelHealthStats, 0, len(channels))
+	for _, channel := range channels {
+		stats = append(stats, model.GetChannelHealthStats(channel.ChannelID))
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(stats))
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	hub_openai "github.com/53AI/53AIHub/service/hub_adaptor/openai"
+	"github.com/gin-gonic/gin"
+	relay_model "github.com/songquanpeng/one-api/relay/model"
+)
+
+// applyHistoryStrategy 按智能体的会话历史配置处理请求消息：
+// 客户端仅发送最新一轮时从消息记录重建历史，再按策略将历史控制在 token 预算内
+func applyHistoryStrategy(c *gin.Context, chatRequest *ChatRequest, agent *model.Agent) {
+	settings := agent.GetHistorySettings()
+	if settings.Strategy == model.HistoryStrategyNone && !settings.Rebuild {
+		return
+	}
+
+	messages := make([]service.HistoryMessage, 0, len(chatRequest.Messages))
+	for _, m := range chatRequest.Messages {
+		messages = append(messages, service.HistoryMessage{Role: m.Role, Content: m.Content})
+	}
+
+	conversation, _ := GetSessionConversation(c)
+	if settings.Rebuild && conversation != nil && conversation.ConversationID > 0 && !hasAssistantMessage(messages) {
+		messages = rebuildHistory(c.Request.Context(), agent, conversation, settings, messages)
+	}
+
+	if settings.Strategy != model.HistoryStrategyNone {
+		messages = trimHistory(c, agent, conversation, settings, messages)
+	}
+
+	chatRequest.Messages = make([]Message, 0, len(messages))
+	for _, m := range messages {
+		chatRequest.Messages = append(chatRequest.Messages, Message{Role: m.Role, Content: m.Content})
+	}
+}
+
+func hasAssistantMessage(messages []service.HistoryMessage) bool {
+	for _, m := range messages {
+		if m.Role == "assistant" {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildHistory 将会话中已完成的消息记录插入到系统消息和客户端发送的最新一轮之间
+func rebuildHistory(ctx context.Context, agent *model.Agent, conversation *model.Conversation, settings model.HistorySettings, messages []service.HistoryMessage) []service.HistoryMessage {
+	stored, err := model.GetConversationHistoryMessages(agent.Eid, conversation.ConversationID, settings.RebuildLimit)
+	if err != nil {
+		logger.Errorf(ctx, "load conversation history failed: %s", err.Error())
+		return messages
+	}
+	history := service.HistoryFromStoredMessages(stored)
+	if len(history) == 0 {
+		return messages
+	}
+
+	system, turns := service.SplitHistoryTurns(messages)
+	rebuilt := make([]service.HistoryMessage, 0, len(system)+len(history)+len(messages))
+	rebuilt = append(rebuilt, system...)
+	rebuilt = append(rebuilt, history...)
+	rebuilt = append(rebuilt, service.FlattenHistoryTurns(turns)...)
+	logger.Infof(ctx, "rebuilt %d history messages from conversation %d", len(history), conversation.ConversationID)
+	return rebuilt
+}
+
+// trimHistory 按策略裁剪历史，使系统提示词、系统消息和保留的轮次总计不超过 token 预算
+func trimHistory(c *gin.Context, agent *model.Agent, conversation *model.Conversation, settings model.HistorySettings, messages []service.HistoryMessage) []service.HistoryMessage {
+	ctx := c.Request.Context()
+	modelName := agent.Model
+	system, turns := service.SplitHistoryTurns(messages)
+	budget := settings.MaxTokens - service.CountHistoryTokens(system, modelName)
+	if agent.Prompt != "" {
+		budget -= hub_openai.CountTokenText(agent.Prompt, modelName)
+	}
+	// 系统提示词本身已超出预算时只保留必要的轮次，不再生成摘要
+	budget = max(budget, 0)
+	if service.CountHistoryTokens(service.FlattenHistoryTurns(turns), modelName) <= budget {
+		return messages
+	}
+
+	var summary string
+	var keepFirst, keepFrom int
+	switch settings.Strategy {
+	case model.HistoryStrategyFirstTurns:
+		keepFirst, keepFrom = service.SelectHistoryTurns(turns, budget, settings.FirstTurns, modelName)
+	case model.HistoryStrategySummary:
+		// 为摘要预留四分之一的预算
+		summaryTokens := budget / 4
+		keepFirst, keepFrom = service.SelectHistoryTurns(turns, budget-summaryTokens, 0, modelName)
+		if keepFrom > 0 && summaryTokens > 0 {
+			var err error
+			summary, err = getHistorySummary(c, agent, conversation, settings, turns[:keepFrom], summaryTokens)
+			if err != nil {
+				// 摘要失败时退化为滑动窗口
+				logger.Warnf(ctx, "summarize conversation history failed: %s", err.Error())
+			}
+		}
+	default:
+		keepFirst, keepFrom = service.SelectHistoryTurns(turns, budget, 0, modelName)
+	}
+
+	trimmed := make([]service.HistoryMessage, 0, len(messages))
+	trimmed = append(trimmed, system...)
+	if summary != "" {
+		trimmed = append(trimmed, service.HistorySummaryMessage(summary))
+	}
+	trimmed = append(trimmed, service.FlattenHistoryTurns(turns[:keepFirst])...)
+	trimmed = append(trimmed, service.FlattenHistoryTurns(turns[keepFrom:])...)
+	logger.Infof(ctx, "history strategy %s dropped %d of %d turns", settings.Strategy, keepFrom-keepFirst, len(turns))
+	return trimmed
+}
+
+// getHistorySummary 获取覆盖 dropped 全部轮次的滚动摘要。会话已有摘要时只合并其后新移出窗口的轮次，
+// 摘要及其覆盖到的最后一轮的指纹保存在会话上
+func getHistorySummary(c *gin.Context, agent *model.Agent, conversation *model.Conversation, settings model.HistorySettings, dropped []service.HistoryTurn, maxTokens int) (string, error) {
+	if conversation == nil || conversation.ConversationID == 0 {
+		return "", errors.New("summary strategy requires conversation_id")
+	}
+
+	anchor := service.HistoryTurnFingerprint(dropped[len(dropped)-1])
+	if conversation.Summary != "" && conversation.SummaryAnchor == anchor {
+		return conversation.Summary, nil
+	}
+
+	pending := dropped
+	previous := ""
+	if conversation.Summary != "" {
+		previous = conversation.Summary
+		if i := service.FindSummaryAnchor(dropped, conversation.SummaryAnchor); i >= 0 {
+			pending = dropped[i+1:]
+		}
+	}
+
+	summary, err := summarizeHistory(c, agent, settings, previous, pending, maxTokens)
+	if err != nil {
+		return "", err
+	}
+	if err := model.UpdateConversationSummary(agent.Eid, conversation.ConversationID, summary, anchor); err != nil {
+		logger.Errorf(c.Request.Context(), "UpdateConversationSummary failed: %s", err.Error())
+	}
+	// 会话对象在后续流程中可能被整体保存，同步内存中的摘要避免被旧值覆盖
+	conversation.Summary = summary
+	conversation.SummaryAnchor = anchor
+	return summary, nil
+}
+
+// summarizeHistory 调用摘要模型生成摘要，用量与普通对话一样按计价规则扣减配额并记录消息
+func summarizeHistory(c *gin.Context, agent *model.Agent, settings model.HistorySettings, previous string, turns []service.HistoryTurn, maxTokens int) (string, error) {
+	ctx := c.Request.Context()
+	modelName := settings.SummaryModel
+	if modelName == "" {
+		modelName = agent.Model
+	}
+	channel, err := service.GetChannelWithTokenRefresh(ctx, agent.Eid, agent.ChannelType, modelName, nil)
+	if err != nil {
+		return "", err
+	}
+
+	request := &relay_model.GeneralOpenAIRequest{
+		Model:     modelName,
+		MaxTokens: maxTokens,
+	}
+	// 对话记录脱敏后再发送给摘要模型，生成的摘要还原为原值保存，后续随请求发送时再统一脱敏
+	redactor := newUpstreamRedactor(agent)
+	for _, m := range service.BuildHistorySummaryMessages(previous, turns, maxTokens) {
+		request.Messages = append(request.Messages, relay_model.Message{Role: m.Role, Content: redactor.Redact(m.Content)})
+	}
+
+	startTime := time.Now()
+	summary, usage, err, _ := completeWithChannel(ctx, channel, request)
+	if err != nil {
+		return "", err
+	}
+	recordHistorySummaryUsage(c, agent, channel, request, summary, usage, startTime)
+	summary = strings.TrimSpace(redactor.Restore(summary))
+	if summary == "" {
+		return "", errors.New("empty summary")
+	}
+	return summary, nil
+}
+
+// recordHistorySummaryUsage 结算摘要请求的配额和积分，并记录为不关联会话的消息，避免出现在会话历史中
+func recordHistorySummaryUsage(c *gin.Context, agent *model.Agent, channel *model.Channel, request *relay_model.GeneralOpenAIRequest,
+	summary string, usage *relay_model.Usage, startTime time.Time) {
+	ctx := c.Request.Context()
+	userID := config.GetUserId(c)
+	pricing := service.GetModelPricing(agent.Eid, channel.ChannelID, channel.Type, request.Model, config.GetUserGroupID(c))
+	charge := pricing.Charge(usage.PromptTokens, 0, usage.CompletionTokens)
+	service.PostConsumeUserQuota(agent.Eid, userID, service.UserQuotaHold{}, charge.Quota)
+
+	requestJSON, _ := json.Marshal(request.Messages)
+	message := &model.Message{
+		Eid:              agent.Eid,
+		UserID:           userID,
+		AgentID:          agent.AgentID,
+		Message:          string(requestJSON),
+		Answer:           summary,
+		ModelName:        request.Model,
+		Quota:            int(charge.Quota),
+		Cost:             charge.Cost,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.PromptTokens + usage.CompletionTokens,
+		ChannelId:        int(channel.ChannelID),
+		RequestId:        helper.GetRequestID(ctx),
+		ElapsedTime:      helper.CalcElapsedTime(startTime),
+		QuotaContent:     charge.Detail,
+	}
+	if err := model.CreateMessage(message); err != nil {
+		logger.Errorf(ctx, "record history summary usage failed: %s", err.Error())
+	}
+	service.ConsumeUserPoints(agent.Eid, userID, charge.Quota, model.PointsSourceChat, message.ID)
+}
@@ -0,0 +1,320 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultThreadPageSize = 20
+	maxThreadPageSize     = 100
+)
+
+// ThreadCreateRequest 创建会话线程请求
+type ThreadCreateRequest struct {
+	Model string `json:"model" example:"agent-6"`
+	Title string `json:"title"`
+}
+
+// ThreadMessageRequest 向会话线程发送消息，只需携带本轮的用户消息
+type ThreadMessageRequest struct {
+	Content string `json:"content" example:"who are you"`
+	Stream  bool   `json:"stream"`
+}
+
+// ThreadForkRequest 分叉会话线程请求
+type ThreadForkRequest struct {
+	MessageID int64  `json:"message_id"` // 复制到该消息为止（含），为 0 时复制全部消息
+	Title     string `json:"title"`
+}
+
+// ThreadListResponse 会话线程列表
+type ThreadListResponse struct {
+	Count         int64                 `json:"count"`
+	Conversations []*model.Conversation `json:"conversations"`
+}
+
+// ThreadMessagesResponse 会话线程消息列表
+type ThreadMessagesResponse struct {
+	Count    int64            `json:"count"`
+	Messages []*model.Message `json:"messages"`
+}
+
+func threadPagination(c *gin.Context) (offset, limit int) {
+	offset, _ = strconv.Atoi(c.Query("offset"))
+	limit, _ = strconv.Atoi(c.Query("limit"))
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = defaultThreadPageSize
+	}
+	if limit > maxThreadPageSize {
+		limit = maxThreadPageSize
+	}
+	return offset, limit
+}
+
+// threadAPIKeyAllows 限定了智能体范围的 API 密钥只能访问对应智能体的会话
+func threadAPIKeyAllows(c *gin.Context, agentID int64) bool {
+	value, exists := c.Get(session.SESSION_API_KEY)
+	if !exists {
+		return true
+	}
+	apiKey, ok := value.(*model.ApiKey)
+	return !ok || apiKey.AllowAgent(agentID)
+}
+
+// getThreadConversation 获取路径中属于当前用户的会话
+func getThreadConversation(c *gin.Context) (*model.Conversation, bool) {
+	conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	conversation, err := model.GetConversationByIdAndUserId(config.GetEID(c), conversationID, config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("conversation not found")))
+		return nil, false
+	}
+	if !threadAPIKeyAllows(c, conversation.AgentID) {
+		c.JSON(http.StatusForbidden, model.AgentAuthError.ToResponse(errors.New("API key is not allowed to access this agent")))
+		return nil, false
+	}
+	return conversation, true
+}
+
+// @Summary Create conversation thread
+// @Description 创建服务端保存上下文的会话线程，之后通过 /v1/conversations/{conversation_id}/messages 只需发送本轮消息
+// @Tags Conversation Thread
+// @Accept json
+// @Produce json
+// @Param request body ThreadCreateRequest true "ThreadCreateRequest"
+// @Success 200 {object} model.CommonResponse{data=model.Conversation} "Success"
+// @Router /v1/conversations [post]
+// @Security BearerAuth
+func CreateConversationThread(c *gin.Context) {
+	var req ThreadCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	agent, err := GetSessionAgent(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+	if agent.AgentType == model.AgentTypeWorkflow {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("工作流类型的 Agent 不支持会话线程")))
+		return
+	}
+
+	conversation := &model.Conversation{
+		Eid:     agent.Eid,
+		UserID:  config.GetUserId(c),
+		AgentID: agent.AgentID,
+		Title:   req.Title,
+		Status:  model.ConversationStatusActive,
+		Model:   agent.Model,
+	}
+	if err := model.CreateConversation(conversation); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(conversation))
+}
+
+// @Summary List conversation threads
+// @Description 获取当前用户的会话线程，按最近更新时间倒序
+// @Tags Conversation Thread
+// @Produce json
+// @Param agent_id query int false "Agent ID"
+// @Param offset query int false "Offset" default(0)
+// @Param limit query int false "Limit" default(20)
+// @Success 200 {object} model.CommonResponse{data=ThreadListResponse} "Success"
+// @Router /v1/conversations [get]
+// @Security BearerAuth
+func ListConversationThreads(c *gin.Context) {
+	agentID, _ := strconv.ParseInt(c.Query("agent_id"), 10, 64)
+	if value, exists := c.Get(session.SESSION_API_KEY); exists {
+		if apiKey, ok := value.(*model.ApiKey); ok && apiKey.HasAgentScope() {
+			if agentID == 0 || !apiKey.AllowAgent(agentID) {
+				c.JSON(http.StatusForbidden, model.AgentAuthError.ToResponse(errors.New("API key is restricted to specific agents, agent_id is required")))
+				return
+			}
+		}
+	}
+
+	offset, limit := threadPagination(c)
+	conversations, total, err := model.GetUserConversationThreads(config.GetEID(c), config.GetUserId(c), agentID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ThreadListResponse{
+		Count:         total,
+		Conversations: conversations,
+	}))
+}
+
+// @Summary List conversation thread messages
+// @Description 分页获取会话线程中的消息
+// @Tags Conversation Thread
+// @Produce json
+// @Param conversation_id path int true "Conversation ID"
+// @Param offset query int false "Offset" default(0)
+// @Param limit query int false "Limit" default(20)
+// @Param direction query string false "asc or desc" default(desc)
+// @Success 200 {object} model.CommonResponse{data=ThreadMessagesResponse} "Success"
+// @Router /v1/conversations/{conversation_id}/messages [get]
+// @Security BearerAuth
+func ListConversationThreadMessages(c *gin.Context) {
+	conversation, ok := getThreadConversation(c)
+	if !ok {
+		return
+	}
+
+	offset, limit := threadPagination(c)
+	direction := c.DefaultQuery("direction", "desc")
+	count, messages, err := model.GetMessagesByConversationIDWithDirection(conversation.Eid, conversation.ConversationID, "", limit, offset, direction)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ThreadMessagesResponse{
+		Count:    count,
+		Messages: messages,
+	}))
+}
+
+// @Summary Send message to conversation thread
+// @Description 只需发送本轮的用户消息，服务端根据会话中已保存的消息重建上下文（遵循智能体的会话历史配置）后转发，响应格式与 /v1/chat/completions 相同，stream=true 时流式返回
+// @Tags Conversation Thread
+// @Accept json
+// @Produce json,text/event-stream
+// @Param conversation_id path int true "Conversation ID"
+// @Param request body ThreadMessageRequest true "ThreadMessageRequest"
+// @Success 500 {object} model.OpenAIErrorResponse
+// @Router /v1/conversations/{conversation_id}/messages [post]
+// @Security BearerAuth
+func CreateConversationThreadMessage(c *gin.Context) {
+	c.Set(ctxkey.Group, "vip")
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToOpenAIErrorRespone(nil))
+		return
+	}
+	var req ThreadMessageRequest
+	if err := json.Unmarshal(body, &req); err != nil || strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToOpenAIErrorRespone(errors.New("content is required")))
+		return
+	}
+
+	agent, err := GetSessionAgent(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToOpenAIErrorRespone(err))
+		return
+	}
+	if agent.AgentType == model.AgentTypeWorkflow {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToOpenAIErrorRespone(errors.New("工作流类型的 Agent 请使用 /v1/workflow/run 接口")))
+		return
+	}
+	conversation, err := GetSessionConversation(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToOpenAIErrorRespone(err))
+		return
+	}
+
+	// 从消息记录重建上下文，后续由 applyHistoryStrategy 按智能体配置裁剪
+	messages := []service.HistoryMessage{{Role: "user", Content: req.Content}}
+	messages = rebuildHistory(c.Request.Context(), agent, conversation, agent.GetHistorySettings(), messages)
+
+	chatRequest := &ChatRequest{
+		Model:          fmt.Sprintf("agent-%d", agent.AgentID),
+		Stream:         req.Stream,
+		ConversationID: conversation.ConversationID,
+	}
+	for _, m := range messages {
+		chatRequest.Messages = append(chatRequest.Messages, Message{Role: m.Role, Content: m.Content})
+	}
+
+	// 按 /v1/chat/completions 处理，渠道适配器据此构造上游请求地址
+	c.Request.URL.Path = "/v1/chat/completions"
+	c.Request.URL.RawPath = ""
+	processChatRequest(c, chatRequest, agent, relaymode.ChatCompletions)
+}
+
+// @Summary Fork conversation thread
+// @Description 复制会话线程生成新的线程，可指定复制到某条消息为止，用于从历史中的某一轮重新开始。复制的消息不带用量，不重复计费和统计
+// @Tags Conversation Thread
+// @Accept json
+// @Produce json
+// @Param conversation_id path int true "Conversation ID"
+// @Param request body ThreadForkRequest false "ThreadForkRequest"
+// @Success 200 {object} model.CommonResponse{data=model.Conversation} "Success"
+// @Router /v1/conversations/{conversation_id}/fork [post]
+// @Security BearerAuth
+func ForkConversationThread(c *gin.Context) {
+	conversation, ok := getThreadConversation(c)
+	if !ok {
+		return
+	}
+
+	var req ThreadForkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+	if req.Title == "" {
+		req.Title = conversation.Title
+	}
+
+	fork, err := model.ForkConversation(conversation, req.MessageID, req.Title)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("message not found")))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(fork))
+}
+
+// @Summary Delete conversation thread
+// @Description 删除会话线程，消息记录保留用于用量统计
+// @Tags Conversation Thread
+// @Produce json
+// @Param conversation_id path int true "Conversation ID"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Router /v1/conversations/{conversation_id} [delete]
+// @Security BearerAuth
+func DeleteConversationThread(c *gin.Context) {
+	conversation, ok := getThreadConversation(c)
+	if !ok {
+		return
+	}
+
+	if err := model.DeleteConversationThread(conversation.Eid, conversation.UserID, conversation.ConversationID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
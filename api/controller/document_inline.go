@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+// isLLMChannelAgent 是否直接对接大模型渠道的应用型智能体；Dify、Coze 等平台渠道自行处理文件，不做内联
+func isLLMChannelAgent(agent *model.Agent) bool {
+	if agent.AgentType != model.AgentTypeApp || agent.ChannelType >= model.ChannelApiDify {
+		return false
+	}
+	return agent.ChannelType != channeltype.Coze && agent.ChannelType != channeltype.FastGPT
+}
+
+// inlineChatDocuments 将用户消息中附带的文档替换为提取出的文本，从最新的消息开始按 token 预算内联，超出部分截断。
+// 大模型渠道无法直接读取文档，不内联时文件会被丢弃。
+// 内联的文本与用户输入一样经过审核规则，拦截时写出错误响应并返回 false
+func inlineChatDocuments(c *gin.Context, chatRequest *ChatRequest, agent *model.Agent) bool {
+	if !isLLMChannelAgent(agent) {
+		return true
+	}
+	settings := agent.GetDocumentParsingSettings()
+	if settings.Disabled {
+		return true
+	}
+
+	ctx := c.Request.Context()
+	userID := config.GetUserId(c)
+	isAdmin := common.IsAdmin(c)
+	budget := settings.MaxTokens
+	moderation := newInputModeration(c, agent)
+	for i := len(chatRequest.Messages) - 1; i >= 0; i-- {
+		message := &chatRequest.Messages[i]
+		if message.Role != "user" || !strings.HasPrefix(strings.TrimSpace(message.Content), "[") {
+			continue
+		}
+		var parts []model.ObjectStringContent
+		if json.Unmarshal([]byte(message.Content), &parts) != nil {
+			continue
+		}
+
+		changed := false
+		for j := range parts {
+			uploadFile := parts[j].GetUploadFile()
+			if uploadFile == nil || uploadFile.Eid != agent.Eid || !service.IsParsableDocument(uploadFile) {
+				continue
+			}
+			// 只能读取自己上传的文件，管理员除外
+			if uploadFile.UserID != userID && !isAdmin {
+				logger.Warnf(ctx, "user %d is not allowed to read file %d", userID, uploadFile.ID)
+				continue
+			}
+			text, err := service.ExtractUploadFileText(uploadFile)
+			if err != nil {
+				logger.Warnf(ctx, "extract text from file %d failed: %s", uploadFile.ID, err.Error())
+			}
+			content := formatInlineDocument(uploadFile.FileName, text, err, &budget, chatRequest.Model)
+			content, ok := moderation.check(content)
+			if !ok {
+				return moderation.finish()
+			}
+			parts[j] = model.ObjectStringContent{
+				Type:    "text",
+				Content: content,
+			}
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		if data, err := json.Marshal(parts); err == nil {
+			message.Content = string(data)
+		}
+	}
+	return moderation.finish()
+}
+
+// formatInlineDocument 生成内联到消息中的文档文本，并从剩余预算中扣除
+func formatInlineDocument(fileName, text string, extractErr error, budget *int, modelName string) string {
+	switch {
+	case extractErr != nil:
+		return fmt.Sprintf("（文件《%s》无法读取：%s）", fileName, extractErr.Error())
+	case strings.TrimSpace(text) == "":
+		return fmt.Sprintf("（文件《%s》中没有文本内容）", fileName)
+	case *budget <= 0:
+		return fmt.Sprintf("（文件《%s》超出本次对话可读取的长度，未能提供内容）", fileName)
+	}
+
+	content, truncated := service.TruncateTextByTokens(text, *budget, modelName)
+	*budget -= openai.CountTokenText(content, modelName)
+	result := fmt.Sprintf("以下是文件《%s》的内容：\n%s", fileName, content)
+	if truncated {
+		result += "\n（文件内容过长，以上仅为前半部分）"
+	}
+	return result
+}
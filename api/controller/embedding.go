@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/middleware"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/controller"
+	relay_model "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// maxEmbeddingInputs 单次请求允许的最大输入条数
+const maxEmbeddingInputs = 2048
+
+// EmbeddingRequest represents the request structure for embeddings API
+type EmbeddingRequest struct {
+	Model          string `json:"model" example:"text-embedding-v3" binding:"required"`                    // Embedding model name
+	Input          any    `json:"input" swaggertype:"array,string" example:"人工智能,机器学习" binding:"required"` // A string or an array of strings
+	EncodingFormat string `json:"encoding_format,omitempty" example:"float"`                               // float or base64
+	Dimensions     int    `json:"dimensions,omitempty" example:"1024"`                                     // Output dimensions, if supported by the model
+	User           string `json:"user,omitempty"`                                                          // End user identifier
+}
+
+// @Summary Embeddings
+// @Description Create embedding vectors for one or a batch of inputs using Embedding-type channels
+// @Tags Embedding
+// @Accept json
+// @Produce json
+// @Param embeddingRequest body EmbeddingRequest true "Embedding request with model and input"
+// @Success 200 {object} openai.EmbeddingResponse "Successful embedding response"
+// @Failure 400 {object} model.OpenAIErrorResponse "Bad request - invalid parameters"
+// @Failure 401 {object} model.OpenAIErrorResponse "Unauthorized - invalid API key"
+// @Failure 500 {object} model.OpenAIErrorResponse "Internal server error"
+// @Router /v1/embeddings [post]
+// @Security BearerAuth
+func Embeddings(c *gin.Context) {
+	ctx := c.Request.Context()
+	startTime := time.Now()
+
+	var embeddingRequest EmbeddingRequest
+	if err := c.ShouldBindJSON(&embeddingRequest); err != nil {
+		logger.Errorf(ctx, "解析 embedding 请求失败: %v", err)
+		c.JSON(http.StatusBadRequest, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: "请求参数格式错误: " + err.Error(),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	textRequest := &relay_model.GeneralOpenAIRequest{
+		Model:          embeddingRequest.Model,
+		Input:          embeddingRequest.Input,
+		EncodingFormat: embeddingRequest.EncodingFormat,
+		Dimensions:     embeddingRequest.Dimensions,
+		User:           embeddingRequest.User,
+	}
+	inputs := textRequest.ParseInput()
+	if err := validateEmbeddingInputs(inputs); err != nil {
+		c.JSON(http.StatusBadRequest, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: err.Error(),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	userId := config.GetUserId(c)
+	eid := config.GetEID(c)
+	logger.SysLogf("🚀 Embedding请求开始 - 模型: %s, 输入条数: %d, UserID: %d", embeddingRequest.Model, len(inputs), userId)
+
+	// 获取可用的 Embedding 渠道
+	channel, err := model.GetRandomChannelByModelType(eid, model.ModelTypeEmbedding, embeddingRequest.Model)
+	if err != nil {
+		logger.Errorf(ctx, "❌ 获取 embedding 渠道失败: %v", err)
+		c.JSON(http.StatusServiceUnavailable, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: fmt.Sprintf("暂无可用的 embedding 服务渠道: %s", embeddingRequest.Model),
+				Type:    "service_unavailable",
+			},
+		})
+		return
+	}
+
+	// 按输入 token 预扣配额
+	promptTokens := openai.CountTokenInput(textRequest.Input, textRequest.Model)
+	pricing := service.GetModelPricing(eid, channel.ChannelID, channel.Type, embeddingRequest.Model, config.GetUserGroupID(c))
+	quotaHold, err := service.PreConsumeUserQuota(eid, userId, pricing.Charge(promptTokens, 0, 0).Quota)
+	if err != nil {
+		bizErr := quotaExceededError()
+		c.JSON(bizErr.StatusCode, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: bizErr.Message,
+				Type:    bizErr.Type,
+			},
+		})
+		return
+	}
+
+	middleware.SetupContextForSelectedChannel(c, channel, embeddingRequest.Model)
+
+	usage, bizErr := relayEmbedding(c, textRequest, promptTokens)
+	if bizErr != nil {
+		returnPreConsumedQuota(eid, userId, quotaHold)
+		go processChannelRelayError(ctx, int(userId), int(channel.ChannelID), channel.Name, *bizErr)
+		statusCode := bizErr.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusInternalServerError
+		}
+		c.JSON(statusCode, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: bizErr.Message,
+				Type:    bizErr.Type,
+			},
+		})
+		return
+	}
+
+	model.RecordChannelResult(channel.ChannelID, true, time.Since(startTime))
+	logger.SysLogf("✅ Embedding请求成功 - ChannelID: %d, Token使用: %d, 耗时: %dms",
+		channel.ChannelID, usage.TotalTokens, helper.CalcElapsedTime(startTime))
+
+	go recordEmbeddingUsage(ctx, userId, eid, &embeddingRequest, len(inputs), usage, channel, pricing, startTime, quotaHold)
+}
+
+// validateEmbeddingInputs 验证 embedding 输入
+func validateEmbeddingInputs(inputs []string) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("input 参数不能为空")
+	}
+	if len(inputs) > maxEmbeddingInputs {
+		return fmt.Errorf("input 数量不能超过 %d", maxEmbeddingInputs)
+	}
+	for i, input := range inputs {
+		if input == "" {
+			return fmt.Errorf("input[%d] 不能为空字符串", i)
+		}
+	}
+	return nil
+}
+
+// relayEmbedding 通过渠道适配器转发 embedding 请求，响应直接写回客户端
+func relayEmbedding(c *gin.Context, textRequest *relay_model.GeneralOpenAIRequest, promptTokens int) (*relay_model.Usage, *relay_model.ErrorWithStatusCode) {
+	meta := GetByContext(c)
+	meta.Mode = relaymode.Embeddings
+	meta.ChannelId = int(c.GetInt64(ctxkey.ChannelId))
+	meta.APIType = model.GetApiType(meta.ChannelType)
+	meta.OriginModelName = textRequest.Model
+	textRequest.Model, _ = getMappedModelName(textRequest.Model, meta.ModelMapping)
+	meta.ActualModelName = textRequest.Model
+	meta.PromptTokens = promptTokens
+
+	adaptor := service.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		return nil, openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
+
+	convertedRequest, err := adaptor.ConvertRequest(c, relaymode.Embeddings, textRequest)
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+
+	resp, err := adaptor.DoRequest(c, meta, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		return nil, controller.RelayErrorHandler(resp)
+	}
+
+	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	if respErr != nil {
+		return nil, respErr
+	}
+	if usage == nil {
+		usage = &relay_model.Usage{PromptTokens: promptTokens, TotalTokens: promptTokens}
+	}
+	return usage, nil
+}
+
+// recordEmbeddingUsage 记录 embedding 使用情况，并按实际用量结算预扣的配额
+func recordEmbeddingUsage(ctx context.Context, userId, eid int64, req *EmbeddingRequest, inputCount int, usage *relay_model.Usage, channel *model.Channel, pricing *service.ModelPricing, startTime time.Time, quotaHold service.UserQuotaHold) {
+	charge := pricing.Charge(usage.PromptTokens, 0, 0)
+	quota := charge.Quota
+	service.PostConsumeUserQuota(eid, userId, quotaHold, quota)
+
+	// 不保存向量结果，只记录请求内容和条数
+	requestJSON, _ := json.Marshal(req)
+
+	requestId := helper.GetRequestID(ctx)
+	if requestId == "" {
+		requestId = fmt.Sprintf("embedding_%d_%d", userId, time.Now().UnixNano())
+	}
+
+	message := &model.Message{
+		Eid:              eid,
+		UserID:           userId,
+		ConversationID:   0, // embedding 不关联会话
+		AgentID:          0, // embedding 不关联 agent
+		Message:          string(requestJSON),
+		Answer:           fmt.Sprintf("{\"object\":\"list\",\"count\":%d}", inputCount),
+		ModelName:        req.Model,
+		Quota:            int(quota),
+		Cost:             charge.Cost,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		ChannelId:        int(channel.ChannelID),
+		RequestId:        requestId,
+		ElapsedTime:      helper.CalcElapsedTime(startTime),
+		IsStream:         false,
+		QuotaContent:     charge.Detail,
+	}
+
+	err := model.CreateMessage(message)
+	service.ConsumeUserPoints(eid, userId, quota, model.PointsSourceEmbedding, message.ID)
+	if err != nil {
+		logger.SysErrorf("❌ 记录 embedding 使用情况失败: %v", err)
+	} else {
+		logger.SysLogf("✅ Embedding使用记录保存成功 - 消息ID: %d, 用户ID: %d, Token: %d, 配额: %d",
+			message.ID, userId, usage.TotalTokens, quota)
+	}
+}
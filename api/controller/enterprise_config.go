@@ -54,7 +54,7 @@ func GetEnterpriseConfigTypes(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param type path string true "配置类型: smtp, auth_sso"
+// @Param type path string true "配置类型: smtp, auth_sso, rate_limit, moderation, pricing"
 // @Success 200 {object} model.CommonResponse{data=model.EnterpriseConfig}
 // @Router /api/enterprise-configs/{type} [get]
 func GetEnterpriseConfig(c *gin.Context) {
@@ -88,7 +88,7 @@ func GetEnterpriseConfig(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param type path string true "配置类型: smtp, auth_sso"
+// @Param type path string true "配置类型: smtp, auth_sso, rate_limit, moderation, pricing"
 // @Success 200 {object} model.CommonResponse{data=bool}
 // @Router /api/enterprise-configs/{type}/enabled [get]
 func IsEnterpriseConfigEnabled(c *gin.Context) {
@@ -121,7 +121,7 @@ type SaveEnterpriseConfigRequest struct {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param type path string true "配置类型: smtp, auth_sso"
+// @Param type path string true "配置类型: smtp, auth_sso, rate_limit, moderation, pricing"
 // @Param config body SaveEnterpriseConfigRequest true "企业配置"
 // @Success 200 {object} model.CommonResponse{data=model.EnterpriseConfig}
 // @Router /api/enterprise-configs/{type} [post]
@@ -135,6 +135,24 @@ func SaveEnterpriseConfig(c *gin.Context) {
 		return
 	}
 
+	switch configType {
+	case model.EnterpriseConfigTypeRateLimit:
+		if _, err := service.ParseRateLimitConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	case model.EnterpriseConfigTypeModeration:
+		if _, err := service.ParseModerationConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	case model.EnterpriseConfigTypePricing:
+		if _, err := service.ParsePricingConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+
 	config, err := service.SaveEnterpriseConfig(eid, configType, req.Content, req.Enabled)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
@@ -151,7 +169,7 @@ func SaveEnterpriseConfig(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param type path string true "配置类型: smtp, auth_sso"
+// @Param type path string true "配置类型: smtp, auth_sso, rate_limit, moderation, pricing"
 // @Success 200 {object} model.CommonResponse{data=bool}
 // @Router /api/enterprise-configs/{type}/toggle [put]
 func ToggleEnterpriseConfig(c *gin.Context) {
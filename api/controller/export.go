@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/common/utils/spreadsheet"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateExportJobRequest struct {
+	Type          string  `json:"type" binding:"required" example:"messages"` // messages、conversations、orders 或 usage
+	Format        string  `json:"format" binding:"required" example:"xlsx"`   // csv 或 xlsx
+	StartDate     string  `json:"start_date" example:"2026-10-01"`            // 企业时区下的开始日期（含）
+	EndDate       string  `json:"end_date" example:"2026-10-18"`              // 企业时区下的结束日期（含）
+	AgentID       int64   `json:"agent_id"`                                   // 订单导出忽略该条件
+	UserID        int64   `json:"user_id"`
+	DepartmentIDs []int64 `json:"department_ids"`          // 只导出这些部门成员的数据
+	Interval      string  `json:"interval" example:"day"`  // 用量导出的时间粒度：hour、day、month 或 total
+	GroupBy       string  `json:"group_by" example:"user"` // 用量导出的分组维度，与用量统计接口一致
+}
+
+type ExportJobListRequest struct {
+	Offset int `form:"offset" default:"0"`
+	Limit  int `form:"limit" default:"10"`
+}
+
+// ExportJobResponse 导出任务，progress 为 0-100 的百分比，任务成功后返回限时下载链接
+type ExportJobResponse struct {
+	*model.ExportJob
+	Filters         model.ExportFilters `json:"filters"`
+	Progress        float64             `json:"progress"`
+	DownloadURL     string              `json:"download_url"`
+	DownloadExpires int64               `json:"download_expires"` // 下载链接过期时间，秒级时间戳
+}
+
+type ExportJobListResponse struct {
+	Count int64                `json:"count"`
+	Jobs  []*ExportJobResponse `json:"jobs"`
+}
+
+// @Summary Create an export job
+// @Description Export messages, conversations, orders or usage aggregates matching the filters to a CSV or XLSX file in the background. Poll the job for progress; a time-limited download link is returned once it succeeds
+// @Tags Export
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateExportJobRequest true "Export job"
+// @Success 200 {object} model.CommonResponse{data=ExportJobResponse} "Success"
+// @Router /api/exports [post]
+func CreateExportJob(c *gin.Context) {
+	var req CreateExportJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if !model.ValidExportType(req.Type) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("invalid type")))
+		return
+	}
+	if !spreadsheet.Supported(req.Format) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("invalid format")))
+		return
+	}
+	if req.Type == model.ExportTypeUsage {
+		if err := validateUsageExportOptions(req.Interval, req.GroupBy); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+
+	eid := config.GetEID(c)
+	start, end, err := parseUsageDateRange(req.StartDate, req.EndDate, model.GetEnterpriseLocation(eid))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	filters, err := json.Marshal(model.ExportFilters{
+		StartTime:     start.UnixMilli(),
+		EndTime:       end.UnixMilli(),
+		AgentID:       req.AgentID,
+		UserID:        req.UserID,
+		DepartmentIDs: req.DepartmentIDs,
+		Interval:      req.Interval,
+		GroupBy:       req.GroupBy,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	secret, err := model.GenerateExportDownloadSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+
+	job := &model.ExportJob{
+		Eid:            eid,
+		UserID:         config.GetUserId(c),
+		Type:           req.Type,
+		Format:         req.Format,
+		Filters:        string(filters),
+		Status:         model.ExportJobStatusPending,
+		DownloadSecret: secret,
+	}
+	if err := model.CreateExportJob(job); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	if err := service.EnqueueExportJob(job); err != nil {
+		_ = model.DeleteExportJob(eid, job.ID)
+		c.JSON(http.StatusServiceUnavailable, model.OperateTooFast.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(newExportJobResponse(job)))
+}
+
+// @Summary List export jobs
+// @Description List export jobs of the enterprise, newest first
+// @Tags Export
+// @Produce json
+// @Security BearerAuth
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=ExportJobListResponse} "Success"
+// @Router /api/exports [get]
+func GetExportJobs(c *gin.Context) {
+	var req ExportJobListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+
+	jobs, count, err := model.GetExportJobs(config.GetEID(c), req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	items := make([]*ExportJobResponse, len(jobs))
+	for i, job := range jobs {
+		items[i] = newExportJobResponse(job)
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ExportJobListResponse{
+		Count: count,
+		Jobs:  items,
+	}))
+}
+
+// @Summary Get an export job
+// @Description Poll the status and progress of an export job. A fresh time-limited download link is returned each time once the job succeeds
+// @Tags Export
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Export job ID"
+// @Success 200 {object} model.CommonResponse{data=ExportJobResponse} "Success"
+// @Router /api/exports/{id} [get]
+func GetExportJob(c *gin.Context) {
+	job, ok := getExportJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(newExportJobResponse(job)))
+}
+
+// @Summary Delete an export job
+// @Description Delete an export job and its exported file. A running job is discarded when it finishes
+// @Tags Export
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Export job ID"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Router /api/exports/{id} [delete]
+func DeleteExportJob(c *gin.Context) {
+	job, ok := getExportJob(c)
+	if !ok {
+		return
+	}
+	if err := service.DeleteExportFile(job); err != nil {
+		c.JSON(http.StatusInternalServerError, model.FileError.ToResponse(err))
+		return
+	}
+	if err := model.DeleteExportJob(job.Eid, job.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary Download an exported file
+// @Description Download the file of a succeeded export job with the signed link returned by the job query. No login is required; the link expires after a short time
+// @Tags Export
+// @Produce octet-stream
+// @Param id path int true "Export job ID"
+// @Param expires query int64 true "Link expiration (timestamp in seconds)"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} file "Exported file"
+// @Router /api/exports/{id}/download [get]
+func DownloadExportFile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+
+	job, err := model.GetExportJobByID(id)
+	if err != nil || !service.VerifyExportDownload(job, expires, c.Query("signature")) {
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(errors.New("download link is invalid or expired")))
+		return
+	}
+	if job.Status != model.ExportJobStatusSucceeded || job.FileKey == "" {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("export file not found")))
+		return
+	}
+
+	fileContent, err := storage.StorageInstance.Load(job.FileKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.FileError.ToResponse(err))
+		return
+	}
+
+	contentType := spreadsheet.ContentType(job.Format)
+	c.Header("Content-Disposition", `attachment; filename="`+job.FileName+`"; filename*=UTF-8''`+url.QueryEscape(job.FileName))
+	c.Header("Content-Length", fmt.Sprintf("%d", len(fileContent)))
+	c.Data(http.StatusOK, contentType, fileContent)
+}
+
+// getExportJob 获取当前企业的导出任务，失败时已返回错误
+func getExportJob(c *gin.Context) (*model.ExportJob, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	job, err := model.GetExportJob(config.GetEID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return nil, false
+	}
+	return job, true
+}
+
+func newExportJobResponse(job *model.ExportJob) *ExportJobResponse {
+	response := &ExportJobResponse{ExportJob: job}
+	response.Filters, _ = job.GetFilters()
+	switch {
+	case job.Status == model.ExportJobStatusSucceeded:
+		response.Progress = 100
+	case job.TotalRows > 0:
+		response.Progress = float64(job.ProcessedRows) * 100 / float64(job.TotalRows)
+	}
+	response.DownloadURL, response.DownloadExpires = service.GetExportDownloadURL(job)
+	return response
+}
+
+// validateUsageExportOptions 校验用量导出的时间粒度和分组维度
+func validateUsageExportOptions(interval, groupBy string) error {
+	switch interval {
+	case "", service.UsageIntervalHour, service.UsageIntervalDay, service.UsageIntervalMonth, service.UsageIntervalTotal:
+	default:
+		return errors.New("invalid interval")
+	}
+	switch groupBy {
+	case "", service.UsageGroupByAgent, service.UsageGroupByUser, service.UsageGroupByDepartment,
+		service.UsageGroupByUserGroup, service.UsageGroupByModel, service.UsageGroupByChannel:
+	default:
+		return errors.New("invalid group_by")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+// 启用重排序时先召回 topK 的倍数，再由 rerank 模型选出 topK
+const knowledgeRerankCandidateFactor = 3
+
+// KnowledgeCitation 回答引用的知识库片段，index 与提示词中的 [编号] 对应
+type KnowledgeCitation struct {
+	Index int `json:"index"`
+	service.KnowledgeHit
+}
+
+// retrieveAgentKnowledge 使用最后一条用户消息检索智能体关联的知识库，命中的片段追加到本次请求的提示词中，引用写入上下文。
+// 检索失败不影响对话，只记录日志
+func retrieveAgentKnowledge(c *gin.Context, chatRequest *ChatRequest, agent *model.Agent) {
+	settings := agent.GetKnowledgeBaseSettings()
+	if len(settings.KnowledgeBaseIDs) == 0 {
+		return
+	}
+	// 检索词会发送给向量和重排模型，按智能体配置先脱敏
+	query := newUpstreamRedactor(agent).Redact(lastUserQuery(chatRequest.Messages))
+	if query == "" {
+		return
+	}
+
+	ctx := c.Request.Context()
+	rerankModel := settings.Rerank.Model
+	var rerankChannel *model.Channel
+	candidates := settings.TopK
+	if settings.Rerank.Enabled {
+		channel, err := model.GetRandomChannel(agent.Eid, model.ChannelApiBailian, rerankModel)
+		if err != nil {
+			logger.Warnf(ctx, "no rerank channel for knowledge retrieval: %s", err.Error())
+		} else {
+			rerankChannel = channel
+			candidates = settings.TopK * knowledgeRerankCandidateFactor
+		}
+	}
+
+	hits, err := service.SearchKnowledgeBases(ctx, agent.Eid, settings.KnowledgeBaseIDs, query, candidates, settings.ScoreThreshold, embedKnowledgeTexts)
+	if err != nil {
+		logger.Errorf(ctx, "SearchKnowledgeBases failed: %s", err.Error())
+		return
+	}
+	if rerankChannel != nil && len(hits) > 0 {
+		reranked, err := service.RerankKnowledgeHits(ctx, rerankChannel, rerankModel, query, hits, settings.TopK)
+		if err != nil {
+			logger.Errorf(ctx, "RerankKnowledgeHits failed: %s", err.Error())
+		} else {
+			hits = reranked
+		}
+	}
+	if len(hits) > settings.TopK {
+		hits = hits[:settings.TopK]
+	}
+	if len(hits) == 0 {
+		return
+	}
+
+	citations := make([]KnowledgeCitation, len(hits))
+	for i, hit := range hits {
+		citations[i] = KnowledgeCitation{Index: i + 1, KnowledgeHit: hit}
+	}
+	agent.Prompt = appendKnowledgePrompt(agent.Prompt, citations)
+	c.Set(ctxkey.RelayCitations, citations)
+	logger.Infof(ctx, "retrieved %d knowledge chunks for agent %d", len(citations), agent.AgentID)
+}
+
+// lastUserQuery 取最后一条用户消息的文本，object_string 格式的多模态消息只取 text 部分
+func lastUserQuery(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		content := strings.TrimSpace(messages[i].Content)
+		var parts []struct {
+			Type    string `json:"type"`
+			Content string `json:"content"`
+		}
+		if strings.HasPrefix(content, "[") && json.Unmarshal([]byte(content), &parts) == nil {
+			var texts []string
+			for _, part := range parts {
+				if part.Type == "text" && part.Content != "" {
+					texts = append(texts, part.Content)
+				}
+			}
+			return strings.Join(texts, "\n")
+		}
+		return content
+	}
+	return ""
+}
+
+func appendKnowledgePrompt(prompt string, citations []KnowledgeCitation) string {
+	var b strings.Builder
+	if prompt != "" {
+		b.WriteString(prompt)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("以下是从知识库中检索到的参考资料。请优先依据参考资料回答，并在引用处用 [编号] 标注来源；参考资料与问题无关时忽略即可。\n")
+	for _, citation := range citations {
+		b.WriteString(fmt.Sprintf("\n[%d] 《%s》\n%s\n", citation.Index, citation.FileName, citation.Content))
+	}
+	return b.String()
+}
+
+// getKnowledgeCitations 本次请求的知识库引用，没有时返回 nil
+func getKnowledgeCitations(c *gin.Context) []KnowledgeCitation {
+	if value, ok := c.Get(ctxkey.RelayCitations); ok {
+		citations, _ := value.([]KnowledgeCitation)
+		return citations
+	}
+	return nil
+}
+
+// saveKnowledgeCitations 将引用记录到消息中
+func saveKnowledgeCitations(c *gin.Context, eid, messageID int64) {
+	citations := getKnowledgeCitations(c)
+	if len(citations) == 0 {
+		return
+	}
+	if err := model.UpdateMessageCitations(eid, messageID, citations); err != nil {
+		logger.Errorf(c.Request.Context(), "UpdateMessageCitations failed: %s", err.Error())
+	}
+}
+
+// citationWriter 在非流式回答的 JSON 中追加 citations 字段；流式回答的引用由首帧携带
+type citationWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	citations []KnowledgeCitation
+
+	status   int
+	body     bytes.Buffer
+	finished bool
+}
+
+// newCitationWriter 没有引用或流式响应时返回 nil，返回值的方法均可在 nil 上调用
+func newCitationWriter(c *gin.Context, isStream bool) *citationWriter {
+	citations := getKnowledgeCitations(c)
+	if isStream || len(citations) == 0 {
+		return nil
+	}
+	w := &citationWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		citations:      citations,
+		status:         http.StatusOK,
+	}
+	c.Writer = w
+	return w
+}
+
+func (w *citationWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+}
+
+func (w *citationWriter) WriteHeaderNow() {}
+
+func (w *citationWriter) Written() bool {
+	return false
+}
+
+func (w *citationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *citationWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *citationWriter) Flush() {}
+
+// finish 恢复原始 Writer 并写出追加引用后的回答，可重复调用
+func (w *citationWriter) finish() {
+	if w == nil || w.finished {
+		return
+	}
+	w.finished = true
+	w.c.Writer = w.ResponseWriter
+
+	if w.body.Len() == 0 {
+		return
+	}
+	body := w.body.Bytes()
+	var response map[string]json.RawMessage
+	if w.status == http.StatusOK && json.Unmarshal(body, &response) == nil {
+		if citations, err := json.Marshal(w.citations); err == nil {
+			response["citations"] = citations
+			if data, err := json.Marshal(response); err == nil {
+				body = data
+			}
+		}
+	}
+	w.ResponseWriter.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,498 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/middleware"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relay_model "github.com/songquanpeng/one-api/relay/model"
+)
+
+// KnowledgeBaseRequest 创建或更新知识库的请求参数
+type KnowledgeBaseRequest struct {
+	Name           string `json:"name" binding:"required" example:"产品手册"`      // 知识库名称
+	Description    string `json:"description" example:""`                      // 描述
+	EmbeddingModel string `json:"embedding_model" example:"text-embedding-v3"` // 向量模型，创建时必填，创建后不可修改
+	ChunkSize      int    `json:"chunk_size" example:"500"`                    // 切片长度（字符），创建后不可修改
+	ChunkOverlap   int    `json:"chunk_overlap" example:"50"`                  // 相邻切片重叠长度（字符），创建后不可修改
+}
+
+// KnowledgeBaseListRequest 获取知识库或文档列表的请求参数
+type KnowledgeBaseListRequest struct {
+	Keyword string `form:"keyword" json:"keyword"`
+	Offset  int    `form:"offset" json:"offset"`
+	Limit   int    `form:"limit" json:"limit"`
+}
+
+// KnowledgeBasesResponse 知识库列表响应
+type KnowledgeBasesResponse struct {
+	Count          int64                  `json:"count"`
+	KnowledgeBases []*model.KnowledgeBase `json:"knowledge_bases"`
+}
+
+// KnowledgeDocumentsResponse 文档列表响应
+type KnowledgeDocumentsResponse struct {
+	Count     int64                      `json:"count"`
+	Documents []*model.KnowledgeDocument `json:"documents"`
+}
+
+// AddKnowledgeDocumentsRequest 添加文档的请求参数
+type AddKnowledgeDocumentsRequest struct {
+	FileIDs []int64 `json:"file_ids" binding:"required"` // 已上传文件的ID
+}
+
+// KnowledgeRetrieveRequest 检索测试的请求参数
+type KnowledgeRetrieveRequest struct {
+	Query          string  `json:"query" binding:"required" example:"如何重置密码"` // 查询内容
+	TopK           int     `json:"top_k" example:"5"`                         // 返回条数
+	ScoreThreshold float64 `json:"score_threshold" example:"0.3"`             // 相似度阈值
+}
+
+// GetKnowledgeBases 获取知识库列表
+// @Summary 获取知识库列表
+// @Description 获取企业下的内置知识库列表
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param keyword query string false "名称关键词"
+// @Param offset query int false "分页偏移量"
+// @Param limit query int false "分页大小" default(10)
+// @Success 200 {object} model.CommonResponse{data=KnowledgeBasesResponse} "成功"
+// @Router /api/knowledge_bases [get]
+func GetKnowledgeBases(c *gin.Context) {
+	var req KnowledgeBaseListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	count, kbs, err := model.GetKnowledgeBaseList(config.GetEID(c), req.Keyword, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(KnowledgeBasesResponse{
+		Count:          count,
+		KnowledgeBases: kbs,
+	}))
+}
+
+// GetKnowledgeBase 获取知识库详情
+// @Summary 获取知识库详情
+// @Description 获取知识库详情
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeBase} "成功"
+// @Router /api/knowledge_bases/{id} [get]
+func GetKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(kb))
+}
+
+// CreateKnowledgeBase 创建知识库
+// @Summary 创建知识库
+// @Description 创建内置知识库，向量模型需有可用的 Embedding 渠道
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body KnowledgeBaseRequest true "知识库信息"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeBase} "成功"
+// @Router /api/knowledge_bases [post]
+func CreateKnowledgeBase(c *gin.Context) {
+	var req KnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	if req.EmbeddingModel == "" {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("embedding_model is required")))
+		return
+	}
+	if _, err := model.GetRandomChannelByModelType(eid, model.ModelTypeEmbedding, req.EmbeddingModel); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(fmt.Errorf("no embedding channel for model: %s", req.EmbeddingModel)))
+		return
+	}
+	if req.ChunkSize < 0 || req.ChunkOverlap < 0 || (req.ChunkSize > 0 && req.ChunkOverlap >= req.ChunkSize) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("chunk_overlap must be less than chunk_size")))
+		return
+	}
+
+	kb := &model.KnowledgeBase{
+		Eid:            eid,
+		Name:           req.Name,
+		Description:    req.Description,
+		EmbeddingModel: req.EmbeddingModel,
+		ChunkSize:      req.ChunkSize,
+		ChunkOverlap:   req.ChunkOverlap,
+		CreatedBy:      config.GetUserId(c),
+	}
+	if err := kb.Create(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(kb))
+}
+
+// UpdateKnowledgeBase 更新知识库
+// @Summary 更新知识库
+// @Description 更新知识库名称和描述，向量模型和切片参数创建后不可修改
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param request body KnowledgeBaseRequest true "知识库信息"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeBase} "成功"
+// @Router /api/knowledge_bases/{id} [put]
+func UpdateKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseParam(c)
+	if !ok {
+		return
+	}
+
+	var req KnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	kb.Name = req.Name
+	kb.Description = req.Description
+	if err := kb.Update(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(kb))
+}
+
+// DeleteKnowledgeBase 删除知识库
+// @Summary 删除知识库
+// @Description 删除知识库及其全部文档和切片
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/knowledge_bases/{id} [delete]
+func DeleteKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseParam(c)
+	if !ok {
+		return
+	}
+
+	if err := kb.Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	service.InvalidateKnowledgeIndex(kb.ID)
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// GetKnowledgeDocuments 获取知识库文档列表
+// @Summary 获取知识库文档列表
+// @Description 获取知识库中的文档及其处理状态
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param keyword query string false "文件名关键词"
+// @Param offset query int false "分页偏移量"
+// @Param limit query int false "分页大小" default(10)
+// @Success 200 {object} model.CommonResponse{data=KnowledgeDocumentsResponse} "成功"
+// @Router /api/knowledge_bases/{id}/documents [get]
+func GetKnowledgeDocuments(c *gin.Context) {
+	kb, ok := getKnowledgeBaseParam(c)
+	if !ok {
+		return
+	}
+
+	var req KnowledgeBaseListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	count, docs, err := model.GetKnowledgeDocumentList(kb.Eid, kb.ID, req.Keyword, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(KnowledgeDocumentsResponse{
+		Count:     count,
+		Documents: docs,
+	}))
+}
+
+// AddKnowledgeDocuments 向知识库添加文档
+// @Summary 向知识库添加文档
+// @Description 将已上传的文件添加到知识库，文件在后台切片并向量化，处理进度见文档状态
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param request body AddKnowledgeDocumentsRequest true "文件ID列表"
+// @Success 200 {object} model.CommonResponse{data=[]model.KnowledgeDocument} "成功"
+// @Router /api/knowledge_bases/{id}/documents [post]
+func AddKnowledgeDocuments(c *gin.Context) {
+	kb, ok := getKnowledgeBaseParam(c)
+	if !ok {
+		return
+	}
+
+	var req AddKnowledgeDocumentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	uploadFiles := make([]*model.UploadFile, 0, len(req.FileIDs))
+	for _, fileID := range req.FileIDs {
+		uploadFile, err := model.GetUploadFileByID(fileID)
+		if err != nil || uploadFile.Eid != kb.Eid {
+			c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("file not found: "+strconv.FormatInt(fileID, 10))))
+			return
+		}
+		uploadFiles = append(uploadFiles, uploadFile)
+	}
+
+	docs := make([]*model.KnowledgeDocument, 0, len(uploadFiles))
+	for _, uploadFile := range uploadFiles {
+		doc := &model.KnowledgeDocument{
+			Eid:             kb.Eid,
+			KnowledgeBaseID: kb.ID,
+			FileID:          uploadFile.ID,
+			FileName:        uploadFile.FileName,
+			Status:          model.KnowledgeDocumentStatusPending,
+		}
+		if err := doc.Create(); err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+		docs = append(docs, doc)
+	}
+	if err := model.RefreshKnowledgeBaseCounts(kb.ID); err != nil {
+		logger.SysErrorf("RefreshKnowledgeBaseCounts failed: %s", err.Error())
+	}
+
+	go ingestKnowledgeDocuments(kb, docs)
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(docs))
+}
+
+// ReindexKnowledgeDocument 重新处理文档
+// @Summary 重新处理文档
+// @Description 重新切片并向量化文档，用于处理失败后重试
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param document_id path int true "文档ID"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeDocument} "成功"
+// @Router /api/knowledge_bases/{id}/documents/{document_id}/reindex [post]
+func ReindexKnowledgeDocument(c *gin.Context) {
+	kb, doc, ok := getKnowledgeDocumentParam(c)
+	if !ok {
+		return
+	}
+	if doc.Status == model.KnowledgeDocumentStatusProcessing {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("document is processing")))
+		return
+	}
+
+	doc.Status = model.KnowledgeDocumentStatusPending
+	go ingestKnowledgeDocuments(kb, []*model.KnowledgeDocument{doc})
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(doc))
+}
+
+// DeleteKnowledgeDocument 删除文档
+// @Summary 删除文档
+// @Description 从知识库中删除文档及其切片，不删除上传的文件
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param document_id path int true "文档ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/knowledge_bases/{id}/documents/{document_id} [delete]
+func DeleteKnowledgeDocument(c *gin.Context) {
+	kb, doc, ok := getKnowledgeDocumentParam(c)
+	if !ok {
+		return
+	}
+
+	if err := doc.Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	service.InvalidateKnowledgeIndex(kb.ID)
+	if err := model.RefreshKnowledgeBaseCounts(kb.ID); err != nil {
+		logger.SysErrorf("RefreshKnowledgeBaseCounts failed: %s", err.Error())
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// RetrieveKnowledgeBase 检索测试
+// @Summary 知识库检索测试
+// @Description 使用查询内容检索知识库，返回命中的切片和相似度，用于调整 top_k 和阈值
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param request body KnowledgeRetrieveRequest true "检索参数"
+// @Success 200 {object} model.CommonResponse{data=[]service.KnowledgeHit} "成功"
+// @Router /api/knowledge_bases/{id}/retrieve [post]
+func RetrieveKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseParam(c)
+	if !ok {
+		return
+	}
+
+	var req KnowledgeRetrieveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.TopK <= 0 {
+		req.TopK = model.DefaultKnowledgeTopK
+	}
+
+	hits, err := service.SearchKnowledgeBases(c.Request.Context(), kb.Eid, []int64{kb.ID}, req.Query, req.TopK, req.ScoreThreshold, embedKnowledgeTexts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	if hits == nil {
+		hits = []service.KnowledgeHit{}
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(hits))
+}
+
+// getKnowledgeBaseParam 获取路径中当前企业的知识库
+func getKnowledgeBaseParam(c *gin.Context) (*model.KnowledgeBase, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, false
+	}
+
+	kb, err := model.GetKnowledgeBaseByID(config.GetEID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	return kb, true
+}
+
+// getKnowledgeDocumentParam 获取路径中的知识库和文档
+func getKnowledgeDocumentParam(c *gin.Context) (*model.KnowledgeBase, *model.KnowledgeDocument, bool) {
+	kb, ok := getKnowledgeBaseParam(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, nil, false
+	}
+
+	doc, err := model.GetKnowledgeDocumentByID(kb.Eid, kb.ID, documentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, nil, false
+	}
+	return kb, doc, true
+}
+
+// ingestKnowledgeDocuments 在后台依次处理文档
+func ingestKnowledgeDocuments(kb *model.KnowledgeBase, docs []*model.KnowledgeDocument) {
+	ctx := context.Background()
+	for _, doc := range docs {
+		_ = service.IngestKnowledgeDocument(ctx, kb, doc, embedKnowledgeTexts)
+	}
+}
+
+// embedKnowledgeTexts 通过 Embedding 渠道为知识库切片和查询生成向量。
+// 复用 relayEmbedding 的渠道适配逻辑，响应写入临时的 ResponseRecorder 后解析；知识库的向量化不计入用户配额
+func embedKnowledgeTexts(ctx context.Context, eid int64, modelName string, inputs []string) ([][]float32, error) {
+	channel, err := model.GetRandomChannelByModelType(eid, model.ModelTypeEmbedding, modelName)
+	if err != nil {
+		return nil, fmt.Errorf("暂无可用的 embedding 服务渠道: %s", modelName)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = (&http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: "/v1/embeddings"},
+		Header: make(http.Header),
+	}).WithContext(ctx)
+	c.Request.Header.Set("Content-Type", "application/json")
+	middleware.SetupContextForSelectedChannel(c, channel, modelName)
+
+	textRequest := &relay_model.GeneralOpenAIRequest{
+		Model: modelName,
+		Input: inputs,
+	}
+	if _, bizErr := relayEmbedding(c, textRequest, openai.CountTokenInput(inputs, modelName)); bizErr != nil {
+		return nil, errors.New(bizErr.Message)
+	}
+
+	var resp openai.EmbeddingResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(inputs))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			continue
+		}
+		vector := make([]float32, len(item.Embedding))
+		for i, v := range item.Embedding {
+			vector[i] = float32(v)
+		}
+		vectors[item.Index] = vector
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedding 响应缺少第 %d 条向量", i)
+		}
+	}
+	return vectors, nil
+}
@@ -18,9 +18,10 @@ type MessagesResponse struct {
 // EnhancedMessage 增强的消息结构，包含解析后的内容
 type EnhancedMessage struct {
 	*model.Message
-	MessageType   model.MessageType `json:"message_type"`   // 消息类型
-	ParsedMessage interface{}       `json:"parsed_message"` // 解析后的 message 内容
-	ParsedAnswer  interface{}       `json:"parsed_answer"`  // 解析后的 answer 内容
+	MessageType   model.MessageType      `json:"message_type"`       // 消息类型
+	ParsedMessage interface{}            `json:"parsed_message"`     // 解析后的 message 内容
+	ParsedAnswer  interface{}            `json:"parsed_answer"`      // 解析后的 answer 内容
+	Feedback      *model.MessageFeedback `json:"feedback,omitempty"` // 用户对回答的评价
 }
 
 type MessageListRequest struct {
@@ -34,10 +35,20 @@ type MessageListRequest struct {
 func convertToEnhancedMessages(messages []*model.Message) []*EnhancedMessage {
 	enhancedMessages := make([]*EnhancedMessage, len(messages))
 
+	feedbacks := map[int64]*model.MessageFeedback{}
+	if len(messages) > 0 {
+		messageIDs := make([]int64, len(messages))
+		for i, msg := range messages {
+			messageIDs[i] = msg.ID
+		}
+		feedbacks, _ = model.GetMessageFeedbackMap(messages[0].Eid, messageIDs)
+	}
+
 	for i, msg := range messages {
 		enhanced := &EnhancedMessage{
 			Message:     msg,
 			MessageType: msg.GetMessageType(),
+			Feedback:    feedbacks[msg.ID],
 		}
 
 		// 根据消息类型解析内容
@@ -247,3 +258,51 @@ func GetMessagesByConversation(c *gin.Context) {
 		Messages: convertToEnhancedMessages(messages),
 	}))
 }
+
+// @Summary Get relay attempts of a message
+// @Description Get every channel attempt made while answering the message, including failed attempts and the channel that finally served it
+// @Tags Message
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.RelayAttempt} "Success"
+// @Router /api/messages/{message_id}/attempts [get]
+func GetMessageRelayAttempts(c *gin.Context) {
+	messageId, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+
+	attempts, err := model.GetRelayAttemptsByMessageID(config.GetEID(c), messageId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(attempts))
+}
+
+// @Summary Get tool calls of a message
+// @Description Get every agent tool executed on the server while answering the message, with arguments and results
+// @Tags Message
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.MessageToolCall} "Success"
+// @Router /api/messages/{message_id}/tool_calls [get]
+func GetMessageToolCalls(c *gin.Context) {
+	messageId, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+
+	calls, err := model.GetMessageToolCalls(config.GetEID(c), messageId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(calls))
+}
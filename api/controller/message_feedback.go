@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+type MessageFeedbackRequest struct {
+	Rating  string `json:"rating" binding:"required" example:"down"` // up 或 down
+	Reason  string `json:"reason" example:"inaccurate"`              // inaccurate/irrelevant/incomplete/unsafe/slow/helpful/other
+	Content string `json:"content" example:"数据已经过期"`                 // 补充说明
+}
+
+type MessageFeedbackListRequest struct {
+	AgentID   int64  `form:"agent_id"`
+	ChannelID int64  `form:"channel_id"`
+	ModelName string `form:"model_name"`
+	Rating    string `form:"rating"`
+	StartTime int64  `form:"start_time"`
+	EndTime   int64  `form:"end_time"`
+	Offset    int    `form:"offset" default:"0"`
+	Limit     int    `form:"limit" default:"10"`
+}
+
+type MessageFeedbackStatsRequest struct {
+	GroupBy   string `form:"group_by" binding:"required"`
+	AgentID   int64  `form:"agent_id"`
+	ChannelID int64  `form:"channel_id"`
+	ModelName string `form:"model_name"`
+	StartTime int64  `form:"start_time"`
+	EndTime   int64  `form:"end_time"`
+}
+
+// MessageFeedbackItem 反馈及其对应的问答
+type MessageFeedbackItem struct {
+	*model.MessageFeedback
+	Message *EnhancedMessage `json:"message"`
+}
+
+type MessageFeedbackListResponse struct {
+	Count     int64                  `json:"count"`
+	Feedbacks []*MessageFeedbackItem `json:"feedbacks"`
+}
+
+// MessageFeedbackStat 反馈统计，satisfaction 为点赞占比
+type MessageFeedbackStat struct {
+	*model.FeedbackStat
+	Name         string  `json:"name"`
+	Total        int64   `json:"total"`
+	Satisfaction float64 `json:"satisfaction"`
+}
+
+// @Summary Submit feedback on an answer
+// @Description Rate an answer up or down with an optional reason and comment. Submitting again overwrites the previous feedback. Feedback is synced to upstream platforms that support it, such as Dify
+// @Tags Message
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path int true "Conversation ID"
+// @Param message_id path int true "Message ID"
+// @Param request body MessageFeedbackRequest true "Feedback"
+// @Success 200 {object} model.CommonResponse{data=model.MessageFeedback} "Success"
+// @Router /api/conversations/{conversation_id}/messages/{message_id}/feedback [put]
+func SubmitMessageFeedback(c *gin.Context) {
+	var req MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if !model.ValidFeedbackRating(req.Rating) || !model.ValidFeedbackReason(req.Reason) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+
+	message, ok := getFeedbackMessage(c)
+	if !ok {
+		return
+	}
+
+	feedback := &model.MessageFeedback{
+		Eid:            message.Eid,
+		MessageID:      message.ID,
+		UserID:         message.UserID,
+		AgentID:        message.AgentID,
+		ConversationID: message.ConversationID,
+		ChannelID:      int64(message.ChannelId),
+		ModelName:      message.ModelName,
+		Rating:         req.Rating,
+		Reason:         req.Reason,
+		Content:        req.Content,
+	}
+	if err := model.SaveMessageFeedback(feedback); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	go func() {
+		if service.SyncMessageFeedback(context.Background(), message, feedback) {
+			_ = model.MarkMessageFeedbackSynced(message.Eid, message.ID)
+		}
+	}()
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(feedback))
+}
+
+// @Summary Revoke feedback on an answer
+// @Description Remove the feedback on an answer, and revoke it on upstream platforms that support feedback
+// @Tags Message
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path int true "Conversation ID"
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Router /api/conversations/{conversation_id}/messages/{message_id}/feedback [delete]
+func DeleteMessageFeedback(c *gin.Context) {
+	message, ok := getFeedbackMessage(c)
+	if !ok {
+		return
+	}
+	feedback, err := model.GetMessageFeedback(message.Eid, message.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+	if err := model.DeleteMessageFeedback(message.Eid, message.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	if feedback.Synced {
+		go service.SyncMessageFeedback(context.Background(), message, nil)
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// getFeedbackMessage 获取当前用户会话中的消息，失败时已返回错误
+func getFeedbackMessage(c *gin.Context) (*model.Message, bool) {
+	conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+
+	eid := config.GetEID(c)
+	userID := config.GetUserId(c)
+	if _, err := model.GetConversationByID(eid, userID, conversationID); err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return nil, false
+	}
+	message, err := model.GetMessageByID(eid, messageID)
+	if err != nil || message.ConversationID != conversationID || message.UserID != userID {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("message not found")))
+		return nil, false
+	}
+	return message, true
+}
+
+// @Summary List answer feedback
+// @Description List answer feedback with the rated question and answer, newest first. Use rating=down to review downvoted answers
+// @Tags Message
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id query int false "Agent ID"
+// @Param channel_id query int false "Channel ID"
+// @Param model_name query string false "Model name"
+// @Param rating query string false "up or down"
+// @Param start_time query int64 false "Start time (timestamp in milliseconds)"
+// @Param end_time query int64 false "End time (timestamp in milliseconds)"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=MessageFeedbackListResponse} "Success"
+// @Router /api/messages/feedbacks [get]
+func GetMessageFeedbacks(c *gin.Context) {
+	var req MessageFeedbackListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+
+	eid := config.GetEID(c)
+	feedbacks, total, err := model.GetMessageFeedbackList(eid, model.FeedbackQuery{
+		AgentID:   req.AgentID,
+		ChannelID: req.ChannelID,
+		ModelName: req.ModelName,
+		Rating:    req.Rating,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	}, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	messageIDs := make([]int64, len(feedbacks))
+	for i, feedback := range feedbacks {
+		messageIDs[i] = feedback.MessageID
+	}
+	messages, err := model.GetMessagesByIDs(eid, messageIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	messageMap := make(map[int64]*EnhancedMessage, len(messages))
+	for _, message := range convertToEnhancedMessages(messages) {
+		messageMap[message.ID] = message
+	}
+
+	items := make([]*MessageFeedbackItem, len(feedbacks))
+	for i, feedback := range feedbacks {
+		items[i] = &MessageFeedbackItem{MessageFeedback: feedback, Message: messageMap[feedback.MessageID]}
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(MessageFeedbackListResponse{
+		Count:     total,
+		Feedbacks: items,
+	}))
+}
+
+// @Summary Answer feedback statistics
+// @Description Aggregate upvotes, downvotes and satisfaction by agent, model, channel or day (UTC) within a time window
+// @Tags Message
+// @Produce json
+// @Security BearerAuth
+// @Param group_by query string true "agent, model, channel or day"
+// @Param agent_id query int false "Agent ID"
+// @Param channel_id query int false "Channel ID"
+// @Param model_name query string false "Model name"
+// @Param start_time query int64 false "Start time (timestamp in milliseconds)"
+// @Param end_time query int64 false "End time (timestamp in milliseconds)"
+// @Success 200 {object} model.CommonResponse{data=[]MessageFeedbackStat} "Success"
+// @Router /api/messages/feedbacks/stats [get]
+func GetMessageFeedbackStats(c *gin.Context) {
+	var req MessageFeedbackStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	stats, err := model.GetMessageFeedbackStats(eid, model.FeedbackQuery{
+		AgentID:   req.AgentID,
+		ChannelID: req.ChannelID,
+		ModelName: req.ModelName,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	}, req.GroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	result := make([]*MessageFeedbackStat, len(stats))
+	for i, stat := range stats {
+		item := &MessageFeedbackStat{
+			FeedbackStat: stat,
+			Name:         feedbackGroupName(eid, req.GroupBy, stat.GroupKey),
+			Total:        stat.Up + stat.Down,
+		}
+		if item.Total > 0 {
+			item.Satisfaction = float64(stat.Up) / float64(item.Total)
+		}
+		result[i] = item
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(result))
+}
+
+// feedbackGroupName 智能体和渠道分组返回名称，其他分组返回分组键本身
+func feedbackGroupName(eid int64, groupBy, key string) string {
+	id, err := strconv.ParseInt(key, 10, 64)
+	if err != nil {
+		return key
+	}
+	switch groupBy {
+	case model.FeedbackGroupByAgent:
+		if agent, err := model.GetAgentByID(eid, id); err == nil {
+			return agent.Name
+		}
+	case model.FeedbackGroupByChannel:
+		if channel, err := model.GetChannelByID(id); err == nil && channel.Eid == eid {
+			return channel.Name
+		}
+	}
+	return key
+}
@@ -1,6 +1,13 @@
 package controller
 
 import (
+	"fmt"
+	"net/http"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
 	"github.com/53AI/53AIHub/service"
 	"github.com/gin-gonic/gin"
@@ -160,3 +167,94 @@ func ListAllModels(c *gin.Context) {
 		Models: models,
 	}))
 }
+
+// AgentModel 以 OpenAI 模型格式描述的智能体
+type AgentModel struct {
+	Id          string  `json:"id"`
+	Object      string  `json:"object"`
+	Created     int64   `json:"created"`
+	OwnedBy     string  `json:"owned_by"`
+	Root        string  `json:"root"`
+	Parent      *string `json:"parent"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	AgentType   int     `json:"agent_type"`
+	ChannelType int     `json:"channel_type"`
+}
+
+// AgentModelsResponse OpenAI 兼容的模型列表响应
+type AgentModelsResponse struct {
+	Object string       `json:"object"`
+	Data   []AgentModel `json:"data"`
+}
+
+// ListAgentModels List agents available to the caller as OpenAI models
+// @Summary List available agents as models
+// @Description OpenAI compatible model list, returns every agent-{id} the caller may use
+// @Tags Relay
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} AgentModelsResponse
+// @Router /v1/models [get]
+func ListAgentModels(c *gin.Context) {
+	eid := config.GetEID(c)
+	user, err := model.GetUserByID(config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(nil))
+		return
+	}
+
+	_, agents, err := model.GetAvailableAgentList(eid, nil, 0, -1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToOpenAIErrorRespone(err))
+		return
+	}
+
+	isAdmin := common.IsAdmin(c)
+	userGroupIds, err := user.GetUserGroupIds()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToOpenAIErrorRespone(err))
+		return
+	}
+
+	var apiKey *model.ApiKey
+	if value, exists := c.Get(session.SESSION_API_KEY); exists {
+		apiKey, _ = value.(*model.ApiKey)
+	}
+
+	data := make([]AgentModel, 0, len(agents))
+	for _, agent := range agents {
+		if apiKey != nil && !apiKey.AllowAgent(agent.AgentID) {
+			continue
+		}
+		if !isAdmin {
+			agentUserGroupIds, err := agent.GetUserGroupIds()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, model.DBError.ToOpenAIErrorRespone(err))
+				return
+			}
+			if !helper.HasIntersection(agentUserGroupIds, userGroupIds) {
+				continue
+			}
+		}
+
+		id := fmt.Sprintf("agent-%d", agent.AgentID)
+		data = append(data, AgentModel{
+			Id:          id,
+			Object:      "model",
+			Created:     agent.CreatedTime / 1000,
+			OwnedBy:     "53aihub",
+			Root:        id,
+			Parent:      nil,
+			Name:        agent.Name,
+			Description: agent.Description,
+			AgentType:   agent.AgentType,
+			ChannelType: agent.ChannelType,
+		})
+	}
+
+	c.JSON(http.StatusOK, AgentModelsResponse{
+		Object: "list",
+		Data:   data,
+	})
+}
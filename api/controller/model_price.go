@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ModelPriceRequest 模型价格，价格为每百万 token 的金额，货币单位见企业计价配置（type=pricing）
+type ModelPriceRequest struct {
+	ModelName        string   `json:"model_name" binding:"required" example:"gpt-4o"`
+	ChannelID        int64    `json:"channel_id" example:"0"` // 0 为企业默认价格，大于 0 时只对该渠道生效
+	InputPrice       float64  `json:"input_price" example:"2.5"`
+	OutputPrice      float64  `json:"output_price" example:"10"`
+	CachedInputPrice *float64 `json:"cached_input_price" example:"1.25"` // 为空时按输入价格计费
+}
+
+type ModelPriceListRequest struct {
+	ModelName string `form:"model_name"`
+	ChannelID int64  `form:"channel_id" default:"-1"` // -1 不按渠道筛选，0 只看企业默认价格
+}
+
+// @Summary List model prices
+// @Description List the model price table of the enterprise. Prices only take effect when the pricing config is enabled; models without a price fall back to the built-in ratios
+// @Tags ModelPrice
+// @Produce json
+// @Security BearerAuth
+// @Param model_name query string false "Model name"
+// @Param channel_id query int false "Channel ID, -1 for all, 0 for enterprise default prices" default(-1)
+// @Success 200 {object} model.CommonResponse{data=[]model.ModelPrice} "Success"
+// @Router /api/model_prices [get]
+func GetModelPrices(c *gin.Context) {
+	req := ModelPriceListRequest{ChannelID: -1}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	prices, err := model.GetModelPrices(config.GetEID(c), req.ModelName, req.ChannelID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(prices))
+}
+
+// @Summary Save a model price
+// @Description Create a model price, or overwrite the existing price of the same model and channel
+// @Tags ModelPrice
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ModelPriceRequest true "Model price"
+// @Success 200 {object} model.CommonResponse{data=model.ModelPrice} "Success"
+// @Router /api/model_prices [post]
+func SaveModelPrice(c *gin.Context) {
+	var req ModelPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	price := req.toModelPrice(eid)
+	if !validateModelPrice(c, price) {
+		return
+	}
+	if err := model.SaveModelPrice(price); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	service.InvalidatePricing(eid)
+	c.JSON(http.StatusOK, model.Success.ToResponse(price))
+}
+
+// @Summary Update a model price
+// @Tags ModelPrice
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Model price ID"
+// @Param request body ModelPriceRequest true "Model price"
+// @Success 200 {object} model.CommonResponse{data=model.ModelPrice} "Success"
+// @Router /api/model_prices/{id} [put]
+func UpdateModelPrice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	var req ModelPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	price, err := model.GetModelPrice(eid, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+	price.ModelName = req.ModelName
+	price.ChannelID = req.ChannelID
+	price.InputPrice = req.InputPrice
+	price.OutputPrice = req.OutputPrice
+	price.CachedInputPrice = req.CachedInputPrice
+	if !validateModelPrice(c, price) {
+		return
+	}
+	if err := model.UpdateModelPrice(price); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	service.InvalidatePricing(eid)
+	c.JSON(http.StatusOK, model.Success.ToResponse(price))
+}
+
+// @Summary Delete a model price
+// @Tags ModelPrice
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Model price ID"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Router /api/model_prices/{id} [delete]
+func DeleteModelPrice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+
+	eid := config.GetEID(c)
+	if err := model.DeleteModelPrice(eid, id); err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+	service.InvalidatePricing(eid)
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+func (r *ModelPriceRequest) toModelPrice(eid int64) *model.ModelPrice {
+	return &model.ModelPrice{
+		Eid:              eid,
+		ModelName:        r.ModelName,
+		ChannelID:        r.ChannelID,
+		InputPrice:       r.InputPrice,
+		OutputPrice:      r.OutputPrice,
+		CachedInputPrice: r.CachedInputPrice,
+	}
+}
+
+// validateModelPrice 校验价格，渠道价格要求渠道属于当前企业，失败时已返回错误
+func validateModelPrice(c *gin.Context, price *model.ModelPrice) bool {
+	if err := price.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return false
+	}
+	if price.ChannelID > 0 {
+		channel, err := model.GetChannelByID(price.ChannelID)
+		if err != nil || channel.Eid != price.Eid {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("channel not found")))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,552 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/hub_adaptor/custom"
+	"github.com/gin-gonic/gin"
+)
+
+const moderationFinishReason = "content_filter"
+
+func newModerationEvent(c *gin.Context, agent *model.Agent, stage, action string, hits []service.ModerationHit) service.ModerationEvent {
+	return service.ModerationEvent{
+		Eid:      agent.Eid,
+		UserID:   config.GetUserId(c),
+		Nickname: config.GetUserNickname(c),
+		IP:       utils.GetClientIP(c),
+		AgentID:  agent.AgentID,
+		Stage:    stage,
+		Action:   action,
+		Hits:     hits,
+	}
+}
+
+// inputModeration 汇总一次请求中各段输入的审核结果，统一记录事件并在拦截时写出错误响应
+type inputModeration struct {
+	c         *gin.Context
+	agent     *model.Agent
+	moderator *service.Moderator
+	action    string
+	hits      []service.ModerationHit
+}
+
+// newInputModeration 未开启输入审核时 moderator 为 nil，check 原样返回
+func newInputModeration(c *gin.Context, agent *model.Agent) *inputModeration {
+	moderator := service.GetModerator(agent.Eid)
+	if moderator != nil && !moderator.Config.Input {
+		moderator = nil
+	}
+	return &inputModeration{c: c, agent: agent, moderator: moderator}
+}
+
+// check 使用本地规则审核文本，返回屏蔽后的文本；命中拦截规则时返回 false
+func (m *inputModeration) check(text string) (string, bool) {
+	if m.moderator == nil || text == "" {
+		return text, true
+	}
+	return m.add(m.moderator.Check(text))
+}
+
+// add 记录一次审核结果
+func (m *inputModeration) add(result *service.ModerationResult) (string, bool) {
+	if len(result.Hits) > 0 {
+		m.hits = append(m.hits, result.Hits...)
+		m.action = service.StricterModerationAction(m.action, result.Action)
+	}
+	return result.Text, !result.Blocked()
+}
+
+// finish 记录审核事件，拦截时写出错误响应并返回 false
+func (m *inputModeration) finish() bool {
+	if len(m.hits) == 0 {
+		return true
+	}
+	service.LogModerationEvent(newModerationEvent(m.c, m.agent, service.ModerationStageInput, m.action, m.hits))
+	m.hits = nil
+
+	if m.action == service.ModerationActionBlock {
+		logger.Warnf(m.c.Request.Context(), "request blocked by moderation, agent %d", m.agent.AgentID)
+		m.c.JSON(http.StatusBadRequest, model.ForbiddenError.ToOpenAIErrorRespone(m.moderator.Config.BlockMessage))
+		return false
+	}
+	return true
+}
+
+// moderateChatInput 审核请求中的用户消息：拦截时写出错误响应并返回 false，屏蔽时直接改写消息内容。
+// 全部用户消息都经过本地规则审核，审核模型只检查最新一条
+func moderateChatInput(c *gin.Context, chatRequest *ChatRequest, agent *model.Agent) bool {
+	moderation := newInputModeration(c, agent)
+	if moderation.moderator == nil {
+		return true
+	}
+
+	last := -1
+	for i, m := range chatRequest.Messages {
+		if m.Role == "user" {
+			last = i
+		}
+	}
+
+	redactor := newUpstreamRedactor(agent)
+	for i, m := range chatRequest.Messages {
+		if m.Role == "assistant" || m.Content == "" {
+			continue
+		}
+		var result *service.ModerationResult
+		if i == last {
+			result = moderation.moderator.CheckWithModel(c.Request.Context(), m.Content, redactor)
+		} else {
+			result = moderation.moderator.Check(m.Content)
+		}
+		text, ok := moderation.add(result)
+		chatRequest.Messages[i].Content = text
+		if !ok {
+			break
+		}
+	}
+	return moderation.finish()
+}
+
+// moderationWriter 审核模型回答。
+// 流式响应逐个数据块过滤 delta.content，命中拦截规则时输出提示语并结束响应；
+// 非流式响应缓存后在 finish 中改写 choices[0].message.content
+type moderationWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	agent     *model.Agent
+	moderator *service.Moderator
+	filter    *service.ModerationStreamFilter
+	isStream  bool
+
+	pending  bytes.Buffer
+	template map[string]interface{} // 最近一个数据块，用于构造补发的数据块
+	done     bool                   // 已输出拦截提示和 [DONE]
+
+	status   int
+	body     bytes.Buffer
+	content  string
+	rewrote  bool
+	finished bool
+}
+
+// newModerationWriter 未启用回答审核时返回 nil，返回值的方法均可在 nil 上调用
+func newModerationWriter(c *gin.Context, agent *model.Agent, isStream bool) *moderationWriter {
+	moderator := service.GetModerator(agent.Eid)
+	if moderator == nil || !moderator.Config.Output {
+		return nil
+	}
+	w := &moderationWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		agent:          agent,
+		moderator:      moderator,
+		filter:         moderator.NewStreamFilter(),
+		isStream:       isStream,
+		status:         http.StatusOK,
+	}
+	c.Writer = w
+	return w
+}
+
+func (w *moderationWriter) WriteHeader(statusCode int) {
+	if w.isStream {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+}
+
+func (w *moderationWriter) WriteHeaderNow() {
+	if w.isStream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *moderationWriter) Written() bool {
+	if w.isStream {
+		return w.ResponseWriter.Written()
+	}
+	return false
+}
+
+func (w *moderationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *moderationWriter) Write(b []byte) (int, error) {
+	if !w.isStream {
+		return w.body.Write(b)
+	}
+	w.pending.Write(b)
+	for {
+		line, err := w.pending.ReadBytes('\n')
+		if err != nil {
+			// 不完整的行留到下次处理
+			rest := append([]byte{}, line...)
+			w.pending.Reset()
+			w.pending.Write(rest)
+			break
+		}
+		w.processLine(line)
+	}
+	return len(b), nil
+}
+
+func (w *moderationWriter) processLine(line []byte) {
+	if w.done {
+		return
+	}
+	trimmed := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(trimmed, "data:") {
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+
+	data := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if data == "[DONE]" {
+		// 上游没有发送 finish_reason 时，在结束前补发保留区中的内容
+		if rest, blocked := w.filter.Flush(); blocked {
+			w.writeBlocked()
+			return
+		} else if rest != "" {
+			w.writeChunk(rest, nil)
+		}
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+	choice := firstChoice(chunk)
+	if choice == nil {
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+	w.template = chunk
+
+	delta, _ := choice["delta"].(map[string]interface{})
+	content, _ := delta["content"].(string)
+	finishReason := choice["finish_reason"]
+
+	var out string
+	var blocked bool
+	if content != "" {
+		out, blocked = w.filter.Write(content)
+	}
+	if !blocked && finishReason != nil {
+		var rest string
+		rest, blocked = w.filter.Flush()
+		out += rest
+	}
+	if blocked {
+		w.writeBlocked()
+		return
+	}
+	if content == "" && out == "" {
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+	if delta == nil {
+		delta = map[string]interface{}{}
+		choice["delta"] = delta
+	}
+	delta["content"] = out
+	w.writeData(chunk)
+}
+
+func firstChoice(chunk map[string]interface{}) map[string]interface{} {
+	choices, _ := chunk["choices"].([]interface{})
+	if len(choices) == 0 {
+		return nil
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	return choice
+}
+
+// writeChunk 基于最近的数据块构造新的数据块
+func (w *moderationWriter) writeChunk(content string, finishReason interface{}) {
+	w.writeData(newStreamChunk(w.template, content, finishReason))
+}
+
+// newStreamChunk 沿用 template 的 id、model 等字段构造只包含 content 的流式数据块
+func newStreamChunk(template map[string]interface{}, content string, finishReason interface{}) map[string]interface{} {
+	chunk := map[string]interface{}{"object": "chat.completion.chunk"}
+	for _, key := range []string{"id", "object", "created", "model"} {
+		if value, ok := template[key]; ok {
+			chunk[key] = value
+		}
+	}
+	chunk["choices"] = []interface{}{map[string]interface{}{
+		"index":         0,
+		"delta":         map[string]interface{}{"role": "assistant", "content": content},
+		"finish_reason": finishReason,
+	}}
+	return chunk
+}
+
+func (w *moderationWriter) writeData(chunk map[string]interface{}) {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return
+	}
+	_, _ = w.ResponseWriter.Write([]byte("data: " + string(data) + "\n\n"))
+	w.Flush()
+}
+
+func (w *moderationWriter) writeBlocked() {
+	w.writeChunk(w.moderator.Config.BlockMessage, moderationFinishReason)
+	_, _ = w.ResponseWriter.Write([]byte("data: [DONE]\n\n"))
+	w.Flush()
+	w.done = true
+}
+
+func (w *moderationWriter) Flush() {
+	if !w.isStream {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish 恢复原始 Writer，写出非流式响应并记录审核日志，可重复调用
+func (w *moderationWriter) finish() {
+	if w == nil || w.finished {
+		return
+	}
+	w.finished = true
+	w.c.Writer = w.ResponseWriter
+
+	action := w.filter.Action()
+	hits := w.filter.Hits
+	if w.isStream {
+		if w.pending.Len() > 0 && !w.done {
+			_, _ = w.ResponseWriter.Write(w.pending.Bytes())
+		}
+	} else if w.body.Len() > 0 {
+		body := w.body.Bytes()
+		var response map[string]interface{}
+		if w.status == http.StatusOK && json.Unmarshal(body, &response) == nil {
+			if choice := firstChoice(response); choice != nil {
+				message, _ := choice["message"].(map[string]interface{})
+				if content, ok := message["content"].(string); ok && content != "" {
+					result := w.moderator.CheckWithModel(w.c.Request.Context(), content, newUpstreamRedactor(w.agent))
+					action, hits = result.Action, result.Hits
+					if len(hits) > 0 {
+						w.content = result.Text
+						if result.Blocked() {
+							w.content = w.moderator.Config.BlockMessage
+							choice["finish_reason"] = moderationFinishReason
+						}
+						message["content"] = w.content
+						w.rewrote = true
+						if rewritten, err := json.Marshal(response); err == nil {
+							body = rewritten
+						}
+					}
+				}
+			}
+		}
+		w.ResponseWriter.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(body)
+	}
+
+	if len(hits) > 0 {
+		service.LogModerationEvent(newModerationEvent(w.c, w.agent, service.ModerationStageOutput, action, hits))
+	}
+}
+
+// answer 返回审核后的回答，用于保存消息记录；流式响应的内容已由拦截器收集审核后的数据
+func (w *moderationWriter) answer(content string) string {
+	if w == nil || !w.rewrote {
+		return content
+	}
+	return w.content
+}
+
+// blocked 回答是否被拦截，被拦截的回答不写入缓存
+func (w *moderationWriter) blocked() bool {
+	if w == nil {
+		return false
+	}
+	if w.isStream {
+		return w.filter.Blocked()
+	}
+	return w.rewrote && w.content == w.moderator.Config.BlockMessage
+}
+
+// moderateWorkflowInput 审核工作流的字符串参数，屏蔽时直接改写参数
+func moderateWorkflowInput(c *gin.Context, agent *model.Agent, parameters map[string]interface{}) error {
+	moderator := service.GetModerator(agent.Eid)
+	if moderator == nil || !moderator.Config.Input {
+		return nil
+	}
+
+	redactor := newUpstreamRedactor(agent)
+	action := ""
+	var hits []service.ModerationHit
+	moderateWorkflowValues(parameters, func(text string) string {
+		result := moderator.CheckWithModel(c.Request.Context(), text, redactor)
+		hits = append(hits, result.Hits...)
+		action = service.StricterModerationAction(action, result.Action)
+		return result.Text
+	})
+	if len(hits) == 0 {
+		return nil
+	}
+	service.LogModerationEvent(newModerationEvent(c, agent, service.ModerationStageInput, action, hits))
+	if action == service.ModerationActionBlock {
+		return errors.New("输入内容未通过审核：" + moderator.Config.BlockMessage)
+	}
+	return nil
+}
+
+// workflowModeration 审核工作流的流式事件和最终输出
+type workflowModeration struct {
+	c         *gin.Context
+	agent     *model.Agent
+	moderator *service.Moderator
+	filter    *service.ModerationStreamFilter
+	onEvent   custom.WorkflowEventHandler
+	executeID string
+	notified  bool // 已输出拦截提示
+	hits      []service.ModerationHit
+}
+
+// newWorkflowModeration 未启用回答审核时返回 nil
+func newWorkflowModeration(c *gin.Context, agent *model.Agent, onEvent custom.WorkflowEventHandler) *workflowModeration {
+	moderator := service.GetModerator(agent.Eid)
+	if moderator == nil || !moderator.Config.Output {
+		return nil
+	}
+	return &workflowModeration{
+		c:         c,
+		agent:     agent,
+		moderator: moderator,
+		filter:    moderator.NewStreamFilter(),
+		onEvent:   onEvent,
+	}
+}
+
+// handler 返回包装后的事件回调；原回调为 nil（阻塞模式）时保持为 nil
+func (m *workflowModeration) handler() custom.WorkflowEventHandler {
+	if m.onEvent == nil {
+		return nil
+	}
+	onEvent := m.onEvent
+	return func(event *custom.WorkflowStreamEvent) {
+		if event.ExecuteID != "" {
+			m.executeID = event.ExecuteID
+		}
+		switch event.Event {
+		case custom.WorkflowEventTextChunk:
+			text, blocked := m.filter.Write(event.Text)
+			if blocked {
+				if m.notified {
+					return
+				}
+				m.notified = true
+				text = m.moderator.Config.BlockMessage
+			}
+			if text == "" {
+				return
+			}
+			event.Text = text
+		case custom.WorkflowEventNodeFinished:
+			m.moderateValues(event.Outputs)
+		case custom.WorkflowEventWorkflowFinished:
+			m.flushText()
+			if event.Data != nil {
+				m.moderateValues(event.Data.WorkflowOutputData)
+			}
+		}
+		onEvent(event)
+	}
+}
+
+// flushText 输出流式文本保留区中的剩余内容
+func (m *workflowModeration) flushText() {
+	if m.onEvent == nil {
+		return
+	}
+	if text, blocked := m.filter.Flush(); !blocked && text != "" {
+		m.onEvent(&custom.WorkflowStreamEvent{
+			Event:     custom.WorkflowEventTextChunk,
+			ExecuteID: m.executeID,
+			Text:      text,
+		})
+	}
+}
+
+func (m *workflowModeration) moderateValues(values map[string]interface{}) {
+	redactor := newUpstreamRedactor(m.agent)
+	moderateWorkflowValues(values, func(text string) string {
+		result := m.moderator.CheckWithModel(m.c.Request.Context(), text, redactor)
+		m.hits = append(m.hits, result.Hits...)
+		if result.Blocked() {
+			return m.moderator.Config.BlockMessage
+		}
+		return result.Text
+	})
+}
+
+// finish 审核最终输出并记录审核日志
+func (m *workflowModeration) finish(response *custom.WorkflowResponseData) {
+	if m == nil {
+		return
+	}
+	m.flushText()
+	if response != nil {
+		m.moderateValues(response.WorkflowOutputData)
+	}
+
+	hits := append(m.filter.Hits, m.hits...)
+	action := m.filter.Action()
+	for _, hit := range m.hits {
+		action = service.StricterModerationAction(action, hit.Action)
+	}
+	if len(hits) > 0 {
+		service.LogModerationEvent(newModerationEvent(m.c, m.agent, service.ModerationStageOutput, action, hits))
+	}
+}
+
+// moderateWorkflowValues 递归改写 map/slice 中的字符串
+func moderateWorkflowValues(values map[string]interface{}, moderate func(string) string) {
+	for key, value := range values {
+		values[key] = moderateWorkflowValue(value, moderate)
+	}
+}
+
+func moderateWorkflowValue(value interface{}, moderate func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return moderate(v)
+	case map[string]interface{}:
+		moderateWorkflowValues(v, moderate)
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = moderateWorkflowValue(v[i], moderate)
+		}
+		return v
+	default:
+		return value
+	}
+}
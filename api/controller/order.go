@@ -447,6 +447,7 @@ func ConfirmManualPayment(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
 		return
 	}
+	grantOrderPoints(order)
 
 	c.JSON(http.StatusOK, model.Success.ToResponse(order))
 }
@@ -244,6 +244,9 @@ func createOrUpdateOrderFromCache(eid int64, orderId string, status int, transac
 			return nil, fmt.Errorf("create order from cache error: %v", err)
 		}
 		xlog.Info("Successfully created order from cache with status:", status)
+		if status == model.OrderStatusPaid {
+			grantOrderPoints(cachedOrder)
+		}
 
 		// Remove from cache
 		removeCachedOrder(orderId)
@@ -267,6 +270,9 @@ func createOrUpdateOrderFromCache(eid int64, orderId string, status int, transac
 		return nil, fmt.Errorf("update order status error: %v", err)
 	}
 	xlog.Info("Successfully updated order status to:", status)
+	if status == model.OrderStatusPaid {
+		grantOrderPoints(dbOrder)
+	}
 
 	// Remove from cache if exists
 	removeCachedOrder(orderId)
@@ -307,6 +313,9 @@ func createOrUpdateOrderFromCacheWithTime(eid int64, orderId string, status int,
 			return nil, fmt.Errorf("create order from cache error: %v", err)
 		}
 		xlog.Info("Successfully created order from cache with status:", status)
+		if status == model.OrderStatusPaid {
+			grantOrderPoints(cachedOrder)
+		}
 
 		// Remove from cache
 		removeCachedOrder(orderId)
@@ -335,6 +344,9 @@ func createOrUpdateOrderFromCacheWithTime(eid int64, orderId string, status int,
 		return nil, fmt.Errorf("update order status error: %v", err)
 	}
 	xlog.Info("Successfully updated order status to:", status)
+	if status == model.OrderStatusPaid {
+		grantOrderPoints(dbOrder)
+	}
 
 	// Remove from cache if exists
 	removeCachedOrder(orderId)
@@ -611,7 +623,7 @@ func WechatPayNotify(c *gin.Context) {
 		defer orderMutex.Unlock()
 
 		// Check if order exists in database
-		_, err := model.GetOrderByOrderId(eid, orderId)
+		dbOrder, err := model.GetOrderByOrderId(eid, orderId)
 		if err != nil {
 			// Order not found in database, check cache
 			cachedOrder, found := getCachedOrder(orderId)
@@ -626,6 +638,7 @@ func WechatPayNotify(c *gin.Context) {
 					c.String(http.StatusInternalServerError, "Failed to create order")
 					return
 				}
+				grantOrderPoints(cachedOrder)
 
 				// Remove from cache
 				removeCachedOrder(orderId)
@@ -642,6 +655,7 @@ func WechatPayNotify(c *gin.Context) {
 				c.String(http.StatusInternalServerError, "Failed to update order")
 				return
 			}
+			grantOrderPoints(dbOrder)
 
 			// Remove from cache if exists
 			removeCachedOrder(orderId)
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+	relay_model "github.com/songquanpeng/one-api/relay/model"
+)
+
+// redactTextRequest 按智能体配置对发送给渠道的消息脱敏。
+// 返回脱敏后的请求副本，原请求保持不变，用于保存消息记录；未启用或没有命中时返回原请求和 nil
+func redactTextRequest(c *gin.Context, agent *model.Agent, textRequest *relay_model.GeneralOpenAIRequest, messageID int64) (*relay_model.GeneralOpenAIRequest, *service.PIIRedactor) {
+	redactor := service.NewPIIRedactor(agent.GetPIIRedactionSettings())
+	if redactor == nil {
+		return textRequest, nil
+	}
+
+	redacted := *textRequest
+	redacted.Messages = make([]relay_model.Message, len(textRequest.Messages))
+	for i, message := range textRequest.Messages {
+		message.Content = redactMessageContent(redactor, message.Content)
+		redacted.Messages[i] = message
+	}
+	if redactor.Total() == 0 {
+		return textRequest, nil
+	}
+
+	logger.Infof(c.Request.Context(), "redacted %d pii values for message %d: %v", redactor.Total(), messageID, redactor.Counts)
+	if err := model.UpdateMessageRedactionCounts(agent.Eid, messageID, redactor.Counts); err != nil {
+		logger.Errorf(c.Request.Context(), "UpdateMessageRedactionCounts failed: %s", err.Error())
+	}
+	return &redacted, redactor
+}
+
+// newUpstreamRedactor 为渠道转发以外的上游调用（审核模型、知识库检索、历史摘要）创建独立的脱敏器，
+// 不计入消息的脱敏统计；未启用时返回 nil，nil 上的 Redact 和 Restore 原样返回
+func newUpstreamRedactor(agent *model.Agent) *service.PIIRedactor {
+	return service.NewPIIRedactor(agent.GetPIIRedactionSettings())
+}
+
+// redactMessageContent 处理纯文本内容和多模态内容中的 text 部分
+func redactMessageContent(redactor *service.PIIRedactor, content any) any {
+	switch v := content.(type) {
+	case string:
+		return redactor.Redact(v)
+	case []any:
+		parts := make([]any, len(v))
+		for i, part := range v {
+			item, ok := part.(map[string]any)
+			if !ok {
+				parts[i] = part
+				continue
+			}
+			copied := make(map[string]any, len(item))
+			for key, value := range item {
+				copied[key] = value
+			}
+			if text, ok := copied["text"].(string); ok {
+				copied["text"] = redactor.Redact(text)
+			}
+			parts[i] = copied
+		}
+		return parts
+	default:
+		return content
+	}
+}
+
+// piiRestoreWriter 将渠道回答中的占位符还原为原值后再写给客户端。
+// 流式响应按数据块还原 delta 中的 content 和 reasoning_content，被拆分的占位符暂存到下一个数据块；
+// 非流式响应缓存后整体还原
+type piiRestoreWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	redactor *service.PIIRedactor
+	isStream bool
+
+	pending   bytes.Buffer
+	content   *service.PIIStreamRestorer
+	reasoning *service.PIIStreamRestorer
+	template  map[string]interface{}
+
+	status   int
+	body     bytes.Buffer
+	finished bool
+}
+
+// newPIIRestoreWriter redactor 为 nil 时返回 nil，返回值的方法均可在 nil 上调用
+func newPIIRestoreWriter(c *gin.Context, redactor *service.PIIRedactor, isStream bool) *piiRestoreWriter {
+	if redactor == nil {
+		return nil
+	}
+	w := &piiRestoreWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		redactor:       redactor,
+		isStream:       isStream,
+		content:        redactor.NewStreamRestorer(),
+		reasoning:      redactor.NewStreamRestorer(),
+		status:         http.StatusOK,
+	}
+	c.Writer = w
+	return w
+}
+
+func (w *piiRestoreWriter) WriteHeader(statusCode int) {
+	if w.isStream {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+}
+
+func (w *piiRestoreWriter) WriteHeaderNow() {
+	if w.isStream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *piiRestoreWriter) Written() bool {
+	if w.isStream {
+		return w.ResponseWriter.Written()
+	}
+	return false
+}
+
+func (w *piiRestoreWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *piiRestoreWriter) Write(b []byte) (int, error) {
+	if !w.isStream {
+		return w.body.Write(b)
+	}
+	w.pending.Write(b)
+	for {
+		line, err := w.pending.ReadBytes('\n')
+		if err != nil {
+			// 不完整的行留到下次处理
+			rest := append([]byte{}, line...)
+			w.pending.Reset()
+			w.pending.Write(rest)
+			break
+		}
+		w.processLine(line)
+	}
+	return len(b), nil
+}
+
+func (w *piiRestoreWriter) processLine(line []byte) {
+	trimmed := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(trimmed, "data:") {
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+
+	data := strings.TrimSpace(strings.TrimPrefix(trimmed, "data:"))
+	if data == "[DONE]" {
+		w.flushPending()
+		_, _ = w.ResponseWriter.Write(line)
+		return
+	}
+
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		_, _ = w.ResponseWriter.Write([]byte(w.redactor.RestoreJSON(string(line))))
+		return
+	}
+	choice := firstChoice(chunk)
+	delta, _ := choice["delta"].(map[string]interface{})
+	if delta == nil {
+		_, _ = w.ResponseWriter.Write([]byte(w.redactor.RestoreJSON(string(line))))
+		return
+	}
+	w.template = chunk
+
+	final := choice["finish_reason"] != nil
+	for key, restorer := range map[string]*service.PIIStreamRestorer{"content": w.content, "reasoning_content": w.reasoning} {
+		text, ok := delta[key].(string)
+		if !ok && !final {
+			continue
+		}
+		text = restorer.Write(text)
+		if final {
+			text += restorer.Flush()
+		}
+		if ok || text != "" {
+			delta[key] = text
+		}
+	}
+	w.writeData(chunk)
+}
+
+// flushPending 上游没有发送 finish_reason 时，在结束前补发暂存的内容
+func (w *piiRestoreWriter) flushPending() {
+	if content := w.content.Flush(); content != "" {
+		w.writeData(newStreamChunk(w.template, content, nil))
+	}
+	if reasoning := w.reasoning.Flush(); reasoning != "" {
+		chunk := newStreamChunk(w.template, "", nil)
+		delta := firstChoice(chunk)["delta"].(map[string]interface{})
+		delta["reasoning_content"] = reasoning
+		w.writeData(chunk)
+	}
+}
+
+func (w *piiRestoreWriter) writeData(chunk map[string]interface{}) {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return
+	}
+	_, _ = w.ResponseWriter.Write([]byte("data: " + string(data) + "\n\n"))
+	w.Flush()
+}
+
+func (w *piiRestoreWriter) Flush() {
+	if !w.isStream {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish 恢复原始 Writer 并写出剩余内容，可重复调用
+func (w *piiRestoreWriter) finish() {
+	if w == nil || w.finished {
+		return
+	}
+	w.finished = true
+	w.c.Writer = w.ResponseWriter
+
+	if w.isStream {
+		if w.pending.Len() > 0 {
+			_, _ = w.ResponseWriter.Write([]byte(w.redactor.RestoreJSON(w.pending.String())))
+		}
+		return
+	}
+	if w.body.Len() == 0 {
+		return
+	}
+	w.ResponseWriter.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write([]byte(w.redactor.RestoreJSON(w.body.String())))
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PointsWalletResponse 用户积分余额
+type PointsWalletResponse struct {
+	UserID        int64 `json:"user_id" example:"1"`
+	Balance       int64 `json:"balance" example:"1200"`
+	PointsEnabled bool  `json:"points_enabled" example:"true"` // 用户当前订阅是否为积分订阅，积分订阅按积分余额限制使用
+	QuotaPerPoint int64 `json:"quota_per_point" example:"1000"`
+}
+
+type PointsLedgerListRequest struct {
+	UserID int64  `form:"user_id"` // 仅管理员接口
+	Type   string `form:"type"`    // grant、deduct、refund、adjust 或 expire
+	Offset int    `form:"offset" default:"0"`
+	Limit  int    `form:"limit" default:"10"`
+}
+
+type PointsLedgerListResponse struct {
+	Count   int64                 `json:"count"`
+	Ledgers []*model.PointsLedger `json:"ledgers"`
+}
+
+type AdjustPointsRequest struct {
+	Amount      int64  `json:"amount" binding:"required" example:"100"` // 正数增加，负数扣减
+	ExpiredTime int64  `json:"expired_time" example:"0"`                // 增加的积分的过期时间（毫秒时间戳），0 表示永不过期
+	Remark      string `json:"remark" binding:"max=255" example:"活动奖励"`
+}
+
+type RefundPointsRequest struct {
+	Remark string `json:"remark" binding:"max=255" example:"回答异常"`
+}
+
+// @Summary Get current user points
+// @Description Get the points balance of the logged-in user
+// @Tags Points
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=PointsWalletResponse} "Success"
+// @Router /api/users/me/points [get]
+func GetCurrentUserPoints(c *gin.Context) {
+	response, err := getPointsWalletResponse(config.GetEID(c), config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(response))
+}
+
+// @Summary List current user points ledgers
+// @Description List the points history of the logged-in user, newest first
+// @Tags Points
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "Ledger type: grant, deduct, refund, adjust or expire"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=PointsLedgerListResponse} "Success"
+// @Router /api/users/me/points/ledgers [get]
+func GetCurrentUserPointsLedgers(c *gin.Context) {
+	var req PointsLedgerListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	req.UserID = config.GetUserId(c)
+	getPointsLedgers(c, &req)
+}
+
+// @Summary List points ledgers
+// @Description List the points history of the enterprise, optionally filtered by user and type
+// @Tags Points
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query int false "User ID"
+// @Param type query string false "Ledger type: grant, deduct, refund, adjust or expire"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=PointsLedgerListResponse} "Success"
+// @Router /api/points/ledgers [get]
+func GetPointsLedgers(c *gin.Context) {
+	var req PointsLedgerListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	getPointsLedgers(c, &req)
+}
+
+// @Summary Get user points
+// @Description Get the points balance of a user
+// @Tags Points
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path int true "User ID"
+// @Success 200 {object} model.CommonResponse{data=PointsWalletResponse} "Success"
+// @Router /api/points/users/{user_id} [get]
+func GetUserPoints(c *gin.Context) {
+	user, ok := getPointsUser(c)
+	if !ok {
+		return
+	}
+	response, err := getPointsWalletResponse(user.Eid, user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(response))
+}
+
+// @Summary Adjust user points
+// @Description Add points to or deduct points from a user. Deductions never take the balance below 0
+// @Tags Points
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path int true "User ID"
+// @Param request body AdjustPointsRequest true "Adjustment"
+// @Success 200 {object} model.CommonResponse{data=PointsWalletResponse} "Success"
+// @Router /api/points/users/{user_id}/adjust [post]
+func AdjustUserPoints(c *gin.Context) {
+	user, ok := getPointsUser(c)
+	if !ok {
+		return
+	}
+	var req AdjustPointsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.ExpiredTime < 0 {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("invalid expired_time")))
+		return
+	}
+
+	if err := service.AdjustUserPoints(user.Eid, user.UserID, req.Amount, req.ExpiredTime, req.Remark, config.GetUserId(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	response, err := getPointsWalletResponse(user.Eid, user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(response))
+}
+
+// @Summary Refund a points deduction
+// @Description Give back the points of a deduction ledger entry. Each deduction can only be refunded once, and refunded points never expire
+// @Tags Points
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Ledger ID"
+// @Param request body RefundPointsRequest false "Refund"
+// @Success 200 {object} model.CommonResponse{data=PointsWalletResponse} "Success"
+// @Router /api/points/ledgers/{id}/refund [post]
+func RefundPointsLedger(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	var req RefundPointsRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+
+	ledger, err := model.GetPointsLedger(config.GetEID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+	if err := service.RefundPointsLedger(ledger, req.Remark, config.GetUserId(c)); err != nil {
+		if errors.Is(err, model.ErrPointsGrantExists) {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("ledger already refunded")))
+			return
+		}
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	response, err := getPointsWalletResponse(ledger.Eid, ledger.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(response))
+}
+
+func getPointsLedgers(c *gin.Context, req *PointsLedgerListRequest) {
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+	ledgers, count, err := model.GetPointsLedgers(config.GetEID(c), req.UserID, req.Type, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(PointsLedgerListResponse{
+		Count:   count,
+		Ledgers: ledgers,
+	}))
+}
+
+func getPointsWalletResponse(eid, userID int64) (*PointsWalletResponse, error) {
+	wallet, err := model.GetPointsWallet(eid, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &PointsWalletResponse{
+		UserID:        userID,
+		Balance:       wallet.Balance,
+		PointsEnabled: service.GetUserQuotaPolicy(eid, userID).Points,
+		QuotaPerPoint: config.QUOTA_PER_POINT,
+	}, nil
+}
+
+// getPointsUser 获取当前企业下路径参数指定的用户，失败时已返回错误
+func getPointsUser(c *gin.Context) (*model.User, bool) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	user, err := model.GetUserByID(userID)
+	if err != nil || user.Eid != config.GetEID(c) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("user not found")))
+		return nil, false
+	}
+	return user, true
+}
+
+// grantOrderPoints 订单支付成功后按用户的新订阅发放本周期的积分
+func grantOrderPoints(order *model.Order) {
+	if order == nil || order.ServiceType != model.ServiceTypeSubscription {
+		return
+	}
+	if err := service.GrantSubscriptionPoints(order.Eid, order.UserID, model.PointsSourceOrder, order.OrderId); err != nil {
+		logger.SysErrorf("grant order points failed: order_id=%s err=%v", order.OrderId, err)
+	}
+}
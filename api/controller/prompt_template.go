@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+var promptWeekdays = []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// renderAgentPrompt 按请求渲染智能体提示词中的变量。
+// 渲染结果写回本次请求加载的 agent，之后的历史裁剪、回答缓存和 addAgentPrompt 都使用渲染后的提示词
+func renderAgentPrompt(c *gin.Context, agent *model.Agent) {
+	if !service.HasPromptTemplate(agent.Prompt) {
+		return
+	}
+	variables := &promptVariables{c: c, agent: agent, values: map[string]string{}}
+	agent.Prompt = service.RenderPromptTemplate(agent.Prompt, variables.resolve)
+}
+
+// promptVariables 提示词变量，只在模板引用时才查询：
+//
+//	user.id / user.nickname / user.departments / user.group
+//	enterprise.name
+//	now.date / now.time / now.datetime / now.weekday / now.timezone（企业时区）
+//	var.<name>（Agent.Settings 中的 prompt_variables）
+type promptVariables struct {
+	c          *gin.Context
+	agent      *model.Agent
+	values     map[string]string
+	enterprise *model.Enterprise
+	now        time.Time
+}
+
+func (v *promptVariables) resolve(name string) (string, bool) {
+	if value, ok := v.values[name]; ok {
+		return value, true
+	}
+	value, ok := v.lookup(name)
+	if ok {
+		v.values[name] = value
+	}
+	return value, ok
+}
+
+func (v *promptVariables) lookup(name string) (string, bool) {
+	if key, ok := strings.CutPrefix(name, "var."); ok {
+		value, ok := v.agent.GetPromptVariables()[key]
+		return value, ok
+	}
+
+	ctx := v.c.Request.Context()
+	userID := config.GetUserId(v.c)
+	switch name {
+	case "user.id":
+		return strconv.FormatInt(userID, 10), true
+	case "user.nickname":
+		return config.GetUserNickname(v.c), true
+	case "user.departments":
+		names, err := model.GetUserDepartmentNames(v.agent.Eid, userID)
+		if err != nil {
+			logger.Errorf(ctx, "GetUserDepartmentNames failed: %s", err.Error())
+		}
+		return strings.Join(names, "、"), true
+	case "user.group":
+		groupID := config.GetUserGroupID(v.c)
+		if groupID == 0 {
+			return "", true
+		}
+		group, err := model.GetGroupByID(groupID)
+		if err != nil {
+			return "", true
+		}
+		return group.GroupName, true
+	case "enterprise.name":
+		if enterprise := v.getEnterprise(); enterprise != nil {
+			return enterprise.DisplayName, true
+		}
+		return "", true
+	case "now.date":
+		return v.getNow().Format("2006-01-02"), true
+	case "now.time":
+		return v.getNow().Format("15:04"), true
+	case "now.datetime":
+		return v.getNow().Format("2006-01-02 15:04:05"), true
+	case "now.weekday":
+		return promptWeekdays[v.getNow().Weekday()], true
+	case "now.timezone":
+		return v.getNow().Location().String(), true
+	}
+	return "", false
+}
+
+func (v *promptVariables) getEnterprise() *model.Enterprise {
+	if v.enterprise == nil {
+		enterprise, err := model.GetEnterpriseByID(v.agent.Eid)
+		if err != nil {
+			return nil
+		}
+		v.enterprise = enterprise
+	}
+	return v.enterprise
+}
+
+// getNow 同一次渲染中的时间变量保持一致
+func (v *promptVariables) getNow() time.Time {
+	if v.now.IsZero() {
+		location := model.ParseTimezone("")
+		if enterprise := v.getEnterprise(); enterprise != nil {
+			location = enterprise.GetLocation()
+		}
+		v.now = time.Now().In(location)
+	}
+	return v.now
+}
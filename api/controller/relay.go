@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -33,7 +32,6 @@ import (
 	"github.com/songquanpeng/one-api/relay/adaptor"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/apitype"
-	billing_ratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/constant/role"
 	"github.com/songquanpeng/one-api/relay/controller"
@@ -44,35 +42,40 @@ import (
 	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
+// quotaExceededCode 配额不足时返回的错误码
+const quotaExceededCode = "insufficient_user_quota"
+
 type Message struct {
 	Role    string `json:"role" example:"user"`
 	Content string `json:"content" example:"who are you"`
 }
 
 type ChatRequest struct {
-	Messages         []Message `json:"messages"`
-	Stream           bool      `json:"stream"`
-	Model            string    `json:"model" example:"agent-6"`
-	Temperature      float64   `json:"temperature,omitempty"`
-	PresencePenalty  float64   `json:"presence_penalty,omitempty"`
-	FrequencyPenalty float64   `json:"frequency_penalty,omitempty"`
-	TopP             float64   `json:"top_p,omitempty"`
-	ConversationID   int64     `json:"conversation_id"`
+	Messages            []Message `json:"messages"`
+	Stream              bool      `json:"stream"`
+	Model               string    `json:"model" example:"agent-6"`
+	Temperature         float64   `json:"temperature,omitempty"`
+	PresencePenalty     float64   `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    float64   `json:"frequency_penalty,omitempty"`
+	TopP                float64   `json:"top_p,omitempty"`
+	MaxTokens           int       `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int      `json:"max_completion_tokens,omitempty" swaggerignore:"true"` // o1 系列模型不支持 max_tokens，由 max_tokens 转换而来
+	ConversationID      int64     `json:"conversation_id"`
 }
 
 // WorkflowRunRequest 工作流运行请求结构体
 type WorkflowRunRequest struct {
 	Parameters     map[string]interface{} `json:"parameters"`      // 工作流参数
-	Stream         bool                   `json:"stream"`          // 是否流式响应（工作流不支持，会被忽略）
+	Stream         bool                   `json:"stream"`          // 是否流式响应，为 true 时以 SSE 推送节点事件
 	Model          string                 `json:"model"`           // Agent模型
 	ConversationID int64                  `json:"conversation_id"` // 会话ID
 }
 
 // @Summary Workflow Run
-// @Description 工作流运行接口，返回标准格式的工作流执行结果
+// @Description 工作流运行接口，返回标准格式的工作流执行结果。stream=true 时以 SSE 推送 node_started、node_finished、text_chunk、workflow_finished 事件，workflow_finished 事件携带最终结果
 // @Tags Workflow
 // @Accept json
-// @Produce json
+// @Produce json,text/event-stream
 // @Param workflowRequest body WorkflowRunRequest true "WorkflowRunRequest"
 // @Success 200 {object} model.CommonResponse{data=custom.WorkflowResponseData}
 // @Router /v1/workflow/run [post]
@@ -110,39 +113,38 @@ func WorkflowRun(c *gin.Context) {
 		return
 	}
 
-	// 检查流式响应参数
-	if workflowRequest.Stream {
-		logger.SysLogf("工作流请求设置了 stream=true，但工作流不支持流式响应，将忽略此参数")
-	}
-
 	logger.SysLogf("工作流运行请求 - Agent: %s, Stream: %v, Parameters: %+v",
 		agent.Model, workflowRequest.Stream, workflowRequest.Parameters)
 
-	// 执行工作流
-	response, err := executeWorkflow(c, &workflowRequest, agent)
+	// 预扣配额，工作流的实际用量在执行结束后结算
+	userId := config.GetUserId(c)
+	quotaHold, err := service.PreConsumeUserQuota(agent.Eid, userId, config.PreConsumedQuota)
 	if err != nil {
-		logger.SysErrorf("工作流执行失败 - Agent: %s, Error: %v", agent.Model, err)
-
-		// 根据错误类型返回不同的状态码
-		statusCode := 500
-		if strings.Contains(err.Error(), "参数") || strings.Contains(err.Error(), "输入") {
-			statusCode = 400
-		} else if strings.Contains(err.Error(), "未找到") || strings.Contains(err.Error(), "不存在") {
-			statusCode = 404
-		}
+		bizErr := quotaExceededError()
+		c.JSON(bizErr.StatusCode, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: bizErr.Message,
+				Type:    bizErr.Type,
+			},
+		})
+		return
+	}
 
-		// 根据状态码选择合适的响应码
-		var responseCode model.ResponseCode
-		switch statusCode {
-		case 400:
-			responseCode = model.ParamError
-		case 404:
-			responseCode = model.NotFound
-		default:
-			responseCode = model.SystemError
-		}
+	// 流式执行，通过 SSE 推送节点事件
+	if workflowRequest.Stream {
+		runWorkflowStream(c, &workflowRequest, agent, quotaHold)
+		return
+	}
 
-		c.JSON(statusCode, responseCode.ToResponse(errors.New(err.Error())))
+	// 执行工作流
+	response, err := executeWorkflow(c, &workflowRequest, agent, nil)
+	if err != nil {
+		logger.SysErrorf("工作流执行失败 - Agent: %s, Error: %v", agent.Model, err)
+		returnPreConsumedQuota(agent.Eid, userId, quotaHold)
+		respondWorkflowError(c, err)
 		return
 	}
 
@@ -150,7 +152,7 @@ func WorkflowRun(c *gin.Context) {
 		agent.Model, response.ExecuteID)
 
 	// 保存工作流消息记录
-	if err := saveWorkflowMessage(c, &workflowRequest, agent, response); err != nil {
+	if err := saveWorkflowMessage(c, &workflowRequest, agent, response, quotaHold, 0); err != nil {
 		logger.SysErrorf("保存工作流消息失败: %v", err)
 		// 不影响主流程，继续返回成功响应
 	}
@@ -159,6 +161,29 @@ func WorkflowRun(c *gin.Context) {
 	c.JSON(200, model.Success.ToResponse(response))
 }
 
+// respondWorkflowError 根据错误类型返回不同的状态码
+func respondWorkflowError(c *gin.Context, err error) {
+	statusCode := 500
+	if strings.Contains(err.Error(), "参数") || strings.Contains(err.Error(), "输入") {
+		statusCode = 400
+	} else if strings.Contains(err.Error(), "未找到") || strings.Contains(err.Error(), "不存在") {
+		statusCode = 404
+	}
+
+	// 根据状态码选择合适的响应码
+	var responseCode model.ResponseCode
+	switch statusCode {
+	case 400:
+		responseCode = model.ParamError
+	case 404:
+		responseCode = model.NotFound
+	default:
+		responseCode = model.SystemError
+	}
+
+	c.JSON(statusCode, responseCode.ToResponse(errors.New(err.Error())))
+}
+
 func GetSessionAgent(c *gin.Context) (agent *model.Agent, err error) {
 	sessionAgent, exists := c.Get(session.SESSION_AGENT)
 	if !exists {
@@ -269,7 +294,7 @@ func processChatRequest(c *gin.Context, chatRequest *ChatRequest, agent *model.A
 	// {"conversation_id":619,"frequency_penalty":0.5,"messages":[{"role":"user","content":"[{\"type\":\"text\",\"content\":\"解析这张图片\"},{\"type\":\"image\",\"content\":\"file_id:175\"}]"}],"model":"agent-56","presence_penalty":0.5,"stream":true,"temperature":0.2,"top_p":0.75}
 	logger.SysLogf("Relay", "Relay", "RelayMode", relayMode, "Agent", agent)
 
-	retryTimes := config.CHANNEL_RETRY_TIMES
+	retryTimes := int(config.CHANNEL_RETRY_TIMES)
 	requestModel := agent.Model
 
 	// 如果是工作流类型的 agent，需要转换模型名称格式
@@ -285,12 +310,41 @@ func processChatRequest(c *gin.Context, chatRequest *ChatRequest, agent *model.A
 
 	chatRequest.Model = requestModel
 
+	// 渲染提示词中的变量，需在历史裁剪和回答缓存之前完成
+	renderAgentPrompt(c, agent)
+
+	// 审核用户输入，拦截时已返回错误。需在历史摘要、知识库检索等任何上游调用之前完成
+	if !moderateChatInput(c, chatRequest, agent) {
+		return
+	}
+
+	// 按智能体配置重建和裁剪会话历史
+	applyHistoryStrategy(c, chatRequest, agent)
+
+	// 检索智能体关联的知识库，参考资料追加到提示词中，需在文档内联和回答缓存之前完成
+	retrieveAgentKnowledge(c, chatRequest, agent)
+
+	// 大模型渠道的智能体将用户上传的文档替换为提取出的文本，文本同样经过输入审核，拦截时已返回错误
+	if !inlineChatDocuments(c, chatRequest, agent) {
+		return
+	}
+
+	// 命中回答缓存时直接回放，不再请求渠道
+	if tryReplayResponseCache(c, chatRequest, agent) {
+		return
+	}
+
 	// if 1o model, unset temperature, presence_penalty, frequency_penalty, top_p
 	if agent.ChannelType == channeltype.OpenAI && strings.Contains(strings.ToLower(chatRequest.Model), "o1") {
 		chatRequest.Temperature = 0
 		chatRequest.PresencePenalty = 0
 		chatRequest.FrequencyPenalty = 0
 		chatRequest.TopP = 0
+		if chatRequest.MaxTokens > 0 {
+			maxTokens := chatRequest.MaxTokens
+			chatRequest.MaxCompletionTokens = &maxTokens
+			chatRequest.MaxTokens = 0
+		}
 	}
 
 	modifiedBody, err := json.Marshal(chatRequest)
@@ -306,29 +360,43 @@ func processChatRequest(c *gin.Context, chatRequest *ChatRequest, agent *model.A
 	// 	return
 	// }
 
-	var lastFailedChannelId int64
-	for i := retryTimes; i > 0; i-- {
-		// 使用新的服务函数获取渠道并检查/刷新token
-		channel, err := service.GetChannelWithTokenRefresh(ctx, agent.Eid, agent.ChannelType, requestModel, lastFailedChannelId)
+	var failedChannelIds []int64
+	for attempt := 1; attempt <= retryTimes; attempt++ {
+		// 使用新的服务函数获取渠道并检查/刷新token，已失败的渠道不再参与选择
+		channel, err := service.GetChannelWithTokenRefresh(ctx, agent.Eid, agent.ChannelType, requestModel, failedChannelIds)
 		if err != nil {
 			logger.Errorf(ctx, "获取渠道失败: %s", err.Error())
-			continue
+			break
 		}
 
 		middleware.SetupContextForSelectedChannel(c, channel, requestModel)
-		logger.SysLogf("ChannelID", channel.ChannelID)
+		logger.SysLogf("ChannelID: %d, Priority: %d, Attempt: %d", channel.ChannelID, channel.GetPriority(), attempt)
 		channelId := c.GetInt64(ctxkey.ChannelId)
-		lastFailedChannelId = channelId
 		requestBody, err := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		attemptStart := time.Now()
 		bizErr := relayHelper(c, relayMode)
+		retry := bizErr != nil && attempt < retryTimes && isRetryableRelayError(c, bizErr)
+		go recordRelayAttempt(c.GetInt64(ctxkey.RelayMessageId), helper.GetRequestID(ctx), agent, channel, attempt, bizErr, retry, attemptStart)
 		if bizErr == nil {
+			model.RecordChannelResult(channel.ChannelID, true, time.Since(attemptStart))
 			return
 		}
-		channelName := c.GetString(ctxkey.ChannelName)
-		go processChannelRelayError(ctx, int(config.GetUserId(c)), int(channelId), channelName, *bizErr)
+		if !isLocalRelayError(bizErr) {
+			channelName := c.GetString(ctxkey.ChannelName)
+			go processChannelRelayError(ctx, int(config.GetUserId(c)), int(channelId), channelName, *bizErr)
+		}
+		if retry {
+			logger.Warnf(ctx, "channel %d failed with status %d, retrying with next channel", channelId, bizErr.StatusCode)
+			failedChannelIds = append(failedChannelIds, channelId)
+			continue
+		}
+		statusCode := bizErr.StatusCode
+		if statusCode == 0 {
+			statusCode = 500
+		}
 		// return error message
-		c.JSON(500, model.OpenAIErrorResponse{
+		c.JSON(statusCode, model.OpenAIErrorResponse{
 			Error: struct {
 				Message string `json:"message"`
 				Type    string `json:"type"`
@@ -350,6 +418,45 @@ func processChatRequest(c *gin.Context, chatRequest *ChatRequest, agent *model.A
 	})
 }
 
+// isRetryableRelayError 判断失败是否可以切换到下一个渠道重试：
+// 仅限上游限流（429）、上游 5xx 以及请求超时等网络错误，且尚未向客户端写出任何数据
+func isRetryableRelayError(c *gin.Context, err *relay_model.ErrorWithStatusCode) bool {
+	if err == nil || isLocalRelayError(err) || c.Writer.Written() {
+		return false
+	}
+	switch err.Code {
+	case "invalid_text_request", "invalid_api_type", "marshal_request_failed", "create_message_failed":
+		return false
+	}
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= http.StatusInternalServerError
+}
+
+// recordRelayAttempt 记录一次渠道转发尝试
+func recordRelayAttempt(messageID int64, requestId string, agent *model.Agent, channel *model.Channel, attempt int, bizErr *relay_model.ErrorWithStatusCode, retried bool, startTime time.Time) {
+	record := &model.RelayAttempt{
+		Eid:         agent.Eid,
+		MessageID:   messageID,
+		RequestId:   requestId,
+		AgentID:     agent.AgentID,
+		ChannelID:   channel.ChannelID,
+		ChannelName: channel.Name,
+		Priority:    channel.GetPriority(),
+		Attempt:     attempt,
+		Success:     bizErr == nil,
+		Retried:     retried,
+		StatusCode:  http.StatusOK,
+		ElapsedTime: helper.CalcElapsedTime(startTime),
+	}
+	if bizErr != nil {
+		record.StatusCode = bizErr.StatusCode
+		record.ErrorType = bizErr.Type
+		record.ErrorMessage = bizErr.Message
+	}
+	if err := model.CreateRelayAttempt(record); err != nil {
+		logger.SysErrorf("record relay attempt failed: %v", err)
+	}
+}
+
 func relayHelper(c *gin.Context, relayMode int) *relay_model.ErrorWithStatusCode {
 	var err *relay_model.ErrorWithStatusCode
 	switch relayMode {
@@ -371,10 +478,14 @@ func relayHelper(c *gin.Context, relayMode int) *relay_model.ErrorWithStatusCode
 
 func processChannelRelayError(ctx context.Context, userId int, channelId int, channelName string, err relay_model.ErrorWithStatusCode) {
 	logger.Errorf(ctx, "relay error (channel id %d, user id: %d): %+v", channelId, userId, err.Error)
+	model.RecordChannelResult(int64(channelId), false, 0)
 	if monitor.ShouldDisableChannel(&err.Error, err.StatusCode) {
-		monitor.DisableChannel(channelId, channelName, err.Message)
-	} else {
-		monitor.Emit(channelId, false)
+		// 自动禁用的渠道由健康检查任务在恢复后重新启用
+		if updateErr := model.UpdateChannelStatus(int64(channelId), model.ChannelStatusAutoDisabled); updateErr != nil {
+			logger.Errorf(ctx, "disable channel %d failed: %s", channelId, updateErr.Error())
+			return
+		}
+		logger.SysLogf("channel #%d (%s) has been disabled: %s", channelId, channelName, err.Message)
 	}
 }
 
@@ -483,6 +594,9 @@ func sendSaveMessageEvent(c *gin.Context, requestId, modelName string, messageID
 		"message_id": messageID,
 		"choices":    []interface{}{},
 	}
+	if citations := getKnowledgeCitations(c); len(citations) > 0 {
+		payload["citations"] = citations
+	}
 	b, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -575,7 +689,8 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 	}
 	meta.IsStream = textRequest.Stream
 
-	if meta.IsStream {
+	// 渠道重试时复用首次创建的拦截器
+	if _, exists := c.Get("stream_response_collector"); meta.IsStream && !exists {
 		SetupStreamInterceptor(c)
 	}
 	// 获取请求ID
@@ -588,16 +703,13 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 	meta.OriginModelName = textRequest.Model
 	textRequest.Model, _ = getMappedModelName(textRequest.Model, meta.ModelMapping)
 	meta.ActualModelName = textRequest.Model
-	modelRatio := billing_ratio.GetModelRatio(textRequest.Model, meta.ChannelType)
-	// groupRatio := billing_ratio.GetGroupRatio(meta.Group)
-	groupRatio := 1.0
-	ratio := modelRatio * groupRatio
 	// set system prompt if not empty
 	agent, err := GetSessionAgent(c)
 	if err != nil {
 		logger.Errorf(ctx, "getSessionAgent failed: %s", err.Error())
 		return openai.ErrorWrapper(err, "invalid_text_request", http.StatusBadRequest)
 	}
+	pricing := service.GetModelPricing(agent.Eid, int64(meta.ChannelId), meta.ChannelType, textRequest.Model, config.GetUserGroupID(c))
 	systemPromptReset := false
 	if agent.Prompt != "" {
 		systemPromptReset = addAgentPrompt(ctx, textRequest, agent.Prompt, agent.ChannelType)
@@ -610,11 +722,6 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 
 	promptTokens := getPromptTokens(textRequest, meta.Mode)
 	meta.PromptTokens = promptTokens
-	preConsumedQuota, bizErr := preConsumeQuota(ctx, textRequest, promptTokens, ratio, meta)
-	if bizErr != nil {
-		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
-		return bizErr
-	}
 	conversation, err := GetSessionConversation(c)
 	if err != nil {
 		logger.Errorf(ctx, "getSessionConversation failed: %s", err.Error())
@@ -625,6 +732,11 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 	if adaptor == nil {
 		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
 	}
+	quotaHold, bizErr := preConsumeQuota(ctx, agent.Eid, user_id, textRequest, promptTokens, pricing.Ratio(), meta)
+	if bizErr != nil {
+		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
+		return bizErr
+	}
 	adaptor.Init(meta)
 	customConfig := &custom.CustomConfig{
 		UserId:                     "angethub_u" + fmt.Sprintf("%d", user_id),
@@ -636,19 +748,59 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 	err = service.SetCustomConfig(&adaptor, customConfig)
 
 	if err != nil {
+		returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
 		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
 	}
 
-	// 1) 前置创建消息记录，获取 messageID
-	messageID, errCreate := createInitialMessage(c, agent, user_id, conversation.ConversationID, textRequest, meta, requestId)
-	if errCreate != nil {
-		logger.Errorf(ctx, "createInitialMessage failed: %s", errCreate.Error())
-		return openai.ErrorWrapper(errCreate, "create_message_failed", http.StatusInternalServerError)
+	// 1) 前置创建消息记录，获取 messageID；渠道重试时沿用同一条消息
+	messageID := c.GetInt64(ctxkey.RelayMessageId)
+	if messageID == 0 {
+		var errCreate error
+		messageID, errCreate = createInitialMessage(c, agent, user_id, conversation.ConversationID, textRequest, meta, requestId)
+		if errCreate != nil {
+			logger.Errorf(ctx, "createInitialMessage failed: %s", errCreate.Error())
+			returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
+			return openai.ErrorWrapper(errCreate, "create_message_failed", http.StatusInternalServerError)
+		}
+		c.Set(ctxkey.RelayMessageId, messageID)
+		saveKnowledgeCitations(c, agent.Eid, messageID)
+	}
+
+	// 发送给渠道前对个人信息脱敏，消息记录仍保存原始内容；回答中的占位符在写给客户端前还原
+	upstreamRequest, redactor := redactTextRequest(c, agent, textRequest, messageID)
+
+	// 2) 智能体配置了工具时，由服务端执行模型返回的工具调用
+	if tools := getRelayTools(agent, meta, upstreamRequest); len(tools) > 0 {
+		citation := newCitationWriter(c, meta.IsStream)
+		moderation := newModerationWriter(c, agent, meta.IsStream)
+		restore := newPIIRestoreWriter(c, redactor, meta.IsStream)
+		capture := newResponseCapture(c, meta.IsStream)
+		usage, responseContent, reasoningContent, bizErr := relayWithTools(c, agent, meta, adaptor, upstreamRequest, tools, messageID, requestId)
+		capture.finish()
+		restore.finish()
+		moderation.finish()
+		citation.finish()
+		responseContent, reasoningContent = redactor.Restore(responseContent), redactor.Restore(reasoningContent)
+		if bizErr != nil {
+			failUpdateMessage(c, agent, messageID, startTime, meta, textRequest.Model, requestId, bizErr.Message)
+			returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
+			return bizErr
+		}
+		customConfig = service.GetCustomConfig(&adaptor)
+		cachedTokens := GetCachedPromptTokens(c, meta.IsStream, capture.bytes())
+		go postConsumeQuota(c, agent, user_id, startTime, ctx, usage, cachedTokens, meta,
+			textRequest, pricing, quotaHold,
+			systemPromptReset, moderation.answer(responseContent), reasoningContent, customConfig, messageID)
+		if !moderation.blocked() {
+			storeResponseCache(c, agent, textRequest.Model, moderation.answer(responseContent), reasoningContent)
+		}
+		return nil
 	}
 
 	// get request body
-	requestBody, err := getRequestBody(c, meta, textRequest, adaptor)
+	requestBody, err := getRequestBody(c, meta, upstreamRequest, adaptor)
 	if err != nil {
+		returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
 		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
 	}
 
@@ -656,6 +808,7 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 	resp, err := adaptor.DoRequest(c, meta, requestBody)
 	if err != nil {
 		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
 		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
 
@@ -670,6 +823,7 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 
 		// 更新前置消息为失败记录
 		failUpdateMessage(c, agent, messageID, startTime, meta, textRequest.Model, requestId, string(errBodyBytes))
+		returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
 
 		// 返回统一错误处理
 		return controller.RelayErrorHandler(resp)
@@ -683,22 +837,36 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 		}
 	}
 
-	// do response
+	// do response，启用回答审核时由 moderationWriter 过滤输出，非流式回答的知识库引用由 citationWriter 追加
+	citation := newCitationWriter(c, meta.IsStream)
+	moderation := newModerationWriter(c, agent, meta.IsStream)
+	restore := newPIIRestoreWriter(c, redactor, meta.IsStream)
+	capture := newResponseCapture(c, meta.IsStream)
 	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	capture.finish()
+	restore.finish()
+	moderation.finish()
+	citation.finish()
 	logger.SysLogf("usage", usage)
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
-		//billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
 		return respErr
 	}
 
 	responseContent, reasoningContent := GetResponseContent(c, meta.IsStream, resp)
+	responseContent = moderation.answer(redactor.Restore(responseContent))
+	reasoningContent = redactor.Restore(reasoningContent)
 
 	customConfig = service.GetCustomConfig(&adaptor)
+	cachedTokens := GetCachedPromptTokens(c, meta.IsStream, capture.bytes())
 	// post-consume quota
-	go postConsumeQuota(c, agent, user_id, startTime, ctx, usage, meta,
-		textRequest, ratio, preConsumedQuota, modelRatio, groupRatio,
+	go postConsumeQuota(c, agent, user_id, startTime, ctx, usage, cachedTokens, meta,
+		textRequest, pricing, quotaHold,
 		systemPromptReset, responseContent, reasoningContent, customConfig, messageID)
+	if !moderation.blocked() {
+		storeResponseCache(c, agent, textRequest.Model, responseContent, reasoningContent)
+	}
 	return nil
 }
 
@@ -761,29 +929,24 @@ func addAgentPrompt(ctx context.Context, textRequest *relay_model.GeneralOpenAIR
 	return true
 }
 
+// postConsumeQuota 按实际用量和计价规则结算配额，并更新消息和会话。cachedTokens 为输入中命中上游缓存的 token 数
 func postConsumeQuota(c *gin.Context, agent *model.Agent, user_id int64, startTime time.Time,
-	ctx context.Context, usage *relay_model.Usage, meta *meta.Meta, textRequest *relay_model.GeneralOpenAIRequest,
-	ratio float64, preConsumedQuota int64, modelRatio float64,
-	groupRatio float64, systemPromptReset bool, responseContent string, reasoningContent string, customConfig *custom.CustomConfig, messageID int64) {
+	ctx context.Context, usage *relay_model.Usage, cachedTokens int, meta *meta.Meta, textRequest *relay_model.GeneralOpenAIRequest,
+	pricing *service.ModelPricing, quotaHold service.UserQuotaHold,
+	systemPromptReset bool, responseContent string, reasoningContent string, customConfig *custom.CustomConfig, messageID int64) {
 	if usage == nil {
 		logger.Error(ctx, "usage is nil, which is unexpected")
+		returnPreConsumedQuota(agent.Eid, user_id, quotaHold)
 		return
 	}
-	var quota int64
-	completionRatio := billing_ratio.GetCompletionRatio(textRequest.Model, meta.ChannelType)
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
-	quota = int64(math.Ceil((float64(promptTokens) + float64(completionTokens)*completionRatio) * ratio))
-	if ratio != 0 && quota <= 0 {
-		quota = 1
-	}
 	totalTokens := promptTokens + completionTokens
-	if totalTokens == 0 {
-		quota = 0
-	}
-	quotaDelta := quota - preConsumedQuota
-
-	logContent := fmt.Sprintf("倍率：%.2f × %.2f × %.2f", modelRatio, groupRatio, completionRatio)
+	charge := pricing.Charge(promptTokens, cachedTokens, completionTokens)
+	quota := charge.Quota
+	// 按实际用量结算：多退少补
+	service.PostConsumeUserQuota(agent.Eid, user_id, quotaHold, quota)
+	service.ConsumeUserPoints(agent.Eid, user_id, quota, model.PointsSourceChat, messageID)
 
 	// 获取前置保存的消息并更新
 	message, err := model.GetMessageByID(agent.Eid, messageID)
@@ -803,7 +966,8 @@ func postConsumeQuota(c *gin.Context, agent *model.Agent, user_id int64, startTi
 	message.Answer = responseContent
 	message.ReasoningContent = reasoningContent
 	message.ModelName = textRequest.Model
-	message.Quota = int(quotaDelta)
+	message.Quota = int(quota)
+	message.Cost = charge.Cost
 	message.PromptTokens = promptTokens
 	message.CompletionTokens = completionTokens
 	message.TotalTokens = totalTokens
@@ -814,7 +978,11 @@ func postConsumeQuota(c *gin.Context, agent *model.Agent, user_id int64, startTi
 	}
 	message.ElapsedTime = helper.CalcElapsedTime(startTime)
 	message.IsStream = meta.IsStream
-	message.QuotaContent = logContent
+	message.QuotaContent = charge.Detail
+	message.IsError = false
+	if customConfig != nil && customConfig.MessageId != "" {
+		message.ChannelMessageID = customConfig.MessageId
+	}
 
 	if err := model.UpdateMessage(message); err != nil {
 		logger.Errorf(ctx, "UpdateMessage failed: %s", err.Error())
@@ -833,7 +1001,7 @@ func postConsumeQuota(c *gin.Context, agent *model.Agent, user_id int64, startTi
 				"answer":   responseContent,
 			})
 
-			conversation.Quota += int(quotaDelta)
+			conversation.Quota += int(quota)
 			conversation.TotalTokens += totalTokens
 			conversation.LastMessage = string(lastMessage)
 			if customConfig != nil {
@@ -852,9 +1020,43 @@ func postConsumeQuota(c *gin.Context, agent *model.Agent, user_id int64, startTi
 	}
 }
 
-func preConsumeQuota(ctx context.Context, textRequest *relay_model.GeneralOpenAIRequest, promptTokens int, ratio float64, meta *meta.Meta) (int64, *relay_model.ErrorWithStatusCode) {
+func preConsumeQuota(ctx context.Context, eid int64, userId int64, textRequest *relay_model.GeneralOpenAIRequest, promptTokens int, ratio float64, meta *meta.Meta) (service.UserQuotaHold, *relay_model.ErrorWithStatusCode) {
 	preConsumedQuota := getPreConsumedQuota(textRequest, promptTokens, ratio)
-	return preConsumedQuota, nil
+	quotaHold, err := service.PreConsumeUserQuota(eid, userId, preConsumedQuota)
+	if err != nil {
+		logger.Warnf(ctx, "user %d quota is not enough, pre consumed quota: %d", userId, preConsumedQuota)
+		return service.UserQuotaHold{}, quotaExceededError()
+	}
+	return quotaHold, nil
+}
+
+// quotaExceededError 构造配额不足的 OpenAI 风格错误
+func quotaExceededError() *relay_model.ErrorWithStatusCode {
+	return &relay_model.ErrorWithStatusCode{
+		Error: relay_model.Error{
+			Message: "You exceeded your current quota, please contact the administrator or upgrade your subscription.",
+			Type:    "53aihub_error",
+			Code:    quotaExceededCode,
+		},
+		StatusCode: http.StatusTooManyRequests,
+	}
+}
+
+// returnPreConsumedQuota 请求失败时退还预扣配额
+func returnPreConsumedQuota(eid int64, userId int64, quotaHold service.UserQuotaHold) {
+	if quotaHold.Quota == 0 {
+		return
+	}
+	service.ReturnUserQuota(eid, userId, quotaHold)
+}
+
+// isLocalRelayError 判断错误是否由本系统产生（如配额不足），此类错误不应计入渠道健康度
+func isLocalRelayError(err *relay_model.ErrorWithStatusCode) bool {
+	if err == nil {
+		return false
+	}
+	code, ok := err.Code.(string)
+	return ok && code == quotaExceededCode
 }
 
 func getPreConsumedQuota(textRequest *relay_model.GeneralOpenAIRequest, promptTokens int, ratio float64) int64 {
@@ -875,6 +1077,7 @@ func failUpdateMessage(c *gin.Context, agent *model.Agent, messageID int64, star
 	}
 	// 将错误文本写入 Answer，tokens/Quota 置零
 	msg.Answer = errText
+	msg.IsError = true
 	msg.ReasoningContent = ""
 	msg.ModelName = modelName
 	msg.Quota = 0
@@ -896,7 +1099,7 @@ func failUpdateMessage(c *gin.Context, agent *model.Agent, messageID int64, star
 }
 
 // executeWorkflow 执行工作流并返回标准响应数据
-func executeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent) (*custom.WorkflowResponseData, error) {
+func executeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, onEvent custom.WorkflowEventHandler) (*custom.WorkflowResponseData, error) {
 	// 允许空参数，归一化为 {}
 	if workflowRequest.Parameters == nil || len(workflowRequest.Parameters) == 0 {
 		workflowRequest.Parameters = map[string]interface{}{}
@@ -905,6 +1108,10 @@ func executeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent
 	logger.SysLogf("工作流执行开始 - Model: %s, ConversationID: %d, Parameters: %+v",
 		workflowRequest.Model, workflowRequest.ConversationID, workflowRequest.Parameters)
 
+	if err := moderateWorkflowInput(c, agent, workflowRequest.Parameters); err != nil {
+		return nil, err
+	}
+
 	modelName := agent.Model
 	// 获取渠道并检查/刷新token
 	logger.SysLogf("工作流执行 - 开始获取渠道，Eid: %d, ChannelType: %d, Model: %s",
@@ -912,7 +1119,7 @@ func executeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent
 
 	// 使用新的服务函数获取渠道并检查/刷新token
 	ctx := c.Request.Context()
-	channel, err := service.GetChannelWithTokenRefresh(ctx, agent.Eid, agent.ChannelType, modelName, 0)
+	channel, err := service.GetChannelWithTokenRefresh(ctx, agent.Eid, agent.ChannelType, modelName, nil)
 	if err != nil {
 		providerID := agent.GetProviderID()
 		logger.SysLogf("尝试获取平台 ID %d", providerID)
@@ -944,32 +1151,42 @@ func executeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent
 	// 设置渠道上下文
 	middleware.SetupContextForSelectedChannel(c, channel, modelName)
 
+	// 审核流式事件和最终输出
+	moderation := newWorkflowModeration(c, agent, onEvent)
+	if moderation != nil {
+		onEvent = moderation.handler()
+	}
+
 	// 直接调用工作流适配器执行
-	return executeWorkflowDirect(c, workflowRequest, agent, channel, modelName)
+	response, err := executeWorkflowDirect(c, workflowRequest, agent, channel, modelName, onEvent)
+	if err == nil {
+		moderation.finish(response)
+	}
+	return response, err
 }
 
 // executeWorkflowDirect 直接执行工作流，简化参数传递
-func executeWorkflowDirect(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string) (*custom.WorkflowResponseData, error) {
+func executeWorkflowDirect(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string, onEvent custom.WorkflowEventHandler) (*custom.WorkflowResponseData, error) {
 	// 根据渠道类型选择对应的工作流适配器
 	if channel.Type == channeltype.Coze || channel.Type == model.ChannelApiTypeCozeStudio {
-		return executeCozeWorkflow(c, workflowRequest, agent, channel, modelName)
+		return executeCozeWorkflow(c, workflowRequest, agent, channel, modelName, onEvent)
 	}
 
 	if channel.Type == model.ChannelApiDify {
-		return executeDifyWorkflow(c, workflowRequest, agent, channel, modelName)
+		return executeDifyWorkflow(c, workflowRequest, agent, channel, modelName, onEvent)
 	}
 
 	if channel.Type == channeltype.FastGPT || channel.Type == model.ChannelApiTypeFastGpt {
 		// 这个 fastgpt 是因为 适配器的默认给 0 了，所以这里要手动设置一下，实际上数据库里面不会存 1007
-		return executeFastGPTWorkflow(c, workflowRequest, agent, channel, modelName)
+		return executeFastGPTWorkflow(c, workflowRequest, agent, channel, modelName, onEvent)
 	}
 
 	if channel.Type == model.ChannelApi53AI {
-		return executeAI53Workflow(c, workflowRequest, agent, channel, modelName)
+		return executeAI53Workflow(c, workflowRequest, agent, channel, modelName, onEvent)
 	}
 
 	if channel.Type == model.ChannelApiTypeN8n {
-		return executeN8nWorkflow(c, workflowRequest, agent, channel, modelName)
+		return executeN8nWorkflow(c, workflowRequest, agent, channel, modelName, onEvent)
 	}
 
 	return nil, fmt.Errorf("不支持的渠道类型: %d", channel.Type)
@@ -1026,7 +1243,7 @@ func handleWorkflowError(resp *http.Response, workflowType string) error {
 }
 
 // executeCozeWorkflow 执行 Coze 工作流
-func executeCozeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string) (*custom.WorkflowResponseData, error) {
+func executeCozeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string, onEvent custom.WorkflowEventHandler) (*custom.WorkflowResponseData, error) {
 	// 获取元数据
 	meta := GetByContext(c)
 	meta.APIType = model.GetApiType(channel.Type)
@@ -1047,6 +1264,7 @@ func executeCozeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 	// 创建工作流适配器
 	workflowAdaptor := &coze.WorkflowAdaptor{}
 	workflowAdaptor.Init(meta)
+	workflowAdaptor.EventHandler = onEvent
 
 	// 设置自定义配置
 	user_id := config.GetUserId(c)
@@ -1088,19 +1306,28 @@ func executeCozeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 		return nil, handleWorkflowError(resp, "Coze")
 	}
 
-	// 读取响应
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取工作流响应失败: %v", err)
-	}
+	var workflowResponse *custom.WorkflowResponseData
+	if onEvent != nil {
+		// 流式模式，处理 stream_run 事件
+		workflowResponse, err = workflowAdaptor.ProcessStreamResponse(resp)
+		if err != nil {
+			return nil, fmt.Errorf("处理工作流流式响应失败: %v", err)
+		}
+	} else {
+		// 读取响应
+		responseBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("读取工作流响应失败: %v", err)
+		}
 
-	logger.SysLogf("Coze工作流原始响应 - StatusCode: %d, 响应长度: %d bytes",
-		resp.StatusCode, len(responseBody))
+		logger.SysLogf("Coze工作流原始响应 - StatusCode: %d, 响应长度: %d bytes",
+			resp.StatusCode, len(responseBody))
 
-	// 转换响应
-	workflowResponse, err := workflowAdaptor.ConvertToWorkflowResponseData(responseBody)
-	if err != nil {
-		return nil, fmt.Errorf("转换工作流响应失败: %v", err)
+		// 转换响应
+		workflowResponse, err = workflowAdaptor.ConvertToWorkflowResponseData(responseBody)
+		if err != nil {
+			return nil, fmt.Errorf("转换工作流响应失败: %v", err)
+		}
 	}
 
 	// 设置响应信息
@@ -1119,7 +1346,7 @@ func executeCozeWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 }
 
 // executeDifyWorkflow 执行 DIFY 工作流
-func executeDifyWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string) (*custom.WorkflowResponseData, error) {
+func executeDifyWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string, onEvent custom.WorkflowEventHandler) (*custom.WorkflowResponseData, error) {
 	// 获取元数据
 	meta := GetByContext(c)
 	meta.APIType = model.GetApiType(channel.Type)
@@ -1140,6 +1367,7 @@ func executeDifyWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 	// 创建工作流适配器
 	workflowAdaptor := &dify.DifyWorkflowAdaptor{}
 	workflowAdaptor.Init(meta)
+	workflowAdaptor.EventHandler = onEvent
 
 	// 设置自定义配置
 	user_id := config.GetUserId(c)
@@ -1205,7 +1433,7 @@ func executeDifyWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 }
 
 // executeFastGPTWorkflow 执行 FastGPT 工作流
-func executeFastGPTWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string) (*custom.WorkflowResponseData, error) {
+func executeFastGPTWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string, onEvent custom.WorkflowEventHandler) (*custom.WorkflowResponseData, error) {
 	// 检查 Agent 类型是否为工作流类型
 	if agent.AgentType != model.AgentTypeWorkflow {
 		return nil, fmt.Errorf("Agent 类型不是工作流类型，当前类型: %d", agent.AgentType)
@@ -1231,6 +1459,7 @@ func executeFastGPTWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest,
 	// 创建工作流适配器
 	workflowAdaptor := &fastgpt.FastGPTWorkflowAdaptor{}
 	workflowAdaptor.Init(meta)
+	workflowAdaptor.EventHandler = onEvent
 
 	// 设置自定义配置
 	user_id := config.GetUserId(c)
@@ -1269,7 +1498,12 @@ func executeFastGPTWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest,
 	}
 
 	// 处理响应
-	workflowResponse, err := workflowAdaptor.ProcessWorkflowResponse(resp)
+	var workflowResponse *custom.WorkflowResponseData
+	if onEvent != nil {
+		workflowResponse, err = workflowAdaptor.ProcessWorkflowStreamResponse(resp)
+	} else {
+		workflowResponse, err = workflowAdaptor.ProcessWorkflowResponse(resp)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("处理FastGPT工作流响应失败: %v", err)
 	}
@@ -1290,7 +1524,7 @@ func executeFastGPTWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest,
 }
 
 // executeAI53Workflow 执行 53AI 工作流
-func executeAI53Workflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string) (*custom.WorkflowResponseData, error) {
+func executeAI53Workflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string, onEvent custom.WorkflowEventHandler) (*custom.WorkflowResponseData, error) {
 	// 检查 Agent 类型是否为工作流类型
 	if agent.AgentType != model.AgentTypeWorkflow {
 		return nil, fmt.Errorf("Agent 类型不是工作流类型，当前类型: %d", agent.AgentType)
@@ -1316,6 +1550,7 @@ func executeAI53Workflow(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 	// 创建工作流适配器
 	workflowAdaptor := &adaptor53AI.AI53WorkflowAdaptor{}
 	workflowAdaptor.Init(meta)
+	workflowAdaptor.EventHandler = onEvent
 
 	// 设置自定义配置
 	user_id := config.GetUserId(c)
@@ -1374,8 +1609,9 @@ func executeAI53Workflow(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 	return workflowResponse, nil
 }
 
-// saveWorkflowMessage 保存工作流消息记录
-func saveWorkflowMessage(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, response *custom.WorkflowResponseData) error {
+// saveWorkflowMessage 保存工作流消息记录，并按实际用量结算预扣的配额
+// messageID 不为 0 时更新已有的消息记录（异步运行提交时预先创建）
+func saveWorkflowMessage(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, response *custom.WorkflowResponseData, quotaHold service.UserQuotaHold, messageID int64) error {
 	ctx := c.Request.Context()
 
 	// 获取用户信息
@@ -1421,21 +1657,14 @@ func saveWorkflowMessage(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 	// 计算 token 消耗
 	promptTokens, completionTokens, totalTokens := calculateWorkflowTokens(workflowRequest, response)
 
-	// 获取费率信息（复用 chat 的费率计算逻辑）
+	// 按计价规则计算配额（与 chat 一致）
 	channelType := getWorkflowChannelType(response)
-	modelRatio := billing_ratio.GetModelRatio(workflowRequest.Model, channelType)
-	groupRatio := 1.0 // 与 chat 保持一致
-	completionRatio := billing_ratio.GetCompletionRatio(workflowRequest.Model, channelType)
-	ratio := modelRatio * groupRatio
-
-	// 计算配额（复用 chat 的配额计算公式）
-	quota := int64(math.Ceil((float64(promptTokens) + float64(completionTokens)*completionRatio) * ratio))
-	if ratio != 0 && quota <= 0 {
-		quota = 1 // 边界情况处理，与 chat 保持一致
-	}
+	pricing := service.GetModelPricing(agent.Eid, int64(response.ChannelID), channelType, workflowRequest.Model, config.GetUserGroupID(c))
+	charge := pricing.Charge(promptTokens, 0, completionTokens)
+	quota := charge.Quota
 
-	// 生成配额内容记录（复用 chat 的格式）
-	quotaContent := fmt.Sprintf("倍率：%.2f × %.2f × %.2f", modelRatio, groupRatio, completionRatio)
+	// 按实际用量结算：多退少补
+	service.PostConsumeUserQuota(agent.Eid, userId, quotaHold, quota)
 
 	// 创建消息记录
 	message := &model.Message{
@@ -1448,23 +1677,35 @@ func saveWorkflowMessage(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 		ReasoningContent:  "",                     // 工作流暂不支持推理内容
 		ModelName:         response.ModelName,
 		Quota:             int(quota),
+		Cost:              charge.Cost,
 		PromptTokens:      promptTokens,
 		CompletionTokens:  completionTokens,
 		TotalTokens:       totalTokens,
 		ChannelId:         response.ChannelID,
 		RequestId:         requestId,
 		ElapsedTime:       elapsedTime,
-		IsStream:          false, // 工作流不支持流式
-		QuotaContent:      quotaContent,
+		IsStream:          workflowRequest.Stream,
+		QuotaContent:      charge.Detail,
 		AgentCustomConfig: agent.CustomConfig, // 历史记录
 	}
 
 	// 保存消息到数据库
-	if err := model.CreateMessage(message); err != nil {
+	if messageID > 0 {
+		existing, err := model.GetMessageByID(agent.Eid, messageID)
+		if err != nil {
+			return fmt.Errorf("获取消息记录失败: %v", err)
+		}
+		message.ID = existing.ID
+		message.BaseModel = existing.BaseModel
+		if err := model.UpdateMessage(message); err != nil {
+			return fmt.Errorf("更新消息记录失败: %v", err)
+		}
+	} else if err := model.CreateMessage(message); err != nil {
 		return fmt.Errorf("创建消息记录失败: %v", err)
 	}
 
 	logger.SysLogf("工作流消息保存成功 - MessageID: %d, ExecuteID: %s", message.ID, response.ExecuteID)
+	service.ConsumeUserPoints(agent.Eid, userId, quota, model.PointsSourceWorkflow, message.ID)
 
 	// 更新会话的最后消息（如果有会话ID）
 	if conversationId != 0 {
@@ -1545,7 +1786,7 @@ func calculateWorkflowTokens(workflowRequest *WorkflowRunRequest, response *cust
 }
 
 // executeN8nWorkflow 执行 n8n 工作流
-func executeN8nWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string) (*custom.WorkflowResponseData, error) {
+func executeN8nWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, channel *model.Channel, modelName string, onEvent custom.WorkflowEventHandler) (*custom.WorkflowResponseData, error) {
 	// 检查 Agent 类型是否为工作流类型
 	if agent.AgentType != model.AgentTypeWorkflow {
 		return nil, fmt.Errorf("Agent 类型不是工作流类型，当前类型: %d", agent.AgentType)
@@ -1571,6 +1812,7 @@ func executeN8nWorkflow(c *gin.Context, workflowRequest *WorkflowRunRequest, age
 	// 创建工作流适配器
 	workflowAdaptor := &n8n.N8nWorkflowAdaptor{}
 	workflowAdaptor.Init(meta)
+	workflowAdaptor.EventHandler = onEvent
 
 	// 设置自定义配置
 	user_id := config.GetUserId(c)
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -17,7 +16,6 @@ import (
 	"github.com/53AI/53AIHub/service"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
-	billing_ratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relay_model "github.com/songquanpeng/one-api/relay/model"
 )
@@ -169,9 +167,29 @@ func Rerank(c *gin.Context) {
 		return
 	}
 
+	// 按输入 token 预扣配额，渠道确定前按企业默认价格估算
+	groupID := user.GroupId
+	preConsumedQuota := getRerankPricing(eid, 0, &rerankRequest, groupID).Charge(calculateRerankUsage(&rerankRequest, 0).PromptTokens, 0, 0).Quota
+	quotaHold, err := service.PreConsumeUserQuota(eid, userId, preConsumedQuota)
+	if err != nil {
+		logger.SysErrorf("❌ Rerank请求失败 - 用户配额不足, UserID: %d", userId)
+		bizErr := quotaExceededError()
+		c.JSON(bizErr.StatusCode, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: bizErr.Message,
+				Type:    bizErr.Type,
+			},
+		})
+		return
+	}
+
 	// 获取可用渠道
 	channel, err := model.GetRandomChannel(eid, channelType, rerankRequest.Model)
 	if err != nil {
+		returnPreConsumedQuota(eid, userId, quotaHold)
 		logger.Errorf(ctx, "❌ 获取 rerank 渠道失败: %v", err)
 		c.JSON(http.StatusInternalServerError, model.OpenAIErrorResponse{
 			Error: struct {
@@ -194,6 +212,7 @@ func Rerank(c *gin.Context) {
 	// 执行 rerank 请求
 	response, usage, err := executeRerankRequest(c, &rerankRequest, channel)
 	if err != nil {
+		returnPreConsumedQuota(eid, userId, quotaHold)
 		logger.Errorf(ctx, "❌ 执行 rerank 请求失败: %v", err)
 		c.JSON(http.StatusInternalServerError, model.OpenAIErrorResponse{
 			Error: struct {
@@ -222,7 +241,7 @@ func Rerank(c *gin.Context) {
 	logger.SysLogf("└─────────────────────────────────────────────────────────────")
 
 	// 异步记录使用情况
-	go recordRerankUsage(ctx, userId, eid, &rerankRequest, response, usage, int(channel.ChannelID), startTime)
+	go recordRerankUsage(ctx, userId, eid, groupID, &rerankRequest, response, usage, int(channel.ChannelID), startTime, quotaHold)
 
 	// 返回响应
 	c.JSON(http.StatusOK, response)
@@ -427,19 +446,17 @@ func calculateRerankUsage(req *RerankRequest, resultCount int) *relay_model.Usag
 	}
 }
 
-// recordRerankUsage 记录 rerank 使用情况
-func recordRerankUsage(ctx context.Context, userId, eid int64, req *RerankRequest, resp *RerankResponse, usage *relay_model.Usage, channelId int, startTime time.Time) {
-	// 计算费用
-	channelType := getChannelTypeByModel(req.Model)
-	modelRatio := billing_ratio.GetModelRatio(req.Model, channelType)
-	groupRatio := 1.0
-	completionRatio := billing_ratio.GetCompletionRatio(req.Model, channelType)
-	ratio := modelRatio * groupRatio
+// getRerankPricing 获取 rerank 模型的计价规则
+func getRerankPricing(eid, channelID int64, req *RerankRequest, groupID int64) *service.ModelPricing {
+	return service.GetModelPricing(eid, channelID, getChannelTypeByModel(req.Model), req.Model, groupID)
+}
 
-	quota := int64(math.Ceil((float64(usage.PromptTokens) + float64(usage.CompletionTokens)*completionRatio) * ratio))
-	if ratio != 0 && quota <= 0 {
-		quota = 1
-	}
+// recordRerankUsage 记录 rerank 使用情况，并按实际用量结算预扣的配额
+func recordRerankUsage(ctx context.Context, userId, eid, groupID int64, req *RerankRequest, resp *RerankResponse, usage *relay_model.Usage, channelId int, startTime time.Time, quotaHold service.UserQuotaHold) {
+	// 计算费用
+	charge := getRerankPricing(eid, int64(channelId), req, groupID).Charge(usage.PromptTokens, 0, usage.CompletionTokens)
+	quota := charge.Quota
+	service.PostConsumeUserQuota(eid, userId, quotaHold, quota)
 
 	// 序列化请求和响应
 	requestJSON, _ := json.Marshal(req)
@@ -461,6 +478,7 @@ func recordRerankUsage(ctx context.Context, userId, eid int64, req *RerankReques
 		Answer:           string(responseJSON),
 		ModelName:        req.Model,
 		Quota:            int(quota),
+		Cost:             charge.Cost,
 		PromptTokens:     usage.PromptTokens,
 		CompletionTokens: usage.CompletionTokens,
 		TotalTokens:      usage.TotalTokens,
@@ -468,10 +486,12 @@ func recordRerankUsage(ctx context.Context, userId, eid int64, req *RerankReques
 		RequestId:        requestId,
 		ElapsedTime:      helper.CalcElapsedTime(startTime),
 		IsStream:         false,
-		QuotaContent:     fmt.Sprintf("倍率：%.2f × %.2f × %.2f", modelRatio, groupRatio, completionRatio),
+		QuotaContent:     charge.Detail,
 	}
 
-	if err := model.CreateMessage(message); err != nil {
+	err := model.CreateMessage(message)
+	service.ConsumeUserPoints(eid, userId, quota, model.PointsSourceRerank, message.ID)
+	if err != nil {
 		logger.SysErrorf("❌ 记录 rerank 使用情况失败: %v", err)
 	} else {
 		logger.SysLogf("✅ Rerank使用记录保存成功")
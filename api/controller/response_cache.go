@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 命中缓存时的响应头
+	responseCacheHeader = "X-53AIHub-Cache"
+	// 流式回放时每个分片的字符数
+	responseCacheChunkSize = 20
+)
+
+// tryReplayResponseCache 智能体开启回答缓存时查询缓存，命中则直接回放缓存的回答并返回 true；
+// 未命中时将缓存 key 写入上下文，请求成功后由 storeResponseCache 写入缓存
+func tryReplayResponseCache(c *gin.Context, chatRequest *ChatRequest, agent *model.Agent) bool {
+	settings := agent.GetResponseCacheSettings()
+	if !settings.Enabled || len(chatRequest.Messages) == 0 {
+		return false
+	}
+
+	messages := make([]service.ResponseCacheMessage, 0, len(chatRequest.Messages))
+	for _, m := range chatRequest.Messages {
+		messages = append(messages, service.ResponseCacheMessage{Role: m.Role, Content: m.Content})
+	}
+	cacheKey := service.BuildResponseCacheKey(agent, messages)
+
+	entry, ok := service.GetResponseCache(agent, cacheKey)
+	if !ok {
+		c.Set(ctxkey.ResponseCacheKey, cacheKey)
+		c.Header(responseCacheHeader, "MISS")
+		return false
+	}
+
+	ctx := c.Request.Context()
+	startTime := time.Now()
+	requestId := helper.GetRequestID(ctx)
+	if requestId == "" {
+		requestId = fmt.Sprintf("req-%d", time.Now().UnixNano())
+	}
+	modelName := entry.ModelName
+	if modelName == "" {
+		modelName = chatRequest.Model
+	}
+
+	messageID, err := saveCachedMessage(c, agent, chatRequest, entry, modelName, requestId, startTime)
+	if err != nil {
+		logger.Errorf(ctx, "save cached message failed: %s", err.Error())
+	} else {
+		c.Set(ctxkey.RelayMessageId, messageID)
+		saveKnowledgeCitations(c, agent.Eid, messageID)
+	}
+
+	c.Header(responseCacheHeader, "HIT")
+	if chatRequest.Stream {
+		replayResponseCacheStream(c, entry, modelName, requestId, messageID)
+	} else {
+		replayResponseCacheJSON(c, entry, modelName, requestId)
+	}
+	return true
+}
+
+// saveCachedMessage 命中缓存时写入零消耗的消息记录，并更新会话的最后一条消息
+func saveCachedMessage(c *gin.Context, agent *model.Agent, chatRequest *ChatRequest, entry *service.ResponseCacheEntry, modelName, requestId string, startTime time.Time) (int64, error) {
+	userID := config.GetUserId(c)
+	messageJSON, err := json.Marshal(chatRequest.Messages)
+	if err != nil {
+		messageJSON = []byte("[]")
+	}
+
+	var conversationID int64
+	conversation, convErr := GetSessionConversation(c)
+	if convErr == nil {
+		conversationID = conversation.ConversationID
+	}
+
+	msg := &model.Message{
+		Eid:               agent.Eid,
+		UserID:            userID,
+		ConversationID:    conversationID,
+		AgentID:           agent.AgentID,
+		Message:           string(messageJSON),
+		Answer:            entry.Answer,
+		ReasoningContent:  entry.ReasoningContent,
+		ModelName:         modelName,
+		RequestId:         requestId,
+		ElapsedTime:       helper.CalcElapsedTime(startTime),
+		IsStream:          chatRequest.Stream,
+		IsCached:          true,
+		AgentCustomConfig: agent.CustomConfig,
+	}
+	if err := model.CreateMessage(msg); err != nil {
+		return 0, err
+	}
+
+	if conversation != nil {
+		lastMessage, _ := json.Marshal(map[string]string{
+			"question": string(messageJSON),
+			"answer":   entry.Answer,
+		})
+		conversation.LastMessage = string(lastMessage)
+		if err := model.UpdateConversation(conversation); err != nil {
+			logger.Errorf(c.Request.Context(), "UpdateConversation failed: %s", err.Error())
+		}
+	}
+	return msg.ID, nil
+}
+
+// replayResponseCacheJSON 以非流式格式返回缓存的回答
+func replayResponseCacheJSON(c *gin.Context, entry *service.ResponseCacheEntry, modelName, requestId string) {
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": entry.Answer,
+	}
+	if entry.ReasoningContent != "" {
+		message["reasoning_content"] = entry.ReasoningContent
+	}
+	response := map[string]interface{}{
+		"id":      requestId,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   modelName,
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"message":       message,
+				"finish_reason": "stop",
+			},
+		},
+		"usage": map[string]int{
+			"prompt_tokens":     0,
+			"completion_tokens": 0,
+			"total_tokens":      0,
+		},
+	}
+	if citations := getKnowledgeCitations(c); len(citations) > 0 {
+		response["citations"] = citations
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// replayResponseCacheStream 以 SSE 格式分片回放缓存的回答，首帧与正常转发一致携带 message_id
+func replayResponseCacheStream(c *gin.Context, entry *service.ResponseCacheEntry, modelName, requestId string, messageID int64) {
+	ctx := c.Request.Context()
+	if err := sendSaveMessageEvent(c, requestId, modelName, messageID); err != nil {
+		logger.Warnf(ctx, "sendSaveMessageEvent failed: %s", err.Error())
+		return
+	}
+
+	created := time.Now().Unix()
+	writeChunk := func(delta map[string]interface{}, finishReason interface{}) error {
+		payload, err := json.Marshal(map[string]interface{}{
+			"id":      requestId,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   modelName,
+			"choices": []interface{}{
+				map[string]interface{}{
+					"index":         0,
+					"delta":         delta,
+					"finish_reason": finishReason,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return writeSSEData(c, payload)
+	}
+
+	for _, piece := range splitRunes(entry.ReasoningContent, responseCacheChunkSize) {
+		if err := writeChunk(map[string]interface{}{"role": "assistant", "reasoning_content": piece}, nil); err != nil {
+			return
+		}
+	}
+	for _, piece := range splitRunes(entry.Answer, responseCacheChunkSize) {
+		if err := writeChunk(map[string]interface{}{"role": "assistant", "content": piece}, nil); err != nil {
+			return
+		}
+	}
+	if err := writeChunk(map[string]interface{}{}, "stop"); err != nil {
+		return
+	}
+	_ = writeSSEData(c, []byte("[DONE]"))
+}
+
+func writeSSEData(c *gin.Context, data []byte) error {
+	chunk := append([]byte("data: "), data...)
+	chunk = append(chunk, []byte("\n\n")...)
+	if _, err := c.Writer.Write(chunk); err != nil {
+		return err
+	}
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// splitRunes 按字符数切分文本，避免截断多字节字符
+func splitRunes(text string, size int) []string {
+	runes := []rune(text)
+	pieces := make([]string, 0, len(runes)/size+1)
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		pieces = append(pieces, string(runes[start:end]))
+	}
+	return pieces
+}
+
+// storeResponseCache 请求成功后写入缓存，仅在本次请求查询过缓存且未命中时生效
+func storeResponseCache(c *gin.Context, agent *model.Agent, modelName, responseContent, reasoningContent string) {
+	cacheKey := c.GetString(ctxkey.ResponseCacheKey)
+	if cacheKey == "" || responseContent == "" {
+		return
+	}
+	settings := agent.GetResponseCacheSettings()
+	entry := &service.ResponseCacheEntry{
+		Answer:           responseContent,
+		ReasoningContent: reasoningContent,
+		ModelName:        modelName,
+	}
+	go func() {
+		if err := service.SetResponseCache(agent, cacheKey, entry, time.Duration(settings.TTL)*time.Second); err != nil {
+			logger.SysErrorf("写入回答缓存失败 - AgentID: %d, Error: %v", agent.AgentID, err)
+		}
+	}()
+}
+
+// getResponseCacheAgent 解析路径中的智能体，仅管理员可操作
+func getResponseCacheAgent(c *gin.Context) (*model.Agent, bool) {
+	agentID, err := strconv.ParseInt(c.Param("agent_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	agent, err := model.GetAgentByID(config.GetEID(c), agentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	if !common.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
+		return nil, false
+	}
+	return agent, true
+}
+
+// @Summary Get agent response cache stats
+// @Description 获取智能体回答缓存的配置、有效条目数和命中率。缓存通过智能体 settings 中的 response_cache 字段开启，如 {"response_cache":{"enabled":true,"ttl":3600}}
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "Agent ID"
+// @Success 200 {object} model.CommonResponse{data=service.ResponseCacheStats} "Success"
+// @Router /api/agents/{agent_id}/response_cache [get]
+func GetAgentResponseCache(c *gin.Context) {
+	agent, ok := getResponseCacheAgent(c)
+	if !ok {
+		return
+	}
+
+	stats, err := service.GetResponseCacheStats(agent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(stats))
+}
+
+// @Summary Purge agent response cache
+// @Description 清除智能体的全部回答缓存并清零命中统计
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "Agent ID"
+// @Success 200 {object} model.CommonResponse{data=map[string]int64} "Success"
+// @Router /api/agents/{agent_id}/response_cache [delete]
+func PurgeAgentResponseCache(c *gin.Context) {
+	agent, ok := getResponseCacheAgent(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := service.PurgeResponseCache(agent.Eid, agent.AgentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	if err := model.ResetResponseCacheStat(agent.Eid, agent.AgentID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(map[string]int64{"deleted": deleted}))
+}
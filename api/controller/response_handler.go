@@ -76,10 +76,99 @@ func GetResponseContent(c *gin.Context, isStream bool, resp *http.Response) (str
 	return "", ""
 }
 
+// responseUsage 上游返回的用量中与缓存相关的字段
+// OpenAI 为 prompt_tokens_details.cached_tokens，DeepSeek 为 prompt_cache_hit_tokens
+type responseUsage struct {
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens"`
+}
+
+func (u *responseUsage) cachedTokens() int {
+	if u == nil {
+		return 0
+	}
+	if u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0 {
+		return u.PromptTokensDetails.CachedTokens
+	}
+	return u.PromptCacheHitTokens
+}
+
+// GetCachedPromptTokens 获取输入中命中上游缓存的 token 数，上游未返回时为 0
+// 非流式响应的 resp.Body 已被 adaptor 读完，需传入 responseCapture 记录的写给客户端的内容
+func GetCachedPromptTokens(c *gin.Context, isStream bool, respBody []byte) int {
+	if isStream {
+		if collector, exists := c.Get("stream_response_collector"); exists {
+			if streamCollector, ok := collector.(*StreamResponseCollector); ok {
+				return streamCollector.CachedTokens()
+			}
+		}
+		return 0
+	}
+	if len(respBody) == 0 {
+		return 0
+	}
+
+	var body struct {
+		Usage *responseUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &body); err != nil {
+		return 0
+	}
+	return body.Usage.cachedTokens()
+}
+
+// responseCapture 记录非流式响应中上游写给客户端的原始内容，写入照常透传
+// 需在其他响应 Writer 之后创建、之前结束，才能拿到未经改写的上游响应
+type responseCapture struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	body     bytes.Buffer
+	finished bool
+}
+
+// newResponseCapture 流式响应时返回 nil，返回值的方法均可在 nil 上调用
+func newResponseCapture(c *gin.Context, isStream bool) *responseCapture {
+	if isStream {
+		return nil
+	}
+	w := &responseCapture{ResponseWriter: c.Writer, c: c}
+	c.Writer = w
+	return w
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCapture) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// finish 恢复原始 Writer，可重复调用
+func (w *responseCapture) finish() {
+	if w == nil || w.finished {
+		return
+	}
+	w.finished = true
+	w.c.Writer = w.ResponseWriter
+}
+
+func (w *responseCapture) bytes() []byte {
+	if w == nil {
+		return nil
+	}
+	return w.body.Bytes()
+}
+
 // StreamResponseCollector 用于收集流式响应
 type StreamResponseCollector struct {
 	content          strings.Builder
 	reasoningContent strings.Builder
+	cachedTokens     int
 }
 
 func NewStreamResponseCollector() *StreamResponseCollector {
@@ -107,9 +196,14 @@ func (c *StreamResponseCollector) Collect(chunk []byte) {
 						ReasoningContent *string `json:"reasoning_content"`
 					} `json:"delta"`
 				} `json:"choices"`
+				Usage *responseUsage `json:"usage"`
 			}
 
 			if err := json.Unmarshal([]byte(dataContent), &streamResp); err == nil {
+				// 用量通常在最后一个数据块中返回
+				if cached := streamResp.Usage.cachedTokens(); cached > 0 {
+					c.cachedTokens = cached
+				}
 				if len(streamResp.Choices) > 0 {
 					delta := streamResp.Choices[0].Delta
 					if delta.Content != nil && *delta.Content != "" {
@@ -128,6 +222,11 @@ func (c *StreamResponseCollector) GetContent() (string, string) {
 	return c.content.String(), c.reasoningContent.String()
 }
 
+// CachedTokens 上游在流式用量中返回的缓存命中 token 数
+func (c *StreamResponseCollector) CachedTokens() int {
+	return c.cachedTokens
+}
+
 // StreamResponseInterceptor 用于拦截和收集流式响应
 type StreamResponseInterceptor struct {
 	gin.ResponseWriter
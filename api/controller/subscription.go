@@ -58,6 +58,13 @@ type BatchSubscriptionItem struct {
 	// @Description Whether AI features are enabled for this subscription
 	// @Example true
 	AiEnabled bool `json:"ai_enabled" example:"true" description:"Whether AI features are enabled"`
+	// @Description Quota allowance per period for each user, 0 means unlimited
+	// @Example 500000
+	QuotaLimit int64 `json:"quota_limit" example:"500000" description:"Quota allowance per period, 0 means unlimited"`
+	// @Description Period the quota allowance is reset by
+	// @Enum day,week,month,quarter,year
+	// @Example month
+	QuotaPeriod string `json:"quota_period" example:"month" description:"Quota period: day/week/month/quarter/year"`
 	// @Description Whether to delete this subscription item
 	// @Example false
 	Delete bool `json:"delete" example:"false" description:"Whether to delete this item"`
@@ -147,6 +154,21 @@ func BatchSubscriptionOperation(c *gin.Context) {
 
 	var isUpdate bool
 	for _, item := range req.Items {
+		if !item.Delete {
+			if item.QuotaLimit < 0 {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(fmt.Errorf("quota_limit 不能小于 0")))
+				return
+			}
+			if item.QuotaPeriod == "" {
+				item.QuotaPeriod = model.TimeUnitMonth
+			} else if !model.IsValidTimeUnit(item.QuotaPeriod) {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(fmt.Errorf("不支持的 quota_period: %s", item.QuotaPeriod)))
+				return
+			}
+		}
+
 		// 检查是否为默认订阅
 		var setting model.SubscriptionSetting
 		if err := tx.Where("setting_id = ?", item.SettingId).First(&setting).Error; err == nil && setting.IsDefault {
@@ -263,6 +285,8 @@ func BatchSubscriptionOperation(c *gin.Context) {
 			setting.GroupId = groupId
 			setting.LogoUrl = item.LogoUrl
 			setting.AiEnabled = item.AiEnabled
+			setting.QuotaLimit = item.QuotaLimit
+			setting.QuotaPeriod = item.QuotaPeriod
 
 			if err := tx.Save(&setting).Error; err != nil {
 				tx.Rollback()
@@ -273,9 +297,11 @@ func BatchSubscriptionOperation(c *gin.Context) {
 		} else {
 			// Create new settings
 			setting = model.SubscriptionSetting{
-				GroupId:   groupId,
-				LogoUrl:   item.LogoUrl,
-				AiEnabled: item.AiEnabled,
+				GroupId:     groupId,
+				LogoUrl:     item.LogoUrl,
+				AiEnabled:   item.AiEnabled,
+				QuotaLimit:  item.QuotaLimit,
+				QuotaPeriod: item.QuotaPeriod,
 			}
 
 			if err := tx.Create(&setting).Error; err != nil {
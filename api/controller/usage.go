@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+// 未指定日期范围时默认统计最近的天数（含今天）
+const defaultUsageStatsDays = 7
+
+type UsageStatsRequest struct {
+	StartDate string `form:"start_date" example:"2026-10-01"` // 企业时区下的开始日期（含）
+	EndDate   string `form:"end_date" example:"2026-10-18"`   // 企业时区下的结束日期（含）
+	Interval  string `form:"interval" example:"day"`          // hour、day、month 或 total
+	GroupBy   string `form:"group_by" example:"agent"`        // agent、user、department、user_group、model、channel，为空时不分组
+	AgentID   int64  `form:"agent_id"`
+	UserID    int64  `form:"user_id"`
+	ModelName string `form:"model_name"`
+	ChannelID int64  `form:"channel_id"`
+}
+
+type UsageStatsResponse struct {
+	Timezone  string               `json:"timezone"`
+	Interval  string               `json:"interval"`
+	GroupBy   string               `json:"group_by"`
+	StartTime int64                `json:"start_time"`
+	EndTime   int64                `json:"end_time"`
+	Stats     []*service.UsageStat `json:"stats"`
+}
+
+// @Summary Usage analytics
+// @Description Aggregate calls, errors, tokens, quota, average and p95 latency from hourly rollups, bucketed by hour, day or month in the enterprise timezone and optionally grouped by agent, user, department, user group, model or channel. Rollups are refreshed every few minutes
+// @Tags Usage
+// @Produce json
+// @Security BearerAuth
+// @Param start_date query string false "Start date in the enterprise timezone (YYYY-MM-DD, inclusive), defaults to 6 days ago"
+// @Param end_date query string false "End date in the enterprise timezone (YYYY-MM-DD, inclusive), defaults to today"
+// @Param interval query string false "hour, day, month or total" default(day)
+// @Param group_by query string false "agent, user, department, user_group, model or channel"
+// @Param agent_id query int false "Agent ID"
+// @Param user_id query int false "User ID"
+// @Param model_name query string false "Model name"
+// @Param channel_id query int false "Channel ID"
+// @Success 200 {object} model.CommonResponse{data=UsageStatsResponse} "Success"
+// @Router /api/usage/stats [get]
+func GetUsageStats(c *gin.Context) {
+	var req UsageStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Interval == "" {
+		req.Interval = service.UsageIntervalDay
+	}
+
+	eid := config.GetEID(c)
+	location := model.GetEnterpriseLocation(eid)
+	start, end, err := parseUsageDateRange(req.StartDate, req.EndDate, location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	stats, err := service.GetUsageStats(eid, model.UsageRollupQuery{
+		StartTime: start.UnixMilli(),
+		EndTime:   end.UnixMilli(),
+		AgentID:   req.AgentID,
+		UserID:    req.UserID,
+		ModelName: req.ModelName,
+		ChannelID: req.ChannelID,
+	}, req.Interval, req.GroupBy, location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(UsageStatsResponse{
+		Timezone:  location.String(),
+		Interval:  req.Interval,
+		GroupBy:   req.GroupBy,
+		StartTime: start.UnixMilli(),
+		EndTime:   end.UnixMilli(),
+		Stats:     stats,
+	}))
+}
+
+// parseUsageDateRange 将企业时区下的起止日期转换为左闭右开的时间范围
+func parseUsageDateRange(startDate, endDate string, location *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	end := today
+	if endDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", endDate, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid end_date")
+		}
+		end = date
+	}
+	start := end.AddDate(0, 0, -(defaultUsageStatsDays - 1))
+	if startDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", startDate, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid start_date")
+		}
+		start = date
+	}
+	if start.After(end) {
+		return time.Time{}, time.Time{}, errors.New("start_date is after end_date")
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
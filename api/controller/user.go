@@ -613,6 +613,40 @@ func GetCurrentUser(c *gin.Context) {
 	}))
 }
 
+// UserQuotaResponse 当前用户的配额使用情况
+type UserQuotaResponse struct {
+	QuotaLimit  int64  `json:"quota_limit" example:"500000"`   // 每周期配额，0 表示不限制
+	QuotaPeriod string `json:"quota_period" example:"month"`   // 周期单位
+	Period      string `json:"period" example:"month:2025-01"` // 当前周期
+	UsedQuota   int64  `json:"used_quota" example:"1200"`      // 已使用配额
+}
+
+// @Summary Get current user quota
+// @Description Get the quota allowance and usage of the current period for the logged-in user
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=UserQuotaResponse} "Success"
+// @Router /api/users/me/quota [get]
+func GetCurrentUserQuota(c *gin.Context) {
+	eid := config.GetEID(c)
+	userID := config.GetUserId(c)
+
+	policy, used, err := service.GetUserQuotaUsage(eid, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(UserQuotaResponse{
+		QuotaLimit:  policy.Limit,
+		QuotaPeriod: policy.Unit,
+		Period:      policy.Period,
+		UsedQuota:   used,
+	}))
+}
+
 type UpdatePasswordRequest struct {
 	NewPassword     string `json:"new_password" binding:"required,min=8,max=20" example:"newPassword123"`
 	ConfirmPassword string `json:"confirm_password" binding:"required,min=8,max=20" example:"newPassword123"`
@@ -0,0 +1,411 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/hub_adaptor/custom"
+	"github.com/gin-gonic/gin"
+)
+
+// workflowRunCancelCheckInterval 执行中检查运行是否被取消的间隔（取消请求可能落在其他实例上）
+const workflowRunCancelCheckInterval = 5 * time.Second
+
+// CreateWorkflowRunRequest 异步工作流运行请求
+type CreateWorkflowRunRequest struct {
+	Parameters     map[string]interface{} `json:"parameters"`                                                // 工作流参数
+	Model          string                 `json:"model" example:"agent-1"`                                   // Agent模型
+	ConversationID int64                  `json:"conversation_id"`                                           // 会话ID
+	CallbackURL    string                 `json:"callback_url,omitempty" example:"https://example.com/hook"` // 运行结束后接收签名回调的地址
+	CallbackSecret string                 `json:"callback_secret,omitempty"`                                 // 回调签名密钥，为空时自动生成
+}
+
+// CreateWorkflowRunResponse 异步工作流运行创建响应，callback_secret 只在创建时返回
+type CreateWorkflowRunResponse struct {
+	*service.WorkflowRunDetail
+	CallbackSecret string `json:"callback_secret,omitempty"`
+}
+
+type workflowRunJob struct {
+	c       *gin.Context
+	run     *model.WorkflowRun
+	agent   *model.Agent
+	request *WorkflowRunRequest
+}
+
+var (
+	workflowRunQueue    chan *workflowRunJob
+	workflowRunPoolOnce sync.Once
+
+	workflowRunCancels     = make(map[int64]context.CancelFunc)
+	workflowRunCancelsLock sync.Mutex
+)
+
+// startWorkflowRunPool 启动异步工作流执行池
+func startWorkflowRunPool() {
+	workflowRunPoolOnce.Do(func() {
+		workers := int(config.WORKFLOW_RUN_WORKERS)
+		if workers <= 0 {
+			workers = 1
+		}
+		workflowRunQueue = make(chan *workflowRunJob, config.WORKFLOW_RUN_QUEUE_SIZE)
+		for i := 0; i < workers; i++ {
+			go func() {
+				for job := range workflowRunQueue {
+					processWorkflowRunJob(job)
+				}
+			}()
+		}
+		logger.SysLogf("异步工作流执行池已启动 - 并发数: %d, 队列长度: %d", workers, config.WORKFLOW_RUN_QUEUE_SIZE)
+	})
+}
+
+// @Summary Create Workflow Run
+// @Description 异步运行工作流，立即返回运行ID。可通过查询接口获取状态和输出，或设置 callback_url 在运行结束时接收签名回调（X-53AIHub-Signature: sha256=HMAC-SHA256(secret, timestamp + "." + body)）
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param request body CreateWorkflowRunRequest true "CreateWorkflowRunRequest"
+// @Success 200 {object} model.CommonResponse{data=CreateWorkflowRunResponse}
+// @Router /v1/workflow/runs [post]
+// @Security BearerAuth
+func CreateWorkflowRun(c *gin.Context) {
+	c.Set(ctxkey.Group, "vip")
+
+	var req CreateWorkflowRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("请求参数解析失败")))
+		return
+	}
+
+	agent, err := GetSessionAgent(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("Agent 未找到")))
+		return
+	}
+	if agent.AgentType != model.AgentTypeWorkflow {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("该 Agent 不是工作流类型")))
+		return
+	}
+
+	callbackSecret := ""
+	if req.CallbackURL != "" {
+		if err := service.ValidateCallbackURL(req.CallbackURL); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+		callbackSecret = req.CallbackSecret
+		if callbackSecret == "" {
+			if callbackSecret, err = model.GenerateWorkflowRunSecret(); err != nil {
+				c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+				return
+			}
+		}
+	}
+
+	workflowRequest := &WorkflowRunRequest{
+		Parameters:     req.Parameters,
+		Model:          req.Model,
+		ConversationID: req.ConversationID,
+	}
+	if workflowRequest.Parameters == nil {
+		workflowRequest.Parameters = map[string]interface{}{}
+	}
+	if workflowRequest.ConversationID == 0 {
+		workflowRequest.ConversationID = c.GetInt64(session.SESSION_CONVERSATION_ID)
+	}
+	parametersJSON, _ := json.Marshal(workflowRequest.Parameters)
+
+	startWorkflowRunPool()
+
+	// 预扣配额，运行结束后结算
+	eid := config.GetEID(c)
+	userId := config.GetUserId(c)
+	quotaHold, err := service.PreConsumeUserQuota(eid, userId, config.PreConsumedQuota)
+	if err != nil {
+		bizErr := quotaExceededError()
+		c.JSON(bizErr.StatusCode, model.OpenAIErrorResponse{
+			Error: struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}{
+				Message: bizErr.Message,
+				Type:    bizErr.Type,
+			},
+		})
+		return
+	}
+
+	runID, err := model.GenerateWorkflowRunID()
+	if err != nil {
+		returnPreConsumedQuota(eid, userId, quotaHold)
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+
+	// 预先创建消息记录，运行结束后写入输出
+	message := &model.Message{
+		Eid:               eid,
+		UserID:            userId,
+		ConversationID:    workflowRequest.ConversationID,
+		AgentID:           agent.AgentID,
+		Message:           string(parametersJSON),
+		ModelName:         agent.Model,
+		RequestId:         runID,
+		AgentCustomConfig: agent.CustomConfig,
+	}
+	if err := model.CreateMessage(message); err != nil {
+		returnPreConsumedQuota(eid, userId, quotaHold)
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	run := &model.WorkflowRun{
+		RunID:            runID,
+		Eid:              eid,
+		UserID:           userId,
+		AgentID:          agent.AgentID,
+		ConversationID:   workflowRequest.ConversationID,
+		MessageID:        message.ID,
+		Model:            req.Model,
+		Status:           model.WorkflowRunStatusPending,
+		Parameters:       string(parametersJSON),
+		PreConsumedQuota: quotaHold.Quota,
+		QuotaPeriod:      quotaHold.Period,
+		CallbackURL:      req.CallbackURL,
+		CallbackSecret:   callbackSecret,
+	}
+	if err := model.CreateWorkflowRun(run); err != nil {
+		returnPreConsumedQuota(eid, userId, quotaHold)
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	// 入队后 run 由执行池持有，响应使用提交时的快照
+	snapshot := *run
+	job := &workflowRunJob{c: c.Copy(), run: run, agent: agent, request: workflowRequest}
+	select {
+	case workflowRunQueue <- job:
+	default:
+		run.Status = model.WorkflowRunStatusFailed
+		run.ErrorMessage = "workflow run queue is full"
+		if ok, _ := model.StartWorkflowRun(run); ok {
+			_, _ = model.FinishWorkflowRun(run)
+		}
+		service.UpdateWorkflowRunMessageError(run)
+		returnPreConsumedQuota(eid, userId, quotaHold)
+		c.JSON(http.StatusServiceUnavailable, model.OperateTooFast.ToResponse(errors.New("工作流运行队列已满，请稍后重试")))
+		return
+	}
+
+	logger.SysLogf("异步工作流已提交 - RunID: %s, Agent: %s", run.RunID, agent.Model)
+	c.JSON(http.StatusOK, model.Success.ToResponse(CreateWorkflowRunResponse{
+		WorkflowRunDetail: service.NewWorkflowRunDetail(&snapshot),
+		CallbackSecret:    callbackSecret,
+	}))
+}
+
+// @Summary Get Workflow Run
+// @Description 查询异步工作流运行的状态和输出
+// @Tags Workflow
+// @Produce json
+// @Param run_id path string true "运行ID"
+// @Success 200 {object} model.CommonResponse{data=service.WorkflowRunDetail}
+// @Router /v1/workflow/runs/{run_id} [get]
+// @Security BearerAuth
+func GetWorkflowRun(c *gin.Context) {
+	run, ok := getOwnedWorkflowRun(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(service.NewWorkflowRunDetail(run)))
+}
+
+// @Summary Cancel Workflow Run
+// @Description 取消排队中或执行中的异步工作流运行，预扣配额会被退还
+// @Tags Workflow
+// @Produce json
+// @Param run_id path string true "运行ID"
+// @Success 200 {object} model.CommonResponse{data=service.WorkflowRunDetail}
+// @Router /v1/workflow/runs/{run_id}/cancel [post]
+// @Security BearerAuth
+func CancelWorkflowRun(c *gin.Context) {
+	run, ok := getOwnedWorkflowRun(c)
+	if !ok {
+		return
+	}
+
+	cancelled, err := model.CancelWorkflowRun(run)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(fmt.Errorf("运行已结束，当前状态: %s", run.Status)))
+		return
+	}
+
+	// 本实例正在执行时立即中止，其他实例会在下次状态检查时中止
+	workflowRunCancelsLock.Lock()
+	if cancel, exists := workflowRunCancels[run.ID]; exists {
+		cancel()
+	}
+	workflowRunCancelsLock.Unlock()
+
+	logger.SysLogf("异步工作流已取消 - RunID: %s", run.RunID)
+	c.JSON(http.StatusOK, model.Success.ToResponse(service.NewWorkflowRunDetail(run)))
+}
+
+// getOwnedWorkflowRun 获取路径中的运行记录，并校验当前用户和 API 密钥是否有权访问
+func getOwnedWorkflowRun(c *gin.Context) (*model.WorkflowRun, bool) {
+	run, err := model.GetWorkflowRunByRunID(config.GetEID(c), c.Param("run_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("workflow run not found")))
+		return nil, false
+	}
+	if run.UserID != config.GetUserId(c) && !common.IsAdmin(c) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(errors.New("workflow run not found")))
+		return nil, false
+	}
+	if value, exists := c.Get(session.SESSION_API_KEY); exists {
+		if apiKey, ok := value.(*model.ApiKey); ok && !apiKey.AllowAgent(run.AgentID) {
+			c.JSON(http.StatusForbidden, model.AgentAuthError.ToResponse(nil))
+			return nil, false
+		}
+	}
+	return run, true
+}
+
+type workflowRunResult struct {
+	response *custom.WorkflowResponseData
+	err      error
+}
+
+// processWorkflowRunJob 执行一次异步工作流运行
+func processWorkflowRunJob(job *workflowRunJob) {
+	run := job.run
+	defer func() {
+		if r := recover(); r != nil {
+			logger.SysErrorf("异步工作流执行异常 - RunID: %s, Panic: %v", run.RunID, r)
+		}
+	}()
+
+	started, err := model.StartWorkflowRun(run)
+	if err != nil {
+		logger.SysErrorf("异步工作流启动失败 - RunID: %s, Error: %v", run.RunID, err)
+		return
+	}
+	if !started {
+		// 排队期间已被取消（或已被超时任务处理）
+		finishWorkflowRunWithoutResult(run)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(job.c.Request.Context()), time.Duration(config.WORKFLOW_RUN_TIMEOUT)*time.Second)
+	defer cancel()
+	workflowRunCancelsLock.Lock()
+	workflowRunCancels[run.ID] = cancel
+	workflowRunCancelsLock.Unlock()
+	defer func() {
+		workflowRunCancelsLock.Lock()
+		delete(workflowRunCancels, run.ID)
+		workflowRunCancelsLock.Unlock()
+	}()
+
+	c := job.c
+	c.Request = c.Request.Clone(ctx)
+	c.Set("workflow_start_time", time.Now())
+
+	// 平台适配器的请求不感知取消，在单独的 goroutine 中执行，取消或超时后不再等待其结果
+	resultCh := make(chan workflowRunResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				resultCh <- workflowRunResult{err: fmt.Errorf("workflow run panic: %v", r)}
+			}
+		}()
+		response, err := executeWorkflow(c, job.request, job.agent, nil)
+		resultCh <- workflowRunResult{response: response, err: err}
+	}()
+
+	ticker := time.NewTicker(workflowRunCancelCheckInterval)
+	defer ticker.Stop()
+
+	var result workflowRunResult
+wait:
+	for {
+		select {
+		case result = <-resultCh:
+			break wait
+		case <-ticker.C:
+			if status, err := model.GetWorkflowRunStatus(run.ID); err == nil && status == model.WorkflowRunStatusCancelled {
+				cancel()
+			}
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				result.err = errors.New("workflow run timed out")
+			} else {
+				result.err = errors.New("workflow run cancelled")
+			}
+			break wait
+		}
+	}
+
+	if result.err != nil {
+		logger.SysErrorf("异步工作流执行失败 - RunID: %s, Error: %v", run.RunID, result.err)
+		run.Status = model.WorkflowRunStatusFailed
+		run.ErrorMessage = result.err.Error()
+	} else {
+		outputs, _ := json.Marshal(result.response.WorkflowOutputData)
+		run.Status = model.WorkflowRunStatusSucceeded
+		run.Outputs = string(outputs)
+		run.ExecuteID = result.response.ExecuteID
+		run.ChannelID = result.response.ChannelID
+	}
+
+	finished, err := model.FinishWorkflowRun(run)
+	if err != nil {
+		logger.SysErrorf("保存异步工作流结果失败 - RunID: %s, Error: %v", run.RunID, err)
+		return
+	}
+	if !finished {
+		finishWorkflowRunWithoutResult(run)
+		return
+	}
+
+	if result.err != nil {
+		returnPreConsumedQuota(run.Eid, run.UserID, service.WorkflowRunQuotaHold(run))
+		service.UpdateWorkflowRunMessageError(run)
+	} else if err := saveWorkflowMessage(c, job.request, job.agent, result.response, service.WorkflowRunQuotaHold(run), run.MessageID); err != nil {
+		logger.SysErrorf("保存工作流消息失败 - RunID: %s, Error: %v", run.RunID, err)
+	}
+
+	logger.SysLogf("异步工作流执行结束 - RunID: %s, Status: %s", run.RunID, run.Status)
+	service.SendWorkflowRunCallback(run)
+}
+
+// finishWorkflowRunWithoutResult 运行被他处结束时的收尾：被取消的运行由执行方退还配额并发送回调，
+// 被超时任务标记失败的运行已由任务处理
+func finishWorkflowRunWithoutResult(run *model.WorkflowRun) {
+	latest, err := model.GetWorkflowRunByRunID(run.Eid, run.RunID)
+	if err != nil || latest.Status != model.WorkflowRunStatusCancelled {
+		return
+	}
+	returnPreConsumedQuota(latest.Eid, latest.UserID, service.WorkflowRunQuotaHold(latest))
+	latest.ErrorMessage = "workflow run cancelled"
+	service.UpdateWorkflowRunMessageError(latest)
+	logger.SysLogf("异步工作流已取消，停止执行 - RunID: %s", latest.RunID)
+	service.SendWorkflowRunCallback(latest)
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/hub_adaptor/custom"
+	"github.com/gin-gonic/gin"
+)
+
+// workflowStreamHeartbeat 流式执行时的心跳间隔，避免长时间无输出的节点导致代理断开连接
+const workflowStreamHeartbeat = 15 * time.Second
+
+// workflowEventStream 工作流 SSE 输出
+// 响应头在第一次写入时才发送，执行在输出任何内容之前失败时仍可返回普通的 JSON 错误
+type workflowEventStream struct {
+	c       *gin.Context
+	mu      sync.Mutex
+	started bool
+	closed  bool
+}
+
+// start 发送 SSE 响应头，调用方需持有锁
+func (s *workflowEventStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	h := s.c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	s.c.Status(http.StatusOK)
+}
+
+// write 写入一段 SSE 数据并立即刷新，调用方需持有锁
+func (s *workflowEventStream) write(chunk string) {
+	if s.closed {
+		return
+	}
+	s.start()
+	if _, err := s.c.Writer.Write([]byte(chunk)); err != nil {
+		logger.SysErrorf("写入工作流流式事件失败: %v", err)
+		return
+	}
+	if flusher, ok := s.c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Send 推送一个工作流事件，可作为 custom.WorkflowEventHandler 传给适配器
+func (s *workflowEventStream) Send(event *custom.WorkflowStreamEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		logger.SysErrorf("序列化工作流流式事件失败: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write("data: " + string(data) + "\n\n")
+}
+
+// Ping 发送 SSE 注释行作为心跳
+func (s *workflowEventStream) Ping() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(": ping\n\n")
+}
+
+// Started 是否已经开始输出
+func (s *workflowEventStream) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+// Close 发送结束标记，之后的写入都会被忽略
+func (s *workflowEventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write("data: [DONE]\n\n")
+	s.closed = true
+}
+
+// runWorkflowStream 以 SSE 方式执行工作流
+// 平台事件被归一化后实时推送，执行结束后照常保存消息并结算配额，最后推送携带完整结果的 workflow_finished 事件
+func runWorkflowStream(c *gin.Context, workflowRequest *WorkflowRunRequest, agent *model.Agent, quotaHold service.UserQuotaHold) {
+	stream := &workflowEventStream{c: c}
+
+	var wg sync.WaitGroup
+	stopHeartbeat := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(workflowStreamHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				stream.Ping()
+			case <-stopHeartbeat:
+				return
+			}
+		}
+	}()
+
+	response, err := executeWorkflow(c, workflowRequest, agent, stream.Send)
+	close(stopHeartbeat)
+	wg.Wait()
+
+	if err != nil {
+		logger.SysErrorf("工作流流式执行失败 - Agent: %s, Error: %v", agent.Model, err)
+		returnPreConsumedQuota(agent.Eid, config.GetUserId(c), quotaHold)
+		if !stream.Started() {
+			respondWorkflowError(c, err)
+			return
+		}
+		stream.Send(&custom.WorkflowStreamEvent{
+			Event: custom.WorkflowEventError,
+			Error: err.Error(),
+		})
+		stream.Close()
+		return
+	}
+
+	logger.SysLogf("工作流流式执行成功 - Agent: %s, ExecuteID: %s", agent.Model, response.ExecuteID)
+
+	// 保存工作流消息记录
+	if err := saveWorkflowMessage(c, workflowRequest, agent, response, quotaHold, 0); err != nil {
+		logger.SysErrorf("保存工作流消息失败: %v", err)
+	}
+
+	stream.Send(&custom.WorkflowStreamEvent{
+		Event:     custom.WorkflowEventWorkflowFinished,
+		ExecuteID: response.ExecuteID,
+		Data:      response,
+	})
+	stream.Close()
+}
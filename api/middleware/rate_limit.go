@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	rateLimitWindow = time.Minute
+	// 并发名额的最长占用时间，超过后自动释放
+	concurrentStreamTTL = 30 * time.Minute
+)
+
+type rateLimitTarget struct {
+	name string
+	key  string
+	rule service.RateLimitRule
+}
+
+// RelayRateLimit 按用户（分组）、智能体、企业限制每分钟请求数和并发流式请求数
+// 限流配置由管理员通过 enterprise-configs type="rate_limit" 设置，需在 RelayTokenAuth 之后使用
+func RelayRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		eid := config.GetEID(c)
+		cfg := service.GetRateLimitConfig(eid)
+		if cfg == nil {
+			c.Next()
+			return
+		}
+
+		userId := config.GetUserId(c)
+		targets := []rateLimitTarget{
+			{name: "user", key: fmt.Sprintf("user:%d", userId), rule: cfg.GetUserRule(c.GetInt64(session.SESSION_USER_GROUP_ID))},
+		}
+		if agentId := c.GetInt64(session.SESSION_AGENT_ID); agentId > 0 {
+			targets = append(targets, rateLimitTarget{name: "agent", key: fmt.Sprintf("agent:%d", agentId), rule: cfg.GetAgentRule(agentId)})
+		}
+		targets = append(targets, rateLimitTarget{name: "enterprise", key: fmt.Sprintf("enterprise:%d", eid), rule: cfg.Enterprise})
+
+		// 后续检查拒绝请求时归还已占用的每分钟请求数
+		counted := make([]string, 0, len(targets))
+		refund := func() {
+			for _, key := range counted {
+				common.RATE_LIMITER.Refund(key)
+			}
+		}
+		for _, target := range targets {
+			if target.rule.RPM <= 0 {
+				continue
+			}
+			key := "rpm:" + target.key
+			allowed, wait := common.RATE_LIMITER.Allow(key, target.rule.RPM, rateLimitWindow)
+			if !allowed {
+				refund()
+				abortWithRateLimit(c, wait, fmt.Sprintf("Rate limit reached for %s: %d requests per minute", target.name, target.rule.RPM))
+				return
+			}
+			counted = append(counted, key)
+		}
+
+		if !isStreamRequest(c) {
+			c.Next()
+			return
+		}
+
+		acquired := make([]string, 0, len(targets))
+		defer func() {
+			for _, key := range acquired {
+				common.RATE_LIMITER.Release(key)
+			}
+		}()
+		for _, target := range targets {
+			if target.rule.Concurrent <= 0 {
+				continue
+			}
+			key := "concurrent:" + target.key
+			if !common.RATE_LIMITER.Acquire(key, target.rule.Concurrent, concurrentStreamTTL) {
+				refund()
+				abortWithRateLimit(c, time.Second, fmt.Sprintf("Concurrent stream limit reached for %s: %d", target.name, target.rule.Concurrent))
+				return
+			}
+			acquired = append(acquired, key)
+		}
+
+		c.Next()
+	}
+}
+
+// isStreamRequest 判断是否为流式请求，读取后恢复请求体
+func isStreamRequest(c *gin.Context) bool {
+	if c.Request.Method == http.MethodGet || c.Request.Body == nil {
+		return false
+	}
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	_ = json.Unmarshal(bodyBytes, &req)
+	return req.Stream
+}
+
+// abortWithRateLimit 返回 OpenAI 风格的 429 响应，并通过 Retry-After 告知需要等待的秒数
+func abortWithRateLimit(c *gin.Context, wait time.Duration, message string) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, model.OperateTooFast.ToOpenAIErrorRespone(message))
+	c.Abort()
+}
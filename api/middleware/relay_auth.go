@@ -12,9 +12,10 @@ import (
 	"github.com/53AI/53AIHub/common"
 	"github.com/53AI/53AIHub/common/logger"
 	"github.com/53AI/53AIHub/common/session"
-	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/common/utils"
 	"github.com/53AI/53AIHub/common/utils/jwt"
 	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,35 +23,71 @@ func RelayTokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		token := c.Request.Header.Get("Authorization")
 		token = strings.Replace(token, "Bearer ", "", 1)
+		if token == "" {
+			// 兼容 Anthropic 客户端的 x-api-key 请求头
+			token = c.Request.Header.Get("x-api-key")
+		}
 		if token == "" {
 			c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(nil))
 			c.Abort()
 			return
 		}
 
-		user_id, eid, err := jwt.UserParseJWT(token)
-		if err != nil {
-			if strings.Contains(err.Error(), "token is expired") {
-				c.JSON(http.StatusUnauthorized, model.TokenExpiredError.ToOpenAIErrorRespone(nil))
-			} else {
-				c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(nil))
+		var user *model.User
+		var apiKey *model.ApiKey
+		var eid int64
+		if model.IsApiKey(token) {
+			var err error
+			apiKey, user, err = authenticateApiKey(c, token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(err))
+				c.Abort()
+				return
+			}
+			eid = apiKey.Eid
+			c.Set(session.SESSION_API_KEY, apiKey)
+		} else {
+			user_id, jwtEid, err := jwt.UserParseJWT(token)
+			if err != nil {
+				if strings.Contains(err.Error(), "token is expired") {
+					c.JSON(http.StatusUnauthorized, model.TokenExpiredError.ToOpenAIErrorRespone(nil))
+				} else {
+					c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(nil))
+				}
+				c.Abort()
+				return
 			}
-			c.Abort()
-			return
-		}
 
-		user := model.ValidateAccessToken(token)
-		if user.UserID != user_id {
-			c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(nil))
-			c.Abort()
-			return
+			user = model.ValidateAccessToken(token)
+			if user == nil || user.UserID != user_id {
+				c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(nil))
+				c.Abort()
+				return
+			}
+			eid = jwtEid
 		}
+		user_id := user.UserID
 
 		c.Set(session.SESSION_USER_ID, user_id)
 		c.Set(session.SESSION_USER_ROLE, user.Role)
 		c.Set(session.SESSION_USER_GROUP_ID, user.GroupId)
 		c.Set(session.ENV_EID, eid)
 
+		// 限定了智能体范围的密钥，先按路径或查询参数中的智能体校验，无请求体的接口同样适用
+		if apiKey != nil && apiKey.HasAgentScope() {
+			if agentID, ok := requestPathAgentID(c, eid, user_id); ok && !apiKey.AllowAgent(agentID) {
+				c.JSON(http.StatusForbidden, model.AgentAuthError.ToOpenAIErrorRespone("API key is not allowed to access this agent"))
+				c.Abort()
+				return
+			}
+		}
+
+		// 无请求体的接口（如 GET /v1/models）无需解析 model 参数
+		if c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+
 		// 读取原始请求体
 		bodyBytes, err := c.GetRawData()
 		if err != nil {
@@ -59,6 +96,12 @@ func RelayTokenAuth() func(c *gin.Context) {
 			return
 		}
 
+		// 无请求体的操作类接口（如取消工作流运行）同样无需解析 model 参数
+		if len(bytes.TrimSpace(bodyBytes)) == 0 {
+			c.Next()
+			return
+		}
+
 		var requestData map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &requestData); err != nil {
 			c.JSON(http.StatusBadRequest, model.ParamError.ToOpenAIErrorRespone(err))
@@ -68,6 +111,24 @@ func RelayTokenAuth() func(c *gin.Context) {
 
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
+		// 会话线程接口的请求体不含 model，根据路径中的会话确定智能体，权限校验与普通请求一致
+		if _, exists := requestData["model"]; !exists && c.Param("conversation_id") != "" {
+			conversationId, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, model.ParamError.ToOpenAIErrorRespone(nil))
+				c.Abort()
+				return
+			}
+			conversation, err := model.GetConversationByIdAndUserId(eid, conversationId, user_id)
+			if err != nil {
+				c.JSON(http.StatusNotFound, model.NotFound.ToOpenAIErrorRespone(errors.New("Conversation not found")))
+				c.Abort()
+				return
+			}
+			requestData["model"] = "agent-" + strconv.FormatInt(conversation.AgentID, 10)
+			requestData["conversation_id"] = float64(conversationId)
+		}
+
 		if modelValue, exists := requestData["model"]; exists {
 			modelStr, ok := modelValue.(string)
 			if !ok {
@@ -113,21 +174,21 @@ func RelayTokenAuth() func(c *gin.Context) {
 					return
 				}
 
-				if !common.IsAdmin(c) {
-					agentUserGroupIds, err := agent.GetUserGroupIds()
-					if err != nil {
-						c.JSON(http.StatusInternalServerError, model.NotFound.ToOpenAIErrorRespone(err))
-						c.Abort()
-						return
-					}
-
-					userGroupIds, err := user.GetUserGroupIds()
-					if !helper.HasIntersection(agentUserGroupIds, userGroupIds) {
+				if err := service.CheckAgentAccess(c, user, agent); err != nil {
+					switch {
+					case errors.Is(err, service.ErrAgentNotAllowedByAPIKey):
+						c.JSON(http.StatusForbidden, model.AgentAuthError.ToOpenAIErrorRespone(err.Error()))
+					case errors.Is(err, service.ErrAgentUserGroupDenied):
 						c.JSON(http.StatusForbidden, model.AgentAuthError.ToOpenAIErrorRespone(nil))
-						c.Abort()
-						return
+					case errors.Is(err, service.ErrAgentPausedByBudget):
+						c.JSON(http.StatusTooManyRequests, model.OperateTooFast.ToOpenAIErrorRespone(err.Error()))
+					default:
+						c.JSON(http.StatusInternalServerError, model.NotFound.ToOpenAIErrorRespone(err))
 					}
-				} else {
+					c.Abort()
+					return
+				}
+				if common.IsAdmin(c) {
 					logger.SysLogf("Admin user access agent: %d", agent.AgentID)
 				}
 
@@ -136,6 +197,65 @@ func RelayTokenAuth() func(c *gin.Context) {
 				logger.SysLogf("Agent ID: %d", agent.AgentID)
 			}
 		}
+		if apiKey != nil && apiKey.HasAgentScope() {
+			if _, exists := c.Get(session.SESSION_AGENT_ID); !exists {
+				c.JSON(http.StatusForbidden, model.AgentAuthError.ToOpenAIErrorRespone("API key is restricted to specific agents"))
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
+
+// requestPathAgentID 获取路径中的会话、工作流运行记录或查询参数 agent_id 对应的智能体，不存在时返回 false
+func requestPathAgentID(c *gin.Context, eid, userID int64) (int64, bool) {
+	if c.Param("conversation_id") != "" {
+		conversationId, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		conversation, err := model.GetConversationByIdAndUserId(eid, conversationId, userID)
+		if err != nil {
+			return 0, false
+		}
+		return conversation.AgentID, true
+	}
+	if c.Param("run_id") != "" {
+		run, err := model.GetWorkflowRunByRunID(eid, c.Param("run_id"))
+		if err != nil {
+			return 0, false
+		}
+		return run.AgentID, true
+	}
+	if agentID, err := strconv.ParseInt(c.Query("agent_id"), 10, 64); err == nil && agentID > 0 {
+		return agentID, true
+	}
+	return 0, false
+}
+
+// authenticateApiKey 校验 API 密钥及其有效期、IP 白名单，返回密钥和所属用户
+func authenticateApiKey(c *gin.Context, token string) (*model.ApiKey, *model.User, error) {
+	apiKey, err := model.GetApiKeyByKey(token)
+	if err != nil || apiKey.Status != model.ApiKeyStatusNormal {
+		return nil, nil, errors.New("invalid api key")
+	}
+	if apiKey.IsExpired() {
+		return nil, nil, errors.New("api key is expired")
+	}
+
+	clientIP := utils.GetClientIP(c)
+	if !apiKey.AllowIP(clientIP) {
+		return nil, nil, errors.New("ip address is not allowed")
+	}
+
+	user, err := model.GetUserByID(apiKey.UserID)
+	if err != nil || user.Eid != apiKey.Eid || user.Status == model.UserStatusDisabled {
+		return nil, nil, errors.New("invalid api key")
+	}
+
+	if err := apiKey.Touch(clientIP); err != nil {
+		logger.SysErrorf("update api key last used time failed: id=%d err=%v", apiKey.ID, err)
+	}
+	return apiKey, user, nil
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 智能体工具类型
+const (
+	AgentToolTypeHTTP    = "http"    // 调用外部 HTTP 接口
+	AgentToolTypeBuiltin = "builtin" // 内置工具
+	AgentToolTypeAgent   = "agent"   // 调用本企业的其他智能体
+)
+
+// 内置工具
+const (
+	BuiltinToolCurrentTime = "current_time"
+	BuiltinToolCalculator  = "calculator"
+)
+
+var agentToolNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// AgentTool 智能体工具定义，存储于 Agent.Tools 的 JSON 数组中
+// http    {"type":"http","name":"get_weather","description":"查询天气","parameters":{"type":"object","properties":{"city":{"type":"string"}}},"url":"https://example.com/weather","method":"GET","headers":{"X-Token":"xxx"},"timeout":10}
+// builtin {"type":"builtin","name":"calculator","builtin":"calculator"}
+// agent   {"type":"agent","name":"ask_lawyer","description":"咨询法务助手","agent_id":12}
+type AgentTool struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	URL         string                 `json:"url,omitempty"`
+	Method      string                 `json:"method,omitempty"`
+	Headers     map[string]string      `json:"headers,omitempty"`
+	Timeout     int                    `json:"timeout,omitempty"` // 秒
+	Builtin     string                 `json:"builtin,omitempty"`
+	AgentID     int64                  `json:"agent_id,omitempty"`
+}
+
+// ParseAgentTools 解析并校验工具定义
+func ParseAgentTools(tools string) ([]AgentTool, error) {
+	if strings.TrimSpace(tools) == "" {
+		return nil, nil
+	}
+	var list []AgentTool
+	if err := json.Unmarshal([]byte(tools), &list); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(list))
+	for i := range list {
+		tool := &list[i]
+		if !agentToolNameRegexp.MatchString(tool.Name) {
+			return nil, fmt.Errorf("tools[%d]: invalid name %q", i, tool.Name)
+		}
+		if names[tool.Name] {
+			return nil, fmt.Errorf("tools[%d]: duplicate name %q", i, tool.Name)
+		}
+		names[tool.Name] = true
+
+		switch tool.Type {
+		case AgentToolTypeHTTP:
+			u, err := url.Parse(tool.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("tools[%d]: invalid url %q", i, tool.URL)
+			}
+			tool.Method = strings.ToUpper(tool.Method)
+			if tool.Method == "" {
+				tool.Method = "POST"
+			}
+		case AgentToolTypeBuiltin:
+			if tool.Builtin == "" {
+				tool.Builtin = tool.Name
+			}
+			if tool.Builtin != BuiltinToolCurrentTime && tool.Builtin != BuiltinToolCalculator {
+				return nil, fmt.Errorf("tools[%d]: unknown builtin %q", i, tool.Builtin)
+			}
+		case AgentToolTypeAgent:
+			if tool.AgentID <= 0 {
+				return nil, fmt.Errorf("tools[%d]: agent_id is required", i)
+			}
+		default:
+			return nil, fmt.Errorf("tools[%d]: unknown type %q", i, tool.Type)
+		}
+	}
+	return list, nil
+}
+
+// GetTools 获取智能体配置的工具，配置无效时返回空
+func (agent *Agent) GetTools() []AgentTool {
+	tools, err := ParseAgentTools(agent.Tools)
+	if err != nil {
+		return nil
+	}
+	return tools
+}
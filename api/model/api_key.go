@@ -0,0 +1,245 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ApiKeyPrefix = "sk-53ai-"
+
+	ApiKeyTypePersonal = "personal"
+	ApiKeyTypeService  = "service"
+
+	ApiKeyStatusRevoked = 0
+	ApiKeyStatusNormal  = 1
+
+	// 最后使用时间的写入间隔，避免每次请求都更新数据库
+	apiKeyLastUsedInterval = int64(60 * 1000)
+)
+
+// ApiKey 用户或服务使用的 API 密钥，只保存哈希值
+type ApiKey struct {
+	ID           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid          int64  `json:"eid" gorm:"not null;index"`
+	UserID       int64  `json:"user_id" gorm:"not null;index;comment:密钥所属用户"`
+	CreatedBy    int64  `json:"created_by" gorm:"not null;default:0;comment:创建人"`
+	Name         string `json:"name" gorm:"size:100;not null;default:''"`
+	Type         string `json:"type" gorm:"size:20;not null;default:'personal';comment:类型。personal个人；service服务"`
+	KeyHash      string `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	KeyPrefix    string `json:"key_prefix" gorm:"size:32;not null;default:'';comment:用于展示的密钥前缀"`
+	AgentIDs     string `json:"-" gorm:"type:text;comment:允许访问的智能体ID，逗号分隔，为空表示不限制"`
+	AllowedIPs   string `json:"-" gorm:"type:text;comment:IP白名单，逗号分隔，支持CIDR，为空表示不限制"`
+	ExpiredTime  int64  `json:"expired_time" gorm:"not null;default:0;comment:过期时间，0表示永不过期"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"not null;default:0"`
+	LastUsedIP   string `json:"last_used_ip" gorm:"size:64;not null;default:''"`
+	Status       int    `json:"status" gorm:"not null;default:1;comment:状态。0已吊销；1正常"`
+	BaseModel
+
+	AgentIDList   []int64  `json:"agent_ids" gorm:"-"`
+	AllowedIPList []string `json:"allowed_ips" gorm:"-"`
+}
+
+func (ApiKey) TableName() string {
+	return "api_keys"
+}
+
+// GenerateApiKey 生成新的明文密钥
+func GenerateApiKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return ApiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashApiKey 计算密钥的哈希值
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsApiKey 判断 token 是否为 API 密钥格式
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, ApiKeyPrefix)
+}
+
+// SetAgentIDs 设置允许访问的智能体
+func (k *ApiKey) SetAgentIDs(agentIDs []int64) {
+	ids := make([]string, 0, len(agentIDs))
+	for _, id := range agentIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	k.AgentIDs = strings.Join(ids, ",")
+	k.AgentIDList = agentIDs
+}
+
+// SetAllowedIPs 设置 IP 白名单
+func (k *ApiKey) SetAllowedIPs(ips []string) {
+	list := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		ip = strings.TrimSpace(ip)
+		if ip != "" {
+			list = append(list, ip)
+		}
+	}
+	k.AllowedIPs = strings.Join(list, ",")
+	k.AllowedIPList = list
+}
+
+// LoadScopes 解析范围字段，用于接口返回
+func (k *ApiKey) LoadScopes() {
+	k.AgentIDList = []int64{}
+	for _, s := range strings.Split(k.AgentIDs, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+			k.AgentIDList = append(k.AgentIDList, id)
+		}
+	}
+	k.AllowedIPList = []string{}
+	for _, ip := range strings.Split(k.AllowedIPs, ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			k.AllowedIPList = append(k.AllowedIPList, ip)
+		}
+	}
+}
+
+// IsExpired 是否已过期
+func (k *ApiKey) IsExpired() bool {
+	return k.ExpiredTime > 0 && k.ExpiredTime < time.Now().UTC().UnixMilli()
+}
+
+// AllowAgent 判断密钥是否可以访问指定智能体
+func (k *ApiKey) AllowAgent(agentID int64) bool {
+	if k.AgentIDs == "" {
+		return true
+	}
+	for _, s := range strings.Split(k.AgentIDs, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil && id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAgentScope 密钥是否限定了智能体范围
+func (k *ApiKey) HasAgentScope() bool {
+	return k.AgentIDs != ""
+}
+
+// AllowIP 判断客户端 IP 是否在白名单内
+func (k *ApiKey) AllowIP(clientIP string) bool {
+	if k.AllowedIPs == "" {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	for _, item := range strings.Split(k.AllowedIPs, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			if _, ipNet, err := net.ParseCIDR(item); err == nil && ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(item); allowed != nil && ip != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidIPRule 校验白名单条目（IP 或 CIDR）
+func IsValidIPRule(rule string) bool {
+	if strings.Contains(rule, "/") {
+		_, _, err := net.ParseCIDR(rule)
+		return err == nil
+	}
+	return net.ParseIP(rule) != nil
+}
+
+// CreateApiKey 创建密钥
+func CreateApiKey(key *ApiKey) error {
+	return DB.Create(key).Error
+}
+
+// GetApiKeyByKey 根据明文密钥查找
+func GetApiKeyByKey(key string) (*ApiKey, error) {
+	var apiKey ApiKey
+	err := DB.Where("key_hash = ?", HashApiKey(key)).First(&apiKey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// GetApiKeyByID 根据ID获取密钥
+func GetApiKeyByID(eid, id int64) (*ApiKey, error) {
+	var apiKey ApiKey
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&apiKey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// GetApiKeyList 获取密钥列表，userID 为 0 时返回企业下全部密钥
+func GetApiKeyList(eid, userID int64, keyword string, offset, limit int) (int64, []*ApiKey, error) {
+	var count int64
+	var keys []*ApiKey
+
+	db := DB.Model(&ApiKey{}).Where("eid = ?", eid)
+	if userID > 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	if keyword != "" {
+		db = db.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err := db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	err := db.Order("id DESC").Offset(offset).Limit(limit).Find(&keys).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, key := range keys {
+		key.LoadScopes()
+	}
+	return count, keys, nil
+}
+
+// Update 更新密钥名称和范围
+func (k *ApiKey) Update() error {
+	return DB.Model(k).Updates(map[string]interface{}{
+		"name":         k.Name,
+		"agent_ids":    k.AgentIDs,
+		"allowed_ips":  k.AllowedIPs,
+		"expired_time": k.ExpiredTime,
+	}).Error
+}
+
+// Revoke 吊销密钥
+func (k *ApiKey) Revoke() error {
+	k.Status = ApiKeyStatusRevoked
+	return DB.Model(k).Update("status", ApiKeyStatusRevoked).Error
+}
+
+// Touch 记录最后使用时间，间隔内重复调用不写库
+func (k *ApiKey) Touch(clientIP string) error {
+	now := time.Now().UTC().UnixMilli()
+	if now-k.LastUsedTime < apiKeyLastUsedInterval && k.LastUsedIP == clientIP {
+		return nil
+	}
+	k.LastUsedTime = now
+	k.LastUsedIP = clientIP
+	return DB.Model(&ApiKey{}).Where("id = ?", k.ID).UpdateColumns(map[string]interface{}{
+		"last_used_time": now,
+		"last_used_ip":   clientIP,
+	}).Error
+}
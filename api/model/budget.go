@@ -0,0 +1,205 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 预算的统计范围
+const (
+	BudgetScopeEnterprise = "enterprise"
+	BudgetScopeChannel    = "channel"
+	BudgetScopeAgent      = "agent"
+	BudgetScopeUserGroup  = "user_group"
+)
+
+// 预算的统计指标
+const (
+	BudgetMetricQuota  = "quota"
+	BudgetMetricTokens = "tokens"
+)
+
+// Budget 消耗预算，按周期统计消息用量，达到告警比例时发送邮件和 webhook 通知
+type Budget struct {
+	ID            int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid           int64  `json:"eid" gorm:"not null;index"`
+	Name          string `json:"name" gorm:"size:100;not null"`
+	ScopeType     string `json:"scope_type" gorm:"size:20;not null"`
+	ScopeID       int64  `json:"scope_id" gorm:"not null;default:0"` // 渠道、智能体或用户分组ID，企业预算为 0
+	Metric        string `json:"metric" gorm:"size:20;not null"`
+	Period        string `json:"period" gorm:"size:10;not null"` // day 或 month
+	Threshold     int64  `json:"threshold" gorm:"not null"`
+	AlertLevels   string `json:"-" gorm:"type:text"` // 告警比例的 JSON 数组，如 [50,80,100]
+	NotifyEmails  string `json:"-" gorm:"type:text"` // 接收告警的邮箱 JSON 数组
+	WebhookURL    string `json:"webhook_url" gorm:"size:512;not null;default:''"`
+	PauseOnExceed bool   `json:"pause_on_exceed" gorm:"not null;default:false"` // 用量达到 100% 时暂停智能体或渠道
+	Enabled       bool   `json:"enabled" gorm:"not null;default:true"`
+
+	// 运行状态，由定时任务维护
+	CurrentPeriod string `json:"current_period" gorm:"size:32;not null;default:''"`
+	Usage         int64  `json:"usage" gorm:"not null;default:0"`
+	AlertedLevel  int    `json:"alerted_level" gorm:"not null;default:0"` // 当前周期已告警的最高比例
+	Paused        bool   `json:"paused" gorm:"not null;default:false"`
+	EvaluatedTime int64  `json:"evaluated_time" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (Budget) TableName() string {
+	return "budgets"
+}
+
+// BudgetAlert 预算告警记录
+type BudgetAlert struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;index:idx_budget_alert,priority:1"`
+	BudgetID    int64  `json:"budget_id" gorm:"not null;index:idx_budget_alert,priority:2"`
+	Period      string `json:"period" gorm:"size:32;not null"`
+	Level       int    `json:"level" gorm:"not null"`
+	Usage       int64  `json:"usage" gorm:"not null"`
+	Threshold   int64  `json:"threshold" gorm:"not null"`
+	Paused      bool   `json:"paused" gorm:"not null;default:false"`
+	EmailSent   bool   `json:"email_sent" gorm:"not null;default:false"`
+	WebhookSent bool   `json:"webhook_sent" gorm:"not null;default:false"`
+	Error       string `json:"error" gorm:"type:text"`
+	BaseModel
+}
+
+func (BudgetAlert) TableName() string {
+	return "budget_alerts"
+}
+
+// ValidBudgetScope 是否为支持的预算范围
+func ValidBudgetScope(scopeType string) bool {
+	switch scopeType {
+	case BudgetScopeEnterprise, BudgetScopeChannel, BudgetScopeAgent, BudgetScopeUserGroup:
+		return true
+	}
+	return false
+}
+
+// GetAlertLevels 解析告警比例
+func (b *Budget) GetAlertLevels() []int {
+	levels := make([]int, 0)
+	if b.AlertLevels != "" {
+		_ = json.Unmarshal([]byte(b.AlertLevels), &levels)
+	}
+	return levels
+}
+
+// GetNotifyEmails 解析告警邮箱
+func (b *Budget) GetNotifyEmails() []string {
+	emails := make([]string, 0)
+	if b.NotifyEmails != "" {
+		_ = json.Unmarshal([]byte(b.NotifyEmails), &emails)
+	}
+	return emails
+}
+
+// CreateBudget 创建预算
+func CreateBudget(budget *Budget) error {
+	return DB.Create(budget).Error
+}
+
+// GetBudget 获取企业下的预算
+func GetBudget(eid, id int64) (*Budget, error) {
+	var budget Budget
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&budget).Error
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// GetBudgets 获取企业的全部预算
+func GetBudgets(eid int64) ([]*Budget, error) {
+	budgets := make([]*Budget, 0)
+	err := DB.Where("eid = ?", eid).Order("id ASC").Find(&budgets).Error
+	return budgets, err
+}
+
+// GetBudgetsToEvaluate 获取需要定时检查的预算：已启用的预算，以及仍处于暂停状态需要恢复的预算
+func GetBudgetsToEvaluate() ([]*Budget, error) {
+	budgets := make([]*Budget, 0)
+	err := DB.Where("enabled = ? OR paused = ?", true, true).Order("id ASC").Find(&budgets).Error
+	return budgets, err
+}
+
+// GetPausedBudgetScopeIDs 获取企业下因预算暂停的智能体或渠道ID
+func GetPausedBudgetScopeIDs(eid int64, scopeType string) ([]int64, error) {
+	ids := make([]int64, 0)
+	err := DB.Model(&Budget{}).Where("eid = ? AND scope_type = ? AND paused = ?", eid, scopeType, true).
+		Distinct().Pluck("scope_id", &ids).Error
+	return ids, err
+}
+
+// UpdateBudget 更新预算配置，不修改运行状态
+func UpdateBudget(budget *Budget) error {
+	return DB.Model(budget).
+		Select("name", "scope_type", "scope_id", "metric", "period", "threshold", "alert_levels",
+			"notify_emails", "webhook_url", "pause_on_exceed", "enabled", "updated_time").
+		Updates(budget).Error
+}
+
+// UpdateBudgetState 保存预算的运行状态
+func UpdateBudgetState(budget *Budget) error {
+	return DB.Model(&Budget{}).Where("id = ?", budget.ID).
+		Updates(map[string]interface{}{
+			"current_period": budget.CurrentPeriod,
+			"usage":          budget.Usage,
+			"alerted_level":  budget.AlertedLevel,
+			"paused":         budget.Paused,
+			"evaluated_time": budget.EvaluatedTime,
+		}).Error
+}
+
+// DeleteBudget 删除预算及其告警记录
+func DeleteBudget(eid, id int64) error {
+	if err := DB.Where("eid = ? AND budget_id = ?", eid, id).Delete(&BudgetAlert{}).Error; err != nil {
+		return err
+	}
+	return DB.Where("eid = ? AND id = ?", eid, id).Delete(&Budget{}).Error
+}
+
+// CreateBudgetAlert 记录一次预算告警
+func CreateBudgetAlert(alert *BudgetAlert) error {
+	return DB.Create(alert).Error
+}
+
+// GetBudgetAlerts 分页获取预算告警记录，最新的在前，budgetID 为 0 时返回企业全部告警
+func GetBudgetAlerts(eid, budgetID int64, offset, limit int) ([]*BudgetAlert, int64, error) {
+	var count int64
+	query := DB.Model(&BudgetAlert{}).Where("eid = ?", eid)
+	if budgetID > 0 {
+		query = query.Where("budget_id = ?", budgetID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	alerts := make([]*BudgetAlert, 0)
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&alerts).Error
+	return alerts, count, err
+}
+
+// SumBudgetUsage 按小时汇总表统计预算范围内 [start, end) 时间段的用量
+// 汇总任务每隔几分钟更新当前小时的数据，用量会有相应延迟
+func SumBudgetUsage(budget *Budget, start, end time.Time) (int64, error) {
+	column := "quota"
+	if budget.Metric == BudgetMetricTokens {
+		column = "total_tokens"
+	}
+	query := DB.Model(&UsageRollup{}).
+		Where("eid = ? AND bucket_time >= ? AND bucket_time < ?", budget.Eid, start.UnixMilli(), end.UnixMilli())
+	switch budget.ScopeType {
+	case BudgetScopeChannel:
+		query = query.Where("channel_id = ?", budget.ScopeID)
+	case BudgetScopeAgent:
+		query = query.Where("agent_id = ?", budget.ScopeID)
+	case BudgetScopeUserGroup:
+		// 按用户当前所在的分组统计
+		query = query.Where("user_id IN (?)",
+			DB.Model(&User{}).Select("user_id").Where("eid = ? AND group_id = ?", budget.Eid, budget.ScopeID))
+	}
+	var usage int64
+	err := query.Select("COALESCE(SUM(" + column + "), 0)").Scan(&usage).Error
+	return usage, err
+}
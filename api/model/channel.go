@@ -17,6 +17,7 @@ const (
 	ChannelStatusEnabled          = 1
 	ChannelStatusManuallyDisabled = 2
 	ChannelStatusAutoDisabled     = 3
+	ChannelStatusBudgetPaused     = 4 // 消耗达到预算后暂停，新周期自动恢复
 )
 
 const (
@@ -135,6 +136,33 @@ func DeleteChannelByID(id int64) error {
 	return DB.Where("channel_id = ?", id).Delete(&Channel{}).Error
 }
 
+// UpdateChannelStatus 更新渠道状态
+func UpdateChannelStatus(channelID int64, status int) error {
+	return DB.Model(&Channel{}).Where("channel_id = ?", channelID).Update("status", status).Error
+}
+
+// PauseChannelByBudget 因预算用尽暂停渠道，只暂停已启用的渠道
+// 手动或自动禁用的渠道保持原状态，恢复时不会被误启用
+func PauseChannelByBudget(eid, channelID int64) error {
+	return DB.Model(&Channel{}).
+		Where("eid = ? AND channel_id = ? AND status = ?", eid, channelID, ChannelStatusEnabled).
+		Update("status", ChannelStatusBudgetPaused).Error
+}
+
+// ResumeChannelByBudget 恢复因预算暂停的渠道
+func ResumeChannelByBudget(eid, channelID int64) error {
+	return DB.Model(&Channel{}).
+		Where("eid = ? AND channel_id = ? AND status = ?", eid, channelID, ChannelStatusBudgetPaused).
+		Update("status", ChannelStatusEnabled).Error
+}
+
+// GetChannelsByStatus 获取指定状态的全部渠道
+func GetChannelsByStatus(statuses []int) ([]Channel, error) {
+	var channels []Channel
+	err := DB.Where("status IN (?)", statuses).Find(&channels).Error
+	return channels, err
+}
+
 func GetChannelsByEid(eid int64) ([]Channel, error) {
 	var channels []Channel
 	err := DB.Where("eid = ?", eid).Find(&channels).Error
@@ -219,15 +247,75 @@ func (channel *Channel) UpdateResponseTime(responseTime int64) {
 }
 
 func GetRandomChannel(eid int64, channelType int, modelName string) (*Channel, error) {
+	return GetPriorityChannel(eid, channelType, modelName, nil)
+}
+
+// GetPriorityChannel 按优先级从高到低选择渠道：只在最高优先级的可用渠道中按权重随机，
+// excludeChannelIds 中的渠道（如本次请求已失败的渠道）不参与选择
+func GetPriorityChannel(eid int64, channelType int, modelName string, excludeChannelIds []int64) (*Channel, error) {
+	var channels []Channel
+
+	db := DB.Where("eid = ? AND type = ? AND status = ? AND models LIKE ?",
+		eid, channelType, ChannelStatusEnabled, "%"+modelName+"%")
+	if len(excludeChannelIds) > 0 {
+		db = db.Where("channel_id NOT IN (?)", excludeChannelIds)
+	}
+	err := db.Order("priority DESC").Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pickHealthyChannel(channels)
+}
+
+// pickHealthyChannel 跳过熔断中的渠道后，在最高优先级的一组中按权重选择
+func pickHealthyChannel(channels []Channel) (*Channel, error) {
+	channel, err := pickWeightedChannel(topPriorityChannels(filterHealthyChannels(channels)))
+	if err != nil {
+		return nil, err
+	}
+	MarkChannelSelected(channel.ChannelID)
+	return channel, nil
+}
+
+// topPriorityChannels 返回按优先级降序排列的渠道中最高优先级的一组
+func topPriorityChannels(channels []Channel) []Channel {
+	if len(channels) == 0 {
+		return channels
+	}
+	top := channels[0].GetPriority()
+	end := 1
+	for end < len(channels) && channels[end].GetPriority() == top {
+		end++
+	}
+	return channels[:end]
+}
+
+// GetPriority 返回渠道优先级，未设置时为 0
+func (channel *Channel) GetPriority() int64 {
+	if channel.Priority == nil {
+		return 0
+	}
+	return *channel.Priority
+}
+
+// GetRandomChannelByModelType 按模型类型（如 Embedding）选择支持该模型的渠道，不限渠道类型
+func GetRandomChannelByModelType(eid int64, modelType int, modelName string) (*Channel, error) {
 	var channels []Channel
 
-	err := DB.Where("eid = ? AND type = ? AND status = ? AND models LIKE ?",
-		eid, channelType, ChannelStatusEnabled, "%"+modelName+"%").
+	err := DB.Where("eid = ? AND model_type = ? AND status = ? AND models LIKE ?",
+		eid, modelType, ChannelStatusEnabled, "%"+modelName+"%").
+		Order("priority DESC").
 		Find(&channels).Error
 	if err != nil {
 		return nil, err
 	}
 
+	return pickHealthyChannel(channels)
+}
+
+// pickWeightedChannel 按权重随机选择一个渠道
+func pickWeightedChannel(channels []Channel) (*Channel, error) {
 	if len(channels) == 0 {
 		return nil, fmt.Errorf("no available channel found")
 	}
@@ -0,0 +1,237 @@
+package model
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common/utils/env"
+)
+
+// 渠道熔断状态
+const (
+	CircuitClosed   = "closed"    // 正常放行
+	CircuitOpen     = "open"      // 熔断中，不参与渠道选择
+	CircuitHalfOpen = "half_open" // 冷却结束，放行一次试探请求
+)
+
+var (
+	// 统计窗口内保留的最近调用次数
+	channelHealthWindow = int(env.Int64("CHANNEL_HEALTH_WINDOW", 20))
+	// 触发熔断所需的最少样本数
+	channelHealthMinSamples = int(env.Int64("CHANNEL_HEALTH_MIN_SAMPLES", 5))
+	// 错误率达到该阈值（百分比）时熔断
+	channelHealthErrorRate = float64(env.Int64("CHANNEL_HEALTH_ERROR_RATE", 50)) / 100
+	// 熔断后的冷却时间，冷却结束进入半开状态
+	channelHealthCooldown = time.Duration(env.Int64("CHANNEL_HEALTH_COOLDOWN_SECONDS", 60)) * time.Second
+)
+
+// ChannelHealthStats 渠道健康统计
+type ChannelHealthStats struct {
+	ChannelID           int64   `json:"channel_id"`
+	State               string  `json:"state"`
+	Samples             int     `json:"samples"`
+	ErrorRate           float64 `json:"error_rate"`
+	LatencyP50          int64   `json:"latency_p50"`
+	LatencyP95          int64   `json:"latency_p95"`
+	LatencyP99          int64   `json:"latency_p99"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	ConsecutiveSuccess  int     `json:"consecutive_success"`
+	OpenedTime          int64   `json:"opened_time"`
+	LastCheckedTime     int64   `json:"last_checked_time"`
+}
+
+type channelResult struct {
+	success bool
+	latency int64
+}
+
+type channelHealth struct {
+	results             []channelResult
+	state               string
+	openedAt            time.Time
+	trialInFlight       bool
+	trialStartedAt      time.Time
+	consecutiveFailures int
+	consecutiveSuccess  int
+	lastCheckedAt       time.Time
+}
+
+var channelHealthMap = make(map[int64]*channelHealth)
+var channelHealthLock sync.Mutex
+
+func getChannelHealth(channelID int64) *channelHealth {
+	h, ok := channelHealthMap[channelID]
+	if !ok {
+		h = &channelHealth{state: CircuitClosed}
+		channelHealthMap[channelID] = h
+	}
+	return h
+}
+
+// RecordChannelResult 记录一次渠道调用（真实请求或探测）的结果和耗时
+func RecordChannelResult(channelID int64, success bool, latency time.Duration) {
+	channelHealthLock.Lock()
+	defer channelHealthLock.Unlock()
+
+	h := getChannelHealth(channelID)
+	h.results = append(h.results, channelResult{success: success, latency: latency.Milliseconds()})
+	if len(h.results) > channelHealthWindow {
+		h.results = h.results[len(h.results)-channelHealthWindow:]
+	}
+	h.lastCheckedAt = time.Now()
+
+	if success {
+		h.consecutiveSuccess++
+		h.consecutiveFailures = 0
+	} else {
+		h.consecutiveFailures++
+		h.consecutiveSuccess = 0
+	}
+
+	switch h.state {
+	case CircuitHalfOpen:
+		h.trialInFlight = false
+		if success {
+			// 试探成功，恢复并清空历史，避免旧错误立即再次触发熔断
+			h.state = CircuitClosed
+			h.results = h.results[len(h.results)-1:]
+		} else {
+			h.state = CircuitOpen
+			h.openedAt = time.Now()
+		}
+	case CircuitOpen:
+		// 熔断期间的探测成功也可直接恢复
+		if success {
+			h.state = CircuitClosed
+			h.results = h.results[len(h.results)-1:]
+		}
+	default:
+		if !success && len(h.results) >= channelHealthMinSamples && h.errorRate() >= channelHealthErrorRate {
+			h.state = CircuitOpen
+			h.openedAt = time.Now()
+		}
+	}
+}
+
+// AllowChannel 判断渠道当前是否可以参与选择（不改变状态）
+// 熔断冷却结束、或上一次试探请求超时未返回时，允许再次试探
+func AllowChannel(channelID int64) bool {
+	channelHealthLock.Lock()
+	defer channelHealthLock.Unlock()
+
+	h, ok := channelHealthMap[channelID]
+	if !ok {
+		return true
+	}
+	return h.allow()
+}
+
+func (h *channelHealth) allow() bool {
+	switch h.state {
+	case CircuitOpen:
+		return time.Since(h.openedAt) >= channelHealthCooldown
+	case CircuitHalfOpen:
+		return !h.trialInFlight || time.Since(h.trialStartedAt) >= channelHealthCooldown
+	default:
+		return true
+	}
+}
+
+// MarkChannelSelected 渠道被选中时调用，熔断中的渠道进入半开状态并占用唯一的试探名额
+func MarkChannelSelected(channelID int64) {
+	channelHealthLock.Lock()
+	defer channelHealthLock.Unlock()
+
+	h, ok := channelHealthMap[channelID]
+	if !ok || h.state == CircuitClosed || !h.allow() {
+		return
+	}
+	h.state = CircuitHalfOpen
+	h.trialInFlight = true
+	h.trialStartedAt = time.Now()
+}
+
+// IsChannelCircuitOpen 渠道是否处于熔断状态（不改变状态，用于展示和探测）
+func IsChannelCircuitOpen(channelID int64) bool {
+	channelHealthLock.Lock()
+	defer channelHealthLock.Unlock()
+
+	h, ok := channelHealthMap[channelID]
+	return ok && h.state != CircuitClosed
+}
+
+// GetChannelHealthStats 获取渠道健康统计
+func GetChannelHealthStats(channelID int64) ChannelHealthStats {
+	channelHealthLock.Lock()
+	defer channelHealthLock.Unlock()
+
+	stats := ChannelHealthStats{ChannelID: channelID, State: CircuitClosed}
+	h, ok := channelHealthMap[channelID]
+	if !ok {
+		return stats
+	}
+
+	stats.State = h.state
+	stats.Samples = len(h.results)
+	stats.ErrorRate = h.errorRate()
+	stats.ConsecutiveFailures = h.consecutiveFailures
+	stats.ConsecutiveSuccess = h.consecutiveSuccess
+	if !h.openedAt.IsZero() && h.state != CircuitClosed {
+		stats.OpenedTime = h.openedAt.UTC().UnixMilli()
+	}
+	if !h.lastCheckedAt.IsZero() {
+		stats.LastCheckedTime = h.lastCheckedAt.UTC().UnixMilli()
+	}
+
+	latencies := make([]int64, 0, len(h.results))
+	for _, r := range h.results {
+		if r.success {
+			latencies = append(latencies, r.latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.LatencyP50 = percentile(latencies, 50)
+	stats.LatencyP95 = percentile(latencies, 95)
+	stats.LatencyP99 = percentile(latencies, 99)
+	return stats
+}
+
+func (h *channelHealth) errorRate() float64 {
+	if len(h.results) == 0 {
+		return 0
+	}
+	failures := 0
+	for _, r := range h.results {
+		if !r.success {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(h.results))
+}
+
+// percentile 计算已排序数据的百分位数（最近秩法）
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// filterHealthyChannels 过滤掉熔断中的渠道；若全部熔断则原样返回，避免局部故障放大为整体不可用
+func filterHealthyChannels(channels []Channel) []Channel {
+	healthy := make([]Channel, 0, len(channels))
+	for _, channel := range channels {
+		if AllowChannel(channel.ChannelID) {
+			healthy = append(healthy, channel)
+		}
+	}
+	if len(healthy) == 0 {
+		return channels
+	}
+	return healthy
+}
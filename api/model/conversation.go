@@ -14,6 +14,8 @@ type Conversation struct {
 	ChannelConversationID             string `json:"channel_conversation_id" gorm:"column:channel_conversation_id;type:varchar(255)"`
 	ChannelConversationExpirationTime int64  `json:"channel_conversation_expiration_time" gorm:"column:channel_conversation_expiration_time;default:0"`
 	Model                             string `json:"model" gorm:"column:model;type:varchar(255)"`
+	Summary                           string `json:"summary" gorm:"column:summary;type:text"`         // 早期轮次的滚动摘要
+	SummaryAnchor                     string `json:"-" gorm:"column:summary_anchor;type:varchar(64)"` // 摘要覆盖的最后一轮的指纹
 	Agent                             *Agent `json:"agent" gorm:"-"`
 	User                              *User  `json:"user" gorm:"-"`
 	BaseModel
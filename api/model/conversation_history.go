@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 会话历史管理策略
+const (
+	HistoryStrategyNone          = ""
+	HistoryStrategySlidingWindow = "sliding_window" // 按 token 预算保留最近的轮次
+	HistoryStrategyFirstTurns    = "first_turns"    // 固定保留最早的 N 轮，其余按预算保留最近的轮次
+	HistoryStrategySummary       = "summary"        // 超出预算的早期轮次由模型生成滚动摘要
+)
+
+// 默认历史 token 预算
+const DefaultHistoryMaxTokens = 4000
+
+// 默认从消息记录重建历史时最多读取的条数
+const DefaultHistoryRebuildLimit = 50
+
+// HistorySettings 智能体会话历史配置，存储于 Agent.Settings 的 history 字段
+// {"history":{"strategy":"sliding_window","max_tokens":4000,"first_turns":1,"rebuild":true,"summary_model":""}}
+type HistorySettings struct {
+	Strategy     string `json:"strategy"`
+	MaxTokens    int    `json:"max_tokens"`    // 历史消息（含系统提示词）的 token 预算，0 表示使用默认值
+	FirstTurns   int    `json:"first_turns"`   // first_turns 策略固定保留的轮数
+	Rebuild      bool   `json:"rebuild"`       // 客户端仅发送最新一轮时，根据会话的消息记录重建历史
+	RebuildLimit int    `json:"rebuild_limit"` // 重建历史时最多读取的消息条数，0 表示使用默认值
+	SummaryModel string `json:"summary_model"` // 生成摘要使用的模型，为空时使用智能体模型
+}
+
+// GetHistorySettings 解析智能体的会话历史配置，未配置或格式错误时不做处理（预算等参数使用默认值）
+func (agent *Agent) GetHistorySettings() HistorySettings {
+	var settings struct {
+		History HistorySettings `json:"history"`
+	}
+	if agent.Settings != "" && json.Unmarshal([]byte(agent.Settings), &settings) != nil {
+		settings.History = HistorySettings{}
+	}
+	if settings.History.MaxTokens <= 0 {
+		settings.History.MaxTokens = DefaultHistoryMaxTokens
+	}
+	if settings.History.RebuildLimit <= 0 {
+		settings.History.RebuildLimit = DefaultHistoryRebuildLimit
+	}
+	return settings.History
+}
+
+// GetConversationHistoryMessages 获取会话最近的已完成消息，按时间正序返回
+// 失败消息的 Answer 为错误信息，不作为历史
+func GetConversationHistoryMessages(eid, conversationID int64, limit int) ([]*Message, error) {
+	var messages []*Message
+	err := DB.Where("eid = ? AND conversation_id = ? AND answer <> '' AND is_error = ?", eid, conversationID, false).
+		Order("created_time DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// UpdateConversationSummary 更新会话的滚动摘要及其覆盖的最后一轮的指纹
+func UpdateConversationSummary(eid, conversationID int64, summary, anchor string) error {
+	return DB.Model(&Conversation{}).
+		Where("eid = ? AND conversation_id = ?", eid, conversationID).
+		Updates(map[string]interface{}{
+			"summary":        summary,
+			"summary_anchor": anchor,
+			"updated_time":   time.Now().UTC().UnixMilli(),
+		}).Error
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// GetUserConversationThreads 分页获取用户的会话线程，agentID 为 0 时不按智能体过滤
+func GetUserConversationThreads(eid, userID, agentID int64, offset, limit int) ([]*Conversation, int64, error) {
+	query := DB.Model(&Conversation{}).Where("eid = ? AND user_id = ?", eid, userID)
+	if agentID > 0 {
+		query = query.Where("agent_id = ?", agentID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var conversations []*Conversation
+	if err := query.Order("updated_time DESC").Offset(offset).Limit(limit).Find(&conversations).Error; err != nil {
+		return nil, 0, err
+	}
+	return conversations, total, nil
+}
+
+// ForkConversation 复制会话及其消息生成新的会话，upToMessageID 大于 0 时只复制该消息及之前的消息
+// 复制的消息不带用量，新会话的用量从 0 开始
+func ForkConversation(source *Conversation, upToMessageID int64, title string) (*Conversation, error) {
+	fork := &Conversation{
+		Eid:     source.Eid,
+		UserID:  source.UserID,
+		AgentID: source.AgentID,
+		Title:   title,
+		Status:  ConversationStatusActive,
+		Model:   source.Model,
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("eid = ? AND conversation_id = ?", source.Eid, source.ConversationID)
+		if upToMessageID > 0 {
+			var anchor Message
+			if err := tx.Where("eid = ? AND conversation_id = ? AND id = ?", source.Eid, source.ConversationID, upToMessageID).
+				First(&anchor).Error; err != nil {
+				return err
+			}
+			query = query.Where("id <= ?", anchor.ID)
+		}
+
+		var messages []*Message
+		if err := query.Order("created_time ASC, id ASC").Find(&messages).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(fork).Error; err != nil {
+			return err
+		}
+		for _, message := range messages {
+			// 复制的消息只保留内容，用量已计入源消息，不再计入汇总、预算和会话用量
+			message.ForkedFrom = message.ID
+			message.ID = 0
+			message.ConversationID = fork.ConversationID
+			message.Quota = 0
+			message.Cost = 0
+			message.PromptTokens = 0
+			message.CompletionTokens = 0
+			message.TotalTokens = 0
+			message.ChannelId = 0
+			message.ElapsedTime = 0
+		}
+		if len(messages) > 0 {
+			if err := tx.Create(&messages).Error; err != nil {
+				return err
+			}
+			last := messages[len(messages)-1]
+			lastMessage, _ := json.Marshal(map[string]string{
+				"question": last.Message,
+				"answer":   last.Answer,
+			})
+			fork.LastMessage = string(lastMessage)
+		}
+		return tx.Model(fork).Update("last_message", fork.LastMessage).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+// DeleteConversationThread 删除用户的会话。与 DeleteConversation 一致保留消息记录，
+// 消息的用量仍计入汇总和预算，删除会话不能绕过预算
+func DeleteConversationThread(eid, userID, conversationID int64) error {
+	result := DB.Where("eid = ? AND conversation_id = ? AND user_id = ?", eid, conversationID, userID).Delete(&Conversation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
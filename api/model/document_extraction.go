@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm/clause"
+)
+
+// 默认内联到对话中的文档文本 token 上限
+const DefaultDocumentParsingMaxTokens = 8000
+
+// DocumentParsingSettings 智能体文档解析配置，存储于 Agent.Settings 的 document_parsing 字段
+// {"document_parsing":{"disabled":false,"max_tokens":8000}}
+type DocumentParsingSettings struct {
+	Disabled  bool `json:"disabled"`
+	MaxTokens int  `json:"max_tokens"` // 单次请求内联的文档文本 token 总数，0 表示使用默认值
+}
+
+// GetDocumentParsingSettings 解析智能体的文档解析配置，未配置时默认开启
+func (agent *Agent) GetDocumentParsingSettings() DocumentParsingSettings {
+	var settings struct {
+		DocumentParsing DocumentParsingSettings `json:"document_parsing"`
+	}
+	if agent.Settings != "" {
+		_ = json.Unmarshal([]byte(agent.Settings), &settings)
+	}
+	if settings.DocumentParsing.MaxTokens <= 0 {
+		settings.DocumentParsing.MaxTokens = DefaultDocumentParsingMaxTokens
+	}
+	return settings.DocumentParsing
+}
+
+// DocumentExtraction 文档文本提取结果，按文件内容哈希缓存，相同内容的文件只解析一次
+type DocumentExtraction struct {
+	ID        int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Hash      string `json:"hash" gorm:"type:varchar(128);not null;uniqueIndex:idx_document_extraction"`
+	Extension string `json:"extension" gorm:"type:varchar(50);not null;default:'';uniqueIndex:idx_document_extraction"`
+	Content   string `json:"content" gorm:"type:longtext"`
+	Error     string `json:"error" gorm:"type:text"`
+	BaseModel
+}
+
+func (DocumentExtraction) TableName() string {
+	return "document_extractions"
+}
+
+// GetDocumentExtraction 按文件哈希和扩展名获取提取结果
+func GetDocumentExtraction(hash, extension string) (*DocumentExtraction, error) {
+	var extraction DocumentExtraction
+	if err := DB.Where("hash = ? AND extension = ?", hash, extension).First(&extraction).Error; err != nil {
+		return nil, err
+	}
+	return &extraction, nil
+}
+
+// SaveDocumentExtraction 保存提取结果，已存在时忽略
+func SaveDocumentExtraction(extraction *DocumentExtraction) error {
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(extraction).Error
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/53AI/53AIHub/common/logger"
@@ -152,6 +154,65 @@ func (enterprise *Enterprise) PartialUpdateEnterprise(updateData map[string]inte
 		Updates(filteredUpdate).Error
 }
 
+// GetLocation 解析企业时区，支持 "UTC+8"、"UTC-5:30" 以及 IANA 名称，解析失败时回退到 UTC+8
+func (enterprise *Enterprise) GetLocation() *time.Location {
+	return ParseTimezone(enterprise.Timezone)
+}
+
+// ParseTimezone 将时区字符串转换为 *time.Location
+func ParseTimezone(timezone string) *time.Location {
+	defaultLocation := time.FixedZone("UTC+8", 8*3600)
+	timezone = strings.TrimSpace(timezone)
+	if timezone == "" {
+		return defaultLocation
+	}
+
+	upper := strings.ToUpper(timezone)
+	if upper == "UTC" || upper == "GMT" {
+		return time.UTC
+	}
+	if strings.HasPrefix(upper, "UTC") || strings.HasPrefix(upper, "GMT") {
+		offset := upper[3:]
+		sign := 1
+		switch {
+		case strings.HasPrefix(offset, "+"):
+			offset = offset[1:]
+		case strings.HasPrefix(offset, "-"):
+			sign = -1
+			offset = offset[1:]
+		default:
+			return defaultLocation
+		}
+
+		hourPart, minutePart, _ := strings.Cut(offset, ":")
+		hours, err := strconv.Atoi(hourPart)
+		if err != nil {
+			return defaultLocation
+		}
+		minutes := 0
+		if minutePart != "" {
+			if minutes, err = strconv.Atoi(minutePart); err != nil {
+				return defaultLocation
+			}
+		}
+		return time.FixedZone(timezone, sign*(hours*3600+minutes*60))
+	}
+
+	if location, err := time.LoadLocation(timezone); err == nil {
+		return location
+	}
+	return defaultLocation
+}
+
+// GetEnterpriseLocation 获取企业所在时区，企业不存在时回退到默认时区
+func GetEnterpriseLocation(eid int64) *time.Location {
+	enterprise, err := GetEnterpriseByID(eid)
+	if err != nil {
+		return ParseTimezone("")
+	}
+	return enterprise.GetLocation()
+}
+
 func GetEnterpriseName(eid int64) (string, error) {
 	var displayName string
 	err := DB.Model(&Enterprise{}).
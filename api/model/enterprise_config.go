@@ -11,20 +11,29 @@ type EnterpriseConfig struct {
 	Type    string `json:"type" gorm:"uniqueIndex:idx_eid_config;size:64;not null;default:''"`
 	// smtp {\"smtp_host\":\"smtp_host.com\",\"smtp_username\":\"smtp_username@xx.com\",\"smtp_port\":\"465\",\"smtp_password\":\"xxxxxx\",\"smtp_from\":\"smtp_username@xx.com\",\"smtp_is_ssl\":true,\"smtp_to\":\"smtp_to\"}
 	// auth_sso {"encrypt_enabled":true,"secret":""}
+	// rate_limit {"enterprise":{"rpm":600,"concurrent":50},"user":{"rpm":60,"concurrent":2},"user_groups":{"1":{"rpm":120,"concurrent":5}},"agents":{"1":{"rpm":300,"concurrent":20}}}
+	// moderation {"input":true,"output":true,"mask_char":"*","block_message":"","stream_window":16,"rules":[{"name":"敏感词","type":"dictionary","action":"mask","dictionary":"词1\n词2"}],"model":{"enabled":false,"channel_id":0,"model":"","action":"flag"}}
+	// pricing {"currency":"CNY","quota_per_unit":500000,"user_groups":{"1":0.8}}
 	Content string `json:"content" gorm:"type:text"`
 	BaseModel
 }
 
 const (
-	EnterpriseConfigTypeSMTP   = "smtp"
-	EnterpriseConfigTypeMobile = "mobile"
-	EnterpriseConfigTypeSSO    = "auth_sso"
+	EnterpriseConfigTypeSMTP       = "smtp"
+	EnterpriseConfigTypeMobile     = "mobile"
+	EnterpriseConfigTypeSSO        = "auth_sso"
+	EnterpriseConfigTypeRateLimit  = "rate_limit"
+	EnterpriseConfigTypeModeration = "moderation"
+	EnterpriseConfigTypePricing    = "pricing"
 )
 
 var EnterpriseConfigTypes = []string{
 	EnterpriseConfigTypeSMTP,
 	EnterpriseConfigTypeMobile,
 	EnterpriseConfigTypeSSO,
+	EnterpriseConfigTypeRateLimit,
+	EnterpriseConfigTypeModeration,
+	EnterpriseConfigTypePricing,
 }
 
 // 根据 type 获取 content 默认值
@@ -36,6 +45,12 @@ func GetEnterpriseConfigDefaultContent(configType string) (string, error) {
 		return `{}`, nil
 	case EnterpriseConfigTypeSSO:
 		return `{"encrypt_enabled":true,"secret":""}`, nil
+	case EnterpriseConfigTypeRateLimit:
+		return `{"enterprise":{"rpm":0,"concurrent":0},"user":{"rpm":0,"concurrent":0},"user_groups":{},"agents":{}}`, nil
+	case EnterpriseConfigTypeModeration:
+		return `{"input":true,"output":true,"mask_char":"*","block_message":"","stream_window":16,"rules":[],"model":{"enabled":false,"channel_id":0,"model":"","action":"flag"}}`, nil
+	case EnterpriseConfigTypePricing:
+		return `{"currency":"USD","quota_per_unit":500000,"user_groups":{}}`, nil
 	default:
 		return "", fmt.Errorf("config type %s not found", configType)
 	}
@@ -0,0 +1,288 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 导出任务状态
+const (
+	ExportJobStatusPending   = "pending"
+	ExportJobStatusRunning   = "running"
+	ExportJobStatusSucceeded = "succeeded"
+	ExportJobStatusFailed    = "failed"
+)
+
+// 导出的数据类型
+const (
+	ExportTypeMessages      = "messages"
+	ExportTypeConversations = "conversations"
+	ExportTypeOrders        = "orders"
+	ExportTypeUsage         = "usage"
+)
+
+// ExportJob 后台导出任务，导出文件保存在存储中，过期后由定时任务清理
+type ExportJob struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64  `json:"eid" gorm:"not null;index"`
+	UserID         int64  `json:"user_id" gorm:"not null;default:0"`
+	Type           string `json:"type" gorm:"size:20;not null"`
+	Format         string `json:"format" gorm:"size:10;not null"`
+	Filters        string `json:"-" gorm:"type:text"` // ExportFilters 的 JSON
+	Status         string `json:"status" gorm:"size:20;not null;default:'pending';index"`
+	TotalRows      int64  `json:"total_rows" gorm:"not null;default:0"`
+	ProcessedRows  int64  `json:"processed_rows" gorm:"not null;default:0"`
+	FileName       string `json:"file_name" gorm:"size:255;not null;default:''"`
+	FileKey        string `json:"-" gorm:"size:512;not null;default:''"`
+	FileSize       int64  `json:"file_size" gorm:"not null;default:0"`
+	DownloadSecret string `json:"-" gorm:"size:100;not null;default:''"` // 下载链接签名密钥
+	ErrorMessage   string `json:"error_message" gorm:"type:text"`
+	StartedTime    int64  `json:"started_time" gorm:"not null;default:0"`
+	FinishedTime   int64  `json:"finished_time" gorm:"not null;default:0"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"not null;default:0;index"` // 导出文件的过期时间
+	BaseModel
+}
+
+func (ExportJob) TableName() string {
+	return "export_jobs"
+}
+
+// ExportFilters 导出的筛选条件，时间为毫秒时间戳，左闭右开
+type ExportFilters struct {
+	StartTime     int64   `json:"start_time"`
+	EndTime       int64   `json:"end_time"`
+	AgentID       int64   `json:"agent_id,omitempty"`
+	UserID        int64   `json:"user_id,omitempty"`
+	DepartmentIDs []int64 `json:"department_ids,omitempty"`
+	Interval      string  `json:"interval,omitempty"` // 仅用量导出
+	GroupBy       string  `json:"group_by,omitempty"` // 仅用量导出
+}
+
+// ValidExportType 是否为支持的导出类型
+func ValidExportType(exportType string) bool {
+	switch exportType {
+	case ExportTypeMessages, ExportTypeConversations, ExportTypeOrders, ExportTypeUsage:
+		return true
+	}
+	return false
+}
+
+// GenerateExportDownloadSecret 生成下载链接签名密钥
+func GenerateExportDownloadSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GetFilters 解析筛选条件
+func (j *ExportJob) GetFilters() (ExportFilters, error) {
+	var filters ExportFilters
+	if j.Filters == "" {
+		return filters, nil
+	}
+	err := json.Unmarshal([]byte(j.Filters), &filters)
+	return filters, err
+}
+
+// IsFinished 任务是否已结束
+func (j *ExportJob) IsFinished() bool {
+	return j.Status == ExportJobStatusSucceeded || j.Status == ExportJobStatusFailed
+}
+
+// CreateExportJob 创建导出任务
+func CreateExportJob(job *ExportJob) error {
+	return DB.Create(job).Error
+}
+
+// GetExportJob 获取企业下的导出任务
+func GetExportJob(eid, id int64) (*ExportJob, error) {
+	var job ExportJob
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetExportJobByID 根据ID获取导出任务，用于已签名的下载请求
+func GetExportJobByID(id int64) (*ExportJob, error) {
+	var job ExportJob
+	err := DB.Where("id = ?", id).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetExportJobs 分页获取企业的导出任务，最新的在前
+func GetExportJobs(eid int64, offset, limit int) ([]*ExportJob, int64, error) {
+	var count int64
+	query := DB.Model(&ExportJob{}).Where("eid = ?", eid)
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	jobs := make([]*ExportJob, 0)
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+	return jobs, count, err
+}
+
+// StartExportJob 将排队中的任务标记为执行中，任务已被删除或已结束时返回 false
+func StartExportJob(job *ExportJob) (bool, error) {
+	now := time.Now().UTC().UnixMilli()
+	result := DB.Model(&ExportJob{}).
+		Where("id = ? AND status = ?", job.ID, ExportJobStatusPending).
+		Updates(map[string]interface{}{
+			"status":       ExportJobStatusRunning,
+			"started_time": now,
+			"updated_time": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	job.Status = ExportJobStatusRunning
+	job.StartedTime = now
+	return true, nil
+}
+
+// UpdateExportJobProgress 更新执行中任务的进度
+func UpdateExportJobProgress(id, processed, total int64) error {
+	return DB.Model(&ExportJob{}).
+		Where("id = ? AND status = ?", id, ExportJobStatusRunning).
+		Updates(map[string]interface{}{
+			"processed_rows": processed,
+			"total_rows":     total,
+			"updated_time":   time.Now().UTC().UnixMilli(),
+		}).Error
+}
+
+// FinishExportJob 写入任务结果，只更新执行中的任务，任务已被删除时返回 false
+func FinishExportJob(job *ExportJob) (bool, error) {
+	now := time.Now().UTC().UnixMilli()
+	job.FinishedTime = now
+	result := DB.Model(&ExportJob{}).
+		Where("id = ? AND status = ?", job.ID, ExportJobStatusRunning).
+		Updates(map[string]interface{}{
+			"status":         job.Status,
+			"total_rows":     job.TotalRows,
+			"processed_rows": job.ProcessedRows,
+			"file_name":      job.FileName,
+			"file_key":       job.FileKey,
+			"file_size":      job.FileSize,
+			"error_message":  job.ErrorMessage,
+			"finished_time":  now,
+			"expired_time":   job.ExpiredTime,
+			"updated_time":   now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteExportJob 删除导出任务记录
+func DeleteExportJob(eid, id int64) error {
+	return DB.Where("eid = ? AND id = ?", eid, id).Delete(&ExportJob{}).Error
+}
+
+// FailStaleExportJobs 将超时仍未结束的任务标记为失败（例如服务重启导致任务丢失），返回受影响的数量
+func FailStaleExportJobs(before int64) (int64, error) {
+	now := time.Now().UTC().UnixMilli()
+	result := DB.Model(&ExportJob{}).
+		Where("status IN ? AND created_time < ?",
+			[]string{ExportJobStatusPending, ExportJobStatusRunning}, before).
+		Updates(map[string]interface{}{
+			"status":        ExportJobStatusFailed,
+			"error_message": "export job timed out",
+			"finished_time": now,
+			"updated_time":  now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// GetExpiredExportJobs 获取导出文件已过期的任务
+func GetExpiredExportJobs(now int64, limit int) ([]*ExportJob, error) {
+	jobs := make([]*ExportJob, 0)
+	err := DB.Where("expired_time > 0 AND expired_time < ?", now).
+		Order("id ASC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// ExportQuery 导出数据的筛选条件，UserIDs 为空时不按用户筛选
+type ExportQuery struct {
+	Eid       int64
+	StartTime int64
+	EndTime   int64
+	AgentID   int64
+	UserIDs   []int64
+}
+
+func (q ExportQuery) apply(db *gorm.DB, withAgent bool) *gorm.DB {
+	db = db.Where("eid = ?", q.Eid)
+	if q.StartTime > 0 {
+		db = db.Where("created_time >= ?", q.StartTime)
+	}
+	if q.EndTime > 0 {
+		db = db.Where("created_time < ?", q.EndTime)
+	}
+	if withAgent && q.AgentID > 0 {
+		db = db.Where("agent_id = ?", q.AgentID)
+	}
+	if len(q.UserIDs) > 0 {
+		db = db.Where("user_id IN ?", q.UserIDs)
+	}
+	return db
+}
+
+// CountMessagesForExport 统计待导出的消息数量
+func CountMessagesForExport(q ExportQuery) (int64, error) {
+	var count int64
+	err := q.apply(DB.Model(&Message{}), true).Count(&count).Error
+	return count, err
+}
+
+// GetMessagesForExport 按ID顺序分页获取待导出的消息
+func GetMessagesForExport(q ExportQuery, afterID int64, limit int) ([]*Message, error) {
+	messages := make([]*Message, 0)
+	err := q.apply(DB, true).Where("id > ?", afterID).
+		Order("id ASC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// CountConversationsForExport 统计待导出的会话数量
+func CountConversationsForExport(q ExportQuery) (int64, error) {
+	var count int64
+	err := q.apply(DB.Model(&Conversation{}), true).Count(&count).Error
+	return count, err
+}
+
+// GetConversationsForExport 按ID顺序分页获取待导出的会话，包含已删除的会话
+func GetConversationsForExport(q ExportQuery, afterID int64, limit int) ([]*Conversation, error) {
+	conversations := make([]*Conversation, 0)
+	err := q.apply(DB, true).Where("conversation_id > ?", afterID).
+		Order("conversation_id ASC").Limit(limit).Find(&conversations).Error
+	return conversations, err
+}
+
+// CountOrdersForExport 统计待导出的订单数量，订单不区分智能体
+func CountOrdersForExport(q ExportQuery) (int64, error) {
+	var count int64
+	err := q.apply(DB.Model(&Order{}), false).Count(&count).Error
+	return count, err
+}
+
+// GetOrdersForExport 按ID顺序分页获取待导出的订单
+func GetOrdersForExport(q ExportQuery, afterID int64, limit int) ([]*Order, error) {
+	orders := make([]*Order, 0)
+	err := q.apply(DB, false).Where("id > ?", afterID).
+		Order("id ASC").Limit(limit).Find(&orders).Error
+	return orders, err
+}
@@ -0,0 +1,259 @@
+package model
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+const (
+	KnowledgeDocumentStatusPending    = "pending"
+	KnowledgeDocumentStatusProcessing = "processing"
+	KnowledgeDocumentStatusCompleted  = "completed"
+	KnowledgeDocumentStatusFailed     = "failed"
+
+	DefaultKnowledgeChunkSize    = 500
+	DefaultKnowledgeChunkOverlap = 50
+	DefaultKnowledgeTopK         = 5
+	DefaultKnowledgeRerankModel  = "gte-rerank-v2"
+)
+
+// KnowledgeBase 内置知识库，文档切片后通过 Embedding 渠道向量化
+type KnowledgeBase struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64  `json:"eid" gorm:"not null;index"`
+	Name           string `json:"name" gorm:"size:100;not null;default:''"`
+	Description    string `json:"description" gorm:"type:text"`
+	EmbeddingModel string `json:"embedding_model" gorm:"size:100;not null;default:'';comment:向量模型，需有对应的 Embedding 渠道"`
+	ChunkSize      int    `json:"chunk_size" gorm:"not null;default:500;comment:切片长度（字符）"`
+	ChunkOverlap   int    `json:"chunk_overlap" gorm:"not null;default:50;comment:相邻切片重叠长度（字符）"`
+	DocumentCount  int    `json:"document_count" gorm:"not null;default:0"`
+	ChunkCount     int    `json:"chunk_count" gorm:"not null;default:0"`
+	CreatedBy      int64  `json:"created_by" gorm:"not null;default:0;comment:创建人"`
+	BaseModel
+}
+
+func (KnowledgeBase) TableName() string {
+	return "knowledge_bases"
+}
+
+// KnowledgeDocument 知识库中的文档，来源于已上传的文件
+type KnowledgeDocument struct {
+	ID              int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid             int64  `json:"eid" gorm:"not null;index"`
+	KnowledgeBaseID int64  `json:"knowledge_base_id" gorm:"not null;index"`
+	FileID          int64  `json:"file_id" gorm:"not null;default:0;comment:UploadFile ID"`
+	FileName        string `json:"file_name" gorm:"size:255;not null;default:''"`
+	Status          string `json:"status" gorm:"size:20;not null;default:'pending';comment:状态。pending待处理；processing处理中；completed已完成；failed失败"`
+	Error           string `json:"error" gorm:"type:text"`
+	ChunkCount      int    `json:"chunk_count" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (KnowledgeDocument) TableName() string {
+	return "knowledge_documents"
+}
+
+// KnowledgeChunk 文档切片及其向量，向量为归一化后的 float32 小端序字节
+type KnowledgeChunk struct {
+	ID              int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid             int64  `json:"eid" gorm:"not null;index"`
+	KnowledgeBaseID int64  `json:"knowledge_base_id" gorm:"not null;index"`
+	DocumentID      int64  `json:"document_id" gorm:"not null;index"`
+	Seq             int    `json:"seq" gorm:"not null;default:0;comment:文档内序号"`
+	Content         string `json:"content" gorm:"type:text"`
+	Embedding       []byte `json:"-"`
+	BaseModel
+}
+
+func (KnowledgeChunk) TableName() string {
+	return "knowledge_chunks"
+}
+
+// KnowledgeRerankSettings 检索结果重排序配置
+type KnowledgeRerankSettings struct {
+	Enabled bool   `json:"enabled"`
+	Model   string `json:"model"`
+}
+
+// KnowledgeBaseSettings 智能体关联知识库配置，存储于 Agent.Settings 的 knowledge_base 字段
+// {"knowledge_base":{"knowledge_base_ids":[1],"top_k":5,"score_threshold":0.3,"rerank":{"enabled":true,"model":"gte-rerank-v2"}}}
+type KnowledgeBaseSettings struct {
+	KnowledgeBaseIDs []int64                 `json:"knowledge_base_ids"`
+	TopK             int                     `json:"top_k"`
+	ScoreThreshold   float64                 `json:"score_threshold"`
+	Rerank           KnowledgeRerankSettings `json:"rerank"`
+}
+
+// GetKnowledgeBaseSettings 解析智能体关联的知识库，未配置时 KnowledgeBaseIDs 为空
+func (agent *Agent) GetKnowledgeBaseSettings() KnowledgeBaseSettings {
+	var settings struct {
+		KnowledgeBase KnowledgeBaseSettings `json:"knowledge_base"`
+	}
+	if agent.Settings == "" || json.Unmarshal([]byte(agent.Settings), &settings) != nil {
+		return KnowledgeBaseSettings{}
+	}
+	if settings.KnowledgeBase.TopK <= 0 {
+		settings.KnowledgeBase.TopK = DefaultKnowledgeTopK
+	}
+	if settings.KnowledgeBase.Rerank.Model == "" {
+		settings.KnowledgeBase.Rerank.Model = DefaultKnowledgeRerankModel
+	}
+	return settings.KnowledgeBase
+}
+
+func (kb *KnowledgeBase) Create() error {
+	if kb.ChunkSize <= 0 {
+		kb.ChunkSize = DefaultKnowledgeChunkSize
+	}
+	if kb.ChunkOverlap < 0 || kb.ChunkOverlap >= kb.ChunkSize {
+		kb.ChunkOverlap = DefaultKnowledgeChunkOverlap
+	}
+	return DB.Create(kb).Error
+}
+
+// Update 更新名称和描述，向量模型和切片参数变更需要重建索引，不在此修改
+func (kb *KnowledgeBase) Update() error {
+	return DB.Model(kb).Select("name", "description", "updated_time").Updates(kb).Error
+}
+
+// Delete 删除知识库及其文档和切片
+func (kb *KnowledgeBase) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("knowledge_base_id = ?", kb.ID).Delete(&KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("knowledge_base_id = ?", kb.ID).Delete(&KnowledgeDocument{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(kb).Error
+	})
+}
+
+func GetKnowledgeBaseByID(eid, id int64) (*KnowledgeBase, error) {
+	var kb KnowledgeBase
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&kb).Error
+	return &kb, err
+}
+
+func GetKnowledgeBaseList(eid int64, keyword string, offset, limit int) (int64, []*KnowledgeBase, error) {
+	var count int64
+	var kbs []*KnowledgeBase
+
+	db := DB.Model(&KnowledgeBase{}).Where("eid = ?", eid)
+	if keyword != "" {
+		db = db.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err := db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	err := db.Order("id DESC").Offset(offset).Limit(limit).Find(&kbs).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, kbs, nil
+}
+
+// RefreshKnowledgeBaseCounts 重新统计知识库的文档数和切片数
+func RefreshKnowledgeBaseCounts(knowledgeBaseID int64) error {
+	var documentCount, chunkCount int64
+	if err := DB.Model(&KnowledgeDocument{}).Where("knowledge_base_id = ?", knowledgeBaseID).Count(&documentCount).Error; err != nil {
+		return err
+	}
+	if err := DB.Model(&KnowledgeChunk{}).Where("knowledge_base_id = ?", knowledgeBaseID).Count(&chunkCount).Error; err != nil {
+		return err
+	}
+	return DB.Model(&KnowledgeBase{}).Where("id = ?", knowledgeBaseID).Updates(map[string]interface{}{
+		"document_count": documentCount,
+		"chunk_count":    chunkCount,
+	}).Error
+}
+
+func (doc *KnowledgeDocument) Create() error {
+	return DB.Create(doc).Error
+}
+
+// UpdateStatus 更新文档处理状态
+func (doc *KnowledgeDocument) UpdateStatus(status, errMsg string, chunkCount int) error {
+	doc.Status = status
+	doc.Error = errMsg
+	doc.ChunkCount = chunkCount
+	return DB.Model(doc).Select("status", "error", "chunk_count", "updated_time").Updates(doc).Error
+}
+
+// Delete 删除文档及其切片
+func (doc *KnowledgeDocument) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", doc.ID).Delete(&KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(doc).Error
+	})
+}
+
+func GetKnowledgeDocumentByID(eid, knowledgeBaseID, id int64) (*KnowledgeDocument, error) {
+	var doc KnowledgeDocument
+	err := DB.Where("eid = ? AND knowledge_base_id = ? AND id = ?", eid, knowledgeBaseID, id).First(&doc).Error
+	return &doc, err
+}
+
+func GetKnowledgeDocumentList(eid, knowledgeBaseID int64, keyword string, offset, limit int) (int64, []*KnowledgeDocument, error) {
+	var count int64
+	var docs []*KnowledgeDocument
+
+	db := DB.Model(&KnowledgeDocument{}).Where("eid = ? AND knowledge_base_id = ?", eid, knowledgeBaseID)
+	if keyword != "" {
+		db = db.Where("file_name LIKE ?", "%"+keyword+"%")
+	}
+	if err := db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	err := db.Order("id DESC").Offset(offset).Limit(limit).Find(&docs).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, docs, nil
+}
+
+// ReplaceKnowledgeChunks 用新的切片替换文档原有的切片
+func ReplaceKnowledgeChunks(documentID int64, chunks []*KnowledgeChunk) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(chunks, 100).Error
+	})
+}
+
+// GetKnowledgeChunksByKnowledgeBaseID 加载知识库的全部切片及向量，用于构建检索索引
+func GetKnowledgeChunksByKnowledgeBaseID(knowledgeBaseID int64) ([]*KnowledgeChunk, error) {
+	var chunks []*KnowledgeChunk
+	err := DB.Where("knowledge_base_id = ?", knowledgeBaseID).Order("document_id, seq").Find(&chunks).Error
+	return chunks, err
+}
+
+// UpdateMessageCitations 记录回答引用的知识库片段
+func UpdateMessageCitations(eid, messageID int64, citations interface{}) error {
+	data, err := json.Marshal(citations)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Message{}).Where("eid = ? AND id = ?", eid, messageID).
+		Update("citations", string(data)).Error
+}
+
+// GetKnowledgeDocumentFileNames 知识库中文档 ID 到文件名的映射
+func GetKnowledgeDocumentFileNames(knowledgeBaseID int64) (map[int64]string, error) {
+	var docs []*KnowledgeDocument
+	if err := DB.Select("id", "file_name").Where("knowledge_base_id = ?", knowledgeBaseID).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	fileNames := make(map[int64]string, len(docs))
+	for _, doc := range docs {
+		fileNames[doc.ID] = doc.FileName
+	}
+	return fileNames, nil
+}
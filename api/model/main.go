@@ -110,6 +110,57 @@ func migrateDB() error {
 	if err = DB.AutoMigrate(&SubscriptionRelation{}); err != nil {
 		return err
 	}
+	if err = DB.AutoMigrate(&UserQuota{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&ApiKey{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&RelayAttempt{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&MessageToolCall{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&WorkflowRun{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&ResponseCache{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&ResponseCacheStat{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&KnowledgeBase{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&KnowledgeDocument{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&KnowledgeChunk{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&DocumentExtraction{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&MessageFeedback{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&UsageRollup{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&ExportJob{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&ModelPrice{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&PointsWallet{}, &PointsGrant{}, &PointsLedger{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Budget{}, &BudgetAlert{}); err != nil {
+		return err
+	}
 	if err = DB.AutoMigrate(&AILink{}); err != nil {
 		return err
 	}
@@ -196,6 +196,32 @@ func BatchCreateMemberDepartmentRelations(relations []*MemberDepartmentRelation)
 	return tx.Commit().Error
 }
 
+// GetDepartmentIDsByUserIDs 批量获取用户所在的部门，key 为用户ID
+// 部门关系关联的是成员绑定，通过绑定的 mid 对应到用户
+func GetDepartmentIDsByUserIDs(eid int64, userIDs []int64) (map[int64][]int64, error) {
+	result := make(map[int64][]int64)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		MID int64 `gorm:"column:mid"`
+		DID int64 `gorm:"column:did"`
+	}
+	err := DB.Model(&MemberDepartmentRelation{}).
+		Select("member_bindings.mid, member_department_relations.did").
+		Joins("JOIN member_bindings ON member_bindings.id = member_department_relations.bid AND member_bindings.eid = member_department_relations.eid AND member_bindings.`from` = member_department_relations.`from`").
+		Where("member_department_relations.eid = ? AND member_bindings.mid IN ?", eid, userIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.MID] = append(result[row.MID], row.DID)
+	}
+	return result, nil
+}
+
 func GetUsersByDepartmentIDs(eid int64, dids []int64) ([]int64, error) {
 	if len(dids) == 0 {
 		return []int64{}, nil
@@ -221,8 +247,9 @@ func GetUsersByDepartmentIDs(eid int64, dids []int64) ([]int64, error) {
 		return []int64{}, nil
 	}
 
+	// 部门关系关联的是成员绑定，绑定的 mid 即用户ID
 	var userIDs []int64
-	err = DB.Model(&User{}).Where("eid = ? AND binding_id IN ?", eid, bids).Pluck("user_id", &userIDs).Error
+	err = DB.Model(&MemberBinding{}).Where("eid = ? AND id IN ? AND mid > 0", eid, bids).Distinct().Pluck("mid", &userIDs).Error
 	if err != nil {
 		return nil, err
 	}
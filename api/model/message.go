@@ -3,25 +3,32 @@ package model
 import "encoding/json"
 
 type Message struct {
-	ID                int64  `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
-	Eid               int64  `json:"eid" gorm:"column:eid;not null"`
-	UserID            int64  `json:"user_id" gorm:"column:user_id;not null"`
-	Message           string `json:"message" gorm:"column:message;type:text"`
-	AgentID           int64  `json:"agent_id" gorm:"column:agent_id;not null"`
-	ConversationID    int64  `json:"conversation_id" gorm:"column:conversation_id;not null"`
-	Answer            string `json:"answer" gorm:"column:answer;type:text"`
-	ReasoningContent  string `json:"reasoning_content" gorm:"column:reasoning_content;type:text"`
-	ModelName         string `json:"model_name" gorm:"index;index:index_username_model_name,priority:1;default:''"`
-	Quota             int    `json:"quota" gorm:"default:0"`
-	PromptTokens      int    `json:"prompt_tokens" gorm:"default:0"`
-	CompletionTokens  int    `json:"completion_tokens" gorm:"default:0"`
-	TotalTokens       int    `json:"total_tokens" gorm:"default:0"`
-	ChannelId         int    `json:"channel" gorm:"index"`
-	RequestId         string `json:"request_id" gorm:"default:''"`
-	ElapsedTime       int64  `json:"elapsed_time" gorm:"default:0"`
-	IsStream          bool   `json:"is_stream" gorm:"default:false"`
-	QuotaContent      string `json:"quota_content" gorm:"default:''"`
-	AgentCustomConfig string `json:"agent_custom_config" gorm:"default:''"`
+	ID                int64   `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	Eid               int64   `json:"eid" gorm:"column:eid;not null"`
+	UserID            int64   `json:"user_id" gorm:"column:user_id;not null"`
+	Message           string  `json:"message" gorm:"column:message;type:text"`
+	AgentID           int64   `json:"agent_id" gorm:"column:agent_id;not null"`
+	ConversationID    int64   `json:"conversation_id" gorm:"column:conversation_id;not null"`
+	Answer            string  `json:"answer" gorm:"column:answer;type:text"`
+	ReasoningContent  string  `json:"reasoning_content" gorm:"column:reasoning_content;type:text"`
+	ModelName         string  `json:"model_name" gorm:"index;index:index_username_model_name,priority:1;default:''"`
+	Quota             int     `json:"quota" gorm:"default:0"`
+	Cost              float64 `json:"cost" gorm:"default:0"` // 按计价规则计算的费用，货币单位见企业计价配置
+	PromptTokens      int     `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens  int     `json:"completion_tokens" gorm:"default:0"`
+	TotalTokens       int     `json:"total_tokens" gorm:"default:0"`
+	ChannelId         int     `json:"channel" gorm:"index"`
+	RequestId         string  `json:"request_id" gorm:"default:''"`
+	ElapsedTime       int64   `json:"elapsed_time" gorm:"default:0"`
+	IsStream          bool    `json:"is_stream" gorm:"default:false"`
+	IsCached          bool    `json:"is_cached" gorm:"default:false"`                       // 是否由回答缓存直接返回
+	IsError           bool    `json:"is_error" gorm:"default:false"`                        // 请求是否失败，失败时 Answer 为错误信息
+	RedactionCounts   string  `json:"redaction_counts" gorm:"type:varchar(255);default:''"` // 个人信息脱敏次数，如 {"phone":1}
+	Citations         string  `json:"citations" gorm:"type:text"`                           // 知识库引用片段
+	ChannelMessageID  string  `json:"channel_message_id" gorm:"size:100;default:''"`        // 上游平台的消息ID
+	ForkedFrom        int64   `json:"forked_from" gorm:"default:0"`                         // 复制会话时的源消息ID，复制的消息不计入用量
+	QuotaContent      string  `json:"quota_content" gorm:"default:''"`
+	AgentCustomConfig string  `json:"agent_custom_config" gorm:"default:''"`
 	BaseModel
 }
 
@@ -91,6 +98,16 @@ func GetMessageByID(eid int64, id int64) (*Message, error) {
 	return &message, nil
 }
 
+// GetMessagesByIDs 批量获取消息
+func GetMessagesByIDs(eid int64, ids []int64) ([]*Message, error) {
+	messages := make([]*Message, 0, len(ids))
+	if len(ids) == 0 {
+		return messages, nil
+	}
+	err := DB.Where("eid = ? AND id IN ?", eid, ids).Find(&messages).Error
+	return messages, err
+}
+
 // GetMessagesByUserID retrieves all messages for a user
 func GetMessagesByUserID(eid int64, userID int64) ([]*Message, error) {
 	var messages []*Message
@@ -0,0 +1,193 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 反馈评价
+const (
+	FeedbackRatingUp   = "up"
+	FeedbackRatingDown = "down"
+)
+
+// 反馈原因分类
+const (
+	FeedbackReasonInaccurate = "inaccurate" // 内容不准确
+	FeedbackReasonIrrelevant = "irrelevant" // 答非所问
+	FeedbackReasonIncomplete = "incomplete" // 回答不完整
+	FeedbackReasonUnsafe     = "unsafe"     // 有害或不当内容
+	FeedbackReasonSlow       = "slow"       // 响应太慢
+	FeedbackReasonHelpful    = "helpful"    // 有帮助
+	FeedbackReasonOther      = "other"      // 其他
+)
+
+// 反馈统计的分组维度
+const (
+	FeedbackGroupByAgent   = "agent"
+	FeedbackGroupByModel   = "model"
+	FeedbackGroupByChannel = "channel"
+	FeedbackGroupByDay     = "day"
+)
+
+// MessageFeedback 用户对回答的评价，每条消息只保留一条，重复提交时覆盖
+type MessageFeedback struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64  `json:"eid" gorm:"not null;index"`
+	MessageID      int64  `json:"message_id" gorm:"not null;uniqueIndex"`
+	UserID         int64  `json:"user_id" gorm:"not null;index"`
+	AgentID        int64  `json:"agent_id" gorm:"not null;index"`
+	ConversationID int64  `json:"conversation_id" gorm:"not null;default:0"`
+	ChannelID      int64  `json:"channel_id" gorm:"not null;default:0;index"`
+	ModelName      string `json:"model_name" gorm:"size:100;not null;default:''"`
+	Rating         string `json:"rating" gorm:"size:10;not null;index"`
+	Reason         string `json:"reason" gorm:"size:50;not null;default:''"`
+	Content        string `json:"content" gorm:"type:text"`
+	Synced         bool   `json:"synced" gorm:"not null;default:false"` // 是否已同步到上游平台
+	BaseModel
+}
+
+func (MessageFeedback) TableName() string {
+	return "message_feedbacks"
+}
+
+// FeedbackStat 按维度汇总的反馈统计
+type FeedbackStat struct {
+	GroupKey string `json:"group_key"`
+	Up       int64  `json:"up"`
+	Down     int64  `json:"down"`
+}
+
+// FeedbackQuery 反馈列表和统计的筛选条件
+type FeedbackQuery struct {
+	AgentID   int64
+	ChannelID int64
+	ModelName string
+	Rating    string
+	StartTime int64
+	EndTime   int64
+}
+
+// ValidFeedbackRating 评价是否合法
+func ValidFeedbackRating(rating string) bool {
+	return rating == FeedbackRatingUp || rating == FeedbackRatingDown
+}
+
+// ValidFeedbackReason 原因分类是否合法，允许为空
+func ValidFeedbackReason(reason string) bool {
+	switch reason {
+	case "", FeedbackReasonInaccurate, FeedbackReasonIrrelevant, FeedbackReasonIncomplete,
+		FeedbackReasonUnsafe, FeedbackReasonSlow, FeedbackReasonHelpful, FeedbackReasonOther:
+		return true
+	}
+	return false
+}
+
+// SaveMessageFeedback 保存反馈，同一消息已有反馈时覆盖
+func SaveMessageFeedback(feedback *MessageFeedback) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "reason", "content", "synced", "updated_time"}),
+	}).Create(feedback).Error
+}
+
+// GetMessageFeedback 获取消息的反馈
+func GetMessageFeedback(eid, messageID int64) (*MessageFeedback, error) {
+	var feedback MessageFeedback
+	if err := DB.Where("eid = ? AND message_id = ?", eid, messageID).First(&feedback).Error; err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// GetMessageFeedbackMap 批量获取消息的反馈，key 为消息ID
+func GetMessageFeedbackMap(eid int64, messageIDs []int64) (map[int64]*MessageFeedback, error) {
+	result := make(map[int64]*MessageFeedback)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	var feedbacks []*MessageFeedback
+	if err := DB.Where("eid = ? AND message_id IN ?", eid, messageIDs).Find(&feedbacks).Error; err != nil {
+		return nil, err
+	}
+	for _, feedback := range feedbacks {
+		result[feedback.MessageID] = feedback
+	}
+	return result, nil
+}
+
+// DeleteMessageFeedback 撤销反馈
+func DeleteMessageFeedback(eid, messageID int64) error {
+	return DB.Where("eid = ? AND message_id = ?", eid, messageID).Delete(&MessageFeedback{}).Error
+}
+
+// MarkMessageFeedbackSynced 标记反馈已同步到上游平台
+func MarkMessageFeedbackSynced(eid, messageID int64) error {
+	return DB.Model(&MessageFeedback{}).Where("eid = ? AND message_id = ?", eid, messageID).Update("synced", true).Error
+}
+
+func (q FeedbackQuery) apply(eid int64) *gorm.DB {
+	query := DB.Model(&MessageFeedback{}).Where("eid = ?", eid)
+	if q.AgentID > 0 {
+		query = query.Where("agent_id = ?", q.AgentID)
+	}
+	if q.ChannelID > 0 {
+		query = query.Where("channel_id = ?", q.ChannelID)
+	}
+	if q.ModelName != "" {
+		query = query.Where("model_name = ?", q.ModelName)
+	}
+	if q.Rating != "" {
+		query = query.Where("rating = ?", q.Rating)
+	}
+	if q.StartTime > 0 {
+		query = query.Where("created_time >= ?", q.StartTime)
+	}
+	if q.EndTime > 0 {
+		query = query.Where("created_time <= ?", q.EndTime)
+	}
+	return query
+}
+
+// GetMessageFeedbackList 按条件分页获取反馈，最新的在前
+func GetMessageFeedbackList(eid int64, q FeedbackQuery, offset, limit int) ([]*MessageFeedback, int64, error) {
+	query := q.apply(eid)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	feedbacks := make([]*MessageFeedback, 0)
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&feedbacks).Error; err != nil {
+		return nil, 0, err
+	}
+	return feedbacks, total, nil
+}
+
+// GetMessageFeedbackStats 按维度汇总点赞和点踩数量，按天分组时以 UTC 零点的毫秒时间戳作为分组键
+func GetMessageFeedbackStats(eid int64, q FeedbackQuery, groupBy string) ([]*FeedbackStat, error) {
+	var column string
+	switch groupBy {
+	case FeedbackGroupByAgent:
+		column = "agent_id"
+	case FeedbackGroupByModel:
+		column = "model_name"
+	case FeedbackGroupByChannel:
+		column = "channel_id"
+	case FeedbackGroupByDay:
+		column = "(created_time - created_time % 86400000)"
+	default:
+		return nil, errors.New("invalid group_by")
+	}
+
+	stats := make([]*FeedbackStat, 0)
+	err := q.apply(eid).
+		Select(column+" AS group_key, "+
+			"SUM(CASE WHEN rating = ? THEN 1 ELSE 0 END) AS up, "+
+			"SUM(CASE WHEN rating = ? THEN 1 ELSE 0 END) AS down", FeedbackRatingUp, FeedbackRatingDown).
+		Group(column).
+		Order("group_key").
+		Scan(&stats).Error
+	return stats, err
+}
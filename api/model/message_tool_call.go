@@ -0,0 +1,37 @@
+package model
+
+// MessageToolCall 记录回答消息过程中在服务端执行的一次工具调用
+type MessageToolCall struct {
+	ID           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid          int64  `json:"eid" gorm:"not null;index"`
+	MessageID    int64  `json:"message_id" gorm:"not null;default:0;index"`
+	AgentID      int64  `json:"agent_id" gorm:"not null;default:0"`
+	Round        int    `json:"round" gorm:"not null;default:1;comment:第几轮工具调用，从1开始"`
+	ToolCallID   string `json:"tool_call_id" gorm:"size:100;not null;default:''"`
+	ToolType     string `json:"tool_type" gorm:"size:20;not null;default:''"`
+	ToolName     string `json:"tool_name" gorm:"size:64;not null;default:''"`
+	Arguments    string `json:"arguments" gorm:"type:text"`
+	Result       string `json:"result" gorm:"type:text"`
+	Success      bool   `json:"success" gorm:"not null;default:false"`
+	ErrorMessage string `json:"error_message" gorm:"type:text"`
+	ElapsedTime  int64  `json:"elapsed_time" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (MessageToolCall) TableName() string {
+	return "message_tool_calls"
+}
+
+// CreateMessageToolCall 保存一次工具调用
+func CreateMessageToolCall(call *MessageToolCall) error {
+	return DB.Create(call).Error
+}
+
+// GetMessageToolCalls 获取消息的全部工具调用，按执行顺序排列
+func GetMessageToolCalls(eid, messageID int64) ([]*MessageToolCall, error) {
+	var calls []*MessageToolCall
+	err := DB.Where("eid = ? AND message_id = ?", eid, messageID).
+		Order("round ASC").Order("id ASC").
+		Find(&calls).Error
+	return calls, err
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ModelPrice 企业的模型价格，价格为每百万 token 的金额，货币单位见企业计价配置。
+// ChannelID 为 0 时是企业默认价格，大于 0 时覆盖该渠道的价格
+type ModelPrice struct {
+	ID               int64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid              int64    `json:"eid" gorm:"not null;uniqueIndex:idx_model_price,priority:1"`
+	ModelName        string   `json:"model_name" gorm:"size:100;not null;uniqueIndex:idx_model_price,priority:2"`
+	ChannelID        int64    `json:"channel_id" gorm:"not null;default:0;uniqueIndex:idx_model_price,priority:3"`
+	InputPrice       float64  `json:"input_price" gorm:"not null;default:0"`
+	OutputPrice      float64  `json:"output_price" gorm:"not null;default:0"`
+	CachedInputPrice *float64 `json:"cached_input_price" gorm:"default:null"` // 命中缓存的输入价格，为空时按输入价格计费
+	BaseModel
+}
+
+func (ModelPrice) TableName() string {
+	return "model_prices"
+}
+
+// Validate 校验价格
+func (p *ModelPrice) Validate() error {
+	if p.ModelName == "" {
+		return errors.New("model_name is required")
+	}
+	if p.ChannelID < 0 {
+		return errors.New("invalid channel_id")
+	}
+	if p.InputPrice < 0 || p.OutputPrice < 0 || (p.CachedInputPrice != nil && *p.CachedInputPrice < 0) {
+		return errors.New("prices must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// GetModelPrices 获取企业的价格表，可按模型和渠道筛选，channelID 小于 0 时不按渠道筛选
+func GetModelPrices(eid int64, modelName string, channelID int64) ([]*ModelPrice, error) {
+	query := DB.Where("eid = ?", eid)
+	if modelName != "" {
+		query = query.Where("model_name = ?", modelName)
+	}
+	if channelID >= 0 {
+		query = query.Where("channel_id = ?", channelID)
+	}
+	prices := make([]*ModelPrice, 0)
+	err := query.Order("model_name ASC, channel_id ASC").Find(&prices).Error
+	return prices, err
+}
+
+// GetModelPrice 获取单条价格
+func GetModelPrice(eid, id int64) (*ModelPrice, error) {
+	var price ModelPrice
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&price).Error
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// SaveModelPrice 保存价格，同一企业、模型和渠道已有价格时覆盖
+func SaveModelPrice(price *ModelPrice) error {
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "eid"}, {Name: "model_name"}, {Name: "channel_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"input_price", "output_price", "cached_input_price", "updated_time"}),
+	}).Create(price).Error
+	if err != nil {
+		return err
+	}
+	// 覆盖已有价格时回填记录ID
+	return DB.Where("eid = ? AND model_name = ? AND channel_id = ?", price.Eid, price.ModelName, price.ChannelID).
+		First(price).Error
+}
+
+// UpdateModelPrice 更新价格
+func UpdateModelPrice(price *ModelPrice) error {
+	return DB.Model(price).
+		Select("model_name", "channel_id", "input_price", "output_price", "cached_input_price", "updated_time").
+		Updates(price).Error
+}
+
+// DeleteModelPrice 删除价格
+func DeleteModelPrice(eid, id int64) error {
+	result := DB.Where("eid = ? AND id = ?", eid, id).Delete(&ModelPrice{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
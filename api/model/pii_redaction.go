@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+)
+
+// 内置的个人信息识别器
+const (
+	PIIDetectorPhone    = "phone"
+	PIIDetectorEmail    = "email"
+	PIIDetectorIDCard   = "id_card"
+	PIIDetectorBankCard = "bank_card"
+)
+
+// PIIBuiltinDetectors 未指定识别器时启用全部内置识别器，顺序即匹配优先级
+var PIIBuiltinDetectors = []string{
+	PIIDetectorIDCard,
+	PIIDetectorBankCard,
+	PIIDetectorPhone,
+	PIIDetectorEmail,
+}
+
+// PIICustomDetector 自定义正则识别器
+type PIICustomDetector struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// PIIRedactionSettings 智能体个人信息脱敏配置，存储于 Agent.Settings 的 pii_redaction 字段
+// {"pii_redaction":{"enabled":true,"detectors":["phone","email","id_card","bank_card"],"custom":[{"name":"employee_no","pattern":"EMP\\d{6}"}]}}
+type PIIRedactionSettings struct {
+	Enabled   bool                `json:"enabled"`
+	Detectors []string            `json:"detectors"`
+	Custom    []PIICustomDetector `json:"custom"`
+}
+
+// GetPIIRedactionSettings 解析智能体的脱敏配置，未配置或格式错误时视为关闭
+func (agent *Agent) GetPIIRedactionSettings() PIIRedactionSettings {
+	var settings struct {
+		PIIRedaction PIIRedactionSettings `json:"pii_redaction"`
+	}
+	if agent.Settings == "" || json.Unmarshal([]byte(agent.Settings), &settings) != nil {
+		return PIIRedactionSettings{}
+	}
+	if len(settings.PIIRedaction.Detectors) == 0 {
+		settings.PIIRedaction.Detectors = PIIBuiltinDetectors
+	}
+	return settings.PIIRedaction
+}
+
+// UpdateMessageRedactionCounts 记录消息发送给模型前各类个人信息的脱敏次数
+func UpdateMessageRedactionCounts(eid, messageID int64, counts map[string]int) error {
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Message{}).Where("eid = ? AND id = ?", eid, messageID).
+		Update("redaction_counts", string(data)).Error
+}
@@ -0,0 +1,325 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 积分流水类型
+const (
+	PointsTypeGrant  = "grant"  // 发放
+	PointsTypeDeduct = "deduct" // 消费扣减
+	PointsTypeRefund = "refund" // 退还
+	PointsTypeAdjust = "adjust" // 管理员调整
+	PointsTypeExpire = "expire" // 过期
+)
+
+// 积分流水来源
+const (
+	PointsSourceSubscription = "subscription"
+	PointsSourceOrder        = "order"
+	PointsSourceChat         = "chat"
+	PointsSourceWorkflow     = "workflow"
+	PointsSourceRerank       = "rerank"
+	PointsSourceEmbedding    = "embedding"
+	PointsSourceAdmin        = "admin"
+	PointsSourceLedger       = "ledger" // 退还某条扣减流水，SourceID 为流水ID
+)
+
+// ErrPointsGrantExists 相同发放标识的积分已发放过
+var ErrPointsGrantExists = errors.New("points grant already exists")
+
+// PointsWallet 用户积分钱包，余额始终等于未过期发放批次的剩余积分之和
+type PointsWallet struct {
+	ID      int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid     int64 `json:"eid" gorm:"not null;uniqueIndex:idx_points_wallet_user,priority:1"`
+	UserID  int64 `json:"user_id" gorm:"not null;uniqueIndex:idx_points_wallet_user,priority:2"`
+	Balance int64 `json:"balance" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (PointsWallet) TableName() string {
+	return "points_wallets"
+}
+
+// PointsGrant 积分发放批次，扣减时优先使用最早过期的批次
+type PointsGrant struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;uniqueIndex:idx_points_grant_key,priority:1"`
+	UserID      int64  `json:"user_id" gorm:"not null;uniqueIndex:idx_points_grant_key,priority:2;index:idx_points_grant_user"`
+	GrantKey    string `json:"grant_key" gorm:"size:100;not null;uniqueIndex:idx_points_grant_key,priority:3"` // 发放标识，用于防止重复发放
+	Amount      int64  `json:"amount" gorm:"not null;default:0"`
+	Remaining   int64  `json:"remaining" gorm:"not null;default:0"`
+	ExpiredTime int64  `json:"expired_time" gorm:"not null;default:0;index"` // 0 表示永不过期
+	BaseModel
+}
+
+func (PointsGrant) TableName() string {
+	return "points_grants"
+}
+
+// PointsLedger 积分流水，只追加不修改。Amount 增加为正、减少为负，Balance 为变动后的余额
+type PointsLedger struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;index:idx_points_ledger_user,priority:1"`
+	UserID      int64  `json:"user_id" gorm:"not null;index:idx_points_ledger_user,priority:2"`
+	Type        string `json:"type" gorm:"size:20;not null"`
+	Amount      int64  `json:"amount" gorm:"not null"`
+	Balance     int64  `json:"balance" gorm:"not null"`
+	SourceType  string `json:"source_type" gorm:"size:20;not null;default:''"`
+	SourceID    string `json:"source_id" gorm:"size:64;not null;default:''"`
+	GrantID     int64  `json:"grant_id" gorm:"not null;default:0"`     // 发放、退还、过期流水对应的批次
+	Quota       int64  `json:"quota" gorm:"not null;default:0"`        // 扣减流水对应消耗的配额
+	ExpiredTime int64  `json:"expired_time" gorm:"not null;default:0"` // 发放的积分过期时间
+	Remark      string `json:"remark" gorm:"size:255;not null;default:''"`
+	OperatorID  int64  `json:"operator_id" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (PointsLedger) TableName() string {
+	return "points_ledgers"
+}
+
+// GetPointsWallet 获取用户积分钱包，不存在时返回余额为 0 的钱包
+func GetPointsWallet(eid, userID int64) (*PointsWallet, error) {
+	wallet := PointsWallet{Eid: eid, UserID: userID}
+	err := DB.Where("eid = ? AND user_id = ?", eid, userID).First(&wallet).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// GetAvailablePoints 获取用户可用积分，即未过期批次的剩余积分之和
+// 过期批次由定时任务清零，清零前钱包余额仍包含这部分积分
+func GetAvailablePoints(eid, userID int64) (int64, error) {
+	var available int64
+	err := DB.Model(&PointsGrant{}).
+		Where("eid = ? AND user_id = ? AND remaining > 0 AND (expired_time = 0 OR expired_time > ?)", eid, userID, time.Now().UTC().UnixMilli()).
+		Select("COALESCE(SUM(remaining), 0)").Scan(&available).Error
+	return available, err
+}
+
+// lockPointsWallet 在事务中锁定用户钱包并返回当前余额，同一用户的积分变动因此串行执行
+func lockPointsWallet(tx *gorm.DB, eid, userID int64) (*PointsWallet, error) {
+	wallet := PointsWallet{Eid: eid, UserID: userID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&wallet).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&PointsWallet{}).Where("eid = ? AND user_id = ?", eid, userID).
+		Update("updated_time", time.Now().UTC().UnixMilli()).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("eid = ? AND user_id = ?", eid, userID).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func updatePointsBalance(tx *gorm.DB, wallet *PointsWallet, delta int64) error {
+	wallet.Balance += delta
+	return tx.Model(&PointsWallet{}).Where("id = ?", wallet.ID).
+		Update("balance", gorm.Expr("balance + ?", delta)).Error
+}
+
+// GrantPoints 发放一批积分并写入流水，grantKey 已存在时返回 ErrPointsGrantExists
+func GrantPoints(grant *PointsGrant, ledger *PointsLedger) error {
+	if grant.Amount <= 0 {
+		return errors.New("points amount must be greater than 0")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		wallet, err := lockPointsWallet(tx, grant.Eid, grant.UserID)
+		if err != nil {
+			return err
+		}
+		grant.Remaining = grant.Amount
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(grant)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPointsGrantExists
+		}
+		if err := updatePointsBalance(tx, wallet, grant.Amount); err != nil {
+			return err
+		}
+
+		ledger.Eid = grant.Eid
+		ledger.UserID = grant.UserID
+		ledger.Amount = grant.Amount
+		ledger.Balance = wallet.Balance
+		ledger.GrantID = grant.ID
+		ledger.ExpiredTime = grant.ExpiredTime
+		return tx.Create(ledger).Error
+	})
+}
+
+// DeductPoints 扣减积分并写入流水，按过期时间从早到晚使用发放批次。
+// 余额不足时只扣减现有余额，返回实际扣减的积分；余额为 0 时不写流水
+func DeductPoints(eid, userID, amount int64, ledger *PointsLedger) (int64, error) {
+	if amount <= 0 {
+		return 0, nil
+	}
+	var deducted int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		wallet, err := lockPointsWallet(tx, eid, userID)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC().UnixMilli()
+		grants := make([]*PointsGrant, 0)
+		if err := tx.Where("eid = ? AND user_id = ? AND remaining > 0 AND (expired_time = 0 OR expired_time > ?)", eid, userID, now).
+			Order("CASE WHEN expired_time = 0 THEN 1 ELSE 0 END, expired_time ASC, id ASC").
+			Find(&grants).Error; err != nil {
+			return err
+		}
+
+		remaining := min(amount, wallet.Balance)
+		for _, grant := range grants {
+			if remaining <= 0 {
+				break
+			}
+			use := min(remaining, grant.Remaining)
+			if err := tx.Model(&PointsGrant{}).Where("id = ?", grant.ID).
+				Updates(map[string]interface{}{
+					"remaining":    gorm.Expr("remaining - ?", use),
+					"updated_time": now,
+				}).Error; err != nil {
+				return err
+			}
+			remaining -= use
+			deducted += use
+		}
+		if deducted == 0 {
+			return nil
+		}
+		if err := updatePointsBalance(tx, wallet, -deducted); err != nil {
+			return err
+		}
+
+		ledger.Eid = eid
+		ledger.UserID = userID
+		ledger.Amount = -deducted
+		ledger.Balance = wallet.Balance
+		return tx.Create(ledger).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deducted, nil
+}
+
+// ExpirePointsGrant 将已过期批次的剩余积分清零并写入过期流水
+func ExpirePointsGrant(grant *PointsGrant) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		wallet, err := lockPointsWallet(tx, grant.Eid, grant.UserID)
+		if err != nil {
+			return err
+		}
+		// 锁定钱包后重新读取，避免与并发扣减重复计算
+		var current PointsGrant
+		if err := tx.Where("id = ?", grant.ID).First(&current).Error; err != nil {
+			return err
+		}
+		if current.Remaining <= 0 {
+			return nil
+		}
+		if err := tx.Model(&PointsGrant{}).Where("id = ?", current.ID).
+			Updates(map[string]interface{}{
+				"remaining":    0,
+				"updated_time": time.Now().UTC().UnixMilli(),
+			}).Error; err != nil {
+			return err
+		}
+		expired := min(current.Remaining, wallet.Balance)
+		if err := updatePointsBalance(tx, wallet, -expired); err != nil {
+			return err
+		}
+		return tx.Create(&PointsLedger{
+			Eid:         current.Eid,
+			UserID:      current.UserID,
+			Type:        PointsTypeExpire,
+			Amount:      -expired,
+			Balance:     wallet.Balance,
+			GrantID:     current.ID,
+			ExpiredTime: current.ExpiredTime,
+		}).Error
+	})
+}
+
+// GetExpiredPointsGrants 获取已过期但仍有剩余积分的批次
+func GetExpiredPointsGrants(now int64, limit int) ([]*PointsGrant, error) {
+	grants := make([]*PointsGrant, 0)
+	err := DB.Where("remaining > 0 AND expired_time > 0 AND expired_time <= ?", now).
+		Order("id ASC").Limit(limit).Find(&grants).Error
+	return grants, err
+}
+
+// GetPointsLedger 获取企业下的单条积分流水
+func GetPointsLedger(eid, id int64) (*PointsLedger, error) {
+	var ledger PointsLedger
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&ledger).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ledger, nil
+}
+
+// GetPointsLedgers 分页获取积分流水，最新的在前，userID 为 0 时返回企业全部用户的流水
+func GetPointsLedgers(eid, userID int64, ledgerType string, offset, limit int) ([]*PointsLedger, int64, error) {
+	var count int64
+	query := DB.Model(&PointsLedger{}).Where("eid = ?", eid)
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ledgerType != "" {
+		query = query.Where("type = ?", ledgerType)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	ledgers := make([]*PointsLedger, 0)
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&ledgers).Error
+	return ledgers, count, err
+}
+
+// GetPointsSubscriptionRelations 获取积分类型的订阅周期配置
+func GetPointsSubscriptionRelations(settingID int64) ([]SubscriptionRelation, error) {
+	relations := make([]SubscriptionRelation, 0)
+	err := DB.Where("setting_id = ? AND type = ? AND amount > 0", settingID, SubscriptionTypePoints).
+		Find(&relations).Error
+	return relations, err
+}
+
+// HasPointsSubscriptionRelation 订阅是否包含积分类型的配置
+func HasPointsSubscriptionRelation(settingID int64) (bool, error) {
+	var count int64
+	err := DB.Model(&SubscriptionRelation{}).
+		Where("setting_id = ? AND type = ?", settingID, SubscriptionTypePoints).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetPointsSubscriptionSettings 获取包含积分配置的订阅
+func GetPointsSubscriptionSettings() ([]*SubscriptionSetting, error) {
+	settings := make([]*SubscriptionSetting, 0)
+	err := DB.Where("setting_id IN (?)",
+		DB.Model(&SubscriptionRelation{}).Select("setting_id").
+			Where("type = ? AND amount > 0", SubscriptionTypePoints)).
+		Find(&settings).Error
+	return settings, err
+}
+
+// GetPointsGroupUserIDs 按ID顺序分页获取订阅分组下未过期的用户，默认订阅不判断过期时间
+func GetPointsGroupUserIDs(groupID int64, isDefault bool, now int64, afterID int64, limit int) ([]int64, error) {
+	query := DB.Model(&User{}).Where("group_id = ? AND status <> ? AND user_id > ?", groupID, UserStatusDisabled, afterID)
+	if !isDefault {
+		query = query.Where("expired_time = 0 OR expired_time > ?", now)
+	}
+	userIDs := make([]int64, 0)
+	err := query.Order("user_id ASC").Limit(limit).Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+)
+
+// GetPromptVariables 解析智能体的自定义提示词变量，存储于 Agent.Settings 的 prompt_variables 字段，
+// 在提示词中以 {{ var.<name> }} 引用
+// {"prompt_variables":{"company":"53AI","city":"深圳"}}
+func (agent *Agent) GetPromptVariables() map[string]string {
+	var settings struct {
+		PromptVariables map[string]string `json:"prompt_variables"`
+	}
+	if agent.Settings == "" || json.Unmarshal([]byte(agent.Settings), &settings) != nil {
+		return nil
+	}
+	return settings.PromptVariables
+}
+
+// GetUserDepartmentNames 获取用户所在的部门名称，部门关系通过成员绑定对应到用户
+func GetUserDepartmentNames(eid, userID int64) ([]string, error) {
+	userDids, err := GetDepartmentIDsByUserIDs(eid, []int64{userID})
+	if err != nil {
+		return nil, err
+	}
+	dids := userDids[userID]
+	if len(dids) == 0 {
+		return []string{}, nil
+	}
+	departments, err := BatchGetDepartmentsByIDs(eid, dids)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(departments))
+	for _, department := range departments {
+		names = append(names, department.Name)
+	}
+	return names, nil
+}
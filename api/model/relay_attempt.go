@@ -0,0 +1,39 @@
+package model
+
+// RelayAttempt 记录一次请求在各渠道上的转发尝试，便于排查最终由哪个渠道完成响应
+type RelayAttempt struct {
+	ID           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid          int64  `json:"eid" gorm:"not null;index"`
+	MessageID    int64  `json:"message_id" gorm:"not null;default:0;index"`
+	RequestId    string `json:"request_id" gorm:"size:64;not null;default:''"`
+	AgentID      int64  `json:"agent_id" gorm:"not null;default:0"`
+	ChannelID    int64  `json:"channel_id" gorm:"not null;default:0"`
+	ChannelName  string `json:"channel_name" gorm:"size:255;not null;default:''"`
+	Priority     int64  `json:"priority" gorm:"not null;default:0"`
+	Attempt      int    `json:"attempt" gorm:"not null;default:1;comment:第几次尝试，从1开始"`
+	Success      bool   `json:"success" gorm:"not null;default:false"`
+	Retried      bool   `json:"retried" gorm:"not null;default:false;comment:失败后是否切换到下一个渠道"`
+	StatusCode   int    `json:"status_code" gorm:"not null;default:0"`
+	ErrorType    string `json:"error_type" gorm:"size:100;not null;default:''"`
+	ErrorMessage string `json:"error_message" gorm:"type:text"`
+	ElapsedTime  int64  `json:"elapsed_time" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (RelayAttempt) TableName() string {
+	return "relay_attempts"
+}
+
+// CreateRelayAttempt 保存一次转发尝试
+func CreateRelayAttempt(attempt *RelayAttempt) error {
+	return DB.Create(attempt).Error
+}
+
+// GetRelayAttemptsByMessageID 获取消息的全部转发尝试，按尝试顺序排列
+func GetRelayAttemptsByMessageID(eid, messageID int64) ([]*RelayAttempt, error) {
+	var attempts []*RelayAttempt
+	err := DB.Where("eid = ? AND message_id = ?", eid, messageID).
+		Order("attempt ASC").Order("id ASC").
+		Find(&attempts).Error
+	return attempts, err
+}
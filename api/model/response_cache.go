@@ -0,0 +1,147 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 默认缓存有效期（秒）
+const DefaultResponseCacheTTL = 3600
+
+// ResponseCacheSettings 智能体回答缓存配置，存储于 Agent.Settings 的 response_cache 字段
+// {"response_cache":{"enabled":true,"ttl":3600}}
+type ResponseCacheSettings struct {
+	Enabled bool  `json:"enabled"`
+	TTL     int64 `json:"ttl"` // 秒，0 表示使用默认值
+}
+
+// ResponseCache 回答缓存条目（未启用 Redis 时使用）
+type ResponseCache struct {
+	ID               int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid              int64  `json:"eid" gorm:"not null;index"`
+	AgentID          int64  `json:"agent_id" gorm:"not null;index"`
+	CacheKey         string `json:"cache_key" gorm:"size:64;not null;uniqueIndex"`
+	Answer           string `json:"answer" gorm:"type:text"`
+	ReasoningContent string `json:"reasoning_content" gorm:"type:text"`
+	ModelName        string `json:"model_name" gorm:"size:100;not null;default:''"`
+	ExpiredTime      int64  `json:"expired_time" gorm:"not null;index"`
+	BaseModel
+}
+
+func (ResponseCache) TableName() string {
+	return "response_caches"
+}
+
+// ResponseCacheStat 智能体缓存命中统计
+type ResponseCacheStat struct {
+	AgentID int64 `json:"agent_id" gorm:"primaryKey;autoIncrement:false"`
+	Eid     int64 `json:"eid" gorm:"not null;index"`
+	Hits    int64 `json:"hits" gorm:"not null;default:0"`
+	Misses  int64 `json:"misses" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (ResponseCacheStat) TableName() string {
+	return "response_cache_stats"
+}
+
+// GetResponseCacheSettings 解析智能体的回答缓存配置，未配置或格式错误时视为关闭
+func (agent *Agent) GetResponseCacheSettings() ResponseCacheSettings {
+	var settings struct {
+		ResponseCache ResponseCacheSettings `json:"response_cache"`
+	}
+	if agent.Settings == "" || json.Unmarshal([]byte(agent.Settings), &settings) != nil {
+		return ResponseCacheSettings{}
+	}
+	if settings.ResponseCache.TTL <= 0 {
+		settings.ResponseCache.TTL = DefaultResponseCacheTTL
+	}
+	return settings.ResponseCache
+}
+
+// GetPromptVersion 智能体提示词版本，提示词、模型或模型参数变化后版本随之变化，旧缓存自然失效
+func (agent *Agent) GetPromptVersion() string {
+	sum := sha256.Sum256([]byte(agent.Model + "\x00" + agent.Prompt + "\x00" + agent.Configs))
+	return hex.EncodeToString(sum[:8])
+}
+
+// GetResponseCache 获取未过期的缓存条目
+func GetResponseCache(cacheKey string) (*ResponseCache, error) {
+	var entry ResponseCache
+	err := DB.Where("cache_key = ? AND expired_time > ?", cacheKey, time.Now().UTC().UnixMilli()).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SaveResponseCache 写入缓存条目，相同 key 覆盖
+func SaveResponseCache(entry *ResponseCache) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cache_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"answer", "reasoning_content", "model_name", "expired_time", "updated_time"}),
+	}).Create(entry).Error
+}
+
+// DeleteResponseCacheByAgent 清除智能体的全部缓存条目
+func DeleteResponseCacheByAgent(eid, agentID int64) (int64, error) {
+	result := DB.Where("eid = ? AND agent_id = ?", eid, agentID).Delete(&ResponseCache{})
+	return result.RowsAffected, result.Error
+}
+
+// CountResponseCacheByAgent 统计智能体未过期的缓存条目数
+func CountResponseCacheByAgent(eid, agentID int64) (int64, error) {
+	var count int64
+	err := DB.Model(&ResponseCache{}).
+		Where("eid = ? AND agent_id = ? AND expired_time > ?", eid, agentID, time.Now().UTC().UnixMilli()).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteExpiredResponseCache 清理已过期的缓存条目
+func DeleteExpiredResponseCache() (int64, error) {
+	result := DB.Where("expired_time <= ?", time.Now().UTC().UnixMilli()).Delete(&ResponseCache{})
+	return result.RowsAffected, result.Error
+}
+
+// IncreaseResponseCacheStat 累加命中或未命中次数
+func IncreaseResponseCacheStat(eid, agentID int64, hit bool) error {
+	column := "misses"
+	if hit {
+		column = "hits"
+	}
+	now := time.Now().UTC().UnixMilli()
+	stat := &ResponseCacheStat{AgentID: agentID, Eid: eid}
+	if hit {
+		stat.Hits = 1
+	} else {
+		stat.Misses = 1
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "agent_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			column:         gorm.Expr("response_cache_stats." + column + " + 1"),
+			"updated_time": now,
+		}),
+	}).Create(stat).Error
+}
+
+// GetResponseCacheStat 获取智能体缓存命中统计
+func GetResponseCacheStat(eid, agentID int64) (*ResponseCacheStat, error) {
+	stat := &ResponseCacheStat{AgentID: agentID, Eid: eid}
+	err := DB.Where("eid = ? AND agent_id = ?", eid, agentID).First(stat).Error
+	if err == gorm.ErrRecordNotFound {
+		return stat, nil
+	}
+	return stat, err
+}
+
+// ResetResponseCacheStat 清零智能体缓存命中统计
+func ResetResponseCacheStat(eid, agentID int64) error {
+	return DB.Where("eid = ? AND agent_id = ?", eid, agentID).Delete(&ResponseCacheStat{}).Error
+}
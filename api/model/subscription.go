@@ -33,6 +33,13 @@ type SubscriptionSetting struct {
 	// @Description Whether this subscription is the default subscription
 	// @Example true
 	IsDefault bool `json:"is_default" gorm:"default:false;column:is_default;comment:'Whether this is the default subscription'"`
+	// @Description Quota allowance per period for each user of this subscription, 0 means unlimited
+	// @Example 500000
+	QuotaLimit int64 `json:"quota_limit" gorm:"default:0;column:quota_limit;comment:'Quota allowance per period, 0 means unlimited'"`
+	// @Description Period the quota allowance is reset by
+	// @Enum day,week,month,quarter,year
+	// @Example month
+	QuotaPeriod string `json:"quota_period" gorm:"type:varchar(10);default:'month';column:quota_period;comment:'Quota period: day/week/month/quarter/year'"`
 	// @Description List of subscription relations containing pricing and duration details
 	Relations []*SubscriptionRelation `json:"relations" gorm:"-"`
 	BaseModel
@@ -91,8 +98,10 @@ func CreateSubscriptionSetting(setting *SubscriptionSetting) error {
 // Update subscription setting
 func UpdateSubscriptionSetting(setting *SubscriptionSetting) error {
 	return DB.Model(setting).Updates(map[string]interface{}{
-		"logo_url":   setting.LogoUrl,
-		"ai_enabled": setting.AiEnabled,
+		"logo_url":     setting.LogoUrl,
+		"ai_enabled":   setting.AiEnabled,
+		"quota_limit":  setting.QuotaLimit,
+		"quota_period": setting.QuotaPeriod,
 	}).Error
 }
 
@@ -109,6 +118,25 @@ func GetSubscriptionSettingById(settingId int64) (*SubscriptionSetting, error) {
 	return &setting, err
 }
 
+// GetSubscriptionSettingByGroupId gets the subscription setting bound to a user group
+func GetSubscriptionSettingByGroupId(groupId int64) (*SubscriptionSetting, error) {
+	var setting SubscriptionSetting
+	err := DB.Where("group_id = ?", groupId).First(&setting).Error
+	if err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// IsValidTimeUnit returns true if unit is one of the supported time units
+func IsValidTimeUnit(unit string) bool {
+	switch unit {
+	case TimeUnitDay, TimeUnitWeek, TimeUnitMonth, TimeUnitQuarter, TimeUnitYear:
+		return true
+	}
+	return false
+}
+
 // Get all subscription settings
 func GetAllSubscriptionSettings(offset, limit int) ([]SubscriptionSetting, int64, error) {
 	var settings []SubscriptionSetting
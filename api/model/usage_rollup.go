@@ -0,0 +1,259 @@
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UsageRollupInterval 汇总粒度，按 UTC 整点切分，整点时区的企业可按本地日、月准确合并
+const UsageRollupInterval = time.Hour
+
+// UsageLatencyBounds 耗时直方图各桶的上界（毫秒），最后一个桶收集超出上界的请求
+var UsageLatencyBounds = []int64{
+	100, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000,
+	7500, 10000, 15000, 20000, 30000, 45000, 60000, 90000, 120000, 300000,
+}
+
+// UsageRollup 按小时汇总的调用量，维度为智能体、用户、模型和渠道
+type UsageRollup struct {
+	ID               int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid              int64  `json:"eid" gorm:"not null;index:idx_usage_rollup_eid_bucket,priority:1"`
+	BucketTime       int64  `json:"bucket_time" gorm:"not null;index:idx_usage_rollup_eid_bucket,priority:2;index"` // 小时起点，毫秒时间戳
+	AgentID          int64  `json:"agent_id" gorm:"not null;default:0"`
+	UserID           int64  `json:"user_id" gorm:"not null;default:0"`
+	ModelName        string `json:"model_name" gorm:"size:100;not null;default:''"`
+	ChannelID        int64  `json:"channel_id" gorm:"not null;default:0"`
+	CallCount        int64  `json:"call_count" gorm:"not null;default:0"`
+	ErrorCount       int64  `json:"error_count" gorm:"not null;default:0"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"not null;default:0"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"not null;default:0"`
+	TotalTokens      int64  `json:"total_tokens" gorm:"not null;default:0"`
+	Quota            int64  `json:"quota" gorm:"not null;default:0"`
+	ElapsedTime      int64  `json:"elapsed_time" gorm:"not null;default:0"`     // 总耗时（毫秒）
+	LatencyHistogram string `json:"latency_histogram" gorm:"type:varchar(512)"` // 耗时直方图，与 UsageLatencyBounds 对应的计数数组
+	BaseModel
+}
+
+func (UsageRollup) TableName() string {
+	return "usage_rollups"
+}
+
+// UsageRollupQuery 汇总数据的筛选条件，时间为毫秒时间戳，左闭右开
+type UsageRollupQuery struct {
+	StartTime int64
+	EndTime   int64
+	AgentID   int64
+	UserID    int64
+	UserIDs   []int64 // 为空时不按用户列表筛选
+	ModelName string
+	ChannelID int64
+}
+
+// LatencyHistogram 耗时直方图
+type LatencyHistogram []int64
+
+func NewLatencyHistogram() LatencyHistogram {
+	return make(LatencyHistogram, len(UsageLatencyBounds)+1)
+}
+
+// ParseLatencyHistogram 解析存储的直方图，格式错误时返回空直方图
+func ParseLatencyHistogram(data string) LatencyHistogram {
+	histogram := NewLatencyHistogram()
+	var counts []int64
+	if data != "" && json.Unmarshal([]byte(data), &counts) == nil {
+		for i := 0; i < len(counts) && i < len(histogram); i++ {
+			histogram[i] = counts[i]
+		}
+	}
+	return histogram
+}
+
+func (h LatencyHistogram) Add(elapsed int64) {
+	index := sort.Search(len(UsageLatencyBounds), func(i int) bool { return UsageLatencyBounds[i] >= elapsed })
+	h[index]++
+}
+
+func (h LatencyHistogram) Merge(other LatencyHistogram) {
+	for i := 0; i < len(h) && i < len(other); i++ {
+		h[i] += other[i]
+	}
+}
+
+// Percentile 估算百分位耗时，返回所在桶的上界；落在最后一个桶时返回最大上界
+func (h LatencyHistogram) Percentile(p float64) int64 {
+	var total int64
+	for _, count := range h {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	target := int64(float64(total)*p + 0.999999)
+	var seen int64
+	for i, count := range h {
+		seen += count
+		if seen >= target {
+			if i < len(UsageLatencyBounds) {
+				return UsageLatencyBounds[i]
+			}
+			break
+		}
+	}
+	return UsageLatencyBounds[len(UsageLatencyBounds)-1]
+}
+
+func (h LatencyHistogram) String() string {
+	data, _ := json.Marshal([]int64(h))
+	return string(data)
+}
+
+// usageMessage 汇总所需的消息字段
+type usageMessage struct {
+	ID               int64
+	Eid              int64
+	CreatedTime      int64
+	AgentID          int64
+	UserID           int64
+	ModelName        string
+	ChannelId        int64
+	IsError          bool
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	Quota            int64
+	ElapsedTime      int64
+}
+
+// RollupMessageUsage 重新汇总 [start, end) 内各小时的消息用量，已有的汇总数据会被覆盖；复制会话产生的消息不计入
+func RollupMessageUsage(start, end int64) error {
+	interval := UsageRollupInterval.Milliseconds()
+	for bucket := start - start%interval; bucket < end; bucket += interval {
+		if err := rollupUsageBucket(bucket, bucket+interval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rollupUsageBucket(start, end int64) error {
+	type rollupKey struct {
+		eid, agentID, userID, channelID int64
+		modelName                       string
+	}
+	type rollupValue struct {
+		rollup    *UsageRollup
+		histogram LatencyHistogram
+	}
+	rollups := make(map[rollupKey]*rollupValue)
+	var keys []rollupKey
+
+	const batchSize = 1000
+	var lastID int64
+	for {
+		var batch []usageMessage
+		err := DB.Model(&Message{}).
+			Select("id, eid, created_time, agent_id, user_id, model_name, channel_id, is_error, prompt_tokens, completion_tokens, total_tokens, quota, elapsed_time").
+			Where("created_time >= ? AND created_time < ? AND id > ? AND forked_from = 0", start, end, lastID).
+			Order("id ASC").Limit(batchSize).
+			Scan(&batch).Error
+		if err != nil {
+			return err
+		}
+		for _, m := range batch {
+			key := rollupKey{eid: m.Eid, agentID: m.AgentID, userID: m.UserID, channelID: m.ChannelId, modelName: m.ModelName}
+			value, ok := rollups[key]
+			if !ok {
+				value = &rollupValue{
+					rollup: &UsageRollup{
+						Eid:        m.Eid,
+						BucketTime: start,
+						AgentID:    m.AgentID,
+						UserID:     m.UserID,
+						ModelName:  m.ModelName,
+						ChannelID:  m.ChannelId,
+					},
+					histogram: NewLatencyHistogram(),
+				}
+				rollups[key] = value
+				keys = append(keys, key)
+			}
+			r := value.rollup
+			r.CallCount++
+			if m.IsError {
+				r.ErrorCount++
+			}
+			r.PromptTokens += m.PromptTokens
+			r.CompletionTokens += m.CompletionTokens
+			r.TotalTokens += m.TotalTokens
+			r.Quota += m.Quota
+			r.ElapsedTime += m.ElapsedTime
+			value.histogram.Add(m.ElapsedTime)
+		}
+		if len(batch) < batchSize {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	records := make([]*UsageRollup, 0, len(keys))
+	for _, key := range keys {
+		value := rollups[key]
+		value.rollup.LatencyHistogram = value.histogram.String()
+		records = append(records, value.rollup)
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bucket_time = ?", start).Delete(&UsageRollup{}).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(records, 200).Error
+	})
+}
+
+// GetLatestUsageRollupTime 最近一次汇总的小时起点，没有汇总数据时返回 0
+func GetLatestUsageRollupTime() (int64, error) {
+	var latest int64
+	err := DB.Model(&UsageRollup{}).Select("COALESCE(MAX(bucket_time), 0)").Scan(&latest).Error
+	return latest, err
+}
+
+// GetEarliestMessageTime 最早一条消息的创建时间，没有消息时返回 0
+func GetEarliestMessageTime() (int64, error) {
+	var earliest int64
+	err := DB.Model(&Message{}).Select("COALESCE(MIN(created_time), 0)").Scan(&earliest).Error
+	return earliest, err
+}
+
+// GetUsageRollups 按条件获取汇总数据
+func GetUsageRollups(eid int64, q UsageRollupQuery) ([]*UsageRollup, error) {
+	query := DB.Where("eid = ?", eid)
+	if q.StartTime > 0 {
+		query = query.Where("bucket_time >= ?", q.StartTime)
+	}
+	if q.EndTime > 0 {
+		query = query.Where("bucket_time < ?", q.EndTime)
+	}
+	if q.AgentID > 0 {
+		query = query.Where("agent_id = ?", q.AgentID)
+	}
+	if q.UserID > 0 {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if len(q.UserIDs) > 0 {
+		query = query.Where("user_id IN ?", q.UserIDs)
+	}
+	if q.ModelName != "" {
+		query = query.Where("model_name = ?", q.ModelName)
+	}
+	if q.ChannelID > 0 {
+		query = query.Where("channel_id = ?", q.ChannelID)
+	}
+	rollups := make([]*UsageRollup, 0)
+	err := query.Order("bucket_time ASC").Find(&rollups).Error
+	return rollups, err
+}
@@ -150,6 +150,16 @@ func GetUserByID(userID int64) (*User, error) {
 	return &user, nil
 }
 
+// GetUsersByIDs 批量获取企业下的用户
+func GetUsersByIDs(eid int64, userIDs []int64) ([]*User, error) {
+	users := make([]*User, 0, len(userIDs))
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	err := DB.Where("eid = ? AND user_id IN ?", eid, userIDs).Find(&users).Error
+	return users, err
+}
+
 func (user *User) LoginValidate(eid int64, username string, password string) error {
 	if username == "" || password == "" {
 		return errors.New("username or password is empty")
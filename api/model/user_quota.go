@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserQuota 用户在某个额度周期内的配额使用量
+type UserQuota struct {
+	ID        int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid       int64  `json:"eid" gorm:"not null;uniqueIndex:idx_user_quota_period,priority:1"`
+	UserID    int64  `json:"user_id" gorm:"not null;uniqueIndex:idx_user_quota_period,priority:2"`
+	Period    string `json:"period" gorm:"type:varchar(32);not null;uniqueIndex:idx_user_quota_period,priority:3;comment:'Period key, e.g. month:2025-01'"`
+	UsedQuota int64  `json:"used_quota" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (UserQuota) TableName() string {
+	return "user_quotas"
+}
+
+// GetQuotaPeriodKey 根据周期单位计算当前时间所在的周期标识
+func GetQuotaPeriodKey(unit string, t time.Time) string {
+	switch unit {
+	case TimeUnitDay:
+		return fmt.Sprintf("%s:%s", unit, t.Format("2006-01-02"))
+	case TimeUnitWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%s:%d-W%02d", unit, year, week)
+	case TimeUnitQuarter:
+		return fmt.Sprintf("%s:%d-Q%d", unit, t.Year(), (int(t.Month())-1)/3+1)
+	case TimeUnitYear:
+		return fmt.Sprintf("%s:%d", unit, t.Year())
+	default:
+		return fmt.Sprintf("%s:%s", TimeUnitMonth, t.Format("2006-01"))
+	}
+}
+
+// GetQuotaPeriodEnd 计算当前时间所在周期的结束时间（下一周期的开始）
+func GetQuotaPeriodEnd(unit string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch unit {
+	case TimeUnitDay:
+		return day.AddDate(0, 0, 1)
+	case TimeUnitWeek:
+		// ISO 周从周一开始
+		return day.AddDate(0, 0, 7-(int(t.Weekday())+6)%7)
+	case TimeUnitQuarter:
+		quarterStart := (int(t.Month())-1)/3*3 + 1
+		return time.Date(t.Year(), time.Month(quarterStart)+3, 1, 0, 0, 0, 0, t.Location())
+	case TimeUnitYear:
+		return time.Date(t.Year()+1, 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// GetUserUsedQuota 获取用户在指定周期内已使用的配额
+func GetUserUsedQuota(eid, userID int64, period string) (int64, error) {
+	var quota UserQuota
+	err := DB.Where("eid = ? AND user_id = ? AND period = ?", eid, userID, period).First(&quota).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return quota.UsedQuota, nil
+}
+
+// ensureUserQuota 确保周期记录存在
+func ensureUserQuota(eid, userID int64, period string) error {
+	quota := UserQuota{
+		Eid:    eid,
+		UserID: userID,
+		Period: period,
+	}
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&quota).Error
+}
+
+// ConsumeUserQuota 在不超过 limit 的前提下原子增加已用配额，limit <= 0 表示不限制
+// 返回 false 表示剩余配额不足
+func ConsumeUserQuota(eid, userID int64, period string, amount, limit int64) (bool, error) {
+	if err := ensureUserQuota(eid, userID, period); err != nil {
+		return false, err
+	}
+
+	query := DB.Model(&UserQuota{}).Where("eid = ? AND user_id = ? AND period = ?", eid, userID, period)
+	if limit > 0 {
+		query = query.Where("used_quota < ? AND used_quota + ? <= ?", limit, amount, limit)
+	}
+	result := query.Updates(map[string]interface{}{
+		"used_quota":   gorm.Expr("used_quota + ?", amount),
+		"updated_time": time.Now().UTC().UnixMilli(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// AdjustUserQuota 调整已用配额，delta 为负数时表示退还
+func AdjustUserQuota(eid, userID int64, period string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	if err := ensureUserQuota(eid, userID, period); err != nil {
+		return err
+	}
+	return DB.Model(&UserQuota{}).
+		Where("eid = ? AND user_id = ? AND period = ?", eid, userID, period).
+		Updates(map[string]interface{}{
+			"used_quota":   gorm.Expr("CASE WHEN used_quota + ? < 0 THEN 0 ELSE used_quota + ? END", delta, delta),
+			"updated_time": time.Now().UTC().UnixMilli(),
+		}).Error
+}
@@ -0,0 +1,201 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// 异步工作流运行状态
+const (
+	WorkflowRunStatusPending   = "pending"
+	WorkflowRunStatusRunning   = "running"
+	WorkflowRunStatusSucceeded = "succeeded"
+	WorkflowRunStatusFailed    = "failed"
+	WorkflowRunStatusCancelled = "cancelled"
+)
+
+// 回调投递状态
+const (
+	WorkflowRunCallbackNone      = ""
+	WorkflowRunCallbackSucceeded = "succeeded"
+	WorkflowRunCallbackFailed    = "failed"
+)
+
+const WorkflowRunIDPrefix = "wfr_"
+
+// WorkflowRun 异步工作流运行记录
+type WorkflowRun struct {
+	ID               int64  `json:"-" gorm:"primaryKey;autoIncrement"`
+	RunID            string `json:"run_id" gorm:"size:64;not null;uniqueIndex"`
+	Eid              int64  `json:"eid" gorm:"not null;index"`
+	UserID           int64  `json:"user_id" gorm:"not null;index"`
+	AgentID          int64  `json:"agent_id" gorm:"not null;default:0"`
+	ConversationID   int64  `json:"conversation_id" gorm:"not null;default:0"`
+	MessageID        int64  `json:"message_id" gorm:"not null;default:0"`
+	Model            string `json:"model" gorm:"size:100;not null;default:''"`
+	Status           string `json:"status" gorm:"size:20;not null;default:'pending';index"`
+	Parameters       string `json:"-" gorm:"type:text"`
+	Outputs          string `json:"-" gorm:"type:text"`
+	ExecuteID        string `json:"execute_id" gorm:"size:100;not null;default:''"`
+	ChannelID        int    `json:"channel_id" gorm:"not null;default:0"`
+	ErrorMessage     string `json:"error_message" gorm:"type:text"`
+	PreConsumedQuota int64  `json:"-" gorm:"not null;default:0"`
+	QuotaPeriod      string `json:"-" gorm:"size:32;not null;default:''"`
+	CallbackURL      string `json:"callback_url" gorm:"size:500;not null;default:''"`
+	CallbackSecret   string `json:"-" gorm:"size:100;not null;default:''"`
+	CallbackStatus   string `json:"callback_status" gorm:"size:20;not null;default:''"`
+	StartedTime      int64  `json:"started_time" gorm:"not null;default:0"`
+	FinishedTime     int64  `json:"finished_time" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (WorkflowRun) TableName() string {
+	return "workflow_runs"
+}
+
+// GenerateWorkflowRunID 生成对外暴露的运行ID
+func GenerateWorkflowRunID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return WorkflowRunIDPrefix + hex.EncodeToString(buf), nil
+}
+
+// GenerateWorkflowRunSecret 生成回调签名密钥
+func GenerateWorkflowRunSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// IsFinished 运行是否已结束
+func (r *WorkflowRun) IsFinished() bool {
+	return r.Status == WorkflowRunStatusSucceeded ||
+		r.Status == WorkflowRunStatusFailed ||
+		r.Status == WorkflowRunStatusCancelled
+}
+
+// CreateWorkflowRun 创建运行记录
+func CreateWorkflowRun(run *WorkflowRun) error {
+	return DB.Create(run).Error
+}
+
+// GetWorkflowRunByRunID 根据运行ID获取记录
+func GetWorkflowRunByRunID(eid int64, runID string) (*WorkflowRun, error) {
+	var run WorkflowRun
+	err := DB.Where("eid = ? AND run_id = ?", eid, runID).First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetWorkflowRunStatus 读取运行的最新状态，用于跨实例感知取消
+func GetWorkflowRunStatus(id int64) (string, error) {
+	var run WorkflowRun
+	err := DB.Select("status").Where("id = ?", id).First(&run).Error
+	return run.Status, err
+}
+
+// StartWorkflowRun 将排队中的运行标记为执行中，运行已被取消时返回 false
+func StartWorkflowRun(run *WorkflowRun) (bool, error) {
+	now := time.Now().UTC().UnixMilli()
+	result := DB.Model(&WorkflowRun{}).
+		Where("id = ? AND status = ?", run.ID, WorkflowRunStatusPending).
+		Updates(map[string]interface{}{
+			"status":       WorkflowRunStatusRunning,
+			"started_time": now,
+			"updated_time": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	run.Status = WorkflowRunStatusRunning
+	run.StartedTime = now
+	return true, nil
+}
+
+// FinishWorkflowRun 写入运行结果，只更新执行中的运行，避免覆盖取消状态
+func FinishWorkflowRun(run *WorkflowRun) (bool, error) {
+	now := time.Now().UTC().UnixMilli()
+	run.FinishedTime = now
+	result := DB.Model(&WorkflowRun{}).
+		Where("id = ? AND status = ?", run.ID, WorkflowRunStatusRunning).
+		Updates(map[string]interface{}{
+			"status":        run.Status,
+			"outputs":       run.Outputs,
+			"execute_id":    run.ExecuteID,
+			"channel_id":    run.ChannelID,
+			"error_message": run.ErrorMessage,
+			"finished_time": now,
+			"updated_time":  now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CancelWorkflowRun 取消排队中或执行中的运行
+func CancelWorkflowRun(run *WorkflowRun) (bool, error) {
+	now := time.Now().UTC().UnixMilli()
+	result := DB.Model(&WorkflowRun{}).
+		Where("id = ? AND status IN ?", run.ID, []string{WorkflowRunStatusPending, WorkflowRunStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":        WorkflowRunStatusCancelled,
+			"finished_time": now,
+			"updated_time":  now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	run.Status = WorkflowRunStatusCancelled
+	run.FinishedTime = now
+	return true, nil
+}
+
+// UpdateWorkflowRunCallbackStatus 更新回调投递状态
+func UpdateWorkflowRunCallbackStatus(id int64, status string) error {
+	return DB.Model(&WorkflowRun{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"callback_status": status,
+			"updated_time":    time.Now().UTC().UnixMilli(),
+		}).Error
+}
+
+// FailStaleWorkflowRuns 将超时仍未结束的运行标记为失败（例如服务重启导致任务丢失），返回受影响的记录
+func FailStaleWorkflowRuns(before int64) ([]*WorkflowRun, error) {
+	var runs []*WorkflowRun
+	err := DB.Where("status IN ? AND created_time < ?",
+		[]string{WorkflowRunStatusPending, WorkflowRunStatusRunning}, before).
+		Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stale := make([]*WorkflowRun, 0, len(runs))
+	for _, run := range runs {
+		if run.Status == WorkflowRunStatusPending {
+			// 先转为执行中，复用 FinishWorkflowRun 的条件更新
+			if ok, err := StartWorkflowRun(run); err != nil || !ok {
+				continue
+			}
+		}
+		run.Status = WorkflowRunStatusFailed
+		run.ErrorMessage = "workflow run timed out"
+		if ok, err := FinishWorkflowRun(run); err == nil && ok {
+			stale = append(stale, run)
+		}
+	}
+	return stale, nil
+}
@@ -64,6 +64,9 @@ func SetApiRouter(router *gin.Engine) {
 
 	userRoute := apiRouter.Group("/users")
 	userRoute.GET("/me", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetCurrentUser)
+	userRoute.GET("/me/quota", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetCurrentUserQuota)
+	userRoute.GET("/me/points", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetCurrentUserPoints)
+	userRoute.GET("/me/points/ledgers", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetCurrentUserPointsLedgers)
 	userRoute.PUT("/password", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdateUserPassword)
 	userRoute.PATCH("/:id/mobile", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdateUserMobile)
 	userRoute.PATCH("/:id/email", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdateUserEmail)
@@ -147,6 +150,7 @@ func SetApiRouter(router *gin.Engine) {
 		channelGroup.DELETE("/:channel_id", controller.DeleteChannel)
 		channelGroup.GET("/test/:channel_id", controller.TestChannel)
 		channelGroup.GET("/models", controller.ListAllModels)
+		channelGroup.GET("/health", controller.GetChannelsHealth)
 	}
 
 	agentGroup := apiRouter.Group("/agents")
@@ -164,6 +168,8 @@ func SetApiRouter(router *gin.Engine) {
 		agentGroup.PATCH("/:agent_id/status", controller.UpdateAgentStatus)
 		agentGroup.GET("/internal_users", controller.GetInternalUserAgents)
 		agentGroup.GET("/:agent_id/conversations", controller.GetAgentConversations)
+		agentGroup.GET("/:agent_id/response_cache", controller.GetAgentResponseCache)
+		agentGroup.DELETE("/:agent_id/response_cache", controller.PurgeAgentResponseCache)
 	}
 
 	conversationGroup := apiRouter.Group("/conversations")
@@ -176,6 +182,17 @@ func SetApiRouter(router *gin.Engine) {
 		conversationGroup.DELETE("/:conversation_id", controller.DeleteConversation)
 		//conversationGroup.POST("/:conversation_id/messages", controller.CreateMessage)
 		conversationGroup.GET("/:conversation_id/messages", controller.GetMessagesByConversation)
+		conversationGroup.PUT("/:conversation_id/messages/:message_id/feedback", controller.SubmitMessageFeedback)
+		conversationGroup.DELETE("/:conversation_id/messages/:message_id/feedback", controller.DeleteMessageFeedback)
+	}
+
+	messageGroup := apiRouter.Group("/messages")
+	messageGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	{
+		messageGroup.GET("/:message_id/attempts", controller.GetMessageRelayAttempts)
+		messageGroup.GET("/:message_id/tool_calls", controller.GetMessageToolCalls)
+		messageGroup.GET("/feedbacks", controller.GetMessageFeedbacks)
+		messageGroup.GET("/feedbacks/stats", controller.GetMessageFeedbackStats)
 	}
 
 	subscription := apiRouter.Group("/subscriptions")
@@ -232,10 +249,23 @@ func SetApiRouter(router *gin.Engine) {
 	apiV1Router.Use(middleware.CORS())
 	apiV1Router.Use(middleware.Logger())
 	apiV1Router.Use(middleware.RelayTokenAuth())
+	apiV1Router.Use(middleware.RelayRateLimit())
 	{
 		apiV1Router.POST("/chat/completions", controller.Relay)
+		apiV1Router.POST("/messages", controller.AnthropicMessages)
+		apiV1Router.POST("/conversations", controller.CreateConversationThread)
+		apiV1Router.GET("/conversations", controller.ListConversationThreads)
+		apiV1Router.GET("/conversations/:conversation_id/messages", controller.ListConversationThreadMessages)
+		apiV1Router.POST("/conversations/:conversation_id/messages", controller.CreateConversationThreadMessage)
+		apiV1Router.POST("/conversations/:conversation_id/fork", controller.ForkConversationThread)
+		apiV1Router.DELETE("/conversations/:conversation_id", controller.DeleteConversationThread)
 		apiV1Router.POST("/workflow/run", controller.WorkflowRun)
+		apiV1Router.POST("/workflow/runs", controller.CreateWorkflowRun)
+		apiV1Router.GET("/workflow/runs/:run_id", controller.GetWorkflowRun)
+		apiV1Router.POST("/workflow/runs/:run_id/cancel", controller.CancelWorkflowRun)
 		apiV1Router.POST("/rerank", controller.Rerank)
+		apiV1Router.POST("/embeddings", controller.Embeddings)
+		apiV1Router.GET("/models", controller.ListAgentModels)
 	}
 
 	paySettingRouter := apiRouter.Group("/pay_settings")
@@ -314,6 +344,77 @@ func SetApiRouter(router *gin.Engine) {
 		promptGroup.PATCH("/:pid/status", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdatePromptStatus)
 	}
 
+	apiKeyGroup := apiRouter.Group("/api_keys")
+	{
+		apiKeyGroup.GET("", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetApiKeys)
+		apiKeyGroup.POST("", middleware.UserTokenAuth(model.RoleCommonUser), controller.CreateApiKey)
+		apiKeyGroup.GET("/admin", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetAllApiKeys)
+		apiKeyGroup.POST("/service", middleware.UserTokenAuth(model.RoleAdminUser), controller.CreateApiKey)
+		apiKeyGroup.PUT("/:id", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdateApiKey)
+		apiKeyGroup.DELETE("/:id", middleware.UserTokenAuth(model.RoleCommonUser), controller.RevokeApiKey)
+	}
+
+	knowledgeBaseGroup := apiRouter.Group("/knowledge_bases")
+	knowledgeBaseGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	{
+		knowledgeBaseGroup.GET("", controller.GetKnowledgeBases)
+		knowledgeBaseGroup.POST("", controller.CreateKnowledgeBase)
+		knowledgeBaseGroup.GET("/:id", controller.GetKnowledgeBase)
+		knowledgeBaseGroup.PUT("/:id", controller.UpdateKnowledgeBase)
+		knowledgeBaseGroup.DELETE("/:id", controller.DeleteKnowledgeBase)
+		knowledgeBaseGroup.POST("/:id/retrieve", controller.RetrieveKnowledgeBase)
+		knowledgeBaseGroup.GET("/:id/documents", controller.GetKnowledgeDocuments)
+		knowledgeBaseGroup.POST("/:id/documents", controller.AddKnowledgeDocuments)
+		knowledgeBaseGroup.DELETE("/:id/documents/:document_id", controller.DeleteKnowledgeDocument)
+		knowledgeBaseGroup.POST("/:id/documents/:document_id/reindex", controller.ReindexKnowledgeDocument)
+	}
+
+	usageGroup := apiRouter.Group("/usage")
+	usageGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	{
+		usageGroup.GET("/stats", controller.GetUsageStats)
+	}
+
+	exportGroup := apiRouter.Group("/exports")
+	// 下载链接已签名，不需要登录
+	exportGroup.GET("/:id/download", controller.DownloadExportFile)
+	exportGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	{
+		exportGroup.POST("", controller.CreateExportJob)
+		exportGroup.GET("", controller.GetExportJobs)
+		exportGroup.GET("/:id", controller.GetExportJob)
+		exportGroup.DELETE("/:id", controller.DeleteExportJob)
+	}
+
+	modelPriceGroup := apiRouter.Group("/model_prices")
+	modelPriceGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	{
+		modelPriceGroup.GET("", controller.GetModelPrices)
+		modelPriceGroup.POST("", controller.SaveModelPrice)
+		modelPriceGroup.PUT("/:id", controller.UpdateModelPrice)
+		modelPriceGroup.DELETE("/:id", controller.DeleteModelPrice)
+	}
+
+	pointsGroup := apiRouter.Group("/points")
+	pointsGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	{
+		pointsGroup.GET("/ledgers", controller.GetPointsLedgers)
+		pointsGroup.POST("/ledgers/:id/refund", controller.RefundPointsLedger)
+		pointsGroup.GET("/users/:user_id", controller.GetUserPoints)
+		pointsGroup.POST("/users/:user_id/adjust", controller.AdjustUserPoints)
+	}
+
+	budgetGroup := apiRouter.Group("/budgets")
+	budgetGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	{
+		budgetGroup.GET("", controller.GetBudgets)
+		budgetGroup.POST("", controller.CreateBudget)
+		budgetGroup.GET("/alerts", controller.GetBudgetAlerts)
+		budgetGroup.GET("/:id", controller.GetBudget)
+		budgetGroup.PUT("/:id", controller.UpdateBudget)
+		budgetGroup.DELETE("/:id", controller.DeleteBudget)
+	}
+
 	navigationRoute := apiRouter.Group("/navigations")
 	navigationRoute.GET("", controller.GetNavigations)
 	navigationRoute.GET("/icons", controller.GetNavigationIcons)
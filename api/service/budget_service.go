@@ -0,0 +1,391 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/model"
+	"github.com/jordan-wright/email"
+)
+
+// 预算暂停状态的本地缓存时间，暂停或恢复时会主动失效
+const budgetPauseCacheTTL = 30 * time.Second
+
+// budgetWebhookTimeout 告警 webhook 的请求超时时间
+const budgetWebhookTimeout = 10 * time.Second
+
+// budgetWebhookClient 告警只允许投递到公网地址，连接时再次校验以防 DNS rebinding
+var budgetWebhookClient = utils.NewPublicHTTPClient(budgetWebhookTimeout)
+
+// 预算统计周期
+const (
+	BudgetPeriodDay   = model.TimeUnitDay
+	BudgetPeriodMonth = model.TimeUnitMonth
+)
+
+// BudgetWebhookPayload 告警 webhook 的请求体
+type BudgetWebhookPayload struct {
+	Event     string `json:"event"` // 固定为 budget.alert
+	Eid       int64  `json:"eid"`
+	BudgetID  int64  `json:"budget_id"`
+	Name      string `json:"name"`
+	ScopeType string `json:"scope_type"`
+	ScopeID   int64  `json:"scope_id"`
+	ScopeName string `json:"scope_name"`
+	Metric    string `json:"metric"`
+	Period    string `json:"period"`
+	Threshold int64  `json:"threshold"`
+	Usage     int64  `json:"usage"`
+	Level     int    `json:"level"`  // 触发的告警比例
+	Paused    bool   `json:"paused"` // 是否已暂停智能体或渠道
+	Time      int64  `json:"time"`   // 毫秒时间戳
+}
+
+// ValidateBudget 校验预算配置
+func ValidateBudget(budget *model.Budget) error {
+	if budget.Name == "" {
+		return errors.New("name is required")
+	}
+	if !model.ValidBudgetScope(budget.ScopeType) {
+		return errors.New("invalid scope_type")
+	}
+	if budget.ScopeType == model.BudgetScopeEnterprise {
+		budget.ScopeID = 0
+	} else if budget.ScopeID <= 0 {
+		return errors.New("scope_id is required")
+	}
+	if budget.Metric != model.BudgetMetricQuota && budget.Metric != model.BudgetMetricTokens {
+		return errors.New("invalid metric")
+	}
+	if budget.Period != BudgetPeriodDay && budget.Period != BudgetPeriodMonth {
+		return errors.New("invalid period")
+	}
+	if budget.Threshold <= 0 {
+		return errors.New("threshold must be greater than 0")
+	}
+	for _, level := range budget.GetAlertLevels() {
+		if level <= 0 || level > 1000 {
+			return fmt.Errorf("invalid alert level: %d", level)
+		}
+	}
+	if budget.WebhookURL != "" {
+		if err := utils.ValidatePublicURL(budget.WebhookURL); err != nil {
+			return fmt.Errorf("invalid webhook_url: %w", err)
+		}
+	}
+	if budget.PauseOnExceed && budget.ScopeType != model.BudgetScopeAgent && budget.ScopeType != model.BudgetScopeChannel {
+		return errors.New("pause_on_exceed is only supported for agent and channel budgets")
+	}
+	return nil
+}
+
+// ValidateBudgetScope 校验预算范围属于当前企业
+func ValidateBudgetScope(eid int64, scopeType string, scopeID int64) error {
+	switch scopeType {
+	case model.BudgetScopeAgent:
+		if _, err := model.GetAgentByID(eid, scopeID); err != nil {
+			return errors.New("agent not found")
+		}
+	case model.BudgetScopeChannel:
+		if channel, err := model.GetChannelByID(scopeID); err != nil || channel.Eid != eid {
+			return errors.New("channel not found")
+		}
+	case model.BudgetScopeUserGroup:
+		if group, err := model.GetGroupByID(scopeID); err != nil || group.Eid != eid {
+			return errors.New("user group not found")
+		}
+	}
+	return nil
+}
+
+// budgetPeriod 返回当前时间所在的统计周期标识及时间范围
+func budgetPeriod(period string, now time.Time) (string, time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if period == BudgetPeriodMonth {
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return model.GetQuotaPeriodKey(period, now), start, model.GetQuotaPeriodEnd(period, now)
+}
+
+// EvaluateBudgets 检查全部预算的用量并发送告警，返回检查的预算数
+func EvaluateBudgets() (int, error) {
+	budgets, err := model.GetBudgetsToEvaluate()
+	if err != nil {
+		return 0, err
+	}
+	for _, budget := range budgets {
+		if err := EvaluateBudget(budget); err != nil {
+			logger.SysErrorf("evaluate budget %d failed: %v", budget.ID, err)
+		}
+	}
+	return len(budgets), nil
+}
+
+// EvaluateBudget 统计预算当前周期的用量：
+// 用量达到新的告警比例时发送告警，达到 100% 且开启暂停时暂停智能体或渠道；
+// 进入新周期、预算调高或停用后用量低于预算时恢复暂停
+func EvaluateBudget(budget *model.Budget) error {
+	now := time.Now().In(model.GetEnterpriseLocation(budget.Eid))
+	period, start, end := budgetPeriod(budget.Period, now)
+	if budget.CurrentPeriod != period {
+		budget.CurrentPeriod = period
+		budget.AlertedLevel = 0
+	}
+
+	usage, err := model.SumBudgetUsage(budget, start, end)
+	if err != nil {
+		return err
+	}
+	budget.Usage = usage
+	budget.EvaluatedTime = now.UnixMilli()
+
+	if budget.Paused && (!budget.Enabled || usage < budget.Threshold) {
+		if err := resumeBudgetScope(budget); err != nil {
+			logger.SysErrorf("resume budget %d scope failed: %v", budget.ID, err)
+		}
+	} else if budget.Paused && budget.ScopeType == model.BudgetScopeChannel {
+		// 暂停期间渠道可能被自动测试等重新启用，需再次暂停
+		if err := model.PauseChannelByBudget(budget.Eid, budget.ScopeID); err != nil {
+			logger.SysErrorf("pause budget %d channel failed: %v", budget.ID, err)
+		}
+	}
+	if !budget.Enabled {
+		return model.UpdateBudgetState(budget)
+	}
+
+	levels := budget.GetAlertLevels()
+	if budget.PauseOnExceed && !slices.Contains(levels, 100) {
+		levels = append(levels, 100)
+	}
+	level := 0
+	for _, l := range levels {
+		if l > budget.AlertedLevel && l > level && usage*100 >= int64(l)*budget.Threshold {
+			level = l
+		}
+	}
+	if level > 0 {
+		alert := &model.BudgetAlert{
+			Eid:       budget.Eid,
+			BudgetID:  budget.ID,
+			Period:    period,
+			Level:     level,
+			Usage:     usage,
+			Threshold: budget.Threshold,
+		}
+		if budget.PauseOnExceed && !budget.Paused && usage >= budget.Threshold {
+			if err := pauseBudgetScope(budget); err != nil {
+				alert.Error = err.Error()
+			}
+		}
+		alert.Paused = budget.Paused
+		sendBudgetAlert(budget, alert)
+		if err := model.CreateBudgetAlert(alert); err != nil {
+			logger.SysErrorf("create budget alert failed: budget_id=%d err=%v", budget.ID, err)
+		}
+		budget.AlertedLevel = level
+	}
+	return model.UpdateBudgetState(budget)
+}
+
+// DeleteBudget 删除预算，已暂停的智能体或渠道会被恢复
+func DeleteBudget(budget *model.Budget) error {
+	if budget.Paused {
+		if err := resumeBudgetScope(budget); err != nil {
+			return err
+		}
+	}
+	return model.DeleteBudget(budget.Eid, budget.ID)
+}
+
+func pauseBudgetScope(budget *model.Budget) error {
+	if budget.ScopeType == model.BudgetScopeChannel {
+		if err := model.PauseChannelByBudget(budget.Eid, budget.ScopeID); err != nil {
+			return err
+		}
+	}
+	budget.Paused = true
+	if err := model.UpdateBudgetState(budget); err != nil {
+		return err
+	}
+	InvalidateBudgetPause(budget.Eid)
+	logger.SysLogf("budget %d paused %s %d", budget.ID, budget.ScopeType, budget.ScopeID)
+	return nil
+}
+
+func resumeBudgetScope(budget *model.Budget) error {
+	budget.Paused = false
+	if err := model.UpdateBudgetState(budget); err != nil {
+		return err
+	}
+	InvalidateBudgetPause(budget.Eid)
+	if budget.ScopeType == model.BudgetScopeChannel {
+		// 同一渠道可能还被其他预算暂停
+		ids, err := model.GetPausedBudgetScopeIDs(budget.Eid, model.BudgetScopeChannel)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(ids, budget.ScopeID) {
+			if err := model.ResumeChannelByBudget(budget.Eid, budget.ScopeID); err != nil {
+				return err
+			}
+		}
+	}
+	logger.SysLogf("budget %d resumed %s %d", budget.ID, budget.ScopeType, budget.ScopeID)
+	return nil
+}
+
+type budgetPauseCache struct {
+	agents   map[int64]bool
+	expireAt time.Time
+}
+
+var budgetPauseCaches sync.Map // key: eid, value: *budgetPauseCache
+
+// IsAgentPausedByBudget 智能体是否因预算用尽被暂停
+func IsAgentPausedByBudget(eid, agentID int64) bool {
+	if cached, ok := budgetPauseCaches.Load(eid); ok {
+		entry := cached.(*budgetPauseCache)
+		if time.Now().Before(entry.expireAt) {
+			return entry.agents[agentID]
+		}
+	}
+
+	entry := &budgetPauseCache{agents: map[int64]bool{}, expireAt: time.Now().Add(budgetPauseCacheTTL)}
+	ids, err := model.GetPausedBudgetScopeIDs(eid, model.BudgetScopeAgent)
+	if err != nil {
+		logger.SysErrorf("get paused agents failed: eid=%d err=%v", eid, err)
+	}
+	for _, id := range ids {
+		entry.agents[id] = true
+	}
+	budgetPauseCaches.Store(eid, entry)
+	return entry.agents[agentID]
+}
+
+// InvalidateBudgetPause 清除企业的预算暂停状态缓存
+func InvalidateBudgetPause(eid int64) {
+	budgetPauseCaches.Delete(eid)
+}
+
+// getBudgetScopeName 获取预算范围的名称，用于告警内容
+func getBudgetScopeName(budget *model.Budget) string {
+	switch budget.ScopeType {
+	case model.BudgetScopeAgent:
+		if agent, err := model.GetAgentByID(budget.Eid, budget.ScopeID); err == nil {
+			return agent.Name
+		}
+	case model.BudgetScopeChannel:
+		if channel, err := model.GetChannelByID(budget.ScopeID); err == nil {
+			return channel.Name
+		}
+	case model.BudgetScopeUserGroup:
+		if group, err := model.GetGroupByID(budget.ScopeID); err == nil {
+			return group.GroupName
+		}
+	case model.BudgetScopeEnterprise:
+		if enterprise, err := model.GetEnterpriseByID(budget.Eid); err == nil {
+			return enterprise.DisplayName
+		}
+	}
+	return fmt.Sprintf("%s #%d", budget.ScopeType, budget.ScopeID)
+}
+
+// sendBudgetAlert 发送告警邮件和 webhook，发送结果记录到 alert
+func sendBudgetAlert(budget *model.Budget, alert *model.BudgetAlert) {
+	scopeName := getBudgetScopeName(budget)
+	errs := make([]string, 0)
+	if alert.Error != "" {
+		errs = append(errs, alert.Error)
+	}
+
+	if emails := budget.GetNotifyEmails(); len(emails) > 0 {
+		if err := sendBudgetAlertEmail(budget, alert, scopeName, emails); err != nil {
+			errs = append(errs, "email: "+err.Error())
+		} else {
+			alert.EmailSent = true
+		}
+	}
+	if budget.WebhookURL != "" {
+		if err := sendBudgetAlertWebhook(budget, alert, scopeName); err != nil {
+			errs = append(errs, "webhook: "+err.Error())
+		} else {
+			alert.WebhookSent = true
+		}
+	}
+	alert.Error = strings.Join(errs, "; ")
+	if alert.Error != "" {
+		logger.SysErrorf("send budget alert failed: budget_id=%d err=%s", budget.ID, alert.Error)
+	}
+}
+
+func sendBudgetAlertEmail(budget *model.Budget, alert *model.BudgetAlert, scopeName string, to []string) error {
+	auth, from, host, port, isSsl, err := GetSmtpConfig(budget.Eid)
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		return errors.New("SMTP from address is empty")
+	}
+
+	metric := "配额"
+	if budget.Metric == model.BudgetMetricTokens {
+		metric = "Token"
+	}
+	periodName := "本月"
+	if budget.Period == BudgetPeriodDay {
+		periodName = "今日"
+	}
+	content := fmt.Sprintf("预算「%s」（%s）%s%s用量已达到 %d%%：已使用 %d，预算 %d。",
+		budget.Name, scopeName, periodName, metric, alert.Level, alert.Usage, alert.Threshold)
+	if alert.Paused {
+		content += "\n已自动暂停，下个周期或调高预算后自动恢复。"
+	}
+
+	e := email.NewEmail()
+	e.From = from
+	e.To = to
+	e.Subject = fmt.Sprintf("预算告警：%s 已使用 %d%%", budget.Name, alert.Level)
+	e.Text = []byte(content)
+	return common.SendEmail(e, auth, isSsl, host, port)
+}
+
+func sendBudgetAlertWebhook(budget *model.Budget, alert *model.BudgetAlert, scopeName string) error {
+	body, err := json.Marshal(BudgetWebhookPayload{
+		Event:     "budget.alert",
+		Eid:       budget.Eid,
+		BudgetID:  budget.ID,
+		Name:      budget.Name,
+		ScopeType: budget.ScopeType,
+		ScopeID:   budget.ScopeID,
+		ScopeName: scopeName,
+		Metric:    budget.Metric,
+		Period:    alert.Period,
+		Threshold: alert.Threshold,
+		Usage:     alert.Usage,
+		Level:     alert.Level,
+		Paused:    alert.Paused,
+		Time:      time.Now().UTC().UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := budgetWebhookClient.Post(budget.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
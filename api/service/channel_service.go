@@ -10,23 +10,20 @@ import (
 )
 
 // GetChannelWithTokenRefresh 获取渠道并检查/刷新token（如果需要 ）
+// 按优先级从高到低选择渠道，excludeChannelIds 为本次请求已经失败的渠道
 // 这个函数可以被聊天和工作流共同使用
-func GetChannelWithTokenRefresh(ctx context.Context, eid int64, channelType int, modelName string, lastFailedChannelId int64) (*model.Channel, error) {
+func GetChannelWithTokenRefresh(ctx context.Context, eid int64, channelType int, modelName string, excludeChannelIds []int64) (*model.Channel, error) {
 	// 获取重试次数
 	retryTimes := config.CHANNEL_RETRY_TIMES
+	excluded := append([]int64{}, excludeChannelIds...)
 
 	var lastErr error
 	for i := retryTimes; i > 0; i-- {
-		// 获取随机渠道
-		channel, err := model.GetRandomChannel(eid, channelType, modelName)
+		// 获取当前最高优先级的渠道
+		channel, err := model.GetPriorityChannel(eid, channelType, modelName, excluded)
 		if err != nil {
 			lastErr = err
-			continue
-		}
-
-		// 避免重复使用上次失败的渠道
-		if channel.ChannelID == lastFailedChannelId {
-			continue
+			break
 		}
 
 		// 检查并刷新token（如果需要）
@@ -35,6 +32,8 @@ func GetChannelWithTokenRefresh(ctx context.Context, eid int64, channelType int,
 			provider, err := model.GetProviderByID(channel.ProviderID, channel.Eid)
 			if err != nil {
 				logger.Errorf(ctx, "refresh token failed: %s", err.Error())
+				lastErr = err
+				excluded = append(excluded, channel.ChannelID)
 				continue
 			}
 			checkProviderType := int(provider.ProviderType)
@@ -47,6 +46,8 @@ func GetChannelWithTokenRefresh(ctx context.Context, eid int64, channelType int,
 				isRefreshToken, err = ser.CheckAndRefreshToken()
 				if err != nil {
 					logger.Errorf(ctx, "refresh token failed: %s", err.Error())
+					lastErr = err
+					excluded = append(excluded, channel.ChannelID)
 					continue
 				}
 			case model.ProviderTypeCozeStudio:
@@ -62,12 +63,15 @@ func GetChannelWithTokenRefresh(ctx context.Context, eid int64, channelType int,
 		// 如果token被刷新，更新渠道信息
 		if isRefreshToken {
 			// update channel key
-			channel, err = model.GetChannelByID(channel.ChannelID)
+			channelId := channel.ChannelID
+			channel, err = model.GetChannelByID(channelId)
 			if err != nil {
 				logger.Errorf(ctx, "refresh token failed: %s", err.Error())
+				lastErr = err
+				excluded = append(excluded, channelId)
 				continue
 			}
-			logger.SysLogf("channel token update success, channel_id=", channel.ChannelID)
+			logger.SysLogf("channel token update success, channel_id=%d", channel.ChannelID)
 		}
 
 		return channel, nil
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/53AI/53AIHub/model"
+	hub_openai "github.com/53AI/53AIHub/service/hub_adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/constant/role"
+)
+
+const (
+	// 每条消息的固定 token 开销（<|start|>{role}\n{content}<|end|>\n）
+	historyTokensPerMessage = 3
+
+	historyRoleUser = "user"
+)
+
+// HistoryMessage 会话历史中的一条消息
+type HistoryMessage struct {
+	Role    string
+	Content string
+}
+
+// HistoryTurn 一轮对话：以 user 消息开始，包含其后的 assistant/tool 等消息
+type HistoryTurn []HistoryMessage
+
+// SplitHistoryTurns 拆分开头的系统消息和之后的对话轮次
+func SplitHistoryTurns(messages []HistoryMessage) (system []HistoryMessage, turns []HistoryTurn) {
+	i := 0
+	for ; i < len(messages) && messages[i].Role == role.System; i++ {
+		system = append(system, messages[i])
+	}
+	for ; i < len(messages); i++ {
+		if messages[i].Role == historyRoleUser || len(turns) == 0 {
+			turns = append(turns, HistoryTurn{})
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], messages[i])
+	}
+	return system, turns
+}
+
+// FlattenHistoryTurns 将轮次展开为消息列表
+func FlattenHistoryTurns(turns []HistoryTurn) []HistoryMessage {
+	var messages []HistoryMessage
+	for _, turn := range turns {
+		messages = append(messages, turn...)
+	}
+	return messages
+}
+
+// CountHistoryTokens 使用模型对应的 tiktoken 编码器计算消息的 token 数
+func CountHistoryTokens(messages []HistoryMessage, modelName string) int {
+	tokens := 0
+	for _, m := range messages {
+		tokens += historyTokensPerMessage + hub_openai.CountTokenText(m.Role, modelName) + hub_openai.CountTokenText(m.Content, modelName)
+	}
+	return tokens
+}
+
+// SelectHistoryTurns 在 token 预算内选择保留的轮次：固定保留最早的 firstTurns 轮，再从最新一轮向前填充。
+// 返回值表示保留 turns[:keepFirst] 和 turns[keepFrom:]，中间的轮次被移出；最新一轮总是保留
+func SelectHistoryTurns(turns []HistoryTurn, budget, firstTurns int, modelName string) (keepFirst, keepFrom int) {
+	if len(turns) == 0 {
+		return 0, 0
+	}
+	if firstTurns > len(turns)-1 {
+		firstTurns = len(turns) - 1
+	}
+	if firstTurns < 0 {
+		firstTurns = 0
+	}
+
+	used := 0
+	for _, turn := range turns[:firstTurns] {
+		used += CountHistoryTokens(turn, modelName)
+	}
+
+	keepFrom = len(turns) - 1
+	used += CountHistoryTokens(turns[keepFrom], modelName)
+	for keepFrom > firstTurns {
+		tokens := CountHistoryTokens(turns[keepFrom-1], modelName)
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		keepFrom--
+	}
+	return firstTurns, keepFrom
+}
+
+// HistoryTurnFingerprint 计算轮次内容指纹，用于定位摘要已覆盖到的位置
+func HistoryTurnFingerprint(turn HistoryTurn) string {
+	h := sha256.New()
+	for _, m := range turn {
+		fmt.Fprintf(h, "%s\x00%s\x00", m.Role, m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FindSummaryAnchor 查找摘要覆盖的最后一轮在 turns 中的位置，未找到时返回 -1
+func FindSummaryAnchor(turns []HistoryTurn, anchor string) int {
+	if anchor == "" {
+		return -1
+	}
+	for i := len(turns) - 1; i >= 0; i-- {
+		if HistoryTurnFingerprint(turns[i]) == anchor {
+			return i
+		}
+	}
+	return -1
+}
+
+// BuildHistorySummaryMessages 构造生成滚动摘要的请求消息：在已有摘要的基础上合并新移出窗口的轮次
+func BuildHistorySummaryMessages(previousSummary string, turns []HistoryTurn, maxTokens int) []HistoryMessage {
+	var transcript strings.Builder
+	if previousSummary != "" {
+		transcript.WriteString("Existing summary:\n")
+		transcript.WriteString(previousSummary)
+		transcript.WriteString("\n\nNew conversation:\n")
+	}
+	for _, m := range FlattenHistoryTurns(turns) {
+		transcript.WriteString(m.Role)
+		transcript.WriteString(": ")
+		transcript.WriteString(m.Content)
+		transcript.WriteString("\n")
+	}
+
+	instruction := fmt.Sprintf("You maintain a rolling summary of a conversation between a user and an assistant. "+
+		"Merge the existing summary (if any) with the new conversation into a single concise summary that keeps facts, "+
+		"user preferences, decisions and open questions needed to continue the conversation. "+
+		"Write in the same language as the conversation and keep it under %d tokens. Output only the summary.", maxTokens)
+	return []HistoryMessage{
+		{Role: role.System, Content: instruction},
+		{Role: historyRoleUser, Content: transcript.String()},
+	}
+}
+
+// HistorySummaryMessage 将摘要包装为系统消息插入到历史之前
+func HistorySummaryMessage(summary string) HistoryMessage {
+	return HistoryMessage{
+		Role:    role.System,
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}
+}
+
+// HistoryFromStoredMessages 根据会话的消息记录重建历史：每条记录取提问中最后一条 user 消息和回答
+func HistoryFromStoredMessages(messages []*model.Message) []HistoryMessage {
+	var history []HistoryMessage
+	for _, message := range messages {
+		question := lastUserContent(message)
+		if question == "" || message.Answer == "" {
+			continue
+		}
+		history = append(history,
+			HistoryMessage{Role: historyRoleUser, Content: question},
+			HistoryMessage{Role: role.Assistant, Content: message.Answer},
+		)
+	}
+	return history
+}
+
+func lastUserContent(message *model.Message) string {
+	parsed, err := message.ParseChatMessage()
+	if err != nil {
+		return ""
+	}
+	for i := len(parsed) - 1; i >= 0; i-- {
+		if r, _ := parsed[i]["role"].(string); r != historyRoleUser {
+			continue
+		}
+		content, _ := parsed[i]["content"].(string)
+		return content
+	}
+	return ""
+}
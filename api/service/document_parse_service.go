@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/common/utils/docparse"
+	"github.com/53AI/53AIHub/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+)
+
+// IsParsableDocument 文件是否为可提取文本的文档类型
+func IsParsableDocument(uploadFile *model.UploadFile) bool {
+	return docparse.Supported(uploadFileExtension(uploadFile))
+}
+
+// ExtractUploadFileText 提取上传文件的文本内容，结果按文件哈希缓存，解析失败的结果同样缓存
+func ExtractUploadFileText(uploadFile *model.UploadFile) (string, error) {
+	ext := uploadFileExtension(uploadFile)
+	if !docparse.Supported(ext) {
+		return "", fmt.Errorf("暂不支持的文件类型: %s", ext)
+	}
+	if uploadFile.Hash != "" {
+		if extraction, err := model.GetDocumentExtraction(uploadFile.Hash, ext); err == nil {
+			if extraction.Error != "" {
+				return "", errors.New(extraction.Error)
+			}
+			return extraction.Content, nil
+		}
+	}
+
+	data, err := storage.StorageInstance.Load(uploadFile.Key)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	text, err := docparse.Extract(ext, data)
+	if err != nil {
+		err = documentParseError(err)
+	}
+	if uploadFile.Hash != "" {
+		extraction := &model.DocumentExtraction{Hash: uploadFile.Hash, Extension: ext, Content: text}
+		if err != nil {
+			extraction.Error = err.Error()
+		}
+		_ = model.SaveDocumentExtraction(extraction)
+	}
+	return text, err
+}
+
+// TruncateTextByTokens 将文本截断到不超过 maxTokens 个 token，返回截断后的文本及是否发生了截断
+func TruncateTextByTokens(text string, maxTokens int, modelName string) (string, bool) {
+	if maxTokens <= 0 {
+		return "", text != ""
+	}
+	tokens := openai.CountTokenText(text, modelName)
+	if tokens <= maxTokens {
+		return text, false
+	}
+	runes := []rune(text)
+	// 按比例估算截断位置，再逐步收缩直到满足上限
+	keep := len(runes) * maxTokens / tokens
+	for keep > 0 {
+		if openai.CountTokenText(string(runes[:keep]), modelName) <= maxTokens {
+			break
+		}
+		keep = keep * 9 / 10
+	}
+	return string(runes[:keep]), true
+}
+
+// uploadFileExtension 小写、不带前导点的扩展名
+func uploadFileExtension(uploadFile *model.UploadFile) string {
+	ext := uploadFile.Extension
+	if ext == "" {
+		ext = filepath.Ext(uploadFile.FileName)
+	}
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+func documentParseError(err error) error {
+	switch {
+	case errors.Is(err, docparse.ErrNoText):
+		return errors.New("文档中没有可提取的文本，可能是扫描件")
+	default:
+		return fmt.Errorf("文档解析失败: %w", err)
+	}
+}
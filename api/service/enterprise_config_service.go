@@ -161,6 +161,15 @@ SaveEnterpriseConfig 保存或更新单个企业配置。
 - 失败返回 error
 */
 func SaveEnterpriseConfig(eid int64, configType string, content string, enabled bool) (*model.EnterpriseConfig, error) {
+	switch configType {
+	case model.EnterpriseConfigTypeRateLimit:
+		defer InvalidateRateLimitConfig(eid)
+	case model.EnterpriseConfigTypeModeration:
+		defer InvalidateModerationConfig(eid)
+	case model.EnterpriseConfigTypePricing:
+		defer InvalidatePricing(eid)
+	}
+
 	var config model.EnterpriseConfig
 	err := model.DB.Where("eid = ? AND type = ?", eid, configType).First(&config).Error
 
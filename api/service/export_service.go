@@ -0,0 +1,540 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/common/utils/spreadsheet"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+)
+
+// exportBatchSize 每批读取的记录数，每批写完后更新一次进度
+const exportBatchSize = 500
+
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// ErrExportQueueFull 导出任务排队已满
+var ErrExportQueueFull = errors.New("too many export jobs in queue, please try again later")
+
+var (
+	exportJobQueue    chan *model.ExportJob
+	exportJobPoolOnce sync.Once
+)
+
+// startExportJobPool 启动导出任务执行池
+func startExportJobPool() {
+	exportJobPoolOnce.Do(func() {
+		workers := int(config.EXPORT_JOB_WORKERS)
+		if workers <= 0 {
+			workers = 1
+		}
+		exportJobQueue = make(chan *model.ExportJob, config.EXPORT_JOB_QUEUE_SIZE)
+		for i := 0; i < workers; i++ {
+			go func() {
+				for job := range exportJobQueue {
+					processExportJob(job)
+				}
+			}()
+		}
+		logger.SysLogf("导出任务执行池已启动 - 并发数: %d, 队列长度: %d", workers, config.EXPORT_JOB_QUEUE_SIZE)
+	})
+}
+
+// EnqueueExportJob 将导出任务加入后台队列，队列已满时返回 ErrExportQueueFull
+func EnqueueExportJob(job *model.ExportJob) error {
+	startExportJobPool()
+	select {
+	case exportJobQueue <- job:
+		return nil
+	default:
+		return ErrExportQueueFull
+	}
+}
+
+func processExportJob(job *model.ExportJob) {
+	ok, err := model.StartExportJob(job)
+	if err != nil {
+		logger.SysErrorf("开始导出任务 %d 失败: %v", job.ID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if err := runExportJob(job); err != nil {
+		logger.SysErrorf("导出任务 %d 失败: %v", job.ID, err)
+		job.Status = model.ExportJobStatusFailed
+		job.ErrorMessage = err.Error()
+	} else {
+		job.Status = model.ExportJobStatusSucceeded
+		job.ExpiredTime = time.Now().Add(time.Duration(config.EXPORT_FILE_TTL) * time.Second).UTC().UnixMilli()
+	}
+
+	finished, err := model.FinishExportJob(job)
+	if err != nil {
+		logger.SysErrorf("保存导出任务 %d 结果失败: %v", job.ID, err)
+	}
+	// 任务在执行期间被删除时，清理已保存的文件
+	if !finished && job.FileKey != "" {
+		if err := storage.StorageInstance.Delete(job.FileKey); err != nil {
+			logger.SysErrorf("删除导出文件 %s 失败: %v", job.FileKey, err)
+		}
+	}
+}
+
+// runExportJob 将数据写入临时文件，完成后保存到存储中
+func runExportJob(job *model.ExportJob) error {
+	filters, err := job.GetFilters()
+	if err != nil {
+		return err
+	}
+	location := model.GetEnterpriseLocation(job.Eid)
+
+	tmp, err := os.CreateTemp("", "export-*."+job.Format)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	writer, err := spreadsheet.NewWriter(job.Format, tmp)
+	if err != nil {
+		return err
+	}
+	exporter := &dataExporter{
+		job:      job,
+		filters:  filters,
+		location: location,
+		writer:   writer,
+		names:    newExportNames(job.Eid),
+	}
+	if err := exporter.run(); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	// 存储接口只支持整体保存，数据在写入阶段已分批落盘，这里一次性读出
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	job.FileName = fmt.Sprintf("%s_%s.%s", job.Type, time.Now().In(location).Format("20060102_150405"), job.Format)
+	job.FileKey = model.GetFileKey(fmt.Sprintf("exports/%d_%s", job.ID, job.FileName), job.Eid, job.UserID)
+	if err := storage.StorageInstance.Save(data, job.FileKey); err != nil {
+		job.FileKey = ""
+		return err
+	}
+	job.FileSize = int64(len(data))
+	return nil
+}
+
+// ExportDownloadSignature 计算下载链接签名，签名内容为任务ID和过期时间（秒）
+func ExportDownloadSignature(job *model.ExportJob, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(job.DownloadSecret))
+	fmt.Fprintf(mac, "%d.%d", job.ID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyExportDownload 校验下载链接签名和有效期
+func VerifyExportDownload(job *model.ExportJob, expires int64, signature string) bool {
+	if job.DownloadSecret == "" || expires < time.Now().Unix() {
+		return false
+	}
+	return hmac.Equal([]byte(ExportDownloadSignature(job, expires)), []byte(signature))
+}
+
+// GetExportDownloadURL 生成限时下载链接，有效期不超过导出文件的过期时间；任务未成功时返回空
+func GetExportDownloadURL(job *model.ExportJob) (string, int64) {
+	if job.Status != model.ExportJobStatusSucceeded || job.FileKey == "" {
+		return "", 0
+	}
+	expires := time.Now().Add(time.Duration(config.EXPORT_LINK_TTL) * time.Second).Unix()
+	if fileExpires := job.ExpiredTime / 1000; fileExpires > 0 && fileExpires < expires {
+		expires = fileExpires
+	}
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", ExportDownloadSignature(job, expires))
+	return fmt.Sprintf("%sapi/exports/%d/download?%s", config.GetApiHost(), job.ID, query.Encode()), expires
+}
+
+// DeleteExportFile 删除任务的导出文件
+func DeleteExportFile(job *model.ExportJob) error {
+	if job.FileKey == "" || !storage.StorageInstance.Exists(job.FileKey) {
+		return nil
+	}
+	return storage.StorageInstance.Delete(job.FileKey)
+}
+
+type dataExporter struct {
+	job      *model.ExportJob
+	filters  model.ExportFilters
+	location *time.Location
+	writer   spreadsheet.Writer
+	names    *exportNames
+}
+
+func (e *dataExporter) run() error {
+	userIDs, matched, err := e.resolveUsers()
+	if err != nil {
+		return err
+	}
+	query := model.ExportQuery{
+		Eid:       e.job.Eid,
+		StartTime: e.filters.StartTime,
+		EndTime:   e.filters.EndTime,
+		AgentID:   e.filters.AgentID,
+		UserIDs:   userIDs,
+	}
+
+	switch e.job.Type {
+	case model.ExportTypeMessages:
+		return e.exportMessages(query, matched)
+	case model.ExportTypeConversations:
+		return e.exportConversations(query, matched)
+	case model.ExportTypeOrders:
+		return e.exportOrders(query, matched)
+	case model.ExportTypeUsage:
+		return e.exportUsage(query, matched)
+	}
+	return errors.New("invalid export type")
+}
+
+// resolveUsers 将用户和部门筛选转换为用户ID列表，matched 为 false 表示没有符合条件的用户
+func (e *dataExporter) resolveUsers() ([]int64, bool, error) {
+	if len(e.filters.DepartmentIDs) == 0 {
+		if e.filters.UserID > 0 {
+			return []int64{e.filters.UserID}, true, nil
+		}
+		return nil, true, nil
+	}
+
+	userIDs, err := model.GetUsersByDepartmentIDs(e.job.Eid, e.filters.DepartmentIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	if e.filters.UserID > 0 {
+		for _, id := range userIDs {
+			if id == e.filters.UserID {
+				return []int64{id}, true, nil
+			}
+		}
+		return nil, false, nil
+	}
+	return userIDs, len(userIDs) > 0, nil
+}
+
+func (e *dataExporter) progress(processed, total int64) {
+	e.job.ProcessedRows = processed
+	e.job.TotalRows = total
+	if err := model.UpdateExportJobProgress(e.job.ID, processed, total); err != nil {
+		logger.SysErrorf("更新导出任务 %d 进度失败: %v", e.job.ID, err)
+	}
+}
+
+func (e *dataExporter) formatTime(ms int64) string {
+	if ms <= 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).In(e.location).Format(exportTimeLayout)
+}
+
+func (e *dataExporter) exportMessages(query model.ExportQuery, matched bool) error {
+	if err := e.writer.WriteRow("id", "created_time", "conversation_id", "agent_id", "agent_name",
+		"user_id", "user_name", "model_name", "channel_id", "channel_name", "question", "answer",
+		"prompt_tokens", "completion_tokens", "total_tokens", "quota", "cost", "elapsed_time_ms",
+		"is_stream", "is_cached", "is_error"); err != nil {
+		return err
+	}
+	if !matched {
+		return nil
+	}
+	total, err := model.CountMessagesForExport(query)
+	if err != nil {
+		return err
+	}
+	e.progress(0, total)
+
+	var processed, lastID int64
+	for {
+		messages, err := model.GetMessagesForExport(query, lastID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		userIDs := make([]int64, len(messages))
+		for i, message := range messages {
+			userIDs[i] = message.UserID
+		}
+		e.names.loadUsers(userIDs)
+
+		for _, m := range messages {
+			if err := e.writer.WriteRow(m.ID, e.formatTime(m.CreatedTime), m.ConversationID,
+				m.AgentID, e.names.agent(m.AgentID), m.UserID, e.names.user(m.UserID),
+				m.ModelName, m.ChannelId, e.names.channel(int64(m.ChannelId)), m.Message, m.Answer,
+				m.PromptTokens, m.CompletionTokens, m.TotalTokens, m.Quota, m.Cost, m.ElapsedTime,
+				m.IsStream, m.IsCached, m.IsError); err != nil {
+				return err
+			}
+		}
+		processed += int64(len(messages))
+		e.progress(processed, max(total, processed))
+		if len(messages) < exportBatchSize {
+			return nil
+		}
+		lastID = messages[len(messages)-1].ID
+	}
+}
+
+func (e *dataExporter) exportConversations(query model.ExportQuery, matched bool) error {
+	if err := e.writer.WriteRow("conversation_id", "created_time", "updated_time", "agent_id", "agent_name",
+		"user_id", "user_name", "title", "status", "model", "total_tokens", "quota", "deleted_time"); err != nil {
+		return err
+	}
+	if !matched {
+		return nil
+	}
+	total, err := model.CountConversationsForExport(query)
+	if err != nil {
+		return err
+	}
+	e.progress(0, total)
+
+	var processed, lastID int64
+	for {
+		conversations, err := model.GetConversationsForExport(query, lastID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		userIDs := make([]int64, len(conversations))
+		for i, conversation := range conversations {
+			userIDs[i] = conversation.UserID
+		}
+		e.names.loadUsers(userIDs)
+
+		for _, c := range conversations {
+			if err := e.writer.WriteRow(c.ConversationID, e.formatTime(c.CreatedTime), e.formatTime(c.UpdatedTime),
+				c.AgentID, e.names.agent(c.AgentID), c.UserID, e.names.user(c.UserID), c.Title,
+				conversationStatusName(c), c.Model, c.TotalTokens, c.Quota, e.formatTime(c.DeletedTime)); err != nil {
+				return err
+			}
+		}
+		processed += int64(len(conversations))
+		e.progress(processed, max(total, processed))
+		if len(conversations) < exportBatchSize {
+			return nil
+		}
+		lastID = conversations[len(conversations)-1].ConversationID
+	}
+}
+
+func (e *dataExporter) exportOrders(query model.ExportQuery, matched bool) error {
+	if err := e.writer.WriteRow("id", "order_id", "created_time", "user_id", "nickname", "subscription_name",
+		"duration", "time_unit", "currency", "amount", "pay_type", "status", "transaction_id",
+		"pay_time", "expired_time"); err != nil {
+		return err
+	}
+	if !matched {
+		return nil
+	}
+	total, err := model.CountOrdersForExport(query)
+	if err != nil {
+		return err
+	}
+	e.progress(0, total)
+
+	var processed, lastID int64
+	for {
+		orders, err := model.GetOrdersForExport(query, lastID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, o := range orders {
+			// 金额以分存储，导出为元
+			if err := e.writer.WriteRow(o.ID, o.OrderId, e.formatTime(o.CreatedTime), o.UserID, o.Nickname,
+				o.SubscriptionName, o.Duration, o.TimeUnit, o.Currency, float64(o.Amount)/100,
+				orderPayTypeName(o.PayType), orderStatusName(o.Status), o.TransactionId,
+				e.formatTime(o.PayTime), e.formatTime(o.ExpiredTime)); err != nil {
+				return err
+			}
+		}
+		processed += int64(len(orders))
+		e.progress(processed, max(total, processed))
+		if len(orders) < exportBatchSize {
+			return nil
+		}
+		lastID = orders[len(orders)-1].ID
+	}
+}
+
+func (e *dataExporter) exportUsage(query model.ExportQuery, matched bool) error {
+	if err := e.writer.WriteRow("bucket", "group_key", "name", "calls", "errors", "prompt_tokens",
+		"completion_tokens", "total_tokens", "quota", "avg_latency_ms", "p95_latency_ms"); err != nil {
+		return err
+	}
+	if !matched {
+		return nil
+	}
+	interval := e.filters.Interval
+	if interval == "" {
+		interval = UsageIntervalDay
+	}
+	stats, err := GetUsageStats(e.job.Eid, model.UsageRollupQuery{
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+		AgentID:   query.AgentID,
+		UserIDs:   query.UserIDs,
+	}, interval, e.filters.GroupBy, e.location)
+	if err != nil {
+		return err
+	}
+	total := int64(len(stats))
+	e.progress(0, total)
+
+	for i, s := range stats {
+		if err := e.writer.WriteRow(s.Bucket, s.GroupKey, s.Name, s.Calls, s.Errors, s.PromptTokens,
+			s.CompletionTokens, s.TotalTokens, s.Quota, s.AvgLatency, s.P95Latency); err != nil {
+			return err
+		}
+		if (i+1)%exportBatchSize == 0 {
+			e.progress(int64(i+1), total)
+		}
+	}
+	e.progress(total, total)
+	return nil
+}
+
+func conversationStatusName(c *model.Conversation) string {
+	if c.DeletedTime > 0 {
+		return "deleted"
+	}
+	switch c.Status {
+	case model.ConversationStatusActive:
+		return "active"
+	case model.ConversationStatusArchived:
+		return "archived"
+	case model.ConversationStatusDeleted:
+		return "deleted"
+	}
+	return strconv.Itoa(c.Status)
+}
+
+func orderStatusName(status int) string {
+	switch status {
+	case model.OrderStatusConfirming:
+		return "confirming"
+	case model.OrderStatusPending:
+		return "pending"
+	case model.OrderStatusPaid:
+		return "paid"
+	case model.OrderStatusExpired:
+		return "expired"
+	case model.OrderStatusClosed:
+		return "closed"
+	}
+	return strconv.Itoa(status)
+}
+
+func orderPayTypeName(payType int) string {
+	switch payType {
+	case model.PayTypeWechat:
+		return "wechat"
+	case model.PayTypeManual:
+		return "manual"
+	case model.PayTypePaypal:
+		return "paypal"
+	case model.PayTypeAlipay:
+		return "alipay"
+	}
+	return strconv.Itoa(payType)
+}
+
+// exportNames 缓存导出过程中用到的智能体、用户和渠道名称
+type exportNames struct {
+	eid      int64
+	agents   map[int64]string
+	users    map[int64]string
+	channels map[int64]string
+}
+
+func newExportNames(eid int64) *exportNames {
+	return &exportNames{
+		eid:      eid,
+		agents:   make(map[int64]string),
+		users:    make(map[int64]string),
+		channels: make(map[int64]string),
+	}
+}
+
+func (n *exportNames) agent(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	name, ok := n.agents[id]
+	if !ok {
+		if agent, err := model.GetAgentByID(n.eid, id); err == nil {
+			name = agent.Name
+		}
+		n.agents[id] = name
+	}
+	return name
+}
+
+func (n *exportNames) channel(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	name, ok := n.channels[id]
+	if !ok {
+		if channel, err := model.GetChannelByID(id); err == nil && channel.Eid == n.eid {
+			name = channel.Name
+		}
+		n.channels[id] = name
+	}
+	return name
+}
+
+// loadUsers 批量加载未缓存的用户名称
+func (n *exportNames) loadUsers(ids []int64) {
+	var missing []int64
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if _, ok := n.users[id]; !ok && id > 0 && !seen[id] {
+			seen[id] = true
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	users, err := model.GetUsersByIDs(n.eid, missing)
+	if err != nil {
+		return
+	}
+	for _, id := range missing {
+		n.users[id] = ""
+	}
+	for _, user := range users {
+		name := user.Nickname
+		if name == "" {
+			name = user.Username
+		}
+		n.users[user.UserID] = name
+	}
+}
+
+func (n *exportNames) user(id int64) string {
+	return n.users[id]
+}
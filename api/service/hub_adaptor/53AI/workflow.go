@@ -32,6 +32,7 @@ func (rc *readCloser) Close() error {
 type AI53WorkflowAdaptor struct {
 	meta         *meta.Meta
 	CustomConfig *custom.CustomConfig
+	EventHandler custom.WorkflowEventHandler // 流式模式下的事件回调
 }
 
 // AI53WorkflowRequest 53AI 工作流请求结构
@@ -587,19 +588,44 @@ scanLoop:
 		case "workflow_started":
 			logger.SysLogf("53AI工作流开始执行 - TaskID: %s", event.TaskID)
 
+		case "node_started":
+			nodeID, _ := event.Data["node_id"].(string)
+			title, _ := event.Data["title"].(string)
+			a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+				Event:     custom.WorkflowEventNodeStarted,
+				ExecuteID: event.TaskID,
+				NodeID:    nodeID,
+				NodeTitle: title,
+			})
+
 		case "text_chunk":
 			// 收集文本块
 			if text, ok := event.Data["text"].(string); ok {
 				textChunks = append(textChunks, text)
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event:     custom.WorkflowEventTextChunk,
+					ExecuteID: event.TaskID,
+					Text:      text,
+				})
 			}
 
 		case "node_finished":
 			// 检查是否有输出
-			if outputs, ok := event.Data["outputs"].(map[string]interface{}); ok {
-				if len(outputs) > 0 {
-					finalOutputs = outputs
-				}
+			outputs, _ := event.Data["outputs"].(map[string]interface{})
+			if len(outputs) > 0 {
+				finalOutputs = outputs
 			}
+			nodeID, _ := event.Data["node_id"].(string)
+			title, _ := event.Data["title"].(string)
+			status, _ := event.Data["status"].(string)
+			a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+				Event:     custom.WorkflowEventNodeFinished,
+				ExecuteID: event.TaskID,
+				NodeID:    nodeID,
+				NodeTitle: title,
+				Status:    status,
+				Outputs:   outputs,
+			})
 
 		case "workflow_finished":
 			logger.SysLogf("53AI工作流执行完成")
@@ -1,11 +1,13 @@
 package coze
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -22,6 +24,7 @@ import (
 type WorkflowAdaptor struct {
 	meta         *meta.Meta
 	CustomConfig *custom.CustomConfig
+	EventHandler custom.WorkflowEventHandler // 设置后使用 stream_run 接口流式执行
 }
 
 func (a *WorkflowAdaptor) Init(meta *meta.Meta) {
@@ -33,6 +36,9 @@ func (a *WorkflowAdaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if a.EventHandler != nil {
+		return fmt.Sprintf("%s/v1/workflow/stream_run", baseUrl), nil
+	}
 	return fmt.Sprintf("%s/v1/workflow/run", baseUrl), nil
 }
 
@@ -518,3 +524,117 @@ func (a *WorkflowAdaptor) parseWorkflowOutputData(rawData interface{}) map[strin
 	logger.SysLogf("Coze工作流数据转换为默认格式，输出字段数: %d", len(result))
 	return result
 }
+
+// WorkflowStreamMessage Coze 工作流流式 Message 事件数据
+type WorkflowStreamMessage struct {
+	Content      string `json:"content"`
+	NodeTitle    string `json:"node_title"`
+	NodeSeqID    string `json:"node_seq_id"`
+	NodeIsFinish bool   `json:"node_is_finish"`
+	NodeID       string `json:"node_id"`
+	NodeType     string `json:"node_type"`
+}
+
+// WorkflowStreamError Coze 工作流流式 Error 事件数据
+type WorkflowStreamError struct {
+	ErrorCode    int    `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// ProcessStreamResponse 处理 Coze 工作流 stream_run 的 SSE 响应
+// Coze 只推送输出节点（消息节点、结束节点）的内容，最后一个输出节点的完整内容作为工作流输出
+func (a *WorkflowAdaptor) ProcessStreamResponse(resp *http.Response) (*custom.WorkflowResponseData, error) {
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	var eventType string
+	var executeID string
+	var currentNode string
+	var nodeContent strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event:") {
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		switch eventType {
+		case "Message":
+			var msg WorkflowStreamMessage
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				logger.SysErrorf("解析Coze工作流事件失败: %v, 数据: %s", err, data)
+				continue
+			}
+			nodeKey := msg.NodeID + msg.NodeTitle
+			if nodeKey != currentNode {
+				currentNode = nodeKey
+				nodeContent.Reset()
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event:     custom.WorkflowEventNodeStarted,
+					NodeID:    msg.NodeID,
+					NodeTitle: msg.NodeTitle,
+				})
+			}
+			nodeContent.WriteString(msg.Content)
+			if msg.Content != "" {
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event:     custom.WorkflowEventTextChunk,
+					NodeID:    msg.NodeID,
+					NodeTitle: msg.NodeTitle,
+					Text:      msg.Content,
+				})
+			}
+			if msg.NodeIsFinish {
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event:     custom.WorkflowEventNodeFinished,
+					NodeID:    msg.NodeID,
+					NodeTitle: msg.NodeTitle,
+					Status:    "succeeded",
+				})
+			}
+
+		case "Error":
+			var streamErr WorkflowStreamError
+			if err := json.Unmarshal([]byte(data), &streamErr); err != nil {
+				return nil, fmt.Errorf("工作流执行失败: %s", data)
+			}
+			logger.SysErrorf("Coze工作流执行失败 - Code: %d, Msg: %s", streamErr.ErrorCode, streamErr.ErrorMessage)
+			return nil, fmt.Errorf("工作流执行失败 - Code: %d, Msg: %s", streamErr.ErrorCode, streamErr.ErrorMessage)
+
+		case "Interrupt":
+			return nil, errors.New("工作流执行中断，暂不支持需要人工交互的工作流")
+
+		case "Done":
+			var done struct {
+				DebugURL string `json:"debug_url"`
+			}
+			if err := json.Unmarshal([]byte(data), &done); err == nil && done.DebugURL != "" {
+				if u, err := url.Parse(done.DebugURL); err == nil {
+					executeID = u.Query().Get("execute_id")
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取Coze工作流响应流失败: %v", err)
+	}
+
+	responseData := &custom.WorkflowResponseData{
+		WorkflowOutputData: a.parseWorkflowOutputData(nodeContent.String()),
+		ExecuteID:          executeID,
+	}
+
+	logger.SysLogf("Coze工作流流式响应处理完成 - ExecuteID: %s, 输出字段数: %d",
+		executeID, len(responseData.WorkflowOutputData))
+
+	return responseData, nil
+}
@@ -5,6 +5,7 @@ type CustomConfig struct {
 	ConversationId             string                   `json:"conversation_id,omitempty"`
 	ConversationExpirationTime int64                    `json:"conversation_expire,omitempty"`
 	AIHubConversationId        int64                    `json:"53AIHub_conversation_id,omitempty"`
+	MessageId                  string                   `json:"message_id,omitempty"`      // 上游平台返回的消息ID，用于同步回答反馈
 	WorkflowParams             map[string]WorkflowParam `json:"workflow_params,omitempty"` // 工作流参数配置
 }
 
@@ -16,3 +16,35 @@ type WorkflowAdaptorResponse interface {
 	GetOutputData() interface{}
 	GetErrorMessage() string
 }
+
+// 工作流流式事件类型，各平台的原始事件统一归一化为以下几种
+const (
+	WorkflowEventNodeStarted      = "node_started"
+	WorkflowEventNodeFinished     = "node_finished"
+	WorkflowEventTextChunk        = "text_chunk"
+	WorkflowEventWorkflowFinished = "workflow_finished"
+	WorkflowEventError            = "error"
+)
+
+// WorkflowStreamEvent 工作流流式事件
+type WorkflowStreamEvent struct {
+	Event     string                 `json:"event"`                // 事件类型
+	ExecuteID string                 `json:"execute_id,omitempty"` // 执行ID
+	NodeID    string                 `json:"node_id,omitempty"`    // 节点ID
+	NodeTitle string                 `json:"node_title,omitempty"` // 节点名称
+	Status    string                 `json:"status,omitempty"`     // 节点执行状态
+	Text      string                 `json:"text,omitempty"`       // 文本片段
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`    // 节点输出
+	Data      *WorkflowResponseData  `json:"data,omitempty"`       // 工作流最终结果，仅 workflow_finished 事件携带
+	Error     string                 `json:"error,omitempty"`      // 错误信息，仅 error 事件携带
+}
+
+// WorkflowEventHandler 工作流流式事件回调，为 nil 时适配器按阻塞模式执行
+type WorkflowEventHandler func(event *WorkflowStreamEvent)
+
+// Emit 调用事件回调，回调为空时忽略
+func (h WorkflowEventHandler) Emit(event *WorkflowStreamEvent) {
+	if h != nil {
+		h(event)
+	}
+}
@@ -152,11 +152,11 @@ func (a *Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error)
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) (usage *model.Usage, err *model.ErrorWithStatusCode) {
 	var responseText *string
-	var channelConversationId string
+	var channelConversationId, channelMessageId string
 	if meta.IsStream {
-		err, responseText, channelConversationId = StreamHandler(c, resp)
+		err, responseText, channelConversationId, channelMessageId = StreamHandler(c, resp)
 	} else {
-		err, responseText, channelConversationId = Handler(c, resp, meta.PromptTokens, meta.ActualModelName)
+		err, responseText, channelConversationId, channelMessageId = Handler(c, resp, meta.PromptTokens, meta.ActualModelName)
 	}
 	if responseText != nil {
 		usage = openai.ResponseText2Usage(*responseText, meta.ActualModelName, meta.PromptTokens)
@@ -166,30 +166,31 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 	usage.PromptTokens = meta.PromptTokens
 	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	a.CustomConfig.ConversationId = channelConversationId
+	a.CustomConfig.MessageId = channelMessageId
 	return
 }
 
-func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName string) (*model.ErrorWithStatusCode, *string, string) {
+func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName string) (*model.ErrorWithStatusCode, *string, string, string) {
 	channelConversationId := ""
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return openai.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil, channelConversationId
+		return openai.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil, channelConversationId, ""
 	}
 	err = resp.Body.Close()
 	if err != nil {
-		return openai.ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil, channelConversationId
+		return openai.ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil, channelConversationId, ""
 	}
 	var difyResponse BlockResponse
 	err = json.Unmarshal(responseBody, &difyResponse)
 	if err != nil {
-		return openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil, channelConversationId
+		return openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil, channelConversationId, ""
 	}
 
 	fullTextResponse := ResponseDify2OpenAI(&difyResponse)
 	fullTextResponse.Model = modelName
 	jsonResponse, err := json.Marshal(fullTextResponse)
 	if err != nil {
-		return openai.ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil, channelConversationId
+		return openai.ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil, channelConversationId, ""
 	}
 	c.Writer.Header().Set("Content-Type", "application/json")
 	c.Writer.WriteHeader(resp.StatusCode)
@@ -199,7 +200,7 @@ func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName st
 		responseText = fullTextResponse.Choices[0].Message.StringContent()
 	}
 	channelConversationId = difyResponse.ConversationID
-	return nil, &responseText, channelConversationId
+	return nil, &responseText, channelConversationId, difyResponse.MessageID
 }
 
 func ResponseDify2OpenAI(difyResponse *BlockResponse) *openai.TextResponse {
@@ -0,0 +1,62 @@
+package dify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/53AI/53AIHub/service/hub_adaptor/custom"
+	"github.com/songquanpeng/one-api/relay/meta"
+)
+
+// 反馈评价，撤销时 rating 为 null
+const (
+	FeedbackRatingLike    = "like"
+	FeedbackRatingDislike = "dislike"
+)
+
+type FeedbackRequest struct {
+	Rating  *string `json:"rating"`
+	User    string  `json:"user"`
+	Content string  `json:"content,omitempty"`
+}
+
+// SendMessageFeedback 调用 DIFY 消息反馈接口，rating 为空表示撤销
+func SendMessageFeedback(meta *meta.Meta, messageID, user, rating, content string) error {
+	baseUrl, err := custom.GetBaseURL(meta.BaseURL)
+	if err != nil {
+		return err
+	}
+	request := FeedbackRequest{User: user, Content: content}
+	if rating != "" {
+		request.Rating = &rating
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v1/messages/%s/feedbacks", baseUrl, messageID)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+meta.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -15,7 +15,7 @@ import (
 	"github.com/songquanpeng/one-api/relay/model"
 )
 
-func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCode, *string, string) {
+func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCode, *string, string, string) {
 	var responseText string
 	createdTime := helper.GetTimestamp()
 	scanner := bufio.NewScanner(resp.Body)
@@ -27,6 +27,7 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 	var modelName string
 
 	channelConversationId := ""
+	channelMessageId := ""
 	for scanner.Scan() {
 		data := scanner.Text()
 		if len(data) < 5 || !strings.HasPrefix(data, "data:") {
@@ -56,6 +57,9 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 			logger.SysError(err.Error())
 		}
 		channelConversationId = difyResponse.ConversationID
+		if difyResponse.MessageID != "" {
+			channelMessageId = difyResponse.MessageID
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -66,10 +70,10 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 
 	err := resp.Body.Close()
 	if err != nil {
-		return openai.ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil, channelConversationId
+		return openai.ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil, channelConversationId, channelMessageId
 	}
 
-	return nil, &responseText, channelConversationId
+	return nil, &responseText, channelConversationId, channelMessageId
 }
 
 func StreamResponseDifyOpenAI(difyResponse *StreamResponse) (*openai.ChatCompletionsStreamResponse, *Response) {
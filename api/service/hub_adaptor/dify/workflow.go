@@ -23,6 +23,7 @@ import (
 type DifyWorkflowAdaptor struct {
 	meta         *meta.Meta
 	CustomConfig *custom.CustomConfig
+	EventHandler custom.WorkflowEventHandler // 流式模式下的事件回调
 }
 
 // DifyWorkflowRequest DIFY 工作流请求结构
@@ -403,24 +404,40 @@ scanLoop:
 			logger.SysLogf("DIFY工作流开始执行 - WorkflowRunID: %s", event.WorkflowRunID)
 
 		case "node_started":
-			if nodeID, ok := event.Data["node_id"].(string); ok {
-				if title, ok := event.Data["title"].(string); ok {
-					logger.SysLogf("DIFY节点开始执行 - NodeID: %s, Title: %s", nodeID, title)
-				}
-			}
+			nodeID, _ := event.Data["node_id"].(string)
+			title, _ := event.Data["title"].(string)
+			logger.SysLogf("DIFY节点开始执行 - NodeID: %s, Title: %s", nodeID, title)
+			a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+				Event:     custom.WorkflowEventNodeStarted,
+				ExecuteID: event.WorkflowRunID,
+				NodeID:    nodeID,
+				NodeTitle: title,
+			})
 
 		case "text_chunk":
 			if text, ok := event.Data["text"].(string); ok {
 				textChunks = append(textChunks, text)
-				// logger.SysLogf("%s", text)
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event:     custom.WorkflowEventTextChunk,
+					ExecuteID: event.WorkflowRunID,
+					Text:      text,
+				})
 			}
 
 		case "node_finished":
-			if nodeID, ok := event.Data["node_id"].(string); ok {
-				if status, ok := event.Data["status"].(string); ok {
-					logger.SysLogf("DIFY节点执行完成 - NodeID: %s, Status: %s", nodeID, status)
-				}
-			}
+			nodeID, _ := event.Data["node_id"].(string)
+			title, _ := event.Data["title"].(string)
+			status, _ := event.Data["status"].(string)
+			outputs, _ := event.Data["outputs"].(map[string]interface{})
+			logger.SysLogf("DIFY节点执行完成 - NodeID: %s, Status: %s", nodeID, status)
+			a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+				Event:     custom.WorkflowEventNodeFinished,
+				ExecuteID: event.WorkflowRunID,
+				NodeID:    nodeID,
+				NodeTitle: title,
+				Status:    status,
+				Outputs:   outputs,
+			})
 
 		case "workflow_finished":
 			logger.SysLogf("DIFY工作流执行完成")
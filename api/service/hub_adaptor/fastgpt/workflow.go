@@ -1,6 +1,7 @@
 package fastgpt
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -22,6 +23,7 @@ type FastGPTWorkflowAdaptor struct {
 	openai.Adaptor // 继承 OpenAI 适配器的文件处理能力
 	meta           *meta.Meta
 	CustomConfig   *custom.CustomConfig
+	EventHandler   custom.WorkflowEventHandler // 设置后以流式模式执行
 }
 
 // FastGPTWorkflowRequest FastGPT 工作流请求结构
@@ -93,8 +95,8 @@ func (a *FastGPTWorkflowAdaptor) ConvertWorkflowToWorkflowRequest(parameters map
 
 	workflowRequest := &FastGPTWorkflowRequest{
 		Variables: processedParams,
-		Stream:    false, // 工作流默认非流式
-		Detail:    true,  // 工作流默认详细模式
+		Stream:    a.EventHandler != nil, // 工作流默认非流式，设置了事件回调时流式执行
+		Detail:    true,                  // 工作流默认详细模式
 	}
 
 	logger.SysLogf("🔄 FastGPT工作流请求转换完成 - 参数数量: %d", len(workflowRequest.Variables))
@@ -282,6 +284,113 @@ func (a *FastGPTWorkflowAdaptor) ProcessWorkflowResponse(resp *http.Response) (*
 	return workflowResponse, nil
 }
 
+// ProcessWorkflowStreamResponse 处理 FastGPT 工作流的流式响应（detail 模式）
+func (a *FastGPTWorkflowAdaptor) ProcessWorkflowStreamResponse(resp *http.Response) (*custom.WorkflowResponseData, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求失败，状态码: %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	var eventType string
+	var responseData []ModuleResponse
+	var textChunks []string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event:") {
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+
+		switch eventType {
+		case "flowNodeStatus":
+			var status struct {
+				Status string `json:"status"`
+				Name   string `json:"name"`
+			}
+			if err := json.Unmarshal([]byte(data), &status); err == nil {
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event:     custom.WorkflowEventNodeStarted,
+					NodeTitle: status.Name,
+					Status:    status.Status,
+				})
+			}
+
+		case "answer", "fastAnswer":
+			var chunk openai.ChatCompletionsStreamResponse
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				continue
+			}
+			for _, choice := range chunk.Choices {
+				text, _ := choice.Delta.Content.(string)
+				if text == "" {
+					continue
+				}
+				textChunks = append(textChunks, text)
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event: custom.WorkflowEventTextChunk,
+					Text:  text,
+				})
+			}
+
+		case "flowResponses":
+			if err := json.Unmarshal([]byte(data), &responseData); err != nil {
+				logger.SysErrorf("解析FastGPT工作流节点响应失败: %v", err)
+				continue
+			}
+			for _, module := range responseData {
+				a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+					Event:     custom.WorkflowEventNodeFinished,
+					NodeID:    module.NodeId,
+					NodeTitle: module.ModuleName,
+					Status:    "succeeded",
+					Outputs:   module.PluginOutput,
+				})
+			}
+
+		case "error":
+			var streamErr struct {
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal([]byte(data), &streamErr); err == nil && streamErr.Message != "" {
+				return nil, fmt.Errorf("工作流执行失败: %s", streamErr.Message)
+			}
+			return nil, fmt.Errorf("工作流执行失败: %s", data)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取FastGPT工作流响应流失败: %v", err)
+	}
+
+	workflowOutput := a.extractWorkflowOutput(responseData)
+	if len(textChunks) > 0 {
+		workflowOutput["text"] = strings.Join(textChunks, "")
+	}
+
+	workflowResponse := &custom.WorkflowResponseData{
+		ExecuteID:          fmt.Sprintf("fastgpt_workflow_%d", a.meta.ChannelId),
+		WorkflowOutputData: workflowOutput,
+		ModelName:          a.meta.ActualModelName,
+		ChannelID:          a.meta.ChannelId,
+	}
+
+	logger.SysLogf("✅ FastGPT工作流流式响应处理完成 - 输出字段数: %d", len(workflowOutput))
+	return workflowResponse, nil
+}
+
 // extractWorkflowOutput 从响应数据中提取工作流输出
 func (a *FastGPTWorkflowAdaptor) extractWorkflowOutput(responseData []ModuleResponse) map[string]interface{} {
 	workflowOutput := make(map[string]interface{})
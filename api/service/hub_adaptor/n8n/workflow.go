@@ -1,6 +1,7 @@
 package n8n
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
@@ -21,6 +22,7 @@ import (
 type N8nWorkflowAdaptor struct {
 	meta         *meta.Meta
 	CustomConfig *custom.CustomConfig
+	EventHandler custom.WorkflowEventHandler // 流式模式下的事件回调
 }
 
 func (a *N8nWorkflowAdaptor) Init(meta *meta.Meta) {
@@ -272,8 +274,17 @@ func (a *N8nWorkflowAdaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestB
 func (a *N8nWorkflowAdaptor) ProcessResponse(resp *http.Response) (*custom.WorkflowResponseData, error) {
 	defer resp.Body.Close()
 
+	reader := bufio.NewReader(resp.Body)
+	if a.EventHandler != nil {
+		// Webhook 开启 Streaming 响应时返回逐行 JSON 分块，否则按普通响应处理
+		firstLine, _ := reader.Peek(peekSize(reader))
+		if isN8nStreamChunk(firstLine) {
+			return a.processStreamResponse(reader)
+		}
+	}
+
 	// 读取响应体
-	responseBody, err := io.ReadAll(resp.Body)
+	responseBody, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("读取n8n工作流响应失败: %v", err)
 	}
@@ -307,6 +318,102 @@ func (a *N8nWorkflowAdaptor) ProcessResponse(resp *http.Response) (*custom.Workf
 	return workflowResponse, nil
 }
 
+// N8nStreamChunk n8n Webhook 流式响应分块
+type N8nStreamChunk struct {
+	Type     string `json:"type"` // begin / item / end / error
+	Content  string `json:"content"`
+	Metadata struct {
+		NodeID   string `json:"nodeId"`
+		NodeName string `json:"nodeName"`
+	} `json:"metadata"`
+}
+
+// peekSize 返回首行探测长度，不超过缓冲区已有数据
+func peekSize(reader *bufio.Reader) int {
+	if _, err := reader.Peek(1); err != nil {
+		return 0
+	}
+	size := reader.Buffered()
+	if size > 1024 {
+		size = 1024
+	}
+	return size
+}
+
+// isN8nStreamChunk 判断响应是否为 n8n 的流式分块格式
+func isN8nStreamChunk(data []byte) bool {
+	line := data
+	if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+		line = data[:idx]
+	}
+	var chunk N8nStreamChunk
+	if err := json.Unmarshal(bytes.TrimSpace(line), &chunk); err != nil {
+		return false
+	}
+	return chunk.Type == "begin" || chunk.Type == "item"
+}
+
+// processStreamResponse 逐行解析 n8n 流式分块，文本内容合并为 output 字段
+func (a *N8nWorkflowAdaptor) processStreamResponse(reader *bufio.Reader) (*custom.WorkflowResponseData, error) {
+	scanner := bufio.NewScanner(reader)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	var output strings.Builder
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk N8nStreamChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			logger.SysErrorf("解析n8n工作流流式分块失败: %v, 数据: %s", err, string(line))
+			continue
+		}
+
+		switch chunk.Type {
+		case "begin":
+			a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+				Event:     custom.WorkflowEventNodeStarted,
+				NodeID:    chunk.Metadata.NodeID,
+				NodeTitle: chunk.Metadata.NodeName,
+			})
+		case "item":
+			output.WriteString(chunk.Content)
+			a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+				Event:     custom.WorkflowEventTextChunk,
+				NodeID:    chunk.Metadata.NodeID,
+				NodeTitle: chunk.Metadata.NodeName,
+				Text:      chunk.Content,
+			})
+		case "end":
+			a.EventHandler.Emit(&custom.WorkflowStreamEvent{
+				Event:     custom.WorkflowEventNodeFinished,
+				NodeID:    chunk.Metadata.NodeID,
+				NodeTitle: chunk.Metadata.NodeName,
+				Status:    "succeeded",
+			})
+		case "error":
+			return nil, fmt.Errorf("n8n工作流执行失败: %s", chunk.Content)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取n8n工作流响应流失败: %v", err)
+	}
+
+	executeID := fmt.Sprintf("n8n-exec-%d", output.Len())
+	workflowResponse := &custom.WorkflowResponseData{
+		WorkflowOutputData: map[string]interface{}{"output": output.String()},
+		ExecuteID:          executeID,
+		ChannelID:          a.meta.ChannelId,
+		ModelName:          a.meta.OriginModelName,
+	}
+
+	logger.SysLogf("✅ n8n工作流流式处理完成 - ExecuteID: %s", executeID)
+	return workflowResponse, nil
+}
+
 // convertN8nResponseToOutputData 将 n8n 数组响应转换为标准输出格式
 func (a *N8nWorkflowAdaptor) convertN8nResponseToOutputData(n8nResponse N8nWorkflowResponse) map[string]interface{} {
 	outputData := make(map[string]interface{})
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	"github.com/songquanpeng/one-api/relay/meta"
+)
+
+const (
+	// 检索索引的缓存时间，文档变更时会主动失效
+	knowledgeIndexCacheTTL = 10 * time.Minute
+	// 单次 embedding 请求的切片数量
+	knowledgeEmbeddingBatchSize = 10
+)
+
+var knowledgeBlankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+
+// KnowledgeEmbedder 调用 Embedding 渠道为一批文本生成向量，返回的向量与 inputs 一一对应
+type KnowledgeEmbedder func(ctx context.Context, eid int64, modelName string, inputs []string) ([][]float32, error)
+
+// KnowledgeHit 检索命中的切片
+type KnowledgeHit struct {
+	KnowledgeBaseID int64   `json:"knowledge_base_id"`
+	DocumentID      int64   `json:"document_id"`
+	ChunkID         int64   `json:"chunk_id"`
+	FileName        string  `json:"file_name"`
+	Seq             int     `json:"seq"`
+	Content         string  `json:"content"`
+	Score           float64 `json:"score"`
+}
+
+type knowledgeIndexEntry struct {
+	chunkID    int64
+	documentID int64
+	seq        int
+	content    string
+	vector     []float32
+}
+
+// knowledgeIndex 单个知识库的内存向量索引，由数据库中的切片构建
+type knowledgeIndex struct {
+	knowledgeBaseID int64
+	entries         []knowledgeIndexEntry
+	fileNames       map[int64]string
+	expireAt        time.Time
+}
+
+var knowledgeIndexes sync.Map // key: knowledge base id, value: *knowledgeIndex
+
+// InvalidateKnowledgeIndex 知识库文档变更后清除检索索引
+func InvalidateKnowledgeIndex(knowledgeBaseID int64) {
+	knowledgeIndexes.Delete(knowledgeBaseID)
+}
+
+func getKnowledgeIndex(knowledgeBaseID int64) (*knowledgeIndex, error) {
+	if cached, ok := knowledgeIndexes.Load(knowledgeBaseID); ok {
+		index := cached.(*knowledgeIndex)
+		if time.Now().Before(index.expireAt) {
+			return index, nil
+		}
+	}
+
+	chunks, err := model.GetKnowledgeChunksByKnowledgeBaseID(knowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+	index := &knowledgeIndex{
+		knowledgeBaseID: knowledgeBaseID,
+		entries:         make([]knowledgeIndexEntry, 0, len(chunks)),
+		expireAt:        time.Now().Add(knowledgeIndexCacheTTL),
+	}
+	for _, chunk := range chunks {
+		vector := DecodeKnowledgeVector(chunk.Embedding)
+		if len(vector) == 0 {
+			continue
+		}
+		index.entries = append(index.entries, knowledgeIndexEntry{
+			chunkID:    chunk.ID,
+			documentID: chunk.DocumentID,
+			seq:        chunk.Seq,
+			content:    chunk.Content,
+			vector:     vector,
+		})
+	}
+	if fileNames, err := model.GetKnowledgeDocumentFileNames(knowledgeBaseID); err == nil {
+		index.fileNames = fileNames
+	}
+	knowledgeIndexes.Store(knowledgeBaseID, index)
+	return index, nil
+}
+
+// search 暴力计算余弦相似度，向量已归一化，点积即为相似度
+func (index *knowledgeIndex) search(query []float32, topK int, threshold float64) []KnowledgeHit {
+	var hits []KnowledgeHit
+	for _, entry := range index.entries {
+		if len(entry.vector) != len(query) {
+			continue
+		}
+		var score float64
+		for i, v := range entry.vector {
+			score += float64(v) * float64(query[i])
+		}
+		if score < threshold {
+			continue
+		}
+		hits = append(hits, KnowledgeHit{
+			KnowledgeBaseID: index.knowledgeBaseID,
+			DocumentID:      entry.documentID,
+			ChunkID:         entry.chunkID,
+			FileName:        index.fileNames[entry.documentID],
+			Seq:             entry.seq,
+			Content:         entry.content,
+			Score:           score,
+		})
+	}
+	return topKnowledgeHits(hits, topK)
+}
+
+func topKnowledgeHits(hits []KnowledgeHit, topK int) []KnowledgeHit {
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if topK > 0 && len(hits) > topK {
+		hits = hits[:topK]
+	}
+	return hits
+}
+
+// SearchKnowledgeBases 在多个知识库中检索，知识库按向量模型分组，每个模型只为查询生成一次向量
+func SearchKnowledgeBases(ctx context.Context, eid int64, knowledgeBaseIDs []int64, query string, topK int, threshold float64, embed KnowledgeEmbedder) ([]KnowledgeHit, error) {
+	queryVectors := map[string][]float32{}
+	var hits []KnowledgeHit
+	for _, id := range knowledgeBaseIDs {
+		kb, err := model.GetKnowledgeBaseByID(eid, id)
+		if err != nil {
+			logger.Warnf(ctx, "knowledge base %d not found: %s", id, err.Error())
+			continue
+		}
+		queryVector, ok := queryVectors[kb.EmbeddingModel]
+		if !ok {
+			vectors, err := embed(ctx, eid, kb.EmbeddingModel, []string{query})
+			if err != nil {
+				return nil, err
+			}
+			if len(vectors) == 0 {
+				return nil, errors.New("empty embedding response")
+			}
+			queryVector = normalizeKnowledgeVector(vectors[0])
+			queryVectors[kb.EmbeddingModel] = queryVector
+		}
+		index, err := getKnowledgeIndex(kb.ID)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, index.search(queryVector, topK, threshold)...)
+	}
+	return topKnowledgeHits(hits, topK), nil
+}
+
+// RerankKnowledgeHits 使用百炼 rerank 模型对检索结果重新排序，分数替换为 rerank 的相关度
+func RerankKnowledgeHits(ctx context.Context, channel *model.Channel, rerankModel, query string, hits []KnowledgeHit, topK int) ([]KnowledgeHit, error) {
+	if len(hits) == 0 {
+		return hits, nil
+	}
+	documents := make([]string, len(hits))
+	for i, hit := range hits {
+		documents[i] = hit.Content
+	}
+	returnDocuments := false
+	req := &RerankRequest{
+		Model:           rerankModel,
+		Query:           query,
+		Documents:       documents,
+		TopN:            &topK,
+		ReturnDocuments: &returnDocuments,
+	}
+	relayMeta := &meta.Meta{
+		ChannelType:     channel.Type,
+		ChannelId:       int(channel.ChannelID),
+		OriginModelName: rerankModel,
+		ActualModelName: rerankModel,
+		APIType:         model.GetApiType(channel.Type),
+		APIKey:          channel.Key,
+		BaseURL:         channel.GetBaseURL(),
+	}
+	resp, _, err := (&BailianRerankService{}).CallBailianRerankAPI(ctx, req, relayMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	reranked := make([]KnowledgeHit, 0, len(resp.Data))
+	for _, result := range resp.Data {
+		if result.Index < 0 || result.Index >= len(hits) {
+			continue
+		}
+		hit := hits[result.Index]
+		hit.Score = result.RelevanceScore
+		reranked = append(reranked, hit)
+	}
+	return topKnowledgeHits(reranked, topK), nil
+}
+
+// IngestKnowledgeDocument 读取文档对应的上传文件，切片并向量化后写入知识库，处理结果记录在文档状态中
+func IngestKnowledgeDocument(ctx context.Context, kb *model.KnowledgeBase, doc *model.KnowledgeDocument, embed KnowledgeEmbedder) error {
+	if err := doc.UpdateStatus(model.KnowledgeDocumentStatusProcessing, "", 0); err != nil {
+		return err
+	}
+
+	chunkCount, err := ingestKnowledgeDocument(ctx, kb, doc, embed)
+	if err != nil {
+		logger.Errorf(ctx, "ingest knowledge document %d failed: %s", doc.ID, err.Error())
+		_ = doc.UpdateStatus(model.KnowledgeDocumentStatusFailed, err.Error(), 0)
+	} else {
+		_ = doc.UpdateStatus(model.KnowledgeDocumentStatusCompleted, "", chunkCount)
+	}
+	InvalidateKnowledgeIndex(kb.ID)
+	if refreshErr := model.RefreshKnowledgeBaseCounts(kb.ID); refreshErr != nil {
+		logger.Errorf(ctx, "RefreshKnowledgeBaseCounts failed: %s", refreshErr.Error())
+	}
+	return err
+}
+
+func ingestKnowledgeDocument(ctx context.Context, kb *model.KnowledgeBase, doc *model.KnowledgeDocument, embed KnowledgeEmbedder) (int, error) {
+	uploadFile, err := model.GetUploadFileByID(doc.FileID)
+	if err != nil || uploadFile.Eid != kb.Eid {
+		return 0, errors.New("文件不存在")
+	}
+	text, err := ExtractUploadFileText(uploadFile)
+	if err != nil {
+		return 0, err
+	}
+
+	contents := SplitKnowledgeText(text, kb.ChunkSize, kb.ChunkOverlap)
+	if len(contents) == 0 {
+		return 0, errors.New("文档没有可用的文本内容")
+	}
+
+	chunks := make([]*model.KnowledgeChunk, 0, len(contents))
+	for start := 0; start < len(contents); start += knowledgeEmbeddingBatchSize {
+		end := min(start+knowledgeEmbeddingBatchSize, len(contents))
+		vectors, err := embed(ctx, kb.Eid, kb.EmbeddingModel, contents[start:end])
+		if err != nil {
+			return 0, fmt.Errorf("向量化失败: %w", err)
+		}
+		if len(vectors) != end-start {
+			return 0, fmt.Errorf("向量化失败: 期望 %d 条向量，实际返回 %d 条", end-start, len(vectors))
+		}
+		for i, vector := range vectors {
+			chunks = append(chunks, &model.KnowledgeChunk{
+				Eid:             kb.Eid,
+				KnowledgeBaseID: kb.ID,
+				DocumentID:      doc.ID,
+				Seq:             start + i,
+				Content:         contents[start+i],
+				Embedding:       EncodeKnowledgeVector(vector),
+			})
+		}
+	}
+	if err := model.ReplaceKnowledgeChunks(doc.ID, chunks); err != nil {
+		return 0, err
+	}
+	return len(chunks), nil
+}
+
+// SplitKnowledgeText 按段落和句子切片，每片不超过 size 个字符，相邻切片之间重叠 overlap 个字符
+func SplitKnowledgeText(text string, size, overlap int) []string {
+	if size <= 0 {
+		size = model.DefaultKnowledgeChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = knowledgeBlankLinesRegexp.ReplaceAllString(text, "\n\n")
+
+	var chunks []string
+	var current []rune
+	fresh := 0 // current 末尾尚未输出过的字符数，其余为上一片的重叠部分
+	emit := func() {
+		if strings.TrimSpace(string(current[len(current)-fresh:])) != "" {
+			chunks = append(chunks, strings.TrimSpace(string(current)))
+		}
+		if len(current) > overlap {
+			current = append([]rune{}, current[len(current)-overlap:]...)
+		}
+		fresh = 0
+	}
+	for _, sentence := range splitKnowledgeSentences(text) {
+		for len(current)+len(sentence) > size {
+			if fresh > 0 {
+				emit()
+				continue
+			}
+			// 超长句子按长度硬切
+			n := size - len(current)
+			current = append(current, sentence[:n]...)
+			fresh += n
+			sentence = sentence[n:]
+			emit()
+		}
+		current = append(current, sentence...)
+		fresh += len(sentence)
+	}
+	if fresh > 0 {
+		emit()
+	}
+	return chunks
+}
+
+// splitKnowledgeSentences 在句末标点和换行之后断句，保留原始字符
+func splitKnowledgeSentences(text string) [][]rune {
+	var sentences [][]rune
+	var current []rune
+	for _, r := range text {
+		current = append(current, r)
+		switch r {
+		case '。', '！', '？', '；', '.', '!', '?', ';', '\n':
+			sentences = append(sentences, current)
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		sentences = append(sentences, current)
+	}
+	return sentences
+}
+
+// EncodeKnowledgeVector 归一化后按 float32 小端序编码
+func EncodeKnowledgeVector(vector []float32) []byte {
+	vector = normalizeKnowledgeVector(vector)
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	return data
+}
+
+// DecodeKnowledgeVector 解码 EncodeKnowledgeVector 编码的向量
+func DecodeKnowledgeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return vector
+}
+
+func normalizeKnowledgeVector(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := math.Sqrt(sum)
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = float32(float64(v) / norm)
+	}
+	return normalized
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/hub_adaptor/dify"
+	"github.com/songquanpeng/one-api/relay/meta"
+)
+
+// SyncMessageFeedback 将反馈同步到支持反馈的上游平台，feedback 为 nil 表示撤销。
+// 不支持反馈的渠道或没有上游消息ID时直接返回，返回值表示是否完成了同步
+func SyncMessageFeedback(ctx context.Context, message *model.Message, feedback *model.MessageFeedback) bool {
+	if message.ChannelMessageID == "" || message.ChannelId == 0 {
+		return false
+	}
+	channel, err := model.GetChannelByID(int64(message.ChannelId))
+	if err != nil || channel.Eid != message.Eid {
+		return false
+	}
+
+	switch channel.Type {
+	case model.ChannelApiDify:
+		relayMeta := &meta.Meta{
+			ChannelId: int(channel.ChannelID),
+			APIKey:    channel.Key,
+		}
+		if channel.BaseURL != nil {
+			relayMeta.BaseURL = *channel.BaseURL
+		}
+		rating, content := "", ""
+		if feedback != nil {
+			rating = dify.FeedbackRatingDislike
+			if feedback.Rating == model.FeedbackRatingUp {
+				rating = dify.FeedbackRatingLike
+			}
+			content = feedback.Content
+		}
+		// 与对话时传给 DIFY 的 user 保持一致
+		user := fmt.Sprintf("angethub_u%d", message.UserID)
+		if err := dify.SendMessageFeedback(relayMeta, message.ChannelMessageID, user, rating, content); err != nil {
+			logger.Errorf(ctx, "sync feedback of message %d to dify failed: %s", message.ID, err.Error())
+			return false
+		}
+		return true
+	}
+	return false
+}
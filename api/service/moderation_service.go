@@ -0,0 +1,583 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/ahocorasick"
+	"github.com/53AI/53AIHub/model"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+// 审核配置的本地缓存时间，保存配置时会主动失效
+const moderationConfigCacheTTL = 30 * time.Second
+
+const (
+	ModerationActionFlag  = "flag"  // 仅标记并记录日志
+	ModerationActionMask  = "mask"  // 将命中内容替换为屏蔽字符
+	ModerationActionBlock = "block" // 拦截整个请求或回答
+
+	ModerationRuleKeyword    = "keyword"
+	ModerationRuleRegex      = "regex"
+	ModerationRuleDictionary = "dictionary"
+	ModerationRuleModel      = "model"
+
+	ModerationStageInput  = "input"
+	ModerationStageOutput = "output"
+
+	defaultModerationMaskChar     = "*"
+	defaultModerationBlockMessage = "内容包含敏感信息，已被拦截"
+	defaultModerationStreamWindow = 16
+	maxModerationStreamWindow     = 256
+	moderationModelTimeout        = 10 * time.Second
+)
+
+var moderationActionRank = map[string]int{
+	"":                    0,
+	ModerationActionFlag:  1,
+	ModerationActionMask:  2,
+	ModerationActionBlock: 3,
+}
+
+// ModerationRule 单条审核规则
+type ModerationRule struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`       // keyword / regex / dictionary
+	Action     string   `json:"action"`     // block / mask / flag
+	Words      []string `json:"words"`      // keyword 规则的关键词列表
+	Patterns   []string `json:"patterns"`   // regex 规则的正则表达式列表
+	Dictionary string   `json:"dictionary"` // dictionary 规则的敏感词库，每行一个词
+}
+
+// ModerationModelConfig 审核模型配置，调用渠道的 /v1/moderations 接口
+type ModerationModelConfig struct {
+	Enabled   bool   `json:"enabled"`
+	ChannelID int64  `json:"channel_id"`
+	Model     string `json:"model"`
+	Action    string `json:"action"` // block / flag
+}
+
+// ModerationConfig 企业内容审核配置，存储于 enterprise-configs type="moderation" 的 JSON 内容
+type ModerationConfig struct {
+	Input        bool                  `json:"input"`         // 审核用户输入
+	Output       bool                  `json:"output"`        // 审核模型回答
+	MaskChar     string                `json:"mask_char"`     // 屏蔽字符
+	BlockMessage string                `json:"block_message"` // 拦截时返回的提示
+	StreamWindow int                   `json:"stream_window"` // 流式输出时为正则规则保留的最大匹配长度
+	Rules        []ModerationRule      `json:"rules"`
+	Model        ModerationModelConfig `json:"model"`
+}
+
+// ModerationHit 一次规则命中，Start/End 为文本中的 rune 下标
+type ModerationHit struct {
+	Rule   string `json:"rule"`
+	Type   string `json:"type"`
+	Action string `json:"action"`
+	Match  string `json:"match"`
+	Start  int    `json:"-"`
+	End    int    `json:"-"`
+}
+
+// ModerationResult 审核结果，Action 为命中规则中最严格的动作，Text 为屏蔽后的文本
+type ModerationResult struct {
+	Action string
+	Text   string
+	Hits   []ModerationHit
+}
+
+// StricterModerationAction 返回两个动作中更严格的一个
+func StricterModerationAction(a, b string) string {
+	if moderationActionRank[b] > moderationActionRank[a] {
+		return b
+	}
+	return a
+}
+
+// Blocked 是否需要拦截
+func (r *ModerationResult) Blocked() bool {
+	return r.Action == ModerationActionBlock
+}
+
+type compiledModerationRule struct {
+	ModerationRule
+	words   [][]rune
+	regexps []*regexp.Regexp
+	matcher *ahocorasick.Matcher
+}
+
+// Moderator 编译后的审核配置
+type Moderator struct {
+	Eid      int64
+	Config   ModerationConfig
+	rules    []compiledModerationRule
+	window   int
+	maskRune rune
+}
+
+type moderatorCache struct {
+	moderator *Moderator
+	expireAt  time.Time
+}
+
+var moderators sync.Map // key: eid, value: *moderatorCache
+
+// ParseModerationConfig 解析并校验审核配置
+func ParseModerationConfig(content string) (*ModerationConfig, error) {
+	var cfg ModerationConfig
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.StreamWindow < 0 || cfg.StreamWindow > maxModerationStreamWindow {
+		return nil, fmt.Errorf("stream_window must be between 0 and %d", maxModerationStreamWindow)
+	}
+	if len([]rune(cfg.MaskChar)) > 1 {
+		return nil, errors.New("mask_char must be a single character")
+	}
+	for i, rule := range cfg.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rules[%d]", i)
+		}
+		if rule.Action != ModerationActionBlock && rule.Action != ModerationActionMask && rule.Action != ModerationActionFlag {
+			return nil, fmt.Errorf("%s: invalid action %q", name, rule.Action)
+		}
+		switch rule.Type {
+		case ModerationRuleKeyword, ModerationRuleDictionary:
+		case ModerationRuleRegex:
+			for _, pattern := range rule.Patterns {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return nil, fmt.Errorf("%s: %s", name, err.Error())
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%s: invalid type %q", name, rule.Type)
+		}
+	}
+	if cfg.Model.Enabled {
+		if cfg.Model.ChannelID <= 0 {
+			return nil, errors.New("model.channel_id is required")
+		}
+		if cfg.Model.Action != ModerationActionBlock && cfg.Model.Action != ModerationActionFlag {
+			return nil, fmt.Errorf("model: invalid action %q", cfg.Model.Action)
+		}
+	}
+	return &cfg, nil
+}
+
+// NewModerator 编译审核配置
+func NewModerator(eid int64, cfg *ModerationConfig) *Moderator {
+	m := &Moderator{Eid: eid, Config: *cfg}
+	if m.Config.MaskChar == "" {
+		m.Config.MaskChar = defaultModerationMaskChar
+	}
+	if m.Config.BlockMessage == "" {
+		m.Config.BlockMessage = defaultModerationBlockMessage
+	}
+	m.maskRune = []rune(m.Config.MaskChar)[0]
+
+	hasRegex := false
+	for _, rule := range cfg.Rules {
+		compiled := compiledModerationRule{ModerationRule: rule}
+		switch rule.Type {
+		case ModerationRuleKeyword:
+			for _, word := range rule.Words {
+				if runes := []rune(strings.ToLower(strings.TrimSpace(word))); len(runes) > 0 {
+					compiled.words = append(compiled.words, runes)
+					m.growWindow(len(runes))
+				}
+			}
+		case ModerationRuleRegex:
+			for _, pattern := range rule.Patterns {
+				if re, err := regexp.Compile(pattern); err == nil {
+					compiled.regexps = append(compiled.regexps, re)
+					hasRegex = true
+				}
+			}
+		case ModerationRuleDictionary:
+			var words []string
+			for _, line := range strings.Split(rule.Dictionary, "\n") {
+				if word := strings.TrimSpace(line); word != "" {
+					words = append(words, word)
+				}
+			}
+			compiled.matcher = ahocorasick.New(words)
+			m.growWindow(compiled.matcher.MaxLen())
+		}
+		m.rules = append(m.rules, compiled)
+	}
+	if hasRegex {
+		window := cfg.StreamWindow
+		if window == 0 {
+			window = defaultModerationStreamWindow
+		}
+		m.growWindow(window)
+	}
+	return m
+}
+
+// growWindow 流式过滤需要保留 最长匹配长度-1 个字符，才能识别跨分片的命中
+func (m *Moderator) growWindow(matchLen int) {
+	if matchLen-1 > m.window {
+		m.window = matchLen - 1
+	}
+}
+
+// GetModerator 获取企业的审核器，未配置或未启用时返回 nil
+func GetModerator(eid int64) *Moderator {
+	if cached, ok := moderators.Load(eid); ok {
+		entry := cached.(*moderatorCache)
+		if time.Now().Before(entry.expireAt) {
+			return entry.moderator
+		}
+	}
+
+	var moderator *Moderator
+	conf, err := GetEnterpriseConfigByType(eid, model.EnterpriseConfigTypeModeration)
+	if err == nil && conf.Enabled && conf.Content != "" {
+		if cfg, err := ParseModerationConfig(conf.Content); err == nil {
+			moderator = NewModerator(eid, cfg)
+		}
+	}
+
+	moderators.Store(eid, &moderatorCache{
+		moderator: moderator,
+		expireAt:  time.Now().Add(moderationConfigCacheTTL),
+	})
+	return moderator
+}
+
+// InvalidateModerationConfig 清除企业审核配置缓存
+func InvalidateModerationConfig(eid int64) {
+	moderators.Delete(eid)
+}
+
+// Check 使用本地规则审核文本
+func (m *Moderator) Check(text string) *ModerationResult {
+	runes := []rune(text)
+	result := &ModerationResult{Text: text}
+	if len(runes) == 0 {
+		return result
+	}
+
+	var lower []rune
+	for _, rule := range m.rules {
+		switch rule.Type {
+		case ModerationRuleKeyword:
+			if lower == nil {
+				lower = make([]rune, len(runes))
+				for i, r := range runes {
+					lower[i] = unicode.ToLower(r)
+				}
+			}
+			for _, word := range rule.words {
+				for _, start := range indexAllRunes(lower, word) {
+					result.Hits = append(result.Hits, m.newHit(rule, runes, start, start+len(word)))
+				}
+			}
+		case ModerationRuleRegex:
+			for _, re := range rule.regexps {
+				for _, loc := range re.FindAllStringIndex(text, -1) {
+					if loc[0] == loc[1] {
+						continue
+					}
+					start := len([]rune(text[:loc[0]]))
+					end := start + len([]rune(text[loc[0]:loc[1]]))
+					result.Hits = append(result.Hits, m.newHit(rule, runes, start, end))
+				}
+			}
+		case ModerationRuleDictionary:
+			for _, match := range rule.matcher.FindAll(runes) {
+				result.Hits = append(result.Hits, m.newHit(rule, runes, match.Start, match.End))
+			}
+		}
+	}
+
+	masked := false
+	for _, hit := range result.Hits {
+		result.Action = StricterModerationAction(result.Action, hit.Action)
+		if hit.Action == ModerationActionMask {
+			for i := hit.Start; i < hit.End; i++ {
+				runes[i] = m.maskRune
+			}
+			masked = true
+		}
+	}
+	if masked {
+		result.Text = string(runes)
+	}
+	sort.SliceStable(result.Hits, func(i, j int) bool { return result.Hits[i].Start < result.Hits[j].Start })
+	return result
+}
+
+func (m *Moderator) newHit(rule compiledModerationRule, runes []rune, start, end int) ModerationHit {
+	return ModerationHit{
+		Rule:   rule.Name,
+		Type:   rule.Type,
+		Action: rule.Action,
+		Match:  string(runes[start:end]),
+		Start:  start,
+		End:    end,
+	}
+}
+
+func indexAllRunes(text, word []rune) []int {
+	var positions []int
+	for i := 0; i+len(word) <= len(text); i++ {
+		matched := true
+		for j := range word {
+			if text[i+j] != word[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// CheckWithModel 先使用本地规则审核，未被拦截且启用了审核模型时再调用模型审核。
+// 发送给审核模型的文本先经 redactor 脱敏，redactor 可为 nil；模型调用失败时放行，只记录错误日志
+func (m *Moderator) CheckWithModel(ctx context.Context, text string, redactor *PIIRedactor) *ModerationResult {
+	result := m.Check(text)
+	if result.Blocked() || !m.Config.Model.Enabled || strings.TrimSpace(text) == "" {
+		return result
+	}
+
+	categories, err := m.checkModel(ctx, redactor.Redact(text))
+	if err != nil {
+		logger.Errorf(ctx, "moderation model check failed: %s", err.Error())
+		return result
+	}
+	if len(categories) == 0 {
+		return result
+	}
+	result.Hits = append(result.Hits, ModerationHit{
+		Rule:   m.Config.Model.Model,
+		Type:   ModerationRuleModel,
+		Action: m.Config.Model.Action,
+		Match:  strings.Join(categories, ","),
+	})
+	result.Action = StricterModerationAction(result.Action, m.Config.Model.Action)
+	return result
+}
+
+type moderationModelResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// checkModel 调用渠道的 /v1/moderations 接口，返回被标记的类别
+func (m *Moderator) checkModel(ctx context.Context, text string) ([]string, error) {
+	channel, err := model.GetChannelByID(m.Config.Model.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.Eid != m.Eid {
+		return nil, errors.New("moderation channel not found")
+	}
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" && channel.Type >= 0 && channel.Type < len(channeltype.ChannelBaseURLs) {
+		baseURL = channeltype.ChannelBaseURLs[channel.Type]
+	}
+
+	payload := map[string]interface{}{"input": text}
+	if m.Config.Model.Model != "" {
+		payload["model"] = m.Config.Model.Model
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, moderationModelTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/v1/moderations", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+channel.Key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed moderationModelResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	if parsed.Error != nil {
+		return nil, errors.New(parsed.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation request failed with status %d", resp.StatusCode)
+	}
+
+	var categories []string
+	for _, r := range parsed.Results {
+		if !r.Flagged {
+			continue
+		}
+		for category, flagged := range r.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+		if len(categories) == 0 {
+			categories = append(categories, "flagged")
+		}
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// ModerationStreamFilter 流式输出过滤器。
+// 每次写入后保留末尾 window 个字符暂不输出，使跨分片的敏感词在完整出现后才被判定；
+// 已屏蔽的内容随保留区一起参与下一次审核，因此不会被重复屏蔽
+type ModerationStreamFilter struct {
+	moderator *Moderator
+	pending   []rune
+	offset    int // pending 第一个字符在全部输出中的位置
+	seen      map[string]bool
+	blocked   bool
+	Hits      []ModerationHit
+}
+
+// NewStreamFilter 创建流式过滤器
+func (m *Moderator) NewStreamFilter() *ModerationStreamFilter {
+	return &ModerationStreamFilter{moderator: m, seen: map[string]bool{}}
+}
+
+// Write 写入增量文本，返回可以安全输出的文本；返回 blocked 为 true 时应停止输出
+func (f *ModerationStreamFilter) Write(text string) (string, bool) {
+	if f.blocked {
+		return "", true
+	}
+	f.pending = append(f.pending, []rune(text)...)
+	return f.process(false)
+}
+
+// Flush 输出结束时调用，返回剩余的文本
+func (f *ModerationStreamFilter) Flush() (string, bool) {
+	if f.blocked {
+		return "", true
+	}
+	return f.process(true)
+}
+
+// Blocked 是否已被拦截
+func (f *ModerationStreamFilter) Blocked() bool {
+	return f.blocked
+}
+
+// Action 返回已命中规则中最严格的动作
+func (f *ModerationStreamFilter) Action() string {
+	action := ""
+	for _, hit := range f.Hits {
+		action = StricterModerationAction(action, hit.Action)
+	}
+	return action
+}
+
+func (f *ModerationStreamFilter) process(final bool) (string, bool) {
+	if len(f.pending) == 0 {
+		return "", false
+	}
+	result := f.moderator.Check(string(f.pending))
+	for _, hit := range result.Hits {
+		// 保留区中的命中会在下一次审核中再次出现，按绝对位置去重
+		key := fmt.Sprintf("%s|%d|%d", hit.Rule, f.offset+hit.Start, f.offset+hit.End)
+		if f.seen[key] {
+			continue
+		}
+		f.seen[key] = true
+		f.Hits = append(f.Hits, hit)
+	}
+	if result.Blocked() {
+		f.blocked = true
+		f.pending = nil
+		return "", true
+	}
+
+	masked := []rune(result.Text)
+	if final {
+		f.pending = nil
+		return string(masked), false
+	}
+	boundary := len(masked) - f.moderator.window
+	if boundary <= 0 {
+		f.pending = masked
+		return "", false
+	}
+	f.pending = masked[boundary:]
+	f.offset += boundary
+	return string(masked[:boundary]), false
+}
+
+// ModerationEvent 需要记录到系统日志的审核事件
+type ModerationEvent struct {
+	Eid      int64
+	UserID   int64
+	Nickname string
+	IP       string
+	AgentID  int64
+	Stage    string
+	Action   string
+	Hits     []ModerationHit
+}
+
+// LogModerationEvent 将命中的审核事件写入系统日志，供管理员复核
+func LogModerationEvent(event ModerationEvent) {
+	if len(event.Hits) == 0 {
+		return
+	}
+
+	var action uint8
+	switch event.Action {
+	case ModerationActionBlock:
+		action = model.SystemLogActionBlock
+	case ModerationActionMask:
+		action = model.SystemLogActionMask
+	default:
+		action = model.SystemLogActionFlag
+	}
+
+	stage := "用户输入"
+	if event.Stage == ModerationStageOutput {
+		stage = "模型回答"
+	}
+	hits := make([]string, 0, len(event.Hits))
+	for _, hit := range event.Hits {
+		hits = append(hits, fmt.Sprintf("%s[%s/%s]:%s", hit.Rule, hit.Type, hit.Action, hit.Match))
+	}
+
+	model.CreateSystemLog(&model.SystemLog{
+		Eid:      event.Eid,
+		UserID:   event.UserID,
+		Nickname: event.Nickname,
+		Module:   model.SystemLogModuleModeration,
+		Action:   action,
+		Content:  fmt.Sprintf("内容审核【%s】智能体ID：%d；命中：%s", stage, event.AgentID, strings.Join(hits, "；")),
+		IP:       event.IP,
+	})
+}
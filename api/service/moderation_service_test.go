@@ -0,0 +1,230 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseModerationConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "有效配置",
+			content: `{"input":true,"output":true,"rules":[{"name":"r1","type":"keyword","action":"mask","words":["a"]},{"type":"regex","action":"flag","patterns":["\\d+"]}],"model":{"enabled":true,"channel_id":1,"action":"block"}}`,
+		},
+		{
+			name:    "无效动作",
+			content: `{"rules":[{"name":"r1","type":"keyword","action":"drop"}]}`,
+			wantErr: `r1: invalid action "drop"`,
+		},
+		{
+			name:    "无效类型时使用下标作为名称",
+			content: `{"rules":[{"type":"model","action":"flag"}]}`,
+			wantErr: `rules[0]: invalid type "model"`,
+		},
+		{
+			name:    "无效正则",
+			content: `{"rules":[{"name":"r1","type":"regex","action":"block","patterns":["("]}]}`,
+			wantErr: "r1: error parsing regexp",
+		},
+		{
+			name:    "流式窗口超出范围",
+			content: `{"stream_window":1000}`,
+			wantErr: "stream_window must be between 0 and 256",
+		},
+		{
+			name:    "屏蔽字符多于一个",
+			content: `{"mask_char":"**"}`,
+			wantErr: "mask_char must be a single character",
+		},
+		{
+			name:    "审核模型缺少渠道",
+			content: `{"model":{"enabled":true,"action":"block"}}`,
+			wantErr: "model.channel_id is required",
+		},
+		{
+			name:    "审核模型不支持屏蔽",
+			content: `{"model":{"enabled":true,"channel_id":1,"action":"mask"}}`,
+			wantErr: `model: invalid action "mask"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseModerationConfig(tt.content)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParseModerationConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ParseModerationConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newTestModerator(rules ...ModerationRule) *Moderator {
+	return NewModerator(1, &ModerationConfig{Output: true, Rules: rules})
+}
+
+func TestModeratorCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      []ModerationRule
+		text       string
+		wantAction string
+		wantText   string
+		wantHits   []string
+	}{
+		{
+			name:       "关键词屏蔽且忽略大小写",
+			rules:      []ModerationRule{{Name: "kw", Type: ModerationRuleKeyword, Action: ModerationActionMask, Words: []string{"Secret"}}},
+			text:       "my SECRET is secret",
+			wantAction: ModerationActionMask,
+			wantText:   "my ****** is ******",
+			wantHits:   []string{"SECRET", "secret"},
+		},
+		{
+			name:       "词库命中中文",
+			rules:      []ModerationRule{{Name: "dict", Type: ModerationRuleDictionary, Action: ModerationActionMask, Dictionary: "敏感词\n\n违禁 "}},
+			text:       "含有敏感词和违禁内容",
+			wantAction: ModerationActionMask,
+			wantText:   "含有***和**内容",
+			wantHits:   []string{"敏感词", "违禁"},
+		},
+		{
+			name:       "正则命中按 rune 计算位置",
+			rules:      []ModerationRule{{Name: "re", Type: ModerationRuleRegex, Action: ModerationActionMask, Patterns: []string{`\d{4}`}}},
+			text:       "编号1234结束",
+			wantAction: ModerationActionMask,
+			wantText:   "编号****结束",
+			wantHits:   []string{"1234"},
+		},
+		{
+			name: "取最严格的动作",
+			rules: []ModerationRule{
+				{Name: "flag", Type: ModerationRuleKeyword, Action: ModerationActionFlag, Words: []string{"foo"}},
+				{Name: "block", Type: ModerationRuleKeyword, Action: ModerationActionBlock, Words: []string{"bar"}},
+			},
+			text:       "foo bar",
+			wantAction: ModerationActionBlock,
+			wantText:   "foo bar",
+			wantHits:   []string{"foo", "bar"},
+		},
+		{
+			name:       "仅标记时不修改文本",
+			rules:      []ModerationRule{{Name: "flag", Type: ModerationRuleKeyword, Action: ModerationActionFlag, Words: []string{"foo"}}},
+			text:       "foo",
+			wantAction: ModerationActionFlag,
+			wantText:   "foo",
+			wantHits:   []string{"foo"},
+		},
+		{
+			name:     "没有命中",
+			rules:    []ModerationRule{{Name: "kw", Type: ModerationRuleKeyword, Action: ModerationActionBlock, Words: []string{"foo"}}},
+			text:     "hello",
+			wantText: "hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newTestModerator(tt.rules...).Check(tt.text)
+			if result.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", result.Action, tt.wantAction)
+			}
+			if result.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", result.Text, tt.wantText)
+			}
+			var hits []string
+			for _, hit := range result.Hits {
+				hits = append(hits, hit.Match)
+			}
+			if strings.Join(hits, ",") != strings.Join(tt.wantHits, ",") {
+				t.Errorf("Hits = %v, want %v", hits, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestModerationStreamFilter(t *testing.T) {
+	maskRule := ModerationRule{Name: "kw", Type: ModerationRuleKeyword, Action: ModerationActionMask, Words: []string{"敏感词"}}
+	blockRule := ModerationRule{Name: "block", Type: ModerationRuleKeyword, Action: ModerationActionBlock, Words: []string{"违禁"}}
+	phoneRule := ModerationRule{Name: "phone", Type: ModerationRuleRegex, Action: ModerationActionMask, Patterns: []string{`1\d{10}`}}
+
+	tests := []struct {
+		name        string
+		rules       []ModerationRule
+		chunks      []string
+		wantOutput  string
+		wantBlocked bool
+		wantHits    int
+	}{
+		{
+			name:       "跨分片的关键词被屏蔽",
+			rules:      []ModerationRule{maskRule},
+			chunks:     []string{"这是敏", "感", "词测试"},
+			wantOutput: "这是***测试",
+			wantHits:   1,
+		},
+		{
+			name:       "保留区中的命中不重复计数",
+			rules:      []ModerationRule{maskRule},
+			chunks:     []string{"敏感词", "a", "b", "c", "敏感词"},
+			wantOutput: "***abc***",
+			wantHits:   2,
+		},
+		{
+			name:       "跨分片的正则在窗口内被屏蔽",
+			rules:      []ModerationRule{phoneRule},
+			chunks:     []string{"电话 138", "0013", "8000 谢谢"},
+			wantOutput: "电话 *********** 谢谢",
+			wantHits:   1,
+		},
+		{
+			name:        "拦截后不再输出",
+			rules:       []ModerationRule{maskRule, blockRule},
+			chunks:      []string{"正常内容很长很长很长", "然后违", "禁内容", "继续"},
+			wantOutput:  "正常内容很长很长很长然",
+			wantBlocked: true,
+			wantHits:    1,
+		},
+		{
+			name:       "没有命中时原样输出",
+			rules:      []ModerationRule{maskRule},
+			chunks:     []string{"hello ", "world"},
+			wantOutput: "hello world",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := newTestModerator(tt.rules...).NewStreamFilter()
+			var output strings.Builder
+			blocked := false
+			for _, chunk := range tt.chunks {
+				text, b := filter.Write(chunk)
+				output.WriteString(text)
+				blocked = blocked || b
+			}
+			text, b := filter.Flush()
+			output.WriteString(text)
+			blocked = blocked || b
+
+			if output.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", output.String(), tt.wantOutput)
+			}
+			if blocked != tt.wantBlocked || filter.Blocked() != tt.wantBlocked {
+				t.Errorf("blocked = %v, want %v", blocked, tt.wantBlocked)
+			}
+			if len(filter.Hits) != tt.wantHits {
+				t.Errorf("Hits = %v, want %d hits", filter.Hits, tt.wantHits)
+			}
+		})
+	}
+}
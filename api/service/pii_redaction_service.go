@@ -0,0 +1,272 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+// 占位符的最大长度，流式还原时用于判断未闭合的 [ 是否可能是占位符的开头
+const piiPlaceholderMaxLen = 48
+
+var (
+	piiPhoneRegexp    = regexp.MustCompile(`(?:\+?86[- ]?)?1[3-9]\d{9}`)
+	piiEmailRegexp    = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	piiIDCardRegexp   = regexp.MustCompile(`[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]`)
+	piiBankCardRegexp = regexp.MustCompile(`[1-9]\d{15,18}`)
+
+	piiPlaceholderRegexp = regexp.MustCompile(`\[[A-Z0-9_]+_\d+\]`)
+)
+
+// piiDetector 识别器：正则找出候选值，validate 不为空时再做校验（如校验位）
+type piiDetector struct {
+	name     string
+	regexp   *regexp.Regexp
+	digits   bool // 数字类识别器要求前后不能紧邻数字
+	validate func(string) bool
+}
+
+var piiBuiltinDetectors = map[string]piiDetector{
+	model.PIIDetectorPhone:    {name: model.PIIDetectorPhone, regexp: piiPhoneRegexp, digits: true},
+	model.PIIDetectorEmail:    {name: model.PIIDetectorEmail, regexp: piiEmailRegexp},
+	model.PIIDetectorIDCard:   {name: model.PIIDetectorIDCard, regexp: piiIDCardRegexp, digits: true, validate: validChineseIDCard},
+	model.PIIDetectorBankCard: {name: model.PIIDetectorBankCard, regexp: piiBankCardRegexp, digits: true, validate: validLuhn},
+}
+
+// validChineseIDCard 按 GB 11643-1999 校验 18 位身份证号的校验码
+func validChineseIDCard(id string) bool {
+	if len(id) != 18 {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	checkCodes := "10X98765432"
+	sum := 0
+	for i, w := range weights {
+		sum += int(id[i]-'0') * w
+	}
+	return strings.ToUpper(id[17:]) == string(checkCodes[sum%11])
+}
+
+// validLuhn 使用 Luhn 算法校验银行卡号
+func validLuhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// PIIRedactor 单次请求内的脱敏器：同一个值在请求内总是替换为同一个占位符，回答中的占位符可还原为原值
+type PIIRedactor struct {
+	detectors    []piiDetector
+	placeholders map[string]string // 原值 -> 占位符
+	values       map[string]string // 占位符 -> 原值
+	counters     map[string]int    // 每类识别器已分配的占位符数量
+	Counts       map[string]int    // 每类识别器的脱敏次数
+}
+
+// NewPIIRedactor 根据智能体配置创建脱敏器，未启用时返回 nil
+func NewPIIRedactor(settings model.PIIRedactionSettings) *PIIRedactor {
+	if !settings.Enabled {
+		return nil
+	}
+	r := &PIIRedactor{
+		placeholders: map[string]string{},
+		values:       map[string]string{},
+		counters:     map[string]int{},
+		Counts:       map[string]int{},
+	}
+	for _, name := range settings.Detectors {
+		if detector, ok := piiBuiltinDetectors[name]; ok {
+			r.detectors = append(r.detectors, detector)
+		}
+	}
+	for _, custom := range settings.Custom {
+		re, err := regexp.Compile(custom.Pattern)
+		if err != nil || custom.Name == "" {
+			continue
+		}
+		r.detectors = append(r.detectors, piiDetector{name: custom.Name, regexp: re})
+	}
+	if len(r.detectors) == 0 {
+		return nil
+	}
+	return r
+}
+
+type piiSpan struct {
+	start, end int
+	detector   string
+}
+
+// Redact 将文本中的个人信息替换为占位符，例如 [PHONE_1]
+func (r *PIIRedactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+
+	// 靠前的识别器优先，与已识别区间重叠的候选值被忽略
+	var spans []piiSpan
+	for _, detector := range r.detectors {
+		for _, loc := range detector.regexp.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if detector.digits && (digitBefore(text, loc[0]) || digitAfter(text, loc[1])) {
+				continue
+			}
+			value := text[loc[0]:loc[1]]
+			if detector.validate != nil && !detector.validate(value) {
+				continue
+			}
+			if overlapsPIISpan(spans, loc[0], loc[1]) {
+				continue
+			}
+			spans = append(spans, piiSpan{start: loc[0], end: loc[1], detector: detector.name})
+		}
+	}
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		b.WriteString(text[last:span.start])
+		b.WriteString(r.placeholder(span.detector, text[span.start:span.end]))
+		r.Counts[span.detector]++
+		last = span.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (r *PIIRedactor) placeholder(detector, value string) string {
+	if placeholder, ok := r.placeholders[value]; ok {
+		return placeholder
+	}
+	r.counters[detector]++
+	placeholder := fmt.Sprintf("[%s_%d]", piiPlaceholderName(detector), r.counters[detector])
+	r.placeholders[value] = placeholder
+	r.values[placeholder] = value
+	return placeholder
+}
+
+func piiPlaceholderName(detector string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, detector)
+	name = strings.Trim(name, "_")
+	if name == "" {
+		name = "PII"
+	}
+	return name
+}
+
+func digitBefore(text string, i int) bool {
+	return i > 0 && text[i-1] >= '0' && text[i-1] <= '9'
+}
+
+func digitAfter(text string, i int) bool {
+	return i < len(text) && text[i] >= '0' && text[i] <= '9'
+}
+
+func overlapsPIISpan(spans []piiSpan, start, end int) bool {
+	for _, span := range spans {
+		if start < span.end && span.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// Restore 将回答中的占位符还原为原值，未知的占位符保持不变
+func (r *PIIRedactor) Restore(text string) string {
+	if r == nil || len(r.values) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	return piiPlaceholderRegexp.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if value, ok := r.values[placeholder]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// RestoreJSON 还原 JSON 文本中的占位符，原值按 JSON 字符串转义
+func (r *PIIRedactor) RestoreJSON(text string) string {
+	if r == nil || len(r.values) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	return piiPlaceholderRegexp.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, ok := r.values[placeholder]
+		if !ok {
+			return placeholder
+		}
+		escaped, err := json.Marshal(value)
+		if err != nil {
+			return placeholder
+		}
+		return string(escaped[1 : len(escaped)-1])
+	})
+}
+
+// Total 脱敏总次数
+func (r *PIIRedactor) Total() int {
+	if r == nil {
+		return 0
+	}
+	total := 0
+	for _, count := range r.Counts {
+		total += count
+	}
+	return total
+}
+
+// PIIStreamRestorer 流式还原：占位符可能被拆分到多个分片中，遇到未闭合的 [ 时暂存到下一个分片
+type PIIStreamRestorer struct {
+	redactor *PIIRedactor
+	pending  string
+}
+
+// NewStreamRestorer 创建流式还原器
+func (r *PIIRedactor) NewStreamRestorer() *PIIStreamRestorer {
+	return &PIIStreamRestorer{redactor: r}
+}
+
+// Write 写入增量文本，返回可以输出的已还原文本
+func (s *PIIStreamRestorer) Write(text string) string {
+	text = s.pending + text
+	s.pending = ""
+
+	if i := strings.LastIndex(text, "["); i >= 0 && !strings.Contains(text[i:], "]") && len(text)-i < piiPlaceholderMaxLen {
+		s.pending = text[i:]
+		text = text[:i]
+	}
+	return s.redactor.Restore(text)
+}
+
+// Flush 输出结束时调用，返回暂存的文本
+func (s *PIIStreamRestorer) Flush() string {
+	text := s.pending
+	s.pending = ""
+	return s.redactor.Restore(text)
+}
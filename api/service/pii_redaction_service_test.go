@@ -0,0 +1,218 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+func TestValidChineseIDCard(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"11010519491231002X", true},
+		{"11010519491231002x", true},
+		{"110105194912310021", false},
+		{"440524188001010014", true},
+		{"440524188001010015", false},
+		{"44052418800101001", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := validChineseIDCard(tt.id); got != tt.want {
+				t.Errorf("validChineseIDCard(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"4111111111111112", false},
+		{"6222021234567890127", false},
+		{"79927398713", true},
+		{"79927398710", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := validLuhn(tt.number); got != tt.want {
+				t.Errorf("validLuhn(%q) = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestPIIRedactor(t *testing.T) *PIIRedactor {
+	t.Helper()
+	r := NewPIIRedactor(model.PIIRedactionSettings{
+		Enabled:   true,
+		Detectors: model.PIIBuiltinDetectors,
+		Custom:    []model.PIICustomDetector{{Name: "employee-no", Pattern: `EMP\d{6}`}},
+	})
+	if r == nil {
+		t.Fatal("NewPIIRedactor() = nil")
+	}
+	return r
+}
+
+func TestNewPIIRedactorDisabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings model.PIIRedactionSettings
+	}{
+		{"未启用", model.PIIRedactionSettings{Detectors: model.PIIBuiltinDetectors}},
+		{"没有可用的识别器", model.PIIRedactionSettings{Enabled: true, Detectors: []string{"unknown"}, Custom: []model.PIICustomDetector{{Name: "bad", Pattern: "("}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewPIIRedactor(tt.settings)
+			if r != nil {
+				t.Fatalf("NewPIIRedactor() = %v, want nil", r)
+			}
+			// nil 脱敏器原样返回
+			if got := r.Redact("13800138000"); got != "13800138000" {
+				t.Errorf("Redact() = %q", got)
+			}
+			if got := r.Restore("[PHONE_1]"); got != "[PHONE_1]" {
+				t.Errorf("Restore() = %q", got)
+			}
+		})
+	}
+}
+
+func TestPIIRedactorRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "手机号与邮箱",
+			text: "电话13800138000，邮箱a.b@example.com",
+			want: "电话[PHONE_1]，邮箱[EMAIL_1]",
+		},
+		{
+			name: "同一个值使用同一个占位符",
+			text: "13800138000 和 13800138000 以及 13900139000",
+			want: "[PHONE_1] 和 [PHONE_1] 以及 [PHONE_2]",
+		},
+		{
+			name: "身份证号校验码正确才脱敏",
+			text: "11010519491231002X 110105194912310021",
+			want: "[ID_CARD_1] 110105194912310021",
+		},
+		{
+			name: "银行卡号需通过 Luhn 校验",
+			text: "卡号4111111111111111，错误卡号4111111111111112",
+			want: "卡号[BANK_CARD_1]，错误卡号4111111111111112",
+		},
+		{
+			name: "前后紧邻数字时不识别",
+			text: "订单号913800138000",
+			want: "订单号913800138000",
+		},
+		{
+			name: "自定义识别器",
+			text: "工号EMP123456",
+			want: "工号[EMPLOYEE_NO_1]",
+		},
+		{
+			name: "没有个人信息",
+			text: "hello",
+			want: "hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestPIIRedactor(t)
+			got := r.Redact(tt.text)
+			if got != tt.want {
+				t.Fatalf("Redact() = %q, want %q", got, tt.want)
+			}
+			if restored := r.Restore(got); restored != tt.text {
+				t.Errorf("Restore() = %q, want %q", restored, tt.text)
+			}
+		})
+	}
+}
+
+func TestPIIRedactorRestore(t *testing.T) {
+	r := NewPIIRedactor(model.PIIRedactionSettings{
+		Enabled:   true,
+		Detectors: []string{model.PIIDetectorPhone},
+		Custom:    []model.PIICustomDetector{{Name: "quoted", Pattern: `"[^"]+"`}},
+	})
+	r.Redact(`13800138000 "小明"`)
+
+	tests := []struct {
+		name string
+		json bool
+		text string
+		want string
+	}{
+		{"还原已知占位符", false, "请拨打[PHONE_1]", "请拨打13800138000"},
+		{"未知占位符保持不变", false, "[PHONE_2] [EMAIL]", "[PHONE_2] [EMAIL]"},
+		{"JSON 中按字符串转义", true, `{"content":"[QUOTED_1]"}`, `{"content":"\"小明\""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Restore(tt.text)
+			if tt.json {
+				got = r.RestoreJSON(tt.text)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPIIStreamRestorer(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string // 每个分片写入后的输出，最后一项为 Flush 的输出
+	}{
+		{
+			name:   "占位符跨分片",
+			chunks: []string{"号码是[PHO", "NE_", "1]，请联系"},
+			want:   []string{"号码是", "", "13800138000，请联系", ""},
+		},
+		{
+			name:   "占位符在单个分片内",
+			chunks: []string{"[PHONE_1]"},
+			want:   []string{"13800138000", ""},
+		},
+		{
+			name:   "未闭合的 [ 在结束时原样输出",
+			chunks: []string{"数组 a[", "0"},
+			want:   []string{"数组 a", "", "[0"},
+		},
+		{
+			name:   "过长的 [ 不再暂存",
+			chunks: []string{"[" + strings.Repeat("x", piiPlaceholderMaxLen)},
+			want:   []string{"[" + strings.Repeat("x", piiPlaceholderMaxLen), ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestPIIRedactor(t)
+			r.Redact("13800138000")
+			restorer := r.NewStreamRestorer()
+			var got []string
+			for _, chunk := range tt.chunks {
+				got = append(got, restorer.Write(chunk))
+			}
+			got = append(got, restorer.Flush())
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
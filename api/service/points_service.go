@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+)
+
+// ErrUserPointsInsufficient 积分订阅用户的积分余额不足
+var ErrUserPointsInsufficient = errors.New("user points insufficient")
+
+// pointsGrantBatchSize 每批发放积分的用户数
+const pointsGrantBatchSize = 200
+
+// QuotaToPoints 将配额换算为积分，不足 1 积分按 1 积分计
+func QuotaToPoints(quota int64) int64 {
+	if quota <= 0 {
+		return 0
+	}
+	quotaPerPoint := max(config.QUOTA_PER_POINT, 1)
+	return (quota + quotaPerPoint - 1) / quotaPerPoint
+}
+
+// checkUserPoints 检查积分余额是否足够支付预扣的配额
+func checkUserPoints(eid, userID int64, quota int64) error {
+	available, err := model.GetAvailablePoints(eid, userID)
+	if err != nil {
+		// 积分表异常时不阻断请求，仅记录日志
+		logger.SysErrorf("get available points failed: eid=%d user_id=%d err=%v", eid, userID, err)
+		return nil
+	}
+	if available <= 0 || available < QuotaToPoints(quota) {
+		return ErrUserPointsInsufficient
+	}
+	return nil
+}
+
+// ConsumeUserPoints 按实际消耗的配额扣减积分订阅用户的积分，sourceID 为对应的消息ID
+func ConsumeUserPoints(eid, userID int64, quota int64, sourceType string, sourceID int64) {
+	if userID == 0 || quota <= 0 {
+		return
+	}
+	if !GetUserQuotaPolicy(eid, userID).Points {
+		return
+	}
+
+	points := QuotaToPoints(quota)
+	deducted, err := model.DeductPoints(eid, userID, points, &model.PointsLedger{
+		Type:       model.PointsTypeDeduct,
+		SourceType: sourceType,
+		SourceID:   strconv.FormatInt(sourceID, 10),
+		Quota:      quota,
+	})
+	if err != nil {
+		logger.SysErrorf("consume user points failed: eid=%d user_id=%d points=%d err=%v", eid, userID, points, err)
+		return
+	}
+	if deducted < points {
+		logger.SysLogf("user points insufficient: eid=%d user_id=%d points=%d deducted=%d", eid, userID, points, deducted)
+	}
+}
+
+// GrantSubscriptionPoints 按用户当前订阅发放本周期的积分，同一订阅配置每周期只发放一次
+func GrantSubscriptionPoints(eid, userID int64, sourceType, sourceID string) error {
+	user, err := model.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.GroupId == 0 {
+		return nil
+	}
+	setting, err := model.GetSubscriptionSettingByGroupId(user.GroupId)
+	if err != nil {
+		return nil
+	}
+	relations, err := model.GetPointsSubscriptionRelations(setting.SettingId)
+	if err != nil {
+		return err
+	}
+	return grantSubscriptionPoints(eid, userID, relations, sourceType, sourceID)
+}
+
+func grantSubscriptionPoints(eid, userID int64, relations []model.SubscriptionRelation, sourceType, sourceID string) error {
+	now := time.Now().In(model.GetEnterpriseLocation(eid))
+	for _, relation := range relations {
+		unit := relation.TimeUnit
+		if !model.IsValidTimeUnit(unit) {
+			unit = model.TimeUnitMonth
+		}
+		period := model.GetQuotaPeriodKey(unit, now)
+		err := model.GrantPoints(&model.PointsGrant{
+			Eid:         eid,
+			UserID:      userID,
+			GrantKey:    fmt.Sprintf("subscription:%d:%s", relation.RelationId, period),
+			Amount:      relation.Amount,
+			ExpiredTime: model.GetQuotaPeriodEnd(unit, now).UTC().UnixMilli(),
+		}, &model.PointsLedger{
+			Type:       model.PointsTypeGrant,
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			Remark:     period,
+		})
+		if err != nil && !errors.Is(err, model.ErrPointsGrantExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GrantAllSubscriptionPoints 为所有积分订阅的有效用户发放本周期的积分，返回处理的用户数
+func GrantAllSubscriptionPoints() (int, error) {
+	settings, err := model.GetPointsSubscriptionSettings()
+	if err != nil {
+		return 0, err
+	}
+
+	granted := 0
+	now := time.Now().UTC().UnixMilli()
+	for _, setting := range settings {
+		group, err := model.GetGroupByID(setting.GroupId)
+		if err != nil {
+			continue
+		}
+		relations, err := model.GetPointsSubscriptionRelations(setting.SettingId)
+		if err != nil {
+			logger.SysErrorf("get points subscription relations failed: setting_id=%d err=%v", setting.SettingId, err)
+			continue
+		}
+
+		var lastID int64
+		for {
+			userIDs, err := model.GetPointsGroupUserIDs(group.GroupId, setting.IsDefault, now, lastID, pointsGrantBatchSize)
+			if err != nil {
+				logger.SysErrorf("get points group users failed: group_id=%d err=%v", group.GroupId, err)
+				break
+			}
+			for _, userID := range userIDs {
+				if err := grantSubscriptionPoints(group.Eid, userID, relations, model.PointsSourceSubscription, strconv.FormatInt(setting.SettingId, 10)); err != nil {
+					logger.SysErrorf("grant subscription points failed: eid=%d user_id=%d err=%v", group.Eid, userID, err)
+					continue
+				}
+				granted++
+			}
+			if len(userIDs) < pointsGrantBatchSize {
+				break
+			}
+			lastID = userIDs[len(userIDs)-1]
+		}
+	}
+	return granted, nil
+}
+
+// ExpirePoints 清零已过期批次的剩余积分，返回成功处理的批次数
+func ExpirePoints(limit int) (int, error) {
+	grants, err := model.GetExpiredPointsGrants(time.Now().UTC().UnixMilli(), limit)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, grant := range grants {
+		if err := model.ExpirePointsGrant(grant); err != nil {
+			logger.SysErrorf("expire points grant %d failed: %v", grant.ID, err)
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// AdjustUserPoints 管理员调整积分，amount 为正时增加，为负时扣减（最多扣至 0）
+func AdjustUserPoints(eid, userID, amount, expiredTime int64, remark string, operatorID int64) error {
+	ledger := &model.PointsLedger{
+		Type:       model.PointsTypeAdjust,
+		SourceType: model.PointsSourceAdmin,
+		SourceID:   strconv.FormatInt(operatorID, 10),
+		Remark:     remark,
+		OperatorID: operatorID,
+	}
+	if amount < 0 {
+		_, err := model.DeductPoints(eid, userID, -amount, ledger)
+		return err
+	}
+	return model.GrantPoints(&model.PointsGrant{
+		Eid:         eid,
+		UserID:      userID,
+		GrantKey:    fmt.Sprintf("adjust:%d:%d", operatorID, time.Now().UnixNano()),
+		Amount:      amount,
+		ExpiredTime: expiredTime,
+	}, ledger)
+}
+
+// RefundPointsLedger 退还一条扣减流水的积分，每条流水只能退还一次，退还的积分永不过期
+func RefundPointsLedger(ledger *model.PointsLedger, remark string, operatorID int64) error {
+	if ledger.Type != model.PointsTypeDeduct || ledger.Amount >= 0 {
+		return errors.New("only deductions can be refunded")
+	}
+	return model.GrantPoints(&model.PointsGrant{
+		Eid:      ledger.Eid,
+		UserID:   ledger.UserID,
+		GrantKey: fmt.Sprintf("refund:%d", ledger.ID),
+		Amount:   -ledger.Amount,
+	}, &model.PointsLedger{
+		Type:       model.PointsTypeRefund,
+		SourceType: model.PointsSourceLedger,
+		SourceID:   strconv.FormatInt(ledger.ID, 10),
+		Remark:     remark,
+		OperatorID: operatorID,
+	})
+}
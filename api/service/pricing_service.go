@@ -0,0 +1,237 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	billing_ratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+// 计价配置和价格表的本地缓存时间，修改时会主动失效
+const pricingCacheTTL = 30 * time.Second
+
+// 未配置计价时沿用 one-api 的换算：1 美元对应 500000 配额
+const (
+	DefaultPricingCurrency     = "USD"
+	DefaultPricingQuotaPerUnit = 500 * 1000.0
+)
+
+// PricingConfig 企业计价配置，存储于 enterprise-configs type="pricing" 的 JSON 内容
+type PricingConfig struct {
+	Currency     string             `json:"currency"`       // 价格表使用的货币，如 CNY、USD
+	QuotaPerUnit float64            `json:"quota_per_unit"` // 每 1 单位货币对应的配额
+	UserGroups   map[string]float64 `json:"user_groups"`    // 用户分组的价格倍率，key 为分组ID
+}
+
+// GetGroupRatio 获取用户分组的价格倍率，未配置时为 1
+func (c *PricingConfig) GetGroupRatio(groupID int64) float64 {
+	if ratio, ok := c.UserGroups[strconv.FormatInt(groupID, 10)]; ok {
+		return ratio
+	}
+	return 1
+}
+
+// ParsePricingConfig 解析并校验计价配置
+func ParsePricingConfig(content string) (*PricingConfig, error) {
+	var cfg PricingConfig
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Currency == "" {
+		cfg.Currency = DefaultPricingCurrency
+	}
+	if len(cfg.Currency) > 10 {
+		return nil, errors.New("invalid currency")
+	}
+	if cfg.QuotaPerUnit == 0 {
+		cfg.QuotaPerUnit = DefaultPricingQuotaPerUnit
+	}
+	if cfg.QuotaPerUnit < 0 {
+		return nil, errors.New("quota_per_unit must be greater than 0")
+	}
+	for id, ratio := range cfg.UserGroups {
+		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid user group id: %s", id)
+		}
+		if ratio < 0 {
+			return nil, fmt.Errorf("user_groups.%s: ratio must be greater than or equal to 0", id)
+		}
+	}
+	return &cfg, nil
+}
+
+type pricingCache struct {
+	config   *PricingConfig
+	prices   map[string]*model.ModelPrice // key: 模型名/渠道ID
+	expireAt time.Time
+}
+
+var pricingCaches sync.Map // key: eid, value: *pricingCache
+
+// getPricingCache 获取企业的计价配置和价格表，未配置或未启用计价时 config 为 nil
+func getPricingCache(eid int64) *pricingCache {
+	if cached, ok := pricingCaches.Load(eid); ok {
+		entry := cached.(*pricingCache)
+		if time.Now().Before(entry.expireAt) {
+			return entry
+		}
+	}
+
+	entry := &pricingCache{expireAt: time.Now().Add(pricingCacheTTL)}
+	conf, err := GetEnterpriseConfigByType(eid, model.EnterpriseConfigTypePricing)
+	if err == nil && conf.Enabled && conf.Content != "" {
+		entry.config, _ = ParsePricingConfig(conf.Content)
+	}
+	if entry.config != nil {
+		prices, err := model.GetModelPrices(eid, "", -1)
+		if err != nil {
+			logger.SysErrorf("获取企业 %d 价格表失败: %v", eid, err)
+		}
+		entry.prices = make(map[string]*model.ModelPrice, len(prices))
+		for _, price := range prices {
+			entry.prices[modelPriceKey(price.ModelName, price.ChannelID)] = price
+		}
+	}
+	pricingCaches.Store(eid, entry)
+	return entry
+}
+
+func modelPriceKey(modelName string, channelID int64) string {
+	return modelName + "/" + strconv.FormatInt(channelID, 10)
+}
+
+// InvalidatePricing 清除企业计价配置和价格表缓存
+func InvalidatePricing(eid int64) {
+	pricingCaches.Delete(eid)
+}
+
+// ModelPricing 一次请求的计费规则。企业启用计价且价格表中有该模型时按价格计费，否则按 one-api 的模型倍率计费
+type ModelPricing struct {
+	ModelName    string
+	Currency     string
+	QuotaPerUnit float64
+	GroupRatio   float64 // 用户分组倍率
+
+	Price        *model.ModelPrice // 为 nil 时按倍率计费
+	ChannelPrice bool              // 是否为渠道价格
+
+	ModelRatio      float64
+	CompletionRatio float64
+}
+
+// GetModelPricing 获取模型的计费规则，渠道价格优先于企业默认价格
+func GetModelPricing(eid, channelID int64, channelType int, modelName string, groupID int64) *ModelPricing {
+	pricing := &ModelPricing{
+		ModelName:    modelName,
+		Currency:     DefaultPricingCurrency,
+		QuotaPerUnit: DefaultPricingQuotaPerUnit,
+		GroupRatio:   1,
+	}
+
+	cache := getPricingCache(eid)
+	if cache.config != nil {
+		pricing.Currency = cache.config.Currency
+		pricing.QuotaPerUnit = cache.config.QuotaPerUnit
+		pricing.GroupRatio = cache.config.GetGroupRatio(groupID)
+		if channelID > 0 {
+			if price, ok := cache.prices[modelPriceKey(modelName, channelID)]; ok {
+				pricing.Price = price
+				pricing.ChannelPrice = true
+				return pricing
+			}
+		}
+		if price, ok := cache.prices[modelPriceKey(modelName, 0)]; ok {
+			pricing.Price = price
+			return pricing
+		}
+	}
+
+	pricing.ModelRatio = billing_ratio.GetModelRatio(modelName, channelType)
+	pricing.CompletionRatio = billing_ratio.GetCompletionRatio(modelName, channelType)
+	return pricing
+}
+
+// Ratio 每个输入 token 对应的配额，用于预扣配额
+func (p *ModelPricing) Ratio() float64 {
+	if p.Price != nil {
+		return p.Price.InputPrice / 1e6 * p.QuotaPerUnit * p.GroupRatio
+	}
+	return p.ModelRatio * p.GroupRatio
+}
+
+// UsageCharge 按用量计算的配额和费用
+type UsageCharge struct {
+	Quota  int64
+	Cost   float64 // 费用，货币单位为 ModelPricing.Currency
+	Detail string  // 计费明细，记录到 Message.QuotaContent
+}
+
+// Charge 按用量计费，cachedTokens 为输入中命中缓存的 token 数
+func (p *ModelPricing) Charge(promptTokens, cachedTokens, completionTokens int) UsageCharge {
+	if p.Price == nil {
+		return p.chargeByRatio(promptTokens, completionTokens)
+	}
+
+	cachedTokens = max(0, min(cachedTokens, promptTokens))
+	cachedPrice := p.Price.InputPrice
+	if p.Price.CachedInputPrice != nil {
+		cachedPrice = *p.Price.CachedInputPrice
+	}
+	inputCost := float64(promptTokens-cachedTokens) * p.Price.InputPrice / 1e6
+	cachedCost := float64(cachedTokens) * cachedPrice / 1e6
+	outputCost := float64(completionTokens) * p.Price.OutputPrice / 1e6
+	baseCost := inputCost + cachedCost + outputCost
+	// 保留到 1e-10，避免浮点误差导致配额向上取整时多扣
+	cost := math.Round(baseCost*p.GroupRatio*1e10) / 1e10
+
+	quota := int64(math.Ceil(math.Round(cost*p.QuotaPerUnit*1e6) / 1e6))
+	if cost > 0 && quota <= 0 {
+		quota = 1
+	}
+	if promptTokens+completionTokens == 0 {
+		quota, cost = 0, 0
+	}
+
+	source := "企业价格"
+	if p.ChannelPrice {
+		source = "渠道价格"
+	}
+	detail := fmt.Sprintf("%s（%s/百万 tokens）：输入 %d × %s", source, p.Currency, promptTokens-cachedTokens, formatPrice(p.Price.InputPrice))
+	if cachedTokens > 0 {
+		detail += fmt.Sprintf(" + 缓存 %d × %s", cachedTokens, formatPrice(cachedPrice))
+	}
+	detail += fmt.Sprintf(" + 输出 %d × %s = %s", completionTokens, formatPrice(p.Price.OutputPrice), formatPrice(baseCost))
+	if p.GroupRatio != 1 {
+		detail += fmt.Sprintf("；分组倍率 %s", formatPrice(p.GroupRatio))
+	}
+	detail += fmt.Sprintf("；费用 %s %s，配额 %d", formatPrice(cost), p.Currency, quota)
+	return UsageCharge{Quota: quota, Cost: cost, Detail: detail}
+}
+
+func (p *ModelPricing) chargeByRatio(promptTokens, completionTokens int) UsageCharge {
+	ratio := p.ModelRatio * p.GroupRatio
+	quota := int64(math.Ceil((float64(promptTokens) + float64(completionTokens)*p.CompletionRatio) * ratio))
+	if ratio != 0 && quota <= 0 {
+		quota = 1
+	}
+	if promptTokens+completionTokens == 0 {
+		quota = 0
+	}
+	return UsageCharge{
+		Quota:  quota,
+		Cost:   float64(quota) / p.QuotaPerUnit,
+		Detail: fmt.Sprintf("倍率：%.2f × %.2f × %.2f", p.ModelRatio, p.GroupRatio, p.CompletionRatio),
+	}
+}
+
+// formatPrice 去掉多余的零，保留到百万分之一
+func formatPrice(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1e6)/1e6, 'f', -1, 64)
+}
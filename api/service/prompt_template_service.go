@@ -0,0 +1,168 @@
+package service
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var promptVariableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// PromptVariableResolver 按名称解析提示词变量，ok 为 false 表示变量不存在
+type PromptVariableResolver func(name string) (value string, ok bool)
+
+type promptTag struct {
+	name    string
+	filters []promptFilter
+}
+
+type promptFilter struct {
+	name string
+	arg  string
+}
+
+// HasPromptTemplate 提示词中是否包含模板标签
+func HasPromptTemplate(tpl string) bool {
+	return strings.Contains(tpl, "{{")
+}
+
+// RenderPromptTemplate 渲染提示词模板。
+// 语法：{{ user.nickname }}、{{ var.city | default: "北京" }}，\{{ 输出字面量 {{；
+// 支持的过滤器：default、upper、lower、trim、json（输出带引号并转义的 JSON 字符串）。
+// 变量值按原样插入且不会再次解析；未知变量或值为空时使用默认值，没有默认值时输出空字符串；
+// 无法解析的标签原样保留
+func RenderPromptTemplate(tpl string, resolve PromptVariableResolver) string {
+	if !HasPromptTemplate(tpl) {
+		return tpl
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(tpl, "{{")
+		if start < 0 {
+			b.WriteString(tpl)
+			break
+		}
+		if start > 0 && tpl[start-1] == '\\' {
+			b.WriteString(tpl[:start-1])
+			b.WriteString("{{")
+			tpl = tpl[start+2:]
+			continue
+		}
+		end := strings.Index(tpl[start+2:], "}}")
+		if end < 0 {
+			b.WriteString(tpl)
+			break
+		}
+		end += start + 2
+
+		b.WriteString(tpl[:start])
+		tag, ok := parsePromptTag(tpl[start+2 : end])
+		if ok {
+			b.WriteString(tag.render(resolve))
+		} else {
+			b.WriteString(tpl[start : end+2])
+		}
+		tpl = tpl[end+2:]
+	}
+	return b.String()
+}
+
+func parsePromptTag(expr string) (promptTag, bool) {
+	parts := splitPromptExpr(expr)
+	tag := promptTag{name: strings.TrimSpace(parts[0])}
+	if !promptVariableNameRegexp.MatchString(tag.name) {
+		return tag, false
+	}
+	for _, part := range parts[1:] {
+		name, arg, _ := strings.Cut(part, ":")
+		filter := promptFilter{name: strings.TrimSpace(name)}
+		arg = strings.TrimSpace(arg)
+		if arg != "" {
+			unquoted, ok := unquotePromptArg(arg)
+			if !ok {
+				return tag, false
+			}
+			filter.arg = unquoted
+		}
+		tag.filters = append(tag.filters, filter)
+	}
+	return tag, true
+}
+
+// splitPromptExpr 按 | 拆分表达式，引号内的 | 不拆分
+func splitPromptExpr(expr string) []string {
+	var parts []string
+	var quote rune
+	escaped := false
+	last := 0
+	for i, r := range expr {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != 0:
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '|':
+			parts = append(parts, expr[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, expr[last:])
+}
+
+func unquotePromptArg(arg string) (string, bool) {
+	if len(arg) < 2 || (arg[0] != '"' && arg[0] != '\'') || arg[len(arg)-1] != arg[0] {
+		return "", false
+	}
+	body := arg[1 : len(arg)-1]
+	var b strings.Builder
+	escaped := false
+	for _, r := range body {
+		if escaped {
+			switch r {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				b.WriteRune(r)
+			}
+			escaped = false
+			continue
+		}
+		if r == '\\' {
+			escaped = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), true
+}
+
+func (t promptTag) render(resolve PromptVariableResolver) string {
+	value, _ := resolve(t.name)
+	for _, filter := range t.filters {
+		switch filter.name {
+		case "default":
+			if strings.TrimSpace(value) == "" {
+				value = filter.arg
+			}
+		case "upper":
+			value = strings.ToUpper(value)
+		case "lower":
+			value = strings.ToLower(value)
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "json":
+			data, _ := json.Marshal(value)
+			value = string(data)
+		}
+	}
+	return value
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+// ErrUserQuotaExceeded 用户当前周期的配额已用完
+var ErrUserQuotaExceeded = errors.New("user quota exceeded")
+
+// UserQuotaPolicy 用户当前生效的配额策略
+type UserQuotaPolicy struct {
+	Limit  int64  // 每周期配额，0 表示不限制
+	Unit   string // 周期单位：day/week/month/quarter/year
+	Period string // 当前周期标识
+	Points bool   // 是否为积分订阅，积分订阅按积分余额限制使用
+}
+
+// GetUserQuotaPolicy 根据用户所在订阅分组获取配额策略
+// 用户未绑定订阅时视为不限制，但仍按月统计用量
+func GetUserQuotaPolicy(eid, userID int64) *UserQuotaPolicy {
+	policy := &UserQuotaPolicy{Unit: model.TimeUnitMonth}
+
+	user, err := model.GetUserByID(userID)
+	if err == nil && user.GroupId > 0 {
+		if setting, err := model.GetSubscriptionSettingByGroupId(user.GroupId); err == nil {
+			policy.Limit = setting.QuotaLimit
+			policy.Points, _ = model.HasPointsSubscriptionRelation(setting.SettingId)
+			if model.IsValidTimeUnit(setting.QuotaPeriod) {
+				policy.Unit = setting.QuotaPeriod
+			}
+		}
+	}
+
+	now := time.Now().In(model.GetEnterpriseLocation(eid))
+	policy.Period = model.GetQuotaPeriodKey(policy.Unit, now)
+	return policy
+}
+
+// UserQuotaHold 预扣的配额及其所在周期。结算和退还都记在预扣时的周期上，
+// 避免跨周期边界的请求在新周期结算而预扣留在旧周期
+type UserQuotaHold struct {
+	Quota  int64  // 预扣配额
+	Period string // 预扣时的周期标识，为空时使用结算时的当前周期
+}
+
+// PreConsumeUserQuota 预扣配额，剩余配额不足时返回 ErrUserQuotaExceeded，
+// 积分订阅用户积分余额不足时返回 ErrUserPointsInsufficient
+func PreConsumeUserQuota(eid, userID int64, quota int64) (UserQuotaHold, error) {
+	if quota < 0 {
+		quota = 0
+	}
+	hold := UserQuotaHold{Quota: quota}
+	if userID == 0 {
+		return hold, nil
+	}
+
+	policy := GetUserQuotaPolicy(eid, userID)
+	hold.Period = policy.Period
+	if policy.Points {
+		if err := checkUserPoints(eid, userID, quota); err != nil {
+			return hold, err
+		}
+	}
+	ok, err := model.ConsumeUserQuota(eid, userID, policy.Period, quota, policy.Limit)
+	if err != nil {
+		// 配额表异常时不阻断请求，仅记录日志
+		logger.SysErrorf("pre consume user quota failed: eid=%d user_id=%d err=%v", eid, userID, err)
+		return hold, nil
+	}
+	if !ok {
+		return hold, ErrUserQuotaExceeded
+	}
+	return hold, nil
+}
+
+// PostConsumeUserQuota 请求结束后按实际用量 quota 结算，多退少补预扣的配额
+func PostConsumeUserQuota(eid, userID int64, hold UserQuotaHold, quota int64) {
+	adjustUserQuota(eid, userID, hold.Period, quota-hold.Quota)
+}
+
+// ReturnUserQuota 请求失败时退还预扣的配额
+func ReturnUserQuota(eid, userID int64, hold UserQuotaHold) {
+	adjustUserQuota(eid, userID, hold.Period, -hold.Quota)
+}
+
+func adjustUserQuota(eid, userID int64, period string, delta int64) {
+	if userID == 0 || delta == 0 {
+		return
+	}
+	if period == "" {
+		period = GetUserQuotaPolicy(eid, userID).Period
+	}
+	if err := model.AdjustUserQuota(eid, userID, period, delta); err != nil {
+		logger.SysErrorf("post consume user quota failed: eid=%d user_id=%d delta=%d err=%v", eid, userID, delta, err)
+	}
+}
+
+// GetUserQuotaUsage 返回用户当前周期的配额策略及已用配额
+func GetUserQuotaUsage(eid, userID int64) (*UserQuotaPolicy, int64, error) {
+	policy := GetUserQuotaPolicy(eid, userID)
+	used, err := model.GetUserUsedQuota(eid, userID, policy.Period)
+	if err != nil {
+		return nil, 0, err
+	}
+	return policy, used, nil
+}
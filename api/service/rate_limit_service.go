@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+// 限流配置的本地缓存时间，保存配置时会主动失效
+const rateLimitConfigCacheTTL = 30 * time.Second
+
+// RateLimitRule 单个限流对象的限制，0 表示不限制
+type RateLimitRule struct {
+	RPM        int `json:"rpm"`        // 每分钟请求数
+	Concurrent int `json:"concurrent"` // 同时进行的流式请求数
+}
+
+// RateLimitConfig 企业限流配置，存储于 enterprise-configs type="rate_limit" 的 JSON 内容
+type RateLimitConfig struct {
+	Enterprise RateLimitRule            `json:"enterprise"`  // 企业整体
+	User       RateLimitRule            `json:"user"`        // 单个用户的默认限制
+	UserGroups map[string]RateLimitRule `json:"user_groups"` // 按用户分组覆盖单个用户的限制，key 为分组ID
+	Agents     map[string]RateLimitRule `json:"agents"`      // 单个智能体整体，key 为智能体ID
+}
+
+// GetUserRule 获取用户的限制，所在分组有配置时优先使用分组配置
+func (c *RateLimitConfig) GetUserRule(groupID int64) RateLimitRule {
+	if rule, ok := c.UserGroups[strconv.FormatInt(groupID, 10)]; ok {
+		return rule
+	}
+	return c.User
+}
+
+// GetAgentRule 获取智能体的限制
+func (c *RateLimitConfig) GetAgentRule(agentID int64) RateLimitRule {
+	return c.Agents[strconv.FormatInt(agentID, 10)]
+}
+
+type rateLimitConfigCache struct {
+	config   *RateLimitConfig
+	expireAt time.Time
+}
+
+var rateLimitConfigs sync.Map // key: eid, value: *rateLimitConfigCache
+
+// ParseRateLimitConfig 解析并校验限流配置
+func ParseRateLimitConfig(content string) (*RateLimitConfig, error) {
+	var cfg RateLimitConfig
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		return nil, err
+	}
+
+	rules := map[string]RateLimitRule{"enterprise": cfg.Enterprise, "user": cfg.User}
+	for id, rule := range cfg.UserGroups {
+		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid user group id: %s", id)
+		}
+		rules["user_groups."+id] = rule
+	}
+	for id, rule := range cfg.Agents {
+		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid agent id: %s", id)
+		}
+		rules["agents."+id] = rule
+	}
+	for name, rule := range rules {
+		if rule.RPM < 0 || rule.Concurrent < 0 {
+			return nil, fmt.Errorf("%s: rpm and concurrent must be greater than or equal to 0", name)
+		}
+	}
+	return &cfg, nil
+}
+
+// GetRateLimitConfig 获取企业限流配置，未配置或未启用时返回 nil
+func GetRateLimitConfig(eid int64) *RateLimitConfig {
+	if cached, ok := rateLimitConfigs.Load(eid); ok {
+		entry := cached.(*rateLimitConfigCache)
+		if time.Now().Before(entry.expireAt) {
+			return entry.config
+		}
+	}
+
+	var cfg *RateLimitConfig
+	conf, err := GetEnterpriseConfigByType(eid, model.EnterpriseConfigTypeRateLimit)
+	if err == nil && conf.Enabled && conf.Content != "" {
+		cfg, _ = ParseRateLimitConfig(conf.Content)
+	}
+
+	rateLimitConfigs.Store(eid, &rateLimitConfigCache{
+		config:   cfg,
+		expireAt: time.Now().Add(rateLimitConfigCacheTTL),
+	})
+	return cfg
+}
+
+// InvalidateRateLimitConfig 清除企业限流配置缓存
+func InvalidateRateLimitConfig(eid int64) {
+	rateLimitConfigs.Delete(eid)
+}
@@ -1,6 +1,11 @@
 package service
 
 import (
+	"errors"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/common/utils/helper"
 	"github.com/53AI/53AIHub/model"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -130,3 +135,38 @@ func UpdateAgentResourcePermissions(c *gin.Context, tx *gorm.DB, agentID int64,
 
 	return nil
 }
+
+// 智能体访问校验失败的原因
+var (
+	ErrAgentNotAllowedByAPIKey = errors.New("API key is not allowed to access this agent")
+	ErrAgentUserGroupDenied    = errors.New("user group is not allowed to access this agent")
+	ErrAgentPausedByBudget     = errors.New("Agent is paused because its budget has been used up")
+)
+
+// CheckAgentAccess 校验当前用户能否调用智能体：API 密钥的智能体范围、用户分组权限（管理员除外）以及预算暂停状态
+func CheckAgentAccess(c *gin.Context, user *model.User, agent *model.Agent) error {
+	if value, exists := c.Get(session.SESSION_API_KEY); exists {
+		if apiKey, ok := value.(*model.ApiKey); ok && apiKey != nil && !apiKey.AllowAgent(agent.AgentID) {
+			return ErrAgentNotAllowedByAPIKey
+		}
+	}
+
+	if !common.IsAdmin(c) {
+		agentUserGroupIds, err := agent.GetUserGroupIds()
+		if err != nil {
+			return err
+		}
+		userGroupIds, err := user.GetUserGroupIds()
+		if err != nil {
+			return err
+		}
+		if !helper.HasIntersection(agentUserGroupIds, userGroupIds) {
+			return ErrAgentUserGroupDenied
+		}
+	}
+
+	if IsAgentPausedByBudget(agent.Eid, agent.AgentID) {
+		return ErrAgentPausedByBudget
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+// ResponseCacheMessage 参与缓存 key 计算的消息
+type ResponseCacheMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ResponseCacheEntry 缓存的回答
+type ResponseCacheEntry struct {
+	Answer           string `json:"answer"`
+	ReasoningContent string `json:"reasoning_content"`
+	ModelName        string `json:"model_name"`
+}
+
+// ResponseCacheStats 智能体缓存统计
+type ResponseCacheStats struct {
+	AgentID int64   `json:"agent_id"`
+	Enabled bool    `json:"enabled"`
+	TTL     int64   `json:"ttl"`
+	Entries int64   `json:"entries"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+func responseCacheRedisKey(cacheKey string) string {
+	return "response_cache:" + cacheKey
+}
+
+func responseCacheAgentRedisKey(eid, agentID int64) string {
+	return fmt.Sprintf("response_cache:agent:%d:%d", eid, agentID)
+}
+
+// BuildResponseCacheKey 根据智能体ID、提示词版本和归一化后的消息列表计算缓存 key
+// 归一化只去掉首尾空白并合并连续空白，其余内容需完全一致才会命中
+func BuildResponseCacheKey(agent *model.Agent, messages []ResponseCacheMessage) string {
+	normalized := make([]ResponseCacheMessage, 0, len(messages))
+	for _, m := range messages {
+		normalized = append(normalized, ResponseCacheMessage{
+			Role:    strings.ToLower(strings.TrimSpace(m.Role)),
+			Content: strings.Join(strings.Fields(m.Content), " "),
+		})
+	}
+	data, _ := json.Marshal(normalized)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s:", agent.AgentID, agent.GetPromptVersion())
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetResponseCache 查询缓存，并记录命中统计
+func GetResponseCache(agent *model.Agent, cacheKey string) (*ResponseCacheEntry, bool) {
+	entry, ok := getResponseCacheEntry(cacheKey)
+	go func() {
+		if err := model.IncreaseResponseCacheStat(agent.Eid, agent.AgentID, ok); err != nil {
+			logger.SysErrorf("记录回答缓存统计失败: %v", err)
+		}
+	}()
+	return entry, ok
+}
+
+func getResponseCacheEntry(cacheKey string) (*ResponseCacheEntry, bool) {
+	if common.IsRedisEnabled() {
+		value, err := common.RedisGet(responseCacheRedisKey(cacheKey))
+		if err != nil {
+			return nil, false
+		}
+		var entry ResponseCacheEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, false
+		}
+		return &entry, true
+	}
+
+	record, err := model.GetResponseCache(cacheKey)
+	if err != nil {
+		return nil, false
+	}
+	return &ResponseCacheEntry{
+		Answer:           record.Answer,
+		ReasoningContent: record.ReasoningContent,
+		ModelName:        record.ModelName,
+	}, true
+}
+
+// SetResponseCache 写入缓存
+func SetResponseCache(agent *model.Agent, cacheKey string, entry *ResponseCacheEntry, ttl time.Duration) error {
+	if entry.Answer == "" {
+		return nil
+	}
+
+	if common.IsRedisEnabled() {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		agentKey := responseCacheAgentRedisKey(agent.Eid, agent.AgentID)
+		pipe := common.RDB.TxPipeline()
+		pipe.Set(ctx, responseCacheRedisKey(cacheKey), data, ttl)
+		pipe.SAdd(ctx, agentKey, cacheKey)
+		pipe.Expire(ctx, agentKey, ttl)
+		_, err = pipe.Exec(ctx)
+		return err
+	}
+
+	return model.SaveResponseCache(&model.ResponseCache{
+		Eid:              agent.Eid,
+		AgentID:          agent.AgentID,
+		CacheKey:         cacheKey,
+		Answer:           entry.Answer,
+		ReasoningContent: entry.ReasoningContent,
+		ModelName:        entry.ModelName,
+		ExpiredTime:      time.Now().Add(ttl).UTC().UnixMilli(),
+	})
+}
+
+// PurgeResponseCache 清除智能体的全部缓存，返回清除的条目数
+func PurgeResponseCache(eid, agentID int64) (int64, error) {
+	if common.IsRedisEnabled() {
+		ctx := context.Background()
+		agentKey := responseCacheAgentRedisKey(eid, agentID)
+		members, err := common.RDB.SMembers(ctx, agentKey).Result()
+		if err != nil {
+			return 0, err
+		}
+		keys := make([]string, 0, len(members)+1)
+		for _, member := range members {
+			keys = append(keys, responseCacheRedisKey(member))
+		}
+		keys = append(keys, agentKey)
+		deleted, err := common.RDB.Del(ctx, keys...).Result()
+		if err != nil {
+			return 0, err
+		}
+		// 索引集合本身不计入
+		if deleted > 0 {
+			deleted--
+		}
+		return deleted, nil
+	}
+	return model.DeleteResponseCacheByAgent(eid, agentID)
+}
+
+// countResponseCache 统计智能体当前有效的缓存条目数
+func countResponseCache(eid, agentID int64) (int64, error) {
+	if common.IsRedisEnabled() {
+		ctx := context.Background()
+		agentKey := responseCacheAgentRedisKey(eid, agentID)
+		members, err := common.RDB.SMembers(ctx, agentKey).Result()
+		if err != nil {
+			return 0, err
+		}
+		var count int64
+		for _, member := range members {
+			exists, err := common.RDB.Exists(ctx, responseCacheRedisKey(member)).Result()
+			if err != nil {
+				return 0, err
+			}
+			if exists > 0 {
+				count++
+			} else {
+				// 顺便清理已过期条目的索引
+				common.RDB.SRem(ctx, agentKey, member)
+			}
+		}
+		return count, nil
+	}
+	return model.CountResponseCacheByAgent(eid, agentID)
+}
+
+// GetResponseCacheStats 获取智能体缓存统计
+func GetResponseCacheStats(agent *model.Agent) (*ResponseCacheStats, error) {
+	settings := agent.GetResponseCacheSettings()
+	stats := &ResponseCacheStats{
+		AgentID: agent.AgentID,
+		Enabled: settings.Enabled,
+		TTL:     settings.TTL,
+	}
+
+	entries, err := countResponseCache(agent.Eid, agent.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	stats.Entries = entries
+
+	stat, err := model.GetResponseCacheStat(agent.Eid, agent.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	stats.Hits = stat.Hits
+	stats.Misses = stat.Misses
+	if total := stat.Hits + stat.Misses; total > 0 {
+		stats.HitRate = float64(stat.Hits) / float64(total)
+	}
+	return stats, nil
+}
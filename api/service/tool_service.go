@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/model"
+)
+
+const (
+	// 工具调用默认超时时间
+	defaultToolTimeout = 10 * time.Second
+	// 工具调用最长超时时间，配置超出时按此截断
+	maxToolTimeout = 60 * time.Second
+	// 工具返回给模型的最大长度，避免超出上下文
+	maxToolResultLength = 16 * 1024
+)
+
+// toolHTTPClient HTTP 工具只允许访问公网地址，连接时再次校验以防 DNS rebinding
+var toolHTTPClient = utils.NewPublicHTTPClient(maxToolTimeout)
+
+// ValidateAgentTools 保存智能体时校验工具配置，HTTP 工具的地址不能指向回环、内网或链路本地地址
+func ValidateAgentTools(tools string) error {
+	list, err := model.ParseAgentTools(tools)
+	if err != nil {
+		return err
+	}
+	for i, tool := range list {
+		if tool.Type != model.AgentToolTypeHTTP {
+			continue
+		}
+		if err := utils.ValidatePublicURL(tool.URL); err != nil {
+			return fmt.Errorf("tools[%d]: invalid url %q: %w", i, tool.URL, err)
+		}
+	}
+	return nil
+}
+
+// ExecuteHTTPTool 调用 HTTP 工具，GET/DELETE 请求参数放在查询串中，其余放在 JSON 请求体中
+func ExecuteHTTPTool(ctx context.Context, tool *model.AgentTool, arguments string) (string, error) {
+	timeout := defaultToolTimeout
+	if tool.Timeout > 0 {
+		timeout = min(time.Duration(tool.Timeout)*time.Second, maxToolTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := map[string]interface{}{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	targetURL := tool.URL
+	var body io.Reader
+	if tool.Method == http.MethodGet || tool.Method == http.MethodDelete {
+		u, err := url.Parse(tool.URL)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		for k, v := range args {
+			query.Set(k, fmt.Sprint(v))
+		}
+		u.RawQuery = query.Encode()
+		targetURL = u.String()
+	} else {
+		jsonData, err := json.Marshal(args)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, tool.Method, targetURL, body)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range tool.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := toolHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxToolResultLength+1))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", fmt.Errorf("http status code: %d, body: %s", resp.StatusCode, TruncateToolResult(string(respBody)))
+	}
+	return TruncateToolResult(string(respBody)), nil
+}
+
+// ExecuteBuiltinTool 执行内置工具
+func ExecuteBuiltinTool(name string, arguments string) (string, error) {
+	args := map[string]interface{}{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	switch name {
+	case model.BuiltinToolCurrentTime:
+		loc := time.Local
+		if tz, ok := args["timezone"].(string); ok && tz != "" {
+			l, err := time.LoadLocation(tz)
+			if err != nil {
+				return "", fmt.Errorf("invalid timezone: %s", tz)
+			}
+			loc = l
+		}
+		now := time.Now().In(loc)
+		return fmt.Sprintf("%s (%s)", now.Format("2006-01-02 15:04:05 Monday"), loc.String()), nil
+	case model.BuiltinToolCalculator:
+		expression, _ := args["expression"].(string)
+		if expression == "" {
+			return "", errors.New("expression is required")
+		}
+		result, err := EvalExpression(expression)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(result, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unknown builtin tool: %s", name)
+	}
+}
+
+// BuiltinToolParameters 内置工具未配置参数时使用的默认参数定义
+func BuiltinToolParameters(name string) map[string]interface{} {
+	switch name {
+	case model.BuiltinToolCurrentTime:
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"timezone": map[string]interface{}{"type": "string", "description": "IANA timezone, e.g. Asia/Shanghai"},
+			},
+		}
+	case model.BuiltinToolCalculator:
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"expression": map[string]interface{}{"type": "string", "description": "Arithmetic expression, e.g. (1+2)*3/4"},
+			},
+			"required": []string{"expression"},
+		}
+	}
+	return nil
+}
+
+// TruncateToolResult 截断过长的工具结果
+func TruncateToolResult(result string) string {
+	if len(result) <= maxToolResultLength {
+		return result
+	}
+	// 避免截断在多字节字符中间
+	cut := maxToolResultLength
+	for cut > 0 && !utf8RuneStart(result[cut]) {
+		cut--
+	}
+	return result[:cut] + "...(truncated)"
+}
+
+func utf8RuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// EvalExpression 计算四则运算表达式，支持 + - * / % 和括号
+func EvalExpression(expression string) (float64, error) {
+	p := &exprParser{input: expression}
+	result, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected character %q at %d", p.input[p.pos], p.pos)
+	}
+	return result, nil
+}
+
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) parseExpr() (float64, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '+' && p.input[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (p *exprParser) parseTerm() (float64, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || !strings.ContainsRune("*/%", rune(p.input[p.pos])) {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left *= right
+		case '/', '%':
+			if right == 0 || (op == '%' && int64(right) == 0) {
+				return 0, errors.New("division by zero")
+			}
+			if op == '/' {
+				left /= right
+			} else {
+				left = float64(int64(left) % int64(right))
+			}
+		}
+	}
+}
+
+func (p *exprParser) parseFactor() (float64, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0, errors.New("unexpected end of expression")
+	}
+	switch p.input[p.pos] {
+	case '-':
+		p.pos++
+		v, err := p.parseFactor()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.parseFactor()
+	case '(':
+		p.pos++
+		v, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return 0, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return v, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] == '.' || (p.input[p.pos] >= '0' && p.input[p.pos] <= '9')) {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, fmt.Errorf("unexpected character %q at %d", p.input[p.pos], p.pos)
+	}
+	return strconv.ParseFloat(p.input[start:p.pos], 64)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+// 用量统计的时间粒度
+const (
+	UsageIntervalHour  = "hour"
+	UsageIntervalDay   = "day"
+	UsageIntervalMonth = "month"
+	UsageIntervalTotal = "total"
+)
+
+// 用量统计的分组维度
+const (
+	UsageGroupByAgent      = "agent"
+	UsageGroupByUser       = "user"
+	UsageGroupByDepartment = "department"
+	UsageGroupByUserGroup  = "user_group"
+	UsageGroupByModel      = "model"
+	UsageGroupByChannel    = "channel"
+)
+
+// UsageStat 一个时间段内某个分组的用量
+type UsageStat struct {
+	Bucket           string `json:"bucket"`      // 企业时区下的时间段，如 2026-10-18；interval 为 total 时为空
+	BucketTime       int64  `json:"bucket_time"` // 时间段起点，毫秒时间戳
+	GroupKey         string `json:"group_key"`
+	Name             string `json:"name"`
+	Calls            int64  `json:"calls"`
+	Errors           int64  `json:"errors"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	Quota            int64  `json:"quota"`
+	AvgLatency       int64  `json:"avg_latency"` // 毫秒
+	P95Latency       int64  `json:"p95_latency"` // 毫秒，按直方图估算
+}
+
+// GetUsageStats 从小时汇总表聚合用量，按企业时区切分时间段。
+// 部门和用户组按用户当前的归属统计，同属多个部门或用户组的用户在每个分组中都会计入
+func GetUsageStats(eid int64, query model.UsageRollupQuery, interval, groupBy string, location *time.Location) ([]*UsageStat, error) {
+	bucketOf, format, err := usageBucketFunc(interval, location)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := model.GetUsageRollups(eid, query)
+	if err != nil {
+		return nil, err
+	}
+	groupsOf, err := usageGroupFunc(eid, groupBy, rollups)
+	if err != nil {
+		return nil, err
+	}
+
+	type statKey struct {
+		bucket int64
+		group  string
+	}
+	type statValue struct {
+		stat      *UsageStat
+		elapsed   int64
+		histogram model.LatencyHistogram
+	}
+	values := make(map[statKey]*statValue)
+	for _, rollup := range rollups {
+		bucket := bucketOf(rollup.BucketTime)
+		histogram := model.ParseLatencyHistogram(rollup.LatencyHistogram)
+		for _, group := range groupsOf(rollup) {
+			key := statKey{bucket: bucket, group: group}
+			value, ok := values[key]
+			if !ok {
+				value = &statValue{
+					stat:      &UsageStat{BucketTime: bucket, GroupKey: group},
+					histogram: model.NewLatencyHistogram(),
+				}
+				if format != "" {
+					value.stat.Bucket = time.UnixMilli(bucket).In(location).Format(format)
+				}
+				values[key] = value
+			}
+			stat := value.stat
+			stat.Calls += rollup.CallCount
+			stat.Errors += rollup.ErrorCount
+			stat.PromptTokens += rollup.PromptTokens
+			stat.CompletionTokens += rollup.CompletionTokens
+			stat.TotalTokens += rollup.TotalTokens
+			stat.Quota += rollup.Quota
+			value.elapsed += rollup.ElapsedTime
+			value.histogram.Merge(histogram)
+		}
+	}
+
+	stats := make([]*UsageStat, 0, len(values))
+	for _, value := range values {
+		if value.stat.Calls > 0 {
+			value.stat.AvgLatency = value.elapsed / value.stat.Calls
+		}
+		value.stat.P95Latency = value.histogram.Percentile(0.95)
+		stats = append(stats, value.stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].BucketTime != stats[j].BucketTime {
+			return stats[i].BucketTime < stats[j].BucketTime
+		}
+		return stats[i].Calls > stats[j].Calls
+	})
+	fillUsageGroupNames(eid, groupBy, stats)
+	return stats, nil
+}
+
+// usageBucketFunc 返回将小时起点映射到时间段起点的函数，以及时间段的显示格式
+func usageBucketFunc(interval string, location *time.Location) (func(int64) int64, string, error) {
+	switch interval {
+	case UsageIntervalHour:
+		return func(t int64) int64 { return t }, "2006-01-02 15:04", nil
+	case UsageIntervalDay, "":
+		return func(t int64) int64 {
+			local := time.UnixMilli(t).In(location)
+			return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location).UnixMilli()
+		}, "2006-01-02", nil
+	case UsageIntervalMonth:
+		return func(t int64) int64 {
+			local := time.UnixMilli(t).In(location)
+			return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, location).UnixMilli()
+		}, "2006-01", nil
+	case UsageIntervalTotal:
+		return func(int64) int64 { return 0 }, "", nil
+	}
+	return nil, "", errors.New("invalid interval")
+}
+
+// usageGroupFunc 返回汇总行所属分组的函数，不分组时所有行归入同一个空分组
+func usageGroupFunc(eid int64, groupBy string, rollups []*model.UsageRollup) (func(*model.UsageRollup) []string, error) {
+	switch groupBy {
+	case "":
+		return func(*model.UsageRollup) []string { return []string{""} }, nil
+	case UsageGroupByAgent:
+		return func(r *model.UsageRollup) []string { return []string{strconv.FormatInt(r.AgentID, 10)} }, nil
+	case UsageGroupByUser:
+		return func(r *model.UsageRollup) []string { return []string{strconv.FormatInt(r.UserID, 10)} }, nil
+	case UsageGroupByModel:
+		return func(r *model.UsageRollup) []string { return []string{r.ModelName} }, nil
+	case UsageGroupByChannel:
+		return func(r *model.UsageRollup) []string { return []string{strconv.FormatInt(r.ChannelID, 10)} }, nil
+	case UsageGroupByDepartment, UsageGroupByUserGroup:
+	default:
+		return nil, errors.New("invalid group_by")
+	}
+
+	userIDs := usageUserIDs(rollups)
+	memberships := make(map[int64][]int64, len(userIDs))
+	if groupBy == UsageGroupByDepartment {
+		departments, err := model.GetDepartmentIDsByUserIDs(eid, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		memberships = departments
+	} else {
+		users, err := model.GetUsersByIDs(eid, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if err := user.LoadGroupIds(); err != nil {
+				return nil, err
+			}
+			memberships[user.UserID] = user.GroupIds
+		}
+	}
+
+	keys := make(map[int64][]string, len(memberships))
+	for userID, ids := range memberships {
+		seen := make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			if id > 0 && !seen[id] {
+				seen[id] = true
+				keys[userID] = append(keys[userID], strconv.FormatInt(id, 10))
+			}
+		}
+	}
+	// 没有部门或用户组的用户归入 "0"
+	return func(r *model.UsageRollup) []string {
+		if groups := keys[r.UserID]; len(groups) > 0 {
+			return groups
+		}
+		return []string{"0"}
+	}, nil
+}
+
+func usageUserIDs(rollups []*model.UsageRollup) []int64 {
+	seen := make(map[int64]bool)
+	var userIDs []int64
+	for _, rollup := range rollups {
+		if !seen[rollup.UserID] {
+			seen[rollup.UserID] = true
+			userIDs = append(userIDs, rollup.UserID)
+		}
+	}
+	return userIDs
+}
+
+// fillUsageGroupNames 填充分组名称，模型分组的名称即模型名
+func fillUsageGroupNames(eid int64, groupBy string, stats []*UsageStat) {
+	names := make(map[string]string)
+	var ids []int64
+	for _, stat := range stats {
+		if _, ok := names[stat.GroupKey]; ok {
+			continue
+		}
+		names[stat.GroupKey] = stat.GroupKey
+		if id, err := strconv.ParseInt(stat.GroupKey, 10, 64); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	switch groupBy {
+	case UsageGroupByAgent:
+		for _, id := range ids {
+			if agent, err := model.GetAgentByID(eid, id); err == nil {
+				names[strconv.FormatInt(id, 10)] = agent.Name
+			}
+		}
+	case UsageGroupByUser:
+		if users, err := model.GetUsersByIDs(eid, ids); err == nil {
+			for _, user := range users {
+				name := user.Nickname
+				if name == "" {
+					name = user.Username
+				}
+				names[strconv.FormatInt(user.UserID, 10)] = name
+			}
+		}
+	case UsageGroupByDepartment:
+		names["0"] = "未分配部门"
+		if departments, err := model.BatchGetDepartmentsByIDs(eid, ids); err == nil {
+			for _, department := range departments {
+				names[strconv.FormatInt(department.DID, 10)] = department.Name
+			}
+		}
+	case UsageGroupByUserGroup:
+		names["0"] = "未分组"
+		for _, id := range ids {
+			if group, err := model.GetGroupByID(id); err == nil && group.Eid == eid {
+				names[strconv.FormatInt(id, 10)] = group.GroupName
+			}
+		}
+	case UsageGroupByChannel:
+		for _, id := range ids {
+			if channel, err := model.GetChannelByID(id); err == nil && channel.Eid == eid {
+				names[strconv.FormatInt(id, 10)] = channel.Name
+			}
+		}
+	}
+
+	for _, stat := range stats {
+		stat.Name = names[stat.GroupKey]
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/model"
+)
+
+const (
+	// 回调请求超时时间
+	workflowCallbackTimeout = 10 * time.Second
+	// 回调失败后的最大重试次数
+	workflowCallbackRetries = 3
+
+	WorkflowCallbackEvent           = "workflow_run.completed"
+	WorkflowCallbackSignatureHeader = "X-53AIHub-Signature"
+	WorkflowCallbackTimestampHeader = "X-53AIHub-Timestamp"
+)
+
+// workflowCallbackClient 回调只允许投递到公网地址，连接时再次校验以防 DNS rebinding
+var workflowCallbackClient = utils.NewPublicHTTPClient(workflowCallbackTimeout)
+
+// WorkflowRunDetail 异步工作流运行详情，查询接口和完成回调使用相同结构
+type WorkflowRunDetail struct {
+	*model.WorkflowRun
+	Parameters map[string]interface{} `json:"parameters"`
+	Outputs    map[string]interface{} `json:"outputs"`
+}
+
+// WorkflowCallbackPayload 完成回调的请求体
+type WorkflowCallbackPayload struct {
+	Event     string             `json:"event"`
+	Timestamp int64              `json:"timestamp"`
+	Data      *WorkflowRunDetail `json:"data"`
+}
+
+// NewWorkflowRunDetail 解析运行记录中的参数和输出
+func NewWorkflowRunDetail(run *model.WorkflowRun) *WorkflowRunDetail {
+	detail := &WorkflowRunDetail{WorkflowRun: run}
+	if run.Parameters != "" {
+		_ = json.Unmarshal([]byte(run.Parameters), &detail.Parameters)
+	}
+	if run.Outputs != "" {
+		_ = json.Unmarshal([]byte(run.Outputs), &detail.Outputs)
+	}
+	return detail
+}
+
+// ValidateCallbackURL 校验回调地址，仅支持 http/https，且不能指向回环、内网或链路本地地址
+func ValidateCallbackURL(callbackURL string) error {
+	if err := utils.ValidatePublicURL(callbackURL); err != nil {
+		return fmt.Errorf("invalid callback_url: %w", err)
+	}
+	return nil
+}
+
+// SignWorkflowCallback 计算回调签名：hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignWorkflowCallback(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendWorkflowRunCallback 向回调地址投递运行结果，失败时按 1s、2s、4s 退避重试，并记录投递状态
+func SendWorkflowRunCallback(run *model.WorkflowRun) {
+	if run.CallbackURL == "" {
+		return
+	}
+
+	timestamp := time.Now().UTC().Unix()
+	body, err := json.Marshal(WorkflowCallbackPayload{
+		Event:     WorkflowCallbackEvent,
+		Timestamp: timestamp,
+		Data:      NewWorkflowRunDetail(run),
+	})
+	if err != nil {
+		logger.SysErrorf("序列化工作流回调失败 - RunID: %s, Error: %v", run.RunID, err)
+		return
+	}
+	signature := SignWorkflowCallback(run.CallbackSecret, timestamp, body)
+
+	status := model.WorkflowRunCallbackFailed
+	for attempt := 0; attempt <= workflowCallbackRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+		}
+		if err = postWorkflowCallback(run.CallbackURL, body, timestamp, signature); err == nil {
+			status = model.WorkflowRunCallbackSucceeded
+			break
+		}
+		logger.SysErrorf("工作流回调失败 - RunID: %s, 第%d次, Error: %v", run.RunID, attempt+1, err)
+	}
+
+	run.CallbackStatus = status
+	if err := model.UpdateWorkflowRunCallbackStatus(run.ID, status); err != nil {
+		logger.SysErrorf("更新工作流回调状态失败 - RunID: %s, Error: %v", run.RunID, err)
+	}
+}
+
+func postWorkflowCallback(callbackURL string, body []byte, timestamp int64, signature string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), workflowCallbackTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WorkflowCallbackTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WorkflowCallbackSignatureHeader, signature)
+
+	resp, err := workflowCallbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// UpdateWorkflowRunMessageError 将失败原因写入运行提交时预先创建的消息记录
+func UpdateWorkflowRunMessageError(run *model.WorkflowRun) {
+	message, err := model.GetMessageByID(run.Eid, run.MessageID)
+	if err != nil {
+		return
+	}
+	answer, _ := json.Marshal(map[string]string{"error": run.ErrorMessage})
+	message.Answer = string(answer)
+	if err := model.UpdateMessage(message); err != nil {
+		logger.SysErrorf("更新工作流消息失败 - RunID: %s, Error: %v", run.RunID, err)
+	}
+}
+
+// WorkflowRunQuotaHold 运行提交时预扣的配额
+func WorkflowRunQuotaHold(run *model.WorkflowRun) UserQuotaHold {
+	return UserQuotaHold{Quota: run.PreConsumedQuota, Period: run.QuotaPeriod}
+}
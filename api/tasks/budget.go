@@ -0,0 +1,34 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/service"
+)
+
+// StartBudgetAlertTask 定期按小时用量汇总检查预算，发送告警并暂停或恢复智能体、渠道
+func StartBudgetAlertTask(interval time.Duration) {
+	go func() {
+		runBudgetAlertTask()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runBudgetAlertTask()
+		}
+	}()
+	logger.SysLog("Budget alert task started with interval: " + interval.String())
+}
+
+func runBudgetAlertTask() {
+	count, err := service.EvaluateBudgets()
+	if err != nil {
+		logger.SysError("Failed to evaluate budgets: " + err.Error())
+		return
+	}
+	if count > 0 {
+		logger.SysLogf("Evaluated %d budgets", count)
+	}
+}
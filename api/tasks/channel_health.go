@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/env"
+	"github.com/53AI/53AIHub/model"
+)
+
+// ChannelProber probes a channel with a real request and returns the elapsed time.
+// It is registered by the controller package, which owns the relay adaptors.
+var ChannelProber func(ctx context.Context, channel *model.Channel) (time.Duration, error)
+
+// channelRecoverSuccessTimes is the number of consecutive successful probes
+// required before an auto-disabled channel is enabled again
+var channelRecoverSuccessTimes = int(env.Int64("CHANNEL_RECOVER_SUCCESS_TIMES", 2))
+
+// StartChannelHealthTask starts probing enabled and auto-disabled channels on a schedule.
+// Probe results feed the per-channel circuit breaker; auto-disabled channels are
+// re-enabled once they recover. An interval of 0 disables the task.
+func StartChannelHealthTask(interval time.Duration) {
+	if interval <= 0 {
+		logger.SysLog("Channel health task disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			probeChannels()
+		}
+	}()
+	logger.SysLog("Channel health task started with interval: " + interval.String())
+}
+
+// probeChannels probes every LLM channel that is enabled or auto-disabled
+func probeChannels() {
+	if ChannelProber == nil {
+		return
+	}
+
+	channels, err := model.GetChannelsByStatus([]int{model.ChannelStatusEnabled, model.ChannelStatusAutoDisabled})
+	if err != nil {
+		logger.SysError("Failed to get channels for health probing: " + err.Error())
+		return
+	}
+
+	recovered := 0
+	failed := 0
+	for i := range channels {
+		channel := &channels[i]
+		// only chat channels can be probed with a test completion
+		if channel.ModelType != model.ModelTypeLLM {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		elapsed, err := ChannelProber(ctx, channel)
+		cancel()
+
+		model.RecordChannelResult(channel.ChannelID, err == nil, elapsed)
+		if err != nil {
+			failed++
+			logger.SysErrorf("Channel probe failed: %d name: %s error: %v", channel.ChannelID, channel.Name, err)
+			continue
+		}
+		channel.UpdateResponseTime(elapsed.Milliseconds())
+
+		if channel.Status == model.ChannelStatusAutoDisabled &&
+			model.GetChannelHealthStats(channel.ChannelID).ConsecutiveSuccess >= channelRecoverSuccessTimes {
+			if err := model.UpdateChannelStatus(channel.ChannelID, model.ChannelStatusEnabled); err != nil {
+				logger.SysErrorf("Failed to re-enable channel: %d error: %v", channel.ChannelID, err)
+				continue
+			}
+			recovered++
+			logger.SysLogf("Channel recovered and re-enabled: %d name: %s", channel.ChannelID, channel.Name)
+		}
+	}
+
+	logger.SysLogf("Channel health probing completed. Channels: %d Failed: %d Recovered: %d", len(channels), failed, recovered)
+}
@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+)
+
+// exportCleanupBatchSize 每次清理的过期导出任务上限
+const exportCleanupBatchSize = 100
+
+// StartExportJobCleanupTask 定期清理导出任务：
+// 服务重启时内存中的导出队列会丢失，超时未结束的任务标记为失败；导出文件过期后删除文件和任务记录
+func StartExportJobCleanupTask(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			cleanupExportJobs()
+		}
+	}()
+	logger.SysLog("Export job cleanup task started with interval: " + interval.String())
+}
+
+func cleanupExportJobs() {
+	before := time.Now().Add(-time.Duration(config.EXPORT_JOB_TIMEOUT) * time.Second).UTC().UnixMilli()
+	count, err := model.FailStaleExportJobs(before)
+	if err != nil {
+		logger.SysError("Failed to cleanup stale export jobs: " + err.Error())
+	} else if count > 0 {
+		logger.SysLogf("Marked %d stale export jobs as failed", count)
+	}
+
+	jobs, err := model.GetExpiredExportJobs(time.Now().UTC().UnixMilli(), exportCleanupBatchSize)
+	if err != nil {
+		logger.SysError("Failed to get expired export jobs: " + err.Error())
+		return
+	}
+	for _, job := range jobs {
+		if err := service.DeleteExportFile(job); err != nil {
+			logger.SysErrorf("Failed to delete export file of job %d: %v", job.ID, err)
+			continue
+		}
+		if err := model.DeleteExportJob(job.Eid, job.ID); err != nil {
+			logger.SysErrorf("Failed to delete export job %d: %v", job.ID, err)
+		}
+	}
+	if len(jobs) > 0 {
+		logger.SysLogf("Deleted %d expired export jobs", len(jobs))
+	}
+}
@@ -2,9 +2,18 @@ package tasks
 
 import (
 	"time"
+
+	"github.com/53AI/53AIHub/config"
 )
 
 func Start() {
 	StartOrderExpirationTask(1 * time.Minute)
 	StartChannelUpdateKeyTask()
-}
\ No newline at end of file
+	StartChannelHealthTask(time.Duration(config.CHANNEL_PROBE_INTERVAL) * time.Second)
+	StartWorkflowRunCleanupTask(1 * time.Minute)
+	StartResponseCacheCleanupTask(1 * time.Hour)
+	StartUsageRollupTask(5 * time.Minute)
+	StartExportJobCleanupTask(10 * time.Minute)
+	StartPointsTask(10 * time.Minute)
+	StartBudgetAlertTask(5 * time.Minute)
+}
@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/service"
+)
+
+// pointsExpireBatchSize 每次处理的过期积分批次上限
+const pointsExpireBatchSize = 500
+
+// StartPointsTask 定期发放积分订阅本周期的积分，并清零已过期的积分
+func StartPointsTask(interval time.Duration) {
+	go func() {
+		runPointsTask()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runPointsTask()
+		}
+	}()
+	logger.SysLog("Points task started with interval: " + interval.String())
+}
+
+func runPointsTask() {
+	// 先清零过期的积分，再发放新周期的积分
+	for {
+		count, err := service.ExpirePoints(pointsExpireBatchSize)
+		if err != nil {
+			logger.SysError("Failed to expire points: " + err.Error())
+			break
+		}
+		if count > 0 {
+			logger.SysLogf("Expired %d points grants", count)
+		}
+		if count < pointsExpireBatchSize {
+			break
+		}
+	}
+
+	count, err := service.GrantAllSubscriptionPoints()
+	if err != nil {
+		logger.SysError("Failed to grant subscription points: " + err.Error())
+		return
+	}
+	if count > 0 {
+		logger.SysLogf("Checked subscription points of %d users", count)
+	}
+}
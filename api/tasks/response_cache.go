@@ -0,0 +1,28 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+// StartResponseCacheCleanupTask 定期清理数据库中已过期的回答缓存（使用 Redis 时由 TTL 自动过期）
+func StartResponseCacheCleanupTask(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleted, err := model.DeleteExpiredResponseCache()
+			if err != nil {
+				logger.SysError("Failed to cleanup expired response cache: " + err.Error())
+				continue
+			}
+			if deleted > 0 {
+				logger.SysLogf("Deleted %d expired response cache entries", deleted)
+			}
+		}
+	}()
+	logger.SysLog("Response cache cleanup task started with interval: " + interval.String())
+}
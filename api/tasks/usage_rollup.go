@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+// usageRollupLookback 每次汇总时向前重算的时长，覆盖创建后才写入用量的消息
+const usageRollupLookback = time.Hour
+
+// StartUsageRollupTask 定期将消息用量汇总到小时汇总表，启动时补齐上次停止后缺失的时段
+func StartUsageRollupTask(interval time.Duration) {
+	go func() {
+		rollupUsage()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			rollupUsage()
+		}
+	}()
+	logger.SysLog("Usage rollup task started with interval: " + interval.String())
+}
+
+func rollupUsage() {
+	start, err := model.GetLatestUsageRollupTime()
+	if err != nil {
+		logger.SysError("Failed to get latest usage rollup time: " + err.Error())
+		return
+	}
+	if start == 0 {
+		if start, err = model.GetEarliestMessageTime(); err != nil {
+			logger.SysError("Failed to get earliest message time: " + err.Error())
+			return
+		}
+		if start == 0 {
+			return
+		}
+	} else {
+		start -= usageRollupLookback.Milliseconds()
+	}
+
+	end := time.Now().UTC().UnixMilli()
+	if err := model.RollupMessageUsage(start, end); err != nil {
+		logger.SysError("Failed to rollup message usage: " + err.Error())
+	}
+}
@@ -0,0 +1,47 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+)
+
+// workflowRunGracePeriod 超过执行超时时间后再等待的时长，给排队中和即将结束的运行留出余量
+const workflowRunGracePeriod = 5 * time.Minute
+
+// StartWorkflowRunCleanupTask 定期将超时未结束的异步工作流运行标记为失败
+// 服务重启时内存中的执行队列会丢失，对应的运行由该任务收尾：退还预扣配额并发送回调
+func StartWorkflowRunCleanupTask(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			cleanupStaleWorkflowRuns()
+		}
+	}()
+	logger.SysLog("Workflow run cleanup task started with interval: " + interval.String())
+}
+
+func cleanupStaleWorkflowRuns() {
+	timeout := time.Duration(config.WORKFLOW_RUN_TIMEOUT)*time.Second + workflowRunGracePeriod
+	before := time.Now().Add(-timeout).UTC().UnixMilli()
+
+	runs, err := model.FailStaleWorkflowRuns(before)
+	if err != nil {
+		logger.SysError("Failed to cleanup stale workflow runs: " + err.Error())
+		return
+	}
+
+	for _, run := range runs {
+		service.ReturnUserQuota(run.Eid, run.UserID, service.WorkflowRunQuotaHold(run))
+		service.UpdateWorkflowRunMessageError(run)
+		go service.SendWorkflowRunCallback(run)
+	}
+	if len(runs) > 0 {
+		logger.SysLogf("Marked %d stale workflow runs as failed", len(runs))
+	}
+}